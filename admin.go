@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const backupBatchSize = 500
+
+// backupRecord is one line of a backup: a row of the table its type names,
+// keyed by column.
+type backupRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// backupTables are the tables a backup holds and the record type of their
+// rows, ordered so that rows come after the rows they reference. Users,
+// API keys, and idempotency keys aren't included: a restore keeps the
+// accounts, like the tenants, rather than replacing them.
+var backupTables = []struct {
+	kind  string
+	model any
+}{
+	{"tenant", &Tenant{}},
+	{"category", &Category{}},
+	{"supplier", &Supplier{}},
+	{"product", &Product{}},
+	{"product_image", &model.ProductImage{}},
+	{"product_translation", &model.ProductTranslation{}},
+	{"price_change", &model.PriceChange{}},
+	{"stock_movement", &model.StockMovement{}},
+	{"order", &model.Order{}},
+	{"order_item", &model.OrderItem{}},
+	{"audit_event", &model.AuditEvent{}},
+	{"webhook", &model.Webhook{}},
+	{"webhook_delivery", &model.WebhookDelivery{}},
+	{"job", &Job{}},
+}
+
+// backupSchema parses the model of a backed up table.
+func backupSchema(db *gorm.DB, v any) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(v); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// backupColumns is a row's record data. Rows are keyed by column rather
+// than written as the API shows them, so fields the API hides, like an
+// image's storage key, are kept.
+func backupColumns(ctx context.Context, s *schema.Schema, row reflect.Value) map[string]any {
+	cols := make(map[string]any, len(s.DBNames))
+	for _, name := range s.DBNames {
+		cols[name] = s.FieldsByDBName[name].ReflectValueOf(ctx, row).Interface()
+	}
+	return cols
+}
+
+// restoreColumns decodes a record's data into the column values to insert,
+// typed as the table's model has them. Keys that aren't columns are
+// ignored.
+func restoreColumns(ctx context.Context, s *schema.Schema, data json.RawMessage) (map[string]any, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	row := reflect.New(s.ModelType).Elem()
+	cols := make(map[string]any, len(raw))
+	for name, value := range raw {
+		field := s.FieldsByDBName[name]
+		if field == nil {
+			continue
+		}
+		v := field.ReflectValueOf(ctx, row)
+		if err := json.Unmarshal(value, v.Addr().Interface()); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		cols[name] = v.Interface()
+		if field.Serializer != nil {
+			// Inserted without the model, so stored as the serializer would
+			b, err := json.Marshal(cols[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			cols[name] = string(b)
+		}
+	}
+	// Data from before tenants belongs to the default tenant
+	if _, ok := s.FieldsByDBName["tenant_id"]; ok {
+		if id, _ := cols["tenant_id"].(uint); id == 0 {
+			cols["tenant_id"] = model.DefaultTenantID
+		}
+	}
+	return cols, nil
+}
+
+// Stream every table as NDJSON, for every tenant
+func backup(w http.ResponseWriter, r *http.Request) {
+	db := dbFor(r).WithContext(repository.AllTenants(r.Context()))
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="golangdb-backup.ndjson"`)
+	// A full dump can outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	enc := newJSONEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	write := func(kind string, v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(backupRecord{Type: kind, Data: data})
+	}
+	// Headers are sent with the first row, so a failure midway can only
+	// truncate the stream; restore rejects a dump without its end marker.
+	dump := func(tx *gorm.DB) error {
+		for _, table := range backupTables {
+			s, err := backupSchema(tx, table.model)
+			if err != nil {
+				return err
+			}
+			rows := reflect.New(reflect.SliceOf(s.ModelType))
+			err = tx.Unscoped().Order(s.PrioritizedPrimaryField.DBName).FindInBatches(rows.Interface(), backupBatchSize, func(*gorm.DB, int) error {
+				for i := range rows.Elem().Len() {
+					if err := write(table.kind, backupColumns(r.Context(), s, rows.Elem().Index(i))); err != nil {
+						return err
+					}
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+				return nil
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return write("end", struct{}{})
+	}
+	// Every table is read in one transaction, so a row written midway
+	// can't leave the dump with a reference to a row it doesn't hold.
+	var opts *sql.TxOptions
+	if db.Dialector.Name() != "sqlite" {
+		opts = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	db.Transaction(dump, opts)
+}
+
+// invalidBackup reports a problem with the uploaded dump itself.
+func invalidBackup(format string, args ...any) error {
+	return newAPIError("invalid_backup", fmt.Sprintf(format, args...))
+}
+
+// Replace all data with the contents of a backup. Tenants are kept, and
+// added or renamed as the backup says; data from before tenants goes to
+// the default tenant.
+func restore(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("confirm") != "true" {
+		writeError(w, r, http.StatusBadRequest, "restore_unconfirmed")
+		return
+	}
+
+	counts := map[string]int{}
+	err := dbFor(r).WithContext(repository.AllTenants(r.Context())).Transaction(func(tx *gorm.DB) error {
+		schemas := make(map[string]*schema.Schema, len(backupTables))
+		for _, table := range backupTables {
+			s, err := backupSchema(tx, table.model)
+			if err != nil {
+				return err
+			}
+			schemas[table.kind] = s
+		}
+		// Everything but the tenants, referencing rows first
+		all := tx.Unscoped().Session(&gorm.Session{AllowGlobalUpdate: true})
+		for i := len(backupTables) - 1; i > 0; i-- {
+			if err := all.Delete(backupTables[i].model).Error; err != nil {
+				return err
+			}
+		}
+
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		// Rows are inserted by table name rather than through their model,
+		// so the callbacks that would record price changes or queue
+		// webhook deliveries for them don't run again.
+		var (
+			pending []map[string]any
+			table   string
+		)
+		flush := func() error {
+			if len(pending) == 0 {
+				return nil
+			}
+			err := tx.Table(table).CreateInBatches(pending, backupBatchSize).Error
+			pending = nil
+			return err
+		}
+		ended := false
+		for line := 1; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			var rec backupRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				return invalidBackup("line %d: %v", line, err)
+			}
+			if ended {
+				return invalidBackup("line %d: data after end marker", line)
+			}
+			if rec.Type == "end" {
+				ended = true
+				continue
+			}
+			s, ok := schemas[rec.Type]
+			if !ok {
+				return invalidBackup("line %d: unknown record type %q", line, rec.Type)
+			}
+			cols, err := restoreColumns(r.Context(), s, rec.Data)
+			if err != nil {
+				return invalidBackup("line %d: %v", line, err)
+			}
+			counts[s.Table]++
+			if rec.Type == "tenant" {
+				var names []string
+				for name := range cols {
+					names = append(names, name)
+				}
+				update := clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoUpdates: clause.AssignmentColumns(names)}
+				if err := tx.Table(s.Table).Clauses(update).Create(cols).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if s.Table != table || len(pending) == backupBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+				table = s.Table
+			}
+			pending = append(pending, cols)
+		}
+		if err := scanner.Err(); err != nil {
+			return invalidBackup("%v", err)
+		}
+		if !ended {
+			return invalidBackup("missing end marker, the backup is truncated")
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		var sequenced []string
+		for _, s := range schemas {
+			if s.PrioritizedPrimaryField.AutoIncrement {
+				sequenced = append(sequenced, s.Table)
+			}
+		}
+		return resetSequences(tx, sequenced...)
+	})
+	switch {
+	case errors.As(err, new(*apiError)):
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	invalidateProducts(r.Context(), depsFor(r))
+	writeJSON(w, r, http.StatusOK, counts)
+}
+
+// resetSequences moves Postgres id sequences past the restored ids so new
+// rows don't collide with them. Other databases track this themselves.
+func resetSequences(tx *gorm.DB, tables ...string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, table := range tables {
+		err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false)", table)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	c, db := newTestAPI(t)
+
+	c.Post("/api/v1/tenants", map[string]any{"name": "Acme"}).Expect(201)
+	acme := c.WithHeader("X-Tenant-ID", "2")
+	acme.Post("/api/v1/webhooks", map[string]any{"url": "https://203.0.113.10/hook", "events": []string{"product.created"}}).Expect(201)
+	var category Category
+	acme.Post("/api/v1/categories", map[string]any{"name": "Tools"}).Expect(201).Decode(&category)
+	var product Product
+	acme.Post("/api/v1/products", map[string]any{"name": "Hammer", "price": "9.99", "category_id": category.ID}).Expect(201).Decode(&product)
+	path := fmt.Sprintf("/api/v1/products/%d", product.ID)
+	acme.Patch(path, map[string]any{"price": "12.50"}).Expect(200)
+	acme.Post(path+"/adjust", map[string]any{"delta": 5, "reason": "restock"}).Expect(200)
+	acme.Put(path+"/translations/fr", map[string]any{"name": "Marteau"}).Expect(201)
+	c.Post("/api/v1/products", map[string]any{"name": "Saw", "price": "5"}).Expect(201)
+	// Fields the API hides are kept too
+	image := model.ProductImage{ProductID: product.ID, Key: "products/hammer.png", ContentType: "image/png"}
+	if err := db.WithContext(repository.WithTenant(context.Background(), 2)).Create(&image).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&Job{ID: "job1", TenantID: 2, Kind: "export", Status: "done", Result: map[string]any{"rows": 2}, File: "exports/job1.csv"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	dump := c.Get("/api/v1/admin/backup").Expect(200).Body.String()
+
+	acme.Delete(path).Expect(204)
+	acme.Post("/api/v1/products", map[string]any{"name": "Drill", "price": "80"}).Expect(201)
+	var restored map[string]int
+	c.WithHeader("Content-Type", "application/x-ndjson").Post("/api/v1/admin/restore?confirm=true", dump).Expect(200).Decode(&restored)
+	for _, table := range []string{"tenants", "categories", "products", "product_translations", "price_history", "stock_movements", "audit_events", "webhooks", "webhook_deliveries", "product_images", "jobs"} {
+		if restored[table] == 0 {
+			t.Errorf("restored no %s: %v", table, restored)
+		}
+	}
+
+	if again := c.Get("/api/v1/admin/backup").Expect(200).Body.String(); again != dump {
+		t.Errorf("backup after restore differs:\n%s\nwant:\n%s", again, dump)
+	}
+	var translation model.ProductTranslation
+	if err := db.WithContext(repository.WithTenant(context.Background(), 2)).Where("product_id = ?", product.ID).Take(&translation).Error; err != nil || translation.Name != "Marteau" {
+		t.Errorf("translation = %+v, %v; want Marteau", translation, err)
+	}
+	if err := db.WithContext(repository.WithTenant(context.Background(), 2)).Take(&image).Error; err != nil || image.Key != "products/hammer.png" {
+		t.Errorf("image = %+v, %v; want its key kept", image, err)
+	}
+	acme.Get(path).Expect(200)
+}
+
+func TestRestoreRejectsTruncatedBackup(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	c.Post("/api/v1/products", map[string]any{"name": "Saw", "price": "5"}).Expect(201)
+	dump := c.Get("/api/v1/admin/backup").Expect(200).Body.String()
+	c.Post("/api/v1/products", map[string]any{"name": "Drill", "price": "80"}).Expect(201)
+
+	c.WithHeader("Content-Type", "application/x-ndjson").Post("/api/v1/admin/restore?confirm=true", dump[:len(dump)-len(`{"type":"end","data":{}}`)-1]).Expect(400)
+	var products struct{ Data []Product }
+	c.Get("/api/v1/products").Expect(200).Decode(&products)
+	if len(products.Data) != 2 {
+		t.Errorf("after a rejected restore got %d products, want 2", len(products.Data))
+	}
+}
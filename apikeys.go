@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/model"
+	"gorm.io/gorm"
+)
+
+// APIKey is the API key model. Handlers use it unqualified.
+type APIKey = model.APIKey
+
+const (
+	// maxAPIKeyName bounds the name of an API key, as its column does.
+	maxAPIKeyName = 100
+	// apiKeyPrefixLength is how much of a key is kept to tell it apart.
+	apiKeyPrefixLength = 12
+	// apiKeyTouchInterval is how often a key's last_used_at is brought up
+	// to date, rather than on every request.
+	apiKeyTouchInterval = time.Minute
+)
+
+// apiKeyRequest is the body of POST /api-keys. The key never expires if
+// ExpiresAt is nil.
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// validate returns the problem with req, if any.
+func (req *apiKeyRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxAPIKeyName {
+		return newAPIError("invalid_api_key_name", maxAPIKeyName)
+	}
+	if len(req.Scopes) == 0 {
+		return newAPIError("invalid_api_key_scopes", strings.Join(model.APIKeyScopes, ", "))
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(model.APIKeyScopes, scope) {
+			return newAPIError("invalid_api_key_scopes", strings.Join(model.APIKeyScopes, ", "))
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return newAPIError("invalid_api_key_expiry")
+	}
+	return nil
+}
+
+// authenticateAPIKey returns the claims of a request made with key: those
+// of an admin of the key's tenant. It answers 401 if the key is unknown,
+// revoked or expired, and 403 if its scopes don't allow the request.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, key string) (*auth.Claims, bool) {
+	conn := depsFor(r).db.WithContext(r.Context())
+	var apiKey APIKey
+	err := conn.Where("hash = ?", auth.HashAPIKey(key)).First(&apiKey).Error
+	now := time.Now()
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !apiKey.Active(now)) {
+		w.Header().Set("WWW-Authenticate", `ApiKey error="invalid_key"`)
+		writeError(w, r, http.StatusUnauthorized, "invalid_api_key")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return nil, false
+	}
+	if !apiKey.Allows(r.Method) {
+		w.Header().Set("WWW-Authenticate", `ApiKey error="insufficient_scope"`)
+		writeError(w, r, http.StatusForbidden, "insufficient_scope", strings.Join(apiKey.Scopes, ", "))
+		return nil, false
+	}
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		// Only for information, so not worth failing the request over
+		if err := conn.Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+			slog.WarnContext(r.Context(), "failed to record API key use", "api_key_id", apiKey.ID, "error", err)
+		}
+	}
+	var tenant uint
+	if apiKey.TenantID != nil {
+		tenant = *apiKey.TenantID
+	}
+	return auth.APIKeyClaims(apiKey.ID, model.RoleAdmin, tenant), true
+}
+
+// requireAdminUser guards the API key routes: only admins signed in as
+// users may manage keys, so that a key can't be used to make more.
+func requireAdminUser(next http.HandlerFunc) http.HandlerFunc {
+	return requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if claimsFor(r).Type == auth.TypeAPIKey {
+			writeError(w, r, http.StatusForbidden, "api_key_forbidden")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// apiKeysOf returns conn confined to the API keys of the caller's tenant,
+// or those of the whole service for a caller without one.
+func apiKeysOf(r *http.Request, conn *gorm.DB) *gorm.DB {
+	if tenant := claimsFor(r).TenantID; tenant != 0 {
+		return conn.Where("tenant_id = ?", tenant)
+	}
+	return conn.Where("tenant_id IS NULL")
+}
+
+// Create an API key, which is only shown in the response
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	key, hash, err := auth.NewAPIKey()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	slices.Sort(req.Scopes)
+	apiKey := APIKey{
+		Name:      req.Name,
+		Prefix:    key[:apiKeyPrefixLength],
+		Hash:      hash,
+		Scopes:    slices.Compact(req.Scopes),
+		CreatedBy: actorFor(r.Context()),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if tenant := claimsFor(r).TenantID; tenant != 0 {
+		apiKey.TenantID = &tenant
+	}
+	if err := dbFor(r).Create(&apiKey).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	apiKey.Key = key
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusCreated, apiKey)
+}
+
+// Get all API keys, revoked ones included
+func getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	var keys []APIKey
+	if err := apiKeysOf(r, readDBFor(r)).Order("id").Find(&keys).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if keys == nil {
+		keys = []APIKey{}
+	}
+	writeJSON(w, r, http.StatusOK, keys)
+}
+
+// Revoke an API key. It is kept, so that the audit log's references to it
+// can still be looked up.
+func revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "api_key_not_found")
+		return
+	}
+	var apiKey APIKey
+	if err := apiKeysOf(r, dbFor(r)).First(&apiKey, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "api_key_not_found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if apiKey.RevokedAt == nil {
+		if err := dbFor(r).Model(&apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
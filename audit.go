@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// actorFor returns who is making the request of ctx, as recorded in the
+// audit log: "user:<id>", "api_key:<id>", or "" if anonymous.
+func actorFor(ctx context.Context) string {
+	claims, _ := ctx.Value(claimsKey{}).(*auth.Claims)
+	if claims == nil {
+		return ""
+	}
+	return claims.Actor()
+}
+
+// recordUpdates records an update event by actor for each of products as
+// changed by change, for bulk writes that bypass the product service.
+// products must be loaded, and locked, in the write's transaction tx
+// before the write.
+func recordUpdates(tx *gorm.DB, actor string, products []Product, change func(p *Product)) error {
+	events := make([]*model.AuditEvent, len(products))
+	for i := range products {
+		before := products[i]
+		change(&products[i])
+		products[i].Version++
+		events[i] = service.NewAuditEvent(model.AuditUpdate, actor, &before, &products[i])
+	}
+	return repository.NewProductRepository(tx).Record(events)
+}
+
+// lockForUpdate loads the products q selects for recordUpdates.
+func lockForUpdate(q *gorm.DB) ([]Product, error) {
+	var products []Product
+	err := q.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&products).Error
+	return products, err
+}
+
+// auditSchema describes how a product's history can be listed.
+var auditSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":     {Column: "id", Kind: query.Uint, Sortable: true},
+		"action": {Column: "action", Kind: query.String, Ops: []query.Op{query.Eq}},
+		"actor":  {Column: "actor", Kind: query.String, Ops: []query.Op{query.Eq}},
+	},
+	Key:          "id",
+	DefaultLimit: defaultPageLimit,
+	MaxLimit:     maxPageLimit,
+}
+
+// auditList is the envelope of a product's history, paged like
+// productList.
+type auditList struct {
+	Data       []model.AuditEvent `json:"data"`
+	Meta       query.Meta         `json:"meta"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// Get the audit history of a product, deleted or not
+func getProductHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	params, err := auditSchema.Parse(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// A new session, as the history takes several queries
+	repo := repository.NewProductRepository(readDBFor(r).Unscoped().Session(&gorm.Session{}))
+	events, total, err := service.NewProductService(repo).WithContext(r.Context()).History(id, params)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	events, next, err := query.Next(params, events)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if events == nil {
+		events = []model.AuditEvent{}
+	}
+	writeJSON(w, r, http.StatusOK, auditList{Data: events, Meta: params.Meta(total), NextCursor: next})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+)
+
+// tokens issues and verifies JWTs. It is set up from the configuration in
+// main.
+var tokens *auth.Issuer
+
+// claimsKey is the context key for the authenticated caller's claims.
+type claimsKey struct{}
+
+// claimsFor returns the claims of r's access token, or nil for an
+// anonymous request.
+func claimsFor(r *http.Request) *auth.Claims {
+	claims, _ := r.Context().Value(claimsKey{}).(*auth.Claims)
+	return claims
+}
+
+// authenticate verifies the bearer access token or the API key, if any,
+// and makes its claims available to handlers. Requests without either pass
+// through anonymously; routes that need a user are wrapped in requireRole.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if token := r.URL.Query().Get("access_token"); header == "" && token != "" && websocket.IsWebSocketUpgrade(r) {
+			// Browsers can't set headers on a WebSocket handshake
+			header = "Bearer " + token
+		}
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if key, ok := strings.CutPrefix(header, "ApiKey "); ok {
+			claims, ok := authenticateAPIKey(w, r, key)
+			if ok {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+			}
+			return
+		}
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		claims, err := tokens.VerifyAccess(token)
+		if !ok || err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, r, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	})
+}
+
+// requireRole returns a wrapper that lets only users with one of roles call
+// a handler.
+func requireRole(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims := claimsFor(r)
+			if claims == nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			if !slices.Contains(roles, claims.Role) {
+				writeError(w, r, http.StatusForbidden, "forbidden")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// requireAdmin guards admin-only routes.
+var requireAdmin = requireRole(model.RoleAdmin)
+
+// requireUser guards routes any signed-in user may call.
+var requireUser = requireRole(model.RoleAdmin, model.RoleViewer)
+
+// credentials is the body of /auth/register and /auth/login.
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func userService(r *http.Request) *service.UserService {
+	return service.NewUserService(repository.NewUserRepository(dbFor(r)))
+}
+
+// Create a user account
+func register(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := decodeJSON(r, &creds); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// Accounts belong to the tenant the request names, if any
+	var tenant *uint
+	if namedTenant(r) != "" {
+		id := tenantFor(r)
+		tenant = &id
+	}
+	user, err := userService(r).Register(creds.Email, creds.Password, tenant)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, user)
+}
+
+// Exchange an email and password for tokens
+func login(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := decodeJSON(r, &creds); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	user, err := userService(r).Authenticate(creds.Email, creds.Password)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	issueTokens(w, r, user)
+}
+
+// Exchange a refresh token for new tokens
+func refreshTokens(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	claims, err := tokens.VerifyRefresh(req.RefreshToken)
+	if err != nil {
+		writeError(w, r, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+	// Reload the user so a changed role or deleted account takes effect
+	user, err := userService(r).Get(claims.UserID())
+	if errors.Is(err, repository.ErrNotFound) {
+		writeError(w, r, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	issueTokens(w, r, user)
+}
+
+func issueTokens(w http.ResponseWriter, r *http.Request, user *model.User) {
+	var tenant uint
+	if user.TenantID != nil {
+		tenant = *user.TenantID
+	}
+	pair, err := tokens.Issue(user.ID, user.Role, tenant)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, pair)
+}
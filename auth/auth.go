@@ -0,0 +1,188 @@
+// Package auth hashes passwords and issues and verifies the JWTs that
+// authenticate API clients.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token lifetimes. An access token authenticates requests; a refresh token
+// can only be exchanged for a new pair.
+const (
+	AccessTTL  = 15 * time.Minute
+	RefreshTTL = 7 * 24 * time.Hour
+)
+
+// Token types, stored in the "typ" claim so a refresh token can't be used
+// as an access token or the other way round.
+const (
+	typeAccess  = "access"
+	typeRefresh = "refresh"
+)
+
+// TypeAPIKey is the type of the claims of a request made with an API key
+// rather than a token. Their subject is the key's ID.
+const TypeAPIKey = "api_key"
+
+// apiKeyPrefix starts every API key, so that a leaked one is easy to
+// recognise.
+const apiKeyPrefix = "gdb_"
+
+// ErrInvalidToken is returned for a token that is malformed, badly signed,
+// expired, or of the wrong type.
+var ErrInvalidToken = errors.New("invalid token")
+
+// HashPassword returns the bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword reports whether password matches hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Claims are the JWT claims the service issues. The user ID is the
+// subject. TenantID is the tenant the user belongs to, or 0 for a user of
+// the whole service.
+type Claims struct {
+	Role     string `json:"role"`
+	Type     string `json:"typ"`
+	TenantID uint   `json:"tid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the user the claims were issued for.
+func (c *Claims) UserID() uint {
+	id, _ := strconv.ParseUint(c.Subject, 10, 0)
+	return uint(id)
+}
+
+// Actor names who the claims were issued for: "user:<id>", or
+// "api_key:<id>" for an API key.
+func (c *Claims) Actor() string {
+	if c.Type == TypeAPIKey {
+		return "api_key:" + c.Subject
+	}
+	return "user:" + c.Subject
+}
+
+// APIKeyClaims returns the claims a request made with the API key id acts
+// under, with role in tenantID or, if it is 0, the whole service.
+func APIKeyClaims(id uint, role string, tenantID uint) *Claims {
+	return &Claims{
+		Role:             role,
+		Type:             TypeAPIKey,
+		TenantID:         tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: strconv.FormatUint(uint64(id), 10)},
+	}
+}
+
+// NewAPIKey returns a new random API key and its hash.
+func NewAPIKey() (key, hash string, err error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(secret)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. Keys
+// are random and long, so a plain SHA-256 is as good as a slow, salted
+// password hash, and can be looked up.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tokens is an access and refresh token pair.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Issuer signs and verifies tokens with an HMAC key.
+type Issuer struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewIssuer returns an Issuer that signs with secret. An empty secret uses
+// a random key, which invalidates every token on restart.
+func NewIssuer(secret string) (*Issuer, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Issuer{key: key, now: time.Now}, nil
+}
+
+// Issue returns a new token pair for the user, of tenantID or, if it is 0,
+// of the whole service.
+func (i *Issuer) Issue(userID uint, role string, tenantID uint) (Tokens, error) {
+	access, err := i.sign(userID, role, tenantID, typeAccess, AccessTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+	refresh, err := i.sign(userID, role, tenantID, typeRefresh, RefreshTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTTL.Seconds()),
+	}, nil
+}
+
+func (i *Issuer) sign(userID uint, role string, tenantID uint, typ string, ttl time.Duration) (string, error) {
+	now := i.now()
+	claims := Claims{
+		Role:     role,
+		Type:     typ,
+		TenantID: tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.key)
+}
+
+// VerifyAccess returns the claims of a valid access token.
+func (i *Issuer) VerifyAccess(token string) (*Claims, error) {
+	return i.verify(token, typeAccess)
+}
+
+// VerifyRefresh returns the claims of a valid refresh token.
+func (i *Issuer) VerifyRefresh(token string) (*Claims, error) {
+	return i.verify(token, typeRefresh)
+}
+
+func (i *Issuer) verify(token, typ string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return i.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(i.now), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != typ || claims.UserID() == 0 {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const maxBatchOperations = 100
+
+type txKey struct{}
+
+// dbFor returns the transaction a request runs in when it is part of a
+// batch, and the shared connection otherwise, bound to r's context so
+// statements are logged with its request ID. Handlers must use it instead
+// of depsFor(r).db so they can take part in a batch.
+func dbFor(r *http.Request) *gorm.DB {
+	if tx, ok := r.Context().Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return depsFor(r).db.WithContext(r.Context())
+}
+
+// batchOperation is one sub-request of a batch. Path and body may refer to
+// the response of an earlier operation as ${N.field}, e.g. ${0.id}.
+type batchOperation struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type batchResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type batchResult struct {
+	Committed bool            `json:"committed"`
+	Responses []batchResponse `json:"responses"`
+}
+
+var (
+	batchRouter = func() *mux.Router {
+		router := mux.NewRouter()
+		registerBatchRoutes(router)
+		return router
+	}()
+
+	// A reference that is a whole JSON string is replaced by the raw value,
+	// so "${0.id}" becomes a number; anywhere else it is spliced in as text.
+	batchQuotedRef = regexp.MustCompile(`"\$\{(\d+)\.(\w+)\}"`)
+	batchRef       = regexp.MustCompile(`\$\{(\d+)\.(\w+)\}`)
+)
+
+// registerBatchRoutes registers the operations a batch may run: reads and
+// writes of products and categories, whose every effect is in the
+// database and so undone with the batch's transaction. Anything else, such
+// as an import, which may enqueue a job, or an image upload, which stores
+// a file, isn't found.
+func registerBatchRoutes(router *mux.Router) {
+	router.HandleFunc("/products", expandable(adminForDeleted(getProducts))).Methods("GET")
+	router.HandleFunc("/products/bulk", requireAdmin(dryRunnable(bulkCreateProducts))).Methods("POST")
+	router.HandleFunc("/products/bulk", requireAdmin(dryRunnable(bulkDeleteProducts))).Methods("DELETE")
+	router.HandleFunc("/products/assign-category", requireAdmin(assignCategory)).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}", expandable(adminForDeleted(getProduct))).Methods("GET")
+	router.HandleFunc("/products/sku/{sku}", expandable(adminForDeleted(getProductBySKU))).Methods("GET")
+	router.HandleFunc("/products", requireAdmin(dryRunnable(createProduct))).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}", requireAdmin(dryRunnable(updateProduct))).Methods("PUT")
+	acceptContentTypes(router.HandleFunc("/products/{id:[0-9]+}", requireAdmin(dryRunnable(patchProduct))).Methods("PATCH"),
+		"application/json", "application/merge-patch+json")
+	router.HandleFunc("/products/{id:[0-9]+}", requireAdmin(dryRunnable(deleteProduct))).Methods("DELETE")
+	router.HandleFunc("/products/{id:[0-9]+}/restore", requireAdmin(dryRunnable(restoreProduct))).Methods("POST")
+	router.HandleFunc("/categories", getCategories).Methods("GET")
+	router.HandleFunc("/categories", requireAdmin(dryRunnable(createCategory))).Methods("POST")
+	router.HandleFunc("/categories/{id:[0-9]+}", getCategory).Methods("GET")
+	router.HandleFunc("/categories/{id:[0-9]+}", requireAdmin(dryRunnable(updateCategory))).Methods("PUT")
+	router.HandleFunc("/categories/{id:[0-9]+}", requireAdmin(dryRunnable(deleteCategory))).Methods("DELETE")
+}
+
+// errBatchFailed aborts the batch transaction after a failed operation.
+var errBatchFailed = errors.New("batch operation failed")
+
+// Run several product/category operations in one transaction
+func batch(w http.ResponseWriter, r *http.Request) {
+	var ops []batchOperation
+	if err := decodeJSON(r, &ops); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if len(ops) == 0 {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	if len(ops) > maxBatchOperations {
+		writeError(w, r, http.StatusBadRequest, "too_many_operations", maxBatchOperations)
+		return
+	}
+
+	var result batchResult
+	failedStatus := 0
+	err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+		ctx := context.WithValue(r.Context(), txKey{}, tx)
+		for i, op := range ops {
+			resp, err := runBatchOperation(ctx, r.Header.Get("Accept-Language"), i, op, result.Responses)
+			if err != nil {
+				resp = batchResponse{Status: http.StatusBadRequest, Body: errorBody(r, http.StatusBadRequest, newAPIError("invalid_operation", i, err.Error()))}
+			}
+			result.Responses = append(result.Responses, resp)
+			if resp.Status >= 400 {
+				failedStatus = resp.Status
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errBatchFailed):
+		writeJSON(w, r, failedStatus, result)
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	result.Committed = !dryRun(r)
+	if result.Committed {
+		invalidateProducts(r.Context(), depsFor(r))
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// runBatchOperation dispatches op, the index'th operation, through the batch
+// router inside ctx's transaction, after resolving references to earlier
+// responses.
+func runBatchOperation(ctx context.Context, acceptLanguage string, index int, op batchOperation, prev []batchResponse) (batchResponse, error) {
+	path, err := resolveBatchRefs(op.Path, prev, false)
+	if err != nil {
+		return batchResponse{}, err
+	}
+	body, err := resolveBatchRefs(string(op.Body), prev, true)
+	if err != nil {
+		return batchResponse{}, err
+	}
+	if !strings.HasPrefix(path, "/") {
+		return batchResponse{}, errors.New("path must start with /")
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(op.Method), path, strings.NewReader(body))
+	if err != nil {
+		return batchResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", acceptLanguage)
+
+	rec := &batchRecorder{header: http.Header{}, status: http.StatusOK}
+	batchRouter.ServeHTTP(rec, req)
+	body = strings.TrimSpace(rec.body.String())
+	if body != "" && !json.Valid([]byte(body)) {
+		// Router-level errors such as 404/405 are plain text
+		return batchResponse{Status: rec.status, Body: errorBody(req, rec.status, newAPIError("invalid_operation", index, body))}, nil
+	}
+	return batchResponse{Status: rec.status, Body: json.RawMessage(body)}, nil
+}
+
+// resolveBatchRefs substitutes ${N.field} with the field of response N.
+func resolveBatchRefs(s string, prev []batchResponse, isJSON bool) (string, error) {
+	var resolveErr error
+	lookup := func(match string, re *regexp.Regexp) json.RawMessage {
+		m := re.FindStringSubmatch(match)
+		n, _ := strconv.Atoi(m[1])
+		if n >= len(prev) {
+			resolveErr = fmt.Errorf("reference to operation %d which has not run", n)
+			return nil
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(prev[n].Body, &fields); err != nil || fields[m[2]] == nil {
+			resolveErr = fmt.Errorf("operation %d has no field %q", n, m[2])
+			return nil
+		}
+		return fields[m[2]]
+	}
+	if isJSON {
+		s = batchQuotedRef.ReplaceAllStringFunc(s, func(match string) string {
+			return string(lookup(match, batchQuotedRef))
+		})
+	}
+	s = batchRef.ReplaceAllStringFunc(s, func(match string) string {
+		v := lookup(match, batchRef)
+		var str string
+		if json.Unmarshal(v, &str) == nil {
+			return str
+		}
+		return string(v)
+	})
+	return s, resolveErr
+}
+
+// errorBody renders err the way writeAPIError would.
+func errorBody(r *http.Request, status int, err *apiError) json.RawMessage {
+	b, _ := json.Marshal(err.problem(r, status))
+	return b
+}
+
+// batchRecorder captures a sub-response in memory.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *batchRecorder) Header() http.Header         { return rec.header }
+func (rec *batchRecorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+func (rec *batchRecorder) WriteHeader(status int)      { rec.status = status }
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestBatchCommits(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	var result batchResult
+	c.Post("/api/v1/batch", []batchOperation{
+		{Method: "POST", Path: "/categories", Body: []byte(`{"name": "Audio"}`)},
+		{Method: "POST", Path: "/products", Body: []byte(`{"name": "Headphones", "price": "99.99", "category_id": "${0.id}"}`)},
+		{Method: "GET", Path: "/products/${1.id}"},
+	}).Expect(200).Decode(&result)
+	if !result.Committed || len(result.Responses) != 3 {
+		t.Fatalf("result %+v", result)
+	}
+
+	var products struct{ Data []Product }
+	c.Get("/api/v1/products").Expect(200).Decode(&products)
+	if len(products.Data) != 1 || products.Data[0].CategoryID == nil {
+		t.Errorf("products after the batch: %+v", products.Data)
+	}
+}
+
+// A failing operation rolls back the ones before it.
+func TestBatchRollsBack(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	var result batchResult
+	c.Post("/api/v1/batch", []batchOperation{
+		{Method: "POST", Path: "/categories", Body: []byte(`{"name": "Audio"}`)},
+		{Method: "POST", Path: "/products", Body: []byte(`{"name": "Headphones", "price": "99.99", "category_id": "${0.id}"}`)},
+		{Method: "POST", Path: "/products", Body: []byte(`{"name": "", "price": "-1"}`)},
+		{Method: "POST", Path: "/products", Body: []byte(`{"name": "Never run", "price": "1"}`)},
+	}).Expect(422).Decode(&result)
+	if result.Committed || len(result.Responses) != 3 || result.Responses[1].Status != 201 || result.Responses[2].Status != 422 {
+		t.Errorf("result %+v, want 201 then 422 and not committed", result)
+	}
+
+	var products struct{ Data []Product }
+	c.Get("/api/v1/products").Expect(200).Decode(&products)
+	var categories []Category
+	c.Get("/api/v1/categories").Expect(200).Decode(&categories)
+	if len(products.Data) != 0 || len(categories) != 0 {
+		t.Errorf("after the rollback: products %+v, categories %+v", products.Data, categories)
+	}
+}
+
+// Operations whose effects a rollback couldn't undo aren't found.
+func TestBatchOperationsLimited(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	var result batchResult
+	c.Post("/api/v1/batch", []batchOperation{
+		{Method: "POST", Path: "/products/import?async=true", Body: []byte(`[]`)},
+	}).Expect(404).Decode(&result)
+	if result.Committed || result.Responses[0].Status != 404 {
+		t.Errorf("result %+v", result)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+// idLookup is the response of a batch get. Data maps the ID of every
+// product found to the product, trimmed to the ?fields= asked for.
+// Missing lists the requested IDs that matched no product, in request
+// order.
+type idLookup struct {
+	Data    map[uint]any `json:"data"`
+	Missing []uint       `json:"missing"`
+}
+
+// batchGetRequest is the body of POST /products/batch-get.
+type batchGetRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// Get the products for a comma-separated list of IDs
+func getProductsByID(w http.ResponseWriter, r *http.Request) {
+	var ids []uint
+	for _, raw := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_product_id", raw)
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+	lookUpProducts(w, r, ids)
+}
+
+// Get the products for a list of IDs too long for a URL
+func batchGetProducts(w http.ResponseWriter, r *http.Request) {
+	var req batchGetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	lookUpProducts(w, r, req.IDs)
+}
+
+// lookUpProducts answers a batch get of the products with ids, in one
+// query. Repeated IDs count once.
+func lookUpProducts(w http.ResponseWriter, r *http.Request, ids []uint) {
+	seen := map[uint]bool{}
+	unique := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	ids = unique
+	if len(ids) == 0 {
+		writeError(w, r, http.StatusBadRequest, "empty_ids")
+		return
+	}
+	if max := depsFor(r).cfg.HTTP.MaxBatchGet; len(ids) > max {
+		writeError(w, r, http.StatusBadRequest, "too_many_ids", max)
+		return
+	}
+	fields, err := query.ParseFields[Product](r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var products []Product
+	if err := readDBFor(r).Where("id IN ?", ids).Find(&products).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if err := localize(r, products); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	lookup := idLookup{Data: make(map[uint]any, len(products)), Missing: []uint{}}
+	for i := range products {
+		lookup.Data[products[i].ID] = fields.Select(&products[i])
+	}
+	for _, id := range ids {
+		if _, ok := lookup.Data[id]; !ok {
+			lookup.Missing = append(lookup.Missing, id)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, lookup)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// uploadRoutes are the routes whose bodies may be as large as
+// http.max_upload_bytes rather than http.max_body_bytes, keyed by
+// *mux.Route.
+var uploadRoutes sync.Map
+
+// acceptUploads declares that route takes whole files, such as a
+// spreadsheet or a backup, and returns it.
+func acceptUploads(route *mux.Route) *mux.Route {
+	uploadRoutes.Store(route, true)
+	return route
+}
+
+type bodyLimitKey struct{}
+
+// limitedBody is a request body cut off after its route's limit. It
+// remembers whether the limit was reached, so that whatever error the
+// handler answers with becomes a 413.
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitBodies bounds the request body to http.max_body_bytes, or
+// http.max_upload_bytes for routes declared with acceptUploads. A body
+// declared larger is answered 413 before it is read; one that turns out
+// larger fails to read past the limit. It runs as mux middleware, after
+// routing, so the matched route is known.
+func limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := depsFor(r).cfg.HTTP
+		limit := int64(cfg.MaxBodyBytes)
+		if route := mux.CurrentRoute(r); route != nil {
+			if _, ok := uploadRoutes.Load(route); ok {
+				limit = int64(cfg.MaxUploadBytes)
+			}
+		}
+		if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			// Not worth reading just to discard; the connection is closed
+			w.Header().Set("Connection", "close")
+			writeError(w, r, http.StatusRequestEntityTooLarge, "body_too_large", limit)
+			return
+		}
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), limit: limit}
+		r.Body = body
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyLimitKey{}, body)))
+	})
+}
+
+// bodyTooLarge reports the limit the body of the request of ctx was cut
+// off at, if it was.
+func bodyTooLarge(ctx context.Context) (limit int64, ok bool) {
+	if body, _ := ctx.Value(bodyLimitKey{}).(*limitedBody); body != nil && body.exceeded {
+		return body.limit, true
+	}
+	return 0, false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/service"
+)
+
+const maxBulkItems = 10000
+
+// bulkResult is the outcome of one item of a bulk request made with
+// ?atomic=false.
+type bulkResult struct {
+	Index  int      `json:"index"`
+	Status int      `json:"status"`
+	ID     uint     `json:"id,omitempty"`
+	Data   *Product `json:"data,omitempty"`
+	Error  *problem `json:"error,omitempty"`
+}
+
+type bulkDeleteRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// bulkAtomic reports whether a bulk request is all-or-nothing, the default,
+// or applies what it can and reports per item (?atomic=false).
+func bulkAtomic(r *http.Request) bool {
+	return r.URL.Query().Get("atomic") != "false"
+}
+
+// Create a JSON array of products in one transaction
+func bulkCreateProducts(w http.ResponseWriter, r *http.Request) {
+	var products []Product
+	if err := decodeJSON(r, &products); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if len(products) > maxBulkItems {
+		writeError(w, r, http.StatusBadRequest, "too_many_items", maxBulkItems)
+		return
+	}
+	atomic := bulkAtomic(r)
+	errs, err := productService(r).CreateMany(products, atomic)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if atomic {
+		writeJSON(w, r, http.StatusCreated, map[string][]Product{"data": products})
+		return
+	}
+
+	results := make([]bulkResult, len(products))
+	for i := range products {
+		results[i] = bulkResult{Index: i, Status: http.StatusCreated, Data: &products[i]}
+		if errs[i] != nil {
+			p := asAPIError(errs[i]).problem(r, http.StatusUnprocessableEntity)
+			results[i] = bulkResult{Index: i, Status: http.StatusUnprocessableEntity, Error: &p}
+		}
+	}
+	writeJSON(w, r, http.StatusMultiStatus, map[string][]bulkResult{"results": results})
+}
+
+// Delete the products listed in {"ids": [...]} in one transaction
+func bulkDeleteProducts(w http.ResponseWriter, r *http.Request) {
+	var req bulkDeleteRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.IDs) > maxBulkItems {
+		writeError(w, r, http.StatusBadRequest, "too_many_items", maxBulkItems)
+		return
+	}
+	atomic := bulkAtomic(r)
+	errs, err := productService(r).DeleteMany(req.IDs, atomic)
+	if atomic && errors.Is(err, service.ErrNotFound) {
+		for i, err := range errs {
+			if err != nil {
+				writeError(w, r, http.StatusNotFound, "bulk_product_not_found", req.IDs[i])
+				return
+			}
+		}
+	}
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if atomic {
+		writeJSON(w, r, http.StatusOK, map[string]int{"deleted": len(req.IDs)})
+		return
+	}
+
+	notFound := newAPIError("product_not_found").problem(r, http.StatusNotFound)
+	results := make([]bulkResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i] = bulkResult{Index: i, Status: http.StatusNoContent, ID: id}
+		if errs[i] != nil {
+			results[i].Status = http.StatusNotFound
+			results[i].Error = &notFound
+		}
+	}
+	writeJSON(w, r, http.StatusMultiStatus, map[string][]bulkResult{"results": results})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// cacheable lets clients cache the catalog reads of next: a 200 or 304
+// response gets Cache-Control with a max-age of HTTP_CACHE_MAX_AGE, or
+// no-cache if it is 0, so that they revalidate with the ETag. Responses
+// are private, as they depend on the tenant and on whether the caller is
+// an admin, and vary with the translations the client accepts. A handler that sets Cache-Control itself keeps it.
+func cacheable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxAge := time.Duration(depsFor(r).cfg.HTTP.CacheMaxAge)
+		next(&cacheControlWriter{ResponseWriter: w, maxAge: maxAge}, r)
+	}
+}
+
+// cacheControlWriter sets Cache-Control as the response starts, once its
+// status is known.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	maxAge      time.Duration
+	wroteHeader bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(status int) {
+	if !cw.wroteHeader && status >= http.StatusOK {
+		cw.wroteHeader = true
+		h := cw.Header()
+		if (status == http.StatusOK || status == http.StatusNotModified) && h.Get("Cache-Control") == "" {
+			if cw.maxAge > 0 {
+				h.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(cw.maxAge/time.Second)))
+			} else {
+				h.Set("Cache-Control", "private, no-cache")
+			}
+			h.Add("Vary", "Accept-Language, Authorization, X-Tenant-ID")
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// notModifiedSince reports whether r's If-Modified-Since is no earlier
+// than modified. It only counts when r has no If-None-Match, which
+// decides instead.
+func notModifiedSince(r *http.Request, modified time.Time) bool {
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// Last-Modified only has whole seconds
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"gorm.io/gorm"
+)
+
+// Category is the category model. Handlers use it unqualified.
+type Category = model.Category
+
+// Get all categories
+func getCategories(w http.ResponseWriter, r *http.Request) {
+	var categories []Category
+	if err := readDBFor(r).Order("id").Find(&categories).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeConditionalJSON(w, r, "", categories)
+}
+
+// Get a single category by ID
+func getCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "category_not_found")
+		return
+	}
+	var category Category
+	if err := readDBFor(r).First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "category_not_found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeConditionalJSON(w, r, "", category)
+}
+
+// Create a new category
+func createCategory(w http.ResponseWriter, r *http.Request) {
+	var category Category
+	if err := decodeJSON(r, &category); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if category.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	category.ID = 0
+	if err := dbFor(r).Create(&category).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, category)
+}
+
+// Rename a category
+func updateCategory(w http.ResponseWriter, r *http.Request) {
+	var category Category
+	if err := decodeJSON(r, &category); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if category.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "category_not_found")
+		return
+	}
+	category.ID, category.TenantID = id, tenantFor(r)
+	res := dbFor(r).Model(&category).Update("name", category.Name)
+	if res.Error != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if res.RowsAffected == 0 {
+		writeError(w, r, http.StatusNotFound, "category_not_found")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, category)
+}
+
+// Delete a category. Its products, deleted ones included, are left
+// without a category.
+func deleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "category_not_found")
+		return
+	}
+	err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+		if err := unassign(tx, actorFor(r.Context()), "category_id", id); err != nil {
+			return err
+		}
+		res := tx.Delete(&Category{}, id)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return errCategoryNotFound
+		}
+		return res.Error
+	})
+	switch {
+	case errors.Is(err, errCategoryNotFound):
+		writeError(w, r, http.StatusNotFound, "category_not_found")
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	invalidateProducts(r.Context(), depsFor(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unassign clears column, category_id or supplier_id, on every product
+// set to id, bumping their versions and recording the change by actor as
+// any other change to them would be.
+func unassign(tx *gorm.DB, actor, column string, id uint) error {
+	products, err := lockForUpdate(tx.Unscoped().Where(column+" = ?", id))
+	if err != nil {
+		return err
+	}
+	err = tx.Unscoped().Model(&Product{}).Where(column+" = ?", id).Updates(map[string]any{
+		column:    nil,
+		"version": gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return recordUpdates(tx, actor, products, func(p *Product) {
+		if column == "category_id" {
+			p.CategoryID = nil
+		} else {
+			p.SupplierID = nil
+		}
+	})
+}
+
+// assignCategoryRequest is the body of POST /products/assign-category.
+type assignCategoryRequest struct {
+	Filter     productFilter `json:"filter"`
+	CategoryID uint          `json:"category_id"`
+	DryRun     bool          `json:"dry_run"`
+}
+
+var errCategoryNotFound = errors.New("category not found")
+
+// Move every product matching a filter into a category
+func assignCategory(w http.ResponseWriter, r *http.Request) {
+	var req assignCategoryRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.CategoryID == 0 {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	if req.Filter.isEmpty() {
+		writeError(w, r, http.StatusBadRequest, "empty_filter")
+		return
+	}
+	filters, err := req.Filter.parse()
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		req.DryRun = true
+	}
+
+	var count int64
+	err = dbFor(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&Category{}, req.CategoryID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errCategoryNotFound
+			}
+			return err
+		}
+		if req.DryRun {
+			return filters.Apply(tx.Model(&Product{})).Count(&count).Error
+		}
+		products, err := lockForUpdate(filters.Apply(tx))
+		if err != nil {
+			return err
+		}
+		res := filters.Apply(tx.Model(&Product{})).Updates(map[string]any{
+			"category_id": req.CategoryID,
+			"version":     gorm.Expr("version + 1"),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		count = res.RowsAffected
+		return recordUpdates(tx, actorFor(r.Context()), products, func(p *Product) {
+			p.CategoryID = &req.CategoryID
+		})
+	})
+	switch {
+	case errors.Is(err, errCategoryNotFound):
+		writeError(w, r, http.StatusConflict, "category_not_found")
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if !req.DryRun {
+		invalidateProducts(r.Context(), depsFor(r))
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"count": count, "dry_run": req.DryRun})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssignCategory(t *testing.T) {
+	c, _ := newTestAPI(t)
+	var category Category
+	c.Post("/api/v1/categories", map[string]any{"name": "Premium"}).Expect(201).Decode(&category)
+	ids := map[string]uint{}
+	for _, p := range []map[string]any{
+		{"name": "Gaming Laptop", "price": "1500"},
+		{"name": "Office Laptop", "price": "800"},
+		{"name": "Netbook Laptop", "price": "300"},
+		{"name": "Gaming Mouse", "price": "900"},
+	} {
+		var created Product
+		c.Post("/api/v1/products", p).Expect(201).Decode(&created)
+		ids[created.Name] = created.ID
+	}
+	req := map[string]any{"filter": map[string]any{"name_like": "laptop", "price_gte": 500}, "category_id": category.ID}
+
+	var result struct {
+		Count  int  `json:"count"`
+		DryRun bool `json:"dry_run"`
+	}
+	c.Post("/api/v1/products/assign-category?dry_run=true", req).Expect(200).Decode(&result)
+	if result.Count != 2 || !result.DryRun {
+		t.Errorf("dry run %+v, want a count of 2", result)
+	}
+	c.Post("/api/v1/products/assign-category", req).Expect(200).Decode(&result)
+	if result.Count != 2 || result.DryRun {
+		t.Errorf("result %+v, want 2 assigned", result)
+	}
+	for name, id := range ids {
+		var p Product
+		c.Get(fmt.Sprintf("/api/v1/products/%d", id)).Expect(200).Decode(&p)
+		assigned := p.CategoryID != nil && *p.CategoryID == category.ID
+		if want := name == "Gaming Laptop" || name == "Office Laptop"; assigned != want {
+			t.Errorf("%s assigned %v, want %v", name, assigned, want)
+		}
+	}
+
+	req["category_id"] = category.ID + 1
+	c.Post("/api/v1/products/assign-category", req).Expect(409)
+	expectProblem(t, c.Post("/api/v1/products/assign-category", map[string]any{"filter": map[string]any{}, "category_id": category.ID}), 400, "empty_filter")
+	// A filter that can't match is the client's mistake, not the server's
+	inverted := map[string]any{"filter": map[string]any{"price_gte": 500, "price_lte": 100}, "category_id": category.ID}
+	expectProblem(t, c.Post("/api/v1/products/assign-category", inverted), 400, "invalid_range")
+	expectProblem(t, c.Post("/api/v1/products/assign-category?dry_run=true", inverted), 400, "invalid_range")
+}
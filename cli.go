@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/logging"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gorm.io/gorm"
+)
+
+// serveOptions are the flags of the serve command.
+type serveOptions struct {
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	generate        int
+	seed            int64
+	quotaFile       string
+	slashMode       string
+	// configFile is the root command's --config, read again on reloads.
+	configFile string
+}
+
+func (o *serveOptions) register(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.shutdownTimeout, "shutdown-timeout", 15*time.Second, "time allowed for graceful shutdown")
+	fs.DurationVar(&o.drainDelay, "drain-delay", 0, "keep serving this long after a shutdown signal, with /readyz failing, before shutting down")
+	fs.IntVar(&o.generate, "generate", 0, "insert this many synthetic products before serving, e.g. into an in-memory database")
+	fs.Int64Var(&o.seed, "seed", 1, "random seed for --generate")
+	fs.StringVar(&o.quotaFile, "tenant-quotas", "", "JSON file of per-tenant quotas")
+	fs.IntVar(&maxListBytes, "max-response-bytes", maxListBytes, "cap on the encoded rows of a list page; 0 for no cap")
+	fs.StringVar(&o.slashMode, "trailing-slash", trailingSlashMatch, "how to treat a trailing slash: match or redirect")
+}
+
+// newRootCommand returns the golangdb command. Without a subcommand it
+// serves the API, as serve does; the other subcommands manage the
+// database the configuration points at.
+func newRootCommand() *cobra.Command {
+	var (
+		configFile string
+		cfg        config.Config
+		logger     *slog.Logger
+		opts       serveOptions
+	)
+	run := func(cmd *cobra.Command, args []string) {
+		opts.configFile = configFile
+		serve(cfg, logger, opts)
+	}
+	root := &cobra.Command{
+		Use:          "golangdb",
+		Short:        "Product inventory API server and admin tool",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if cfg, err = config.Load(configFile); err != nil {
+				return err
+			}
+			if logger, err = logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+			query.SetSecret(cfg.CursorSecret)
+			return nil
+		},
+		Run: run,
+	}
+	root.PersistentFlags().StringVar(&configFile, "config", os.Getenv("CONFIG_FILE"), "JSON or YAML config file; overrides environment variables")
+	opts.register(root.Flags())
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the API",
+		Args:  cobra.NoArgs,
+		Run:   run,
+	}
+	opts.register(serveCmd.Flags())
+	root.AddCommand(serveCmd, newSeedCommand(&cfg), newMigrateCommand(&cfg), newProductCommand(&cfg))
+	return root
+}
+
+// newSeedCommand returns the seed command, which inserts synthetic
+// products for demos and load tests.
+func newSeedCommand(cfg *config.Config) *cobra.Command {
+	var (
+		count  int
+		seed   int64
+		tenant uint
+	)
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Insert synthetic products",
+		Long:  "Insert synthetic products for demos and load tests. The same seed always produces the same products.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if count < 1 {
+				return errors.New("--count must be positive")
+			}
+			db, err := openTenantDB(cmd.Context(), *cfg, tenant)
+			if err != nil {
+				return err
+			}
+			if err := generateProducts(db, count, seed); err != nil {
+				return err
+			}
+			fmt.Printf("inserted %d products into tenant %d\n", count, tenant)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&count, "count", 1000, "number of products to insert")
+	cmd.Flags().Int64Var(&seed, "seed", 1, "random seed")
+	cmd.Flags().UintVar(&tenant, "tenant", model.DefaultTenantID, "ID of the tenant to insert into")
+	return cmd
+}
+
+// newProductCommand returns the product command, for operators to look
+// up, add and remove products through the same service layer as the API:
+// with its validation, its audit log and its webhooks.
+func newProductCommand(cfg *config.Config) *cobra.Command {
+	var tenant uint
+	cmd := &cobra.Command{
+		Use:   "product",
+		Short: "List, create and delete products",
+	}
+	cmd.PersistentFlags().UintVar(&tenant, "tenant", model.DefaultTenantID, "ID of the tenant whose products to work on")
+	products := func(ctx context.Context) (*service.ProductService, error) {
+		return cliProductService(ctx, *cfg, tenant)
+	}
+	cmd.AddCommand(newProductListCommand(cfg, products), newProductCreateCommand(products), newProductDeleteCommand(products))
+	return cmd
+}
+
+func newProductListCommand(cfg *config.Config, products func(ctx context.Context) (*service.ProductService, error)) *cobra.Command {
+	var (
+		limit   int
+		sort    string
+		cursor  string
+		filters []string
+	)
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List products",
+		Example: "  golangdb product list --sort=-price --filter price_gte=10 --filter name_like=desk",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Reuse the REST query parameters, so filters and sorts are
+			// the same as GET /products's
+			values := url.Values{}
+			for _, filter := range filters {
+				name, value, ok := strings.Cut(filter, "=")
+				if !ok {
+					return fmt.Errorf("--filter must be name=value, got %q", filter)
+				}
+				values.Set(name, value)
+			}
+			if limit != 0 {
+				values.Set("limit", strconv.Itoa(limit))
+			}
+			if sort != "" {
+				values.Set("sort", sort)
+			}
+			if cursor != "" {
+				values.Set("cursor", cursor)
+			}
+			params, err := productSchema.Parse(values)
+			if err != nil {
+				return cliError(err)
+			}
+			svc, err := products(cmd.Context())
+			if err != nil {
+				return err
+			}
+			list, total, err := svc.List(params)
+			if err != nil {
+				return cliError(err)
+			}
+			list, next, err := query.Next(params, list)
+			if err != nil {
+				return err
+			}
+			if err := printProducts(os.Stdout, list); err != nil {
+				return err
+			}
+			fmt.Printf("%d of %d products\n", len(list), total)
+			switch {
+			case next == "":
+			case cfg.CursorSecret == "":
+				// Signed with a key of this process's own
+				fmt.Println("more products: set CURSOR_SECRET to page through them with --cursor")
+			default:
+				fmt.Printf("next page: --cursor=%s\n", next)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 0, fmt.Sprintf("products per page, at most %d (default %d)", maxPageLimit, defaultPageLimit))
+	cmd.Flags().StringVar(&sort, "sort", "", "comma-separated fields to sort by, each optionally prefixed with - for descending")
+	cmd.Flags().StringVar(&cursor, "cursor", "", "continue after the previous page")
+	cmd.Flags().StringArrayVar(&filters, "filter", nil, "a filter of GET /products as name=value, e.g. price_gte=10; repeatable")
+	return cmd
+}
+
+func newProductCreateCommand(products func(ctx context.Context) (*service.ProductService, error)) *cobra.Command {
+	var (
+		product                model.Product
+		price, sku             string
+		categoryID, supplierID uint
+	)
+	cmd := &cobra.Command{
+		Use:     "create",
+		Short:   "Create a product",
+		Example: `  golangdb product create --name "Standing Desk" --price 349.00 --quantity 12`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if product.Price, err = money.Parse(price); err != nil {
+				return fmt.Errorf("--price must be a decimal amount, got %q", price)
+			}
+			if cmd.Flags().Changed("sku") {
+				product.SKU = &sku
+			}
+			if cmd.Flags().Changed("category-id") {
+				product.CategoryID = &categoryID
+			}
+			if cmd.Flags().Changed("supplier-id") {
+				product.SupplierID = &supplierID
+			}
+			svc, err := products(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := svc.Create(&product); err != nil {
+				return cliError(err)
+			}
+			return printProducts(os.Stdout, []model.Product{product})
+		},
+	}
+	cmd.Flags().StringVar(&product.Name, "name", "", "name")
+	cmd.Flags().StringVar(&price, "price", "", "price, e.g. 19.99")
+	cmd.Flags().StringVar(&product.Currency, "currency", model.DefaultCurrency, "ISO 4217 currency code")
+	cmd.Flags().IntVar(&product.Quantity, "quantity", 0, "quantity in stock")
+	cmd.Flags().IntVar(&product.MinStock, "min-stock", 0, "quantity below which the product is low on stock")
+	cmd.Flags().IntVar(&product.MaxStock, "max-stock", 0, "most that may be held in stock; 0 for no limit")
+	cmd.Flags().StringVar(&sku, "sku", "", "stock keeping unit")
+	cmd.Flags().UintVar(&categoryID, "category-id", 0, "ID of the product's category")
+	cmd.Flags().UintVar(&supplierID, "supplier-id", 0, "ID of the product's supplier")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("price")
+	return cmd
+}
+
+func newProductDeleteCommand(products func(ctx context.Context) (*service.ProductService, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID...",
+		Short: "Delete products",
+		Long:  "Delete products. Like DELETE /products/{id}, deleted products can be restored through the API.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]uint, len(args))
+			for i, arg := range args {
+				id, err := strconv.ParseUint(arg, 10, 0)
+				if err != nil || id == 0 {
+					return fmt.Errorf("invalid product ID %q", arg)
+				}
+				ids[i] = uint(id)
+			}
+			svc, err := products(cmd.Context())
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := svc.Delete(id, 0); err != nil {
+					return fmt.Errorf("product %d: %w", id, cliError(err))
+				}
+				fmt.Printf("deleted product %d\n", id)
+			}
+			return nil
+		},
+	}
+}
+
+// openTenantDB connects to the database configured in cfg, as the server
+// does, with its statements confined to tenant.
+func openTenantDB(ctx context.Context, cfg config.Config, tenant uint) (*gorm.DB, error) {
+	db := initDB(cfg.DB, cfg.Log)
+	exists, err := tenantExists(db, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("tenant %d doesn't exist", tenant)
+	}
+	return db.WithContext(repository.WithTenant(ctx, tenant)), nil
+}
+
+// cliProductService returns the product service for tenant's products,
+// behind the product cache if the server shares one, so that the
+// server doesn't keep serving what the command changed.
+func cliProductService(ctx context.Context, cfg config.Config, tenant uint) (*service.ProductService, error) {
+	db, err := openTenantDB(ctx, cfg, tenant)
+	if err != nil {
+		return nil, err
+	}
+	d := &deps{db: db, cfg: cfg}
+	if cfg.Cache.RedisURL != "" {
+		if d.cache, err = newProductCache(cfg.Cache); err != nil {
+			return nil, err
+		}
+	}
+	ctx = db.Statement.Context
+	repo := d.cachedProducts(ctx, repository.NewProductRepository(db))
+	return service.NewProductService(repo).As(cliActor()).WithContext(ctx), nil
+}
+
+// cliActor is who the audit log attributes the commands' writes to: the
+// operating system user running them.
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// cliError describes err as the API would, in English, with the problem
+// of each field on a line of its own. Server errors are left as they are,
+// since an operator can make more of the details.
+func cliError(err error) error {
+	if errorStatus(err) == http.StatusInternalServerError {
+		return err
+	}
+	apiErr := asAPIError(err)
+	msg := apiErr.Error()
+	for _, f := range apiErr.Fields {
+		msg += fmt.Sprintf("\n  %s: %s", f.Field, f.Err.Error())
+	}
+	return errors.New(msg)
+}
+
+// printProducts writes products to w as a table.
+func printProducts(w io.Writer, products []model.Product) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPRICE\tQUANTITY\tRESERVED\tSKU\tVERSION")
+	for _, p := range products {
+		sku := ""
+		if p.SKU != nil {
+			sku = *p.SKU
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s %s\t%d\t%d\t%s\t%d\n", p.ID, p.Name, p.Price, p.Currency, p.Quantity, p.Reserved, sku, p.Version)
+	}
+	return tw.Flush()
+}
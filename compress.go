@@ -0,0 +1,202 @@
+package main
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipWriters are reused across responses, as each holds sizeable buffers.
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// compressResponses is middleware that gzips responses for clients whose
+// Accept-Encoding allows it, once they reach cfg.HTTP.CompressMinBytes or
+// are flushed. Only text and JSON are compressed; images and workbooks
+// already are.
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		minBytes := depsFor(r).cfg.HTTP.CompressMinBytes
+		if minBytes <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, minBytes: minBytes}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding value allows gzip, by
+// name or through *, with a nonzero quality.
+func acceptsGzip(acceptEncoding string) bool {
+	accepted := false
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if name == "gzip" {
+			// Named, it overrides *
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// compressible reports whether responses of contentType are worth
+// gzipping.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" ||
+		strings.HasSuffix(mediaType, "+json") || mediaType == "application/x-ndjson"
+}
+
+// gzipResponseWriter holds back the start of a response until it knows
+// whether to gzip it: a body known or seen to reach minBytes is gzipped,
+// as is one flushed before, which is streamed. Anything else is sent as
+// is. ETags are left alone: they name a product's version, not the bytes
+// on the wire.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minBytes int
+
+	status  int  // the status the handler wrote, or 0
+	decided bool // whether the header has been sent on
+	gz      *gzip.Writer
+	buf     []byte
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status != 0 || w.decided {
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		// Left to net/http to sniff, which it can't once gzipped
+		w.passThrough()
+		return
+	}
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || !compressible(header.Get("Content-Type")) {
+		w.passThrough()
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+		if length >= w.minBytes {
+			w.startGzip()
+		} else {
+			w.passThrough()
+		}
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(b)
+	case w.decided:
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minBytes {
+		w.startGzip()
+		if err := w.drain(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what was written so far, gzipped if the response is to be,
+// so streamed responses still stream.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.WriteHeader(http.StatusOK)
+		}
+		if !w.decided {
+			w.startGzip()
+			w.drain()
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipResponseWriter) startGzip() {
+	w.decided = true
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.ResponseWriter.WriteHeader(w.status)
+	w.gz = gzipWriters.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+func (w *gzipResponseWriter) passThrough() {
+	w.decided = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// drain writes what was held back.
+func (w *gzipResponseWriter) drain() error {
+	buf := w.buf
+	w.buf = nil
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else if len(buf) > 0 {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends a response too small to gzip, or finishes a gzipped one.
+func (w *gzipResponseWriter) close() {
+	if w.status != 0 && !w.decided {
+		w.passThrough()
+		w.drain()
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,1001 @@
+// Package config loads the service configuration from environment
+// variables and an optional JSON or YAML file.
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/secrets"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// Database drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+)
+
+// Storage drivers.
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// DB holds the database connection settings. Driver picks the database;
+// Path is only used by SQLite and the network settings only by the others.
+type DB struct {
+	Driver   string `json:"driver" yaml:"driver"`
+	Path     string `json:"path" yaml:"path"` // SQLite file, or :memory:
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"` // 0 for the driver's default
+	User     string `json:"user" yaml:"user"`
+	Password Secret `json:"password" yaml:"password"`
+	Name     string `json:"name" yaml:"name"`
+	SSLMode  string `json:"sslmode" yaml:"sslmode"` // PostgreSQL only
+
+	// UserSecret and PasswordSecret, if set, are references to the user
+	// and password kept as secrets, such as file:/run/secrets/db_password,
+	// resolved by Load. They replace User and Password.
+	UserSecret     string `json:"user_secret" yaml:"user_secret"`
+	PasswordSecret string `json:"password_secret" yaml:"password_secret"`
+
+	// StatementTimeout bounds each statement; 0 means no limit.
+	StatementTimeout Duration `json:"statement_timeout" yaml:"statement_timeout"`
+
+	// BreakerFailures is how many statements in a row may fail for want
+	// of the database, by not connecting or timing out, before the rest
+	// fail fast for BreakerCooldown, after which one is let through to
+	// try again. 0 turns the circuit breaker off.
+	BreakerFailures int      `json:"breaker_failures" yaml:"breaker_failures"`
+	BreakerCooldown Duration `json:"breaker_cooldown" yaml:"breaker_cooldown"`
+
+	// MaxOpenConns and MaxIdleConns size the connection pool; 0 open means
+	// no limit, and 0 idle keeps none. ConnMaxLifetime closes connections
+	// after that long, so they move to new servers behind a load balancer;
+	// 0 keeps them forever.
+	MaxOpenConns    int      `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+
+	// PingTimeout bounds each check that the database answers after
+	// connecting. ConnectTimeout is how long startup keeps retrying, with
+	// exponential backoff, while it doesn't; 0 tries only once.
+	PingTimeout    Duration `json:"ping_timeout" yaml:"ping_timeout"`
+	ConnectTimeout Duration `json:"connect_timeout" yaml:"connect_timeout"`
+
+	// Migrate applies pending schema migrations at startup. Otherwise the
+	// server refuses to start until they are applied with "migrate up".
+	Migrate bool `json:"migrate" yaml:"migrate"`
+
+	// Replicas are the addresses, host or host:port, of read replicas of
+	// a PostgreSQL or MySQL database, reached with the same user, password
+	// and name. Reads that can tolerate replication lag are spread over
+	// them. Each is checked every ReplicaCheckInterval, and left out while
+	// it is down or more than ReplicaMaxLag behind.
+	Replicas             []string `json:"replicas" yaml:"replicas"`
+	ReplicaMaxLag        Duration `json:"replica_max_lag" yaml:"replica_max_lag"`
+	ReplicaCheckInterval Duration `json:"replica_check_interval" yaml:"replica_check_interval"`
+}
+
+// Replica returns the settings of the replica at addr: c's, but for that
+// host and port.
+func (c DB) Replica(addr string) DB {
+	c.Host, c.Port, c.Replicas = addr, 0, nil
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		c.Host = host
+		c.Port, _ = strconv.Atoi(port)
+	}
+	return c
+}
+
+// PortOrDefault returns Port, or the default port of the driver if Port
+// is 0.
+func (c DB) PortOrDefault() int {
+	switch {
+	case c.Port != 0:
+		return c.Port
+	case c.Driver == DriverMySQL:
+		return 3306
+	}
+	return 5432
+}
+
+// HTTP holds the HTTP server settings. The timeouts map to the fields of
+// the same names on http.Server.
+type HTTP struct {
+	Addr              string   `json:"addr" yaml:"addr"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
+	ReadTimeout       Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout      Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout"`
+
+	// MaxBodyBytes bounds the size of a request body. MaxUploadBytes
+	// replaces it for the routes that take whole files: imports and
+	// restores.
+	MaxBodyBytes   int `json:"max_body_bytes" yaml:"max_body_bytes"`
+	MaxUploadBytes int `json:"max_upload_bytes" yaml:"max_upload_bytes"`
+
+	// CompressMinBytes is the smallest response gzipped for clients that
+	// accept it; streamed responses are gzipped whatever their size. 0
+	// turns compression off.
+	CompressMinBytes int `json:"compress_min_bytes" yaml:"compress_min_bytes"`
+
+	// RequestTimeout bounds the handling of a request. LongRequestTimeout
+	// replaces it for the routes that take long by design: imports,
+	// exports, bulk writes, batches, backups and restores. 0 means no
+	// limit.
+	RequestTimeout     Duration `json:"request_timeout" yaml:"request_timeout"`
+	LongRequestTimeout Duration `json:"long_request_timeout" yaml:"long_request_timeout"`
+
+	// MaxBatchGet bounds the IDs of one batch get of products.
+	MaxBatchGet int `json:"max_batch_get" yaml:"max_batch_get"`
+
+	// CacheMaxAge is how long clients may reuse catalog reads without
+	// asking again. 0 has them revalidate every time.
+	CacheMaxAge Duration `json:"cache_max_age" yaml:"cache_max_age"`
+}
+
+// TLS holds the HTTPS settings. TLS is off unless CertFile and KeyFile,
+// or AutocertDomains, are set; with it on, the gRPC server uses the same
+// certificate.
+type TLS struct {
+	// CertFile and KeyFile are the PEM certificate chain and private key.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+
+	// AutocertDomains, instead, obtains and renews certificates for these
+	// host names from an ACME CA, Let's Encrypt unless
+	// AutocertDirectoryURL names another. They are kept in
+	// AutocertCacheDir, so a restart doesn't request them again.
+	AutocertDomains      []string `json:"autocert_domains" yaml:"autocert_domains"`
+	AutocertCacheDir     string   `json:"autocert_cache_dir" yaml:"autocert_cache_dir"`
+	AutocertEmail        string   `json:"autocert_email" yaml:"autocert_email"`
+	AutocertDirectoryURL string   `json:"autocert_directory_url" yaml:"autocert_directory_url"`
+
+	// RedirectAddr, if set, is where plain HTTP is served, only to
+	// redirect to HTTPS and to answer ACME challenges. Typically :80.
+	RedirectAddr string `json:"redirect_addr" yaml:"redirect_addr"`
+
+	// MinVersion is the oldest TLS version accepted: 1.2 or 1.3.
+	MinVersion string `json:"min_version" yaml:"min_version"`
+}
+
+// Enabled reports whether the server speaks HTTPS.
+func (c TLS) Enabled() bool {
+	return c.CertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// GRPC holds the gRPC server settings. The server is off unless Addr is
+// set.
+type GRPC struct {
+	Addr string `json:"addr" yaml:"addr"`
+}
+
+// Secret is a setting that must not be shown: printed, logged or encoded,
+// it reads [REDACTED] unless empty.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secrets.Redacted
+}
+
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+
+func (s Secret) LogValue() slog.Value { return slog.StringValue(s.String()) }
+
+func (s Secret) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+func (s *Secret) UnmarshalText(text []byte) error {
+	*s = Secret(text)
+	return nil
+}
+
+// Duration is a time.Duration written as a string such as "30s" in env
+// vars and config files.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Log holds the logging settings.
+type Log struct {
+	Format string `json:"format" yaml:"format"` // json or text
+	Level  string `json:"level" yaml:"level"`   // debug, info, warn or error
+
+	// SlowQuery is how long a database statement can take before it is
+	// logged as slow; 0 logs none.
+	SlowQuery Duration `json:"slow_query" yaml:"slow_query"`
+	// SQLParams logs statements with their bound parameters instead of
+	// placeholders. Parameters can hold personal data and secrets, so it
+	// is refused in production.
+	SQLParams bool `json:"sql_params" yaml:"sql_params"`
+}
+
+// RateLimit holds the per-client request limits, in requests per minute
+// with bursts of up to a minute's worth; 0 means unlimited. Writes are
+// requests other than GET, HEAD and OPTIONS.
+type RateLimit struct {
+	ReadsPerMinute  int `json:"reads_per_minute" yaml:"reads_per_minute"`
+	WritesPerMinute int `json:"writes_per_minute" yaml:"writes_per_minute"`
+
+	// RedisURL, if set, keeps the buckets in Redis so that every instance
+	// shares them. Otherwise each instance limits on its own.
+	RedisURL string `json:"redis_url" yaml:"redis_url"`
+}
+
+// Cache holds the product read cache settings. The cache is off unless
+// TTL is set.
+type Cache struct {
+	TTL Duration `json:"ttl" yaml:"ttl"`
+	// Size is how many entries the in-process cache holds.
+	Size int `json:"size" yaml:"size"`
+	// RedisURL, if set, caches in Redis, shared by every instance, instead
+	// of in process.
+	RedisURL string `json:"redis_url" yaml:"redis_url"`
+}
+
+// Idempotency holds the settings of Idempotency-Key handling.
+type Idempotency struct {
+	// TTL is how long a key's response is kept for replay.
+	TTL Duration `json:"ttl" yaml:"ttl"`
+}
+
+// CORS holds the cross-origin settings for browser clients. CORS is off
+// unless AllowedOrigins is set; "*" allows any origin, but not together
+// with AllowCredentials.
+type CORS struct {
+	AllowedOrigins   []string `json:"allowed_origins" yaml:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods" yaml:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers" yaml:"allowed_headers"`
+	AllowCredentials bool     `json:"allow_credentials" yaml:"allow_credentials"`
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge Duration `json:"max_age" yaml:"max_age"`
+}
+
+// Tracing holds the OpenTelemetry tracing settings. Tracing is off unless
+// Endpoint is set.
+type Tracing struct {
+	// Endpoint is the URL of the OTLP collector, such as
+	// http://localhost:4317; http:// sends spans without TLS.
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Protocol is the OTLP transport: grpc or http/protobuf.
+	Protocol string `json:"protocol" yaml:"protocol"`
+	// SampleRatio is the fraction of new traces recorded, from 0 to 1.
+	// Requests that arrive as part of a trace follow its caller's choice.
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+	ServiceName string  `json:"service_name" yaml:"service_name"`
+}
+
+// Jobs holds the settings of the background job workers.
+type Jobs struct {
+	// Workers is how many jobs run at once.
+	Workers int `json:"workers" yaml:"workers"`
+	// QueueSize is how many jobs can wait for a worker; beyond that new
+	// ones are refused.
+	QueueSize int `json:"queue_size" yaml:"queue_size"`
+	// Retention is how long a finished job, and any file it produced, is
+	// kept.
+	Retention Duration `json:"retention" yaml:"retention"`
+}
+
+// Storage holds where product images and export files are kept: in a
+// local directory, or in a bucket of S3 or a compatible service such as
+// MinIO.
+type Storage struct {
+	Driver string `json:"driver" yaml:"driver"` // local or s3
+
+	// Dir is the directory of the local driver. Its files are served by
+	// the API under /media/.
+	Dir string `json:"dir" yaml:"dir"`
+
+	S3Endpoint  string `json:"s3_endpoint" yaml:"s3_endpoint"` // host[:port]
+	S3Region    string `json:"s3_region" yaml:"s3_region"`
+	S3Bucket    string `json:"s3_bucket" yaml:"s3_bucket"`
+	S3AccessKey string `json:"s3_access_key" yaml:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key" yaml:"s3_secret_key"`
+	// S3Insecure talks to the endpoint over plain HTTP, for a local MinIO.
+	S3Insecure bool `json:"s3_insecure" yaml:"s3_insecure"`
+
+	// PublicURL, if set, is the base URL the objects are public at, such
+	// as a CDN in front of the bucket. Otherwise S3 objects get signed
+	// URLs that expire after URLExpiry.
+	PublicURL string   `json:"public_url" yaml:"public_url"`
+	URLExpiry Duration `json:"url_expiry" yaml:"url_expiry"`
+
+	// MaxImageBytes bounds the size of an uploaded image.
+	MaxImageBytes int `json:"max_image_bytes" yaml:"max_image_bytes"`
+}
+
+// Secrets holds where secrets referenced by other settings are read from.
+// References to files are relative to Dir; references to Vault need
+// VaultAddr.
+type Secrets struct {
+	Dir string `json:"dir" yaml:"dir"`
+
+	// VaultAddr and VaultToken reach HashiCorp Vault, whose KV version 2
+	// engine at VaultMount holds the secrets.
+	VaultAddr      string `json:"vault_addr" yaml:"vault_addr"`
+	VaultToken     Secret `json:"vault_token" yaml:"vault_token"`
+	VaultMount     string `json:"vault_mount" yaml:"vault_mount"`
+	VaultNamespace string `json:"vault_namespace" yaml:"vault_namespace"`
+}
+
+// Catalog holds settings of the product catalog itself.
+type Catalog struct {
+	// Locale is the BCP 47 locale of products' own names, which reads
+	// fall back to when no translation suits the client better.
+	Locale string `json:"locale" yaml:"locale"`
+}
+
+// Config is the complete service configuration.
+type Config struct {
+	DB        DB        `json:"db" yaml:"db"`
+	HTTP      HTTP      `json:"http" yaml:"http"`
+	TLS       TLS       `json:"tls" yaml:"tls"`
+	GRPC      GRPC      `json:"grpc" yaml:"grpc"`
+	Log       Log       `json:"log" yaml:"log"`
+	RateLimit RateLimit `json:"rate_limit" yaml:"rate_limit"`
+	Cache     Cache     `json:"cache" yaml:"cache"`
+	CORS      CORS      `json:"cors" yaml:"cors"`
+	Tracing   Tracing   `json:"tracing" yaml:"tracing"`
+	Storage   Storage   `json:"storage" yaml:"storage"`
+	Jobs      Jobs      `json:"jobs" yaml:"jobs"`
+	Catalog   Catalog   `json:"catalog" yaml:"catalog"`
+	Secrets   Secrets   `json:"secrets" yaml:"secrets"`
+
+	Idempotency Idempotency `json:"idempotency" yaml:"idempotency"`
+
+	// Flags turns features on or off by name. Features left out keep
+	// their defaults.
+	Flags map[string]bool `json:"flags" yaml:"flags"`
+
+	// CursorSecret signs pagination cursors. If empty, a random key is
+	// used and cursors don't survive a restart.
+	CursorSecret string `json:"cursor_secret" yaml:"cursor_secret"`
+
+	// JWTSecret signs access and refresh tokens. If empty, a random key is
+	// used and every token is invalidated by a restart.
+	JWTSecret string `json:"jwt_secret" yaml:"jwt_secret"`
+
+	// Environment names where the server runs, such as development or
+	// staging. Settings unsafe for real data are refused in production.
+	Environment string `json:"environment" yaml:"environment"`
+}
+
+// EnvProduction is the Environment of servers with real data.
+const EnvProduction = "production"
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		DB: DB{
+			Driver:  DriverPostgres,
+			Path:    ":memory:",
+			Host:    "localhost",
+			User:    "postgres",
+			Name:    "crud_db",
+			SSLMode: "disable",
+
+			StatementTimeout: Duration(10 * time.Second),
+			BreakerFailures:  5,
+			BreakerCooldown:  Duration(10 * time.Second),
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			PingTimeout:     Duration(5 * time.Second),
+			ConnectTimeout:  Duration(time.Minute),
+
+			ReplicaMaxLag:        Duration(5 * time.Second),
+			ReplicaCheckInterval: Duration(5 * time.Second),
+		},
+		HTTP: HTTP{
+			Addr:              ":8080",
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(60 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			RequestTimeout:    Duration(30 * time.Second),
+			MaxBodyBytes:      1 << 20,
+			MaxUploadBytes:    64 << 20,
+			CompressMinBytes:  1 << 10,
+			MaxBatchGet:       100,
+		},
+		TLS:   TLS{MinVersion: "1.2"},
+		Log:   Log{Format: "json", Level: "info", SlowQuery: Duration(200 * time.Millisecond)},
+		Cache: Cache{Size: 10000},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Accept-Language", "Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "Prefer", "X-Request-ID", "X-Tenant-ID"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Idempotency: Idempotency{TTL: Duration(24 * time.Hour)},
+		Tracing:     Tracing{Protocol: "grpc", SampleRatio: 1, ServiceName: "golangdb"},
+		Storage: Storage{
+			Driver:        StorageLocal,
+			Dir:           "media",
+			URLExpiry:     Duration(time.Hour),
+			MaxImageBytes: 10 << 20,
+		},
+		Jobs:    Jobs{Workers: 4, QueueSize: 100, Retention: Duration(7 * 24 * time.Hour)},
+		Catalog: Catalog{Locale: "en"},
+		Secrets: Secrets{Dir: "/run/secrets", VaultMount: "secret"},
+
+		Environment: EnvProduction,
+	}
+}
+
+// Load builds the configuration from the defaults, then environment
+// variables, then the file at path if path is not empty, each overriding
+// the one before. The result is validated.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	if path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.ResolveSecrets(context.Background(), cfg.SecretProviders()); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// SecretProviders returns the providers of the secrets c may reference:
+// env, file, and vault if VaultAddr is set.
+func (c Config) SecretProviders() secrets.Providers {
+	providers := secrets.Providers{
+		"env":  secrets.Env{},
+		"file": secrets.Files{Dir: c.Secrets.Dir},
+	}
+	if c.Secrets.VaultAddr != "" {
+		providers["vault"] = &secrets.Vault{
+			Addr:      c.Secrets.VaultAddr,
+			Token:     string(c.Secrets.VaultToken),
+			Mount:     c.Secrets.VaultMount,
+			Namespace: c.Secrets.VaultNamespace,
+		}
+	}
+	return providers
+}
+
+// ResolveSecrets replaces the settings kept as secrets with the secrets
+// their references name, looked up in providers.
+func (c *Config) ResolveSecrets(ctx context.Context, providers secrets.Providers) error {
+	if c.DB.UserSecret != "" {
+		user, err := providers.Resolve(ctx, c.DB.UserSecret)
+		if err != nil {
+			return fmt.Errorf("config: db.user_secret (DB_USER_SECRET): %w", err)
+		}
+		c.DB.User = user
+	}
+	if c.DB.PasswordSecret != "" {
+		password, err := providers.Resolve(ctx, c.DB.PasswordSecret)
+		if err != nil {
+			return fmt.Errorf("config: db.password_secret (DB_PASSWORD_SECRET): %w", err)
+		}
+		c.DB.Password = Secret(password)
+	}
+	return nil
+}
+
+// envVars maps each environment variable to the setting it overrides.
+func (c *Config) envVars() map[string]any {
+	return map[string]any{
+		"DB_DRIVER":                  &c.DB.Driver,
+		"DB_PATH":                    &c.DB.Path,
+		"DB_HOST":                    &c.DB.Host,
+		"DB_PORT":                    &c.DB.Port,
+		"DB_USER":                    &c.DB.User,
+		"DB_PASSWORD":                &c.DB.Password,
+		"DB_USER_SECRET":             &c.DB.UserSecret,
+		"DB_PASSWORD_SECRET":         &c.DB.PasswordSecret,
+		"DB_NAME":                    &c.DB.Name,
+		"DB_SSLMODE":                 &c.DB.SSLMode,
+		"DB_STATEMENT_TIMEOUT":       &c.DB.StatementTimeout,
+		"DB_BREAKER_FAILURES":        &c.DB.BreakerFailures,
+		"DB_BREAKER_COOLDOWN":        &c.DB.BreakerCooldown,
+		"DB_MIGRATE":                 &c.DB.Migrate,
+		"DB_MAX_OPEN_CONNS":          &c.DB.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":          &c.DB.MaxIdleConns,
+		"DB_CONN_MAX_LIFETIME":       &c.DB.ConnMaxLifetime,
+		"DB_PING_TIMEOUT":            &c.DB.PingTimeout,
+		"DB_CONNECT_TIMEOUT":         &c.DB.ConnectTimeout,
+		"DB_REPLICAS":                &c.DB.Replicas,
+		"DB_REPLICA_MAX_LAG":         &c.DB.ReplicaMaxLag,
+		"DB_REPLICA_CHECK_INTERVAL":  &c.DB.ReplicaCheckInterval,
+		"HTTP_ADDR":                  &c.HTTP.Addr,
+		"HTTP_READ_HEADER_TIMEOUT":   &c.HTTP.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":          &c.HTTP.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":         &c.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":          &c.HTTP.IdleTimeout,
+		"HTTP_REQUEST_TIMEOUT":       &c.HTTP.RequestTimeout,
+		"HTTP_LONG_REQUEST_TIMEOUT":  &c.HTTP.LongRequestTimeout,
+		"HTTP_MAX_BODY_BYTES":        &c.HTTP.MaxBodyBytes,
+		"HTTP_MAX_UPLOAD_BYTES":      &c.HTTP.MaxUploadBytes,
+		"HTTP_COMPRESS_MIN_BYTES":    &c.HTTP.CompressMinBytes,
+		"HTTP_MAX_BATCH_GET":         &c.HTTP.MaxBatchGet,
+		"HTTP_CACHE_MAX_AGE":         &c.HTTP.CacheMaxAge,
+		"TLS_CERT_FILE":              &c.TLS.CertFile,
+		"TLS_KEY_FILE":               &c.TLS.KeyFile,
+		"TLS_AUTOCERT_DOMAINS":       &c.TLS.AutocertDomains,
+		"TLS_AUTOCERT_CACHE_DIR":     &c.TLS.AutocertCacheDir,
+		"TLS_AUTOCERT_EMAIL":         &c.TLS.AutocertEmail,
+		"TLS_AUTOCERT_DIRECTORY_URL": &c.TLS.AutocertDirectoryURL,
+		"TLS_REDIRECT_ADDR":          &c.TLS.RedirectAddr,
+		"TLS_MIN_VERSION":            &c.TLS.MinVersion,
+		"GRPC_ADDR":                  &c.GRPC.Addr,
+		"LOG_FORMAT":                 &c.Log.Format,
+		"LOG_LEVEL":                  &c.Log.Level,
+		"LOG_SLOW_QUERY":             &c.Log.SlowQuery,
+		"LOG_SQL_PARAMS":             &c.Log.SQLParams,
+		"ENVIRONMENT":                &c.Environment,
+		"RATE_LIMIT_READS":           &c.RateLimit.ReadsPerMinute,
+		"RATE_LIMIT_WRITES":          &c.RateLimit.WritesPerMinute,
+		"RATE_LIMIT_REDIS_URL":       &c.RateLimit.RedisURL,
+		"CACHE_TTL":                  &c.Cache.TTL,
+		"CACHE_SIZE":                 &c.Cache.Size,
+		"CACHE_REDIS_URL":            &c.Cache.RedisURL,
+		"STORAGE_DRIVER":             &c.Storage.Driver,
+		"STORAGE_DIR":                &c.Storage.Dir,
+		"STORAGE_S3_ENDPOINT":        &c.Storage.S3Endpoint,
+		"STORAGE_S3_REGION":          &c.Storage.S3Region,
+		"STORAGE_S3_BUCKET":          &c.Storage.S3Bucket,
+		"STORAGE_S3_ACCESS_KEY":      &c.Storage.S3AccessKey,
+		"STORAGE_S3_SECRET_KEY":      &c.Storage.S3SecretKey,
+		"STORAGE_S3_INSECURE":        &c.Storage.S3Insecure,
+		"STORAGE_PUBLIC_URL":         &c.Storage.PublicURL,
+		"STORAGE_URL_EXPIRY":         &c.Storage.URLExpiry,
+		"STORAGE_MAX_IMAGE_BYTES":    &c.Storage.MaxImageBytes,
+		"JOBS_WORKERS":               &c.Jobs.Workers,
+		"JOBS_QUEUE_SIZE":            &c.Jobs.QueueSize,
+		"JOBS_RETENTION":             &c.Jobs.Retention,
+		"CATALOG_LOCALE":             &c.Catalog.Locale,
+		"SECRETS_DIR":                &c.Secrets.Dir,
+		"VAULT_ADDR":                 &c.Secrets.VaultAddr,
+		"VAULT_TOKEN":                &c.Secrets.VaultToken,
+		"VAULT_MOUNT":                &c.Secrets.VaultMount,
+		"VAULT_NAMESPACE":            &c.Secrets.VaultNamespace,
+		"CORS_ALLOWED_ORIGINS":       &c.CORS.AllowedOrigins,
+		"CORS_ALLOWED_METHODS":       &c.CORS.AllowedMethods,
+		"CORS_ALLOWED_HEADERS":       &c.CORS.AllowedHeaders,
+		"CORS_ALLOW_CREDENTIALS":     &c.CORS.AllowCredentials,
+		"CORS_MAX_AGE":               &c.CORS.MaxAge,
+		"IDEMPOTENCY_TTL":            &c.Idempotency.TTL,
+		"TRACING_ENDPOINT":           &c.Tracing.Endpoint,
+		"TRACING_PROTOCOL":           &c.Tracing.Protocol,
+		"TRACING_SAMPLE_RATIO":       &c.Tracing.SampleRatio,
+		"TRACING_SERVICE_NAME":       &c.Tracing.ServiceName,
+		"CURSOR_SECRET":              &c.CursorSecret,
+		"JWT_SECRET":                 &c.JWTSecret,
+		"FEATURE_FLAGS":              &c.Flags,
+	}
+}
+
+func (c *Config) applyEnv() error {
+	for name, dst := range c.envVars() {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		switch dst := dst.(type) {
+		case *string:
+			*dst = v
+		case *Secret:
+			*dst = Secret(v)
+		case *int:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("config: %s must be an integer, got %q", name, v)
+			}
+			*dst = n
+		case *float64:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("config: %s must be a number, got %q", name, v)
+			}
+			*dst = f
+		case *[]string:
+			*dst = splitList(v)
+		case *bool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("config: %s must be true or false, got %q", name, v)
+			}
+			*dst = b
+		case *Duration:
+			if err := dst.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("config: %s must be a duration such as 30s, got %q", name, v)
+			}
+		case *map[string]bool:
+			// name turns a flag on, name=false off
+			flags := map[string]bool{}
+			for _, item := range splitList(v) {
+				key, value, found := strings.Cut(item, "=")
+				enabled := true
+				if found {
+					b, err := strconv.ParseBool(strings.TrimSpace(value))
+					if err != nil {
+						return fmt.Errorf("config: %s must list name or name=true|false, got %q", name, item)
+					}
+					enabled = b
+				}
+				flags[strings.TrimSpace(key)] = enabled
+			}
+			*dst = flags
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma-separated env var value, dropping blanks.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// applyFile overlays the settings present in a .json, .yaml or .yml file.
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("config: %s: unsupported file type, use .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+var (
+	sslModes   = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	logFormats = []string{"json", "text"}
+	logLevels  = []string{"debug", "info", "warn", "error"}
+
+	tracingProtocols = []string{"grpc", "http/protobuf"}
+	tlsVersions      = []string{"1.2", "1.3"}
+)
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	switch c.DB.Driver {
+	case DriverSQLite:
+		if c.DB.Path == "" {
+			errs = append(errs, errors.New("db.path (DB_PATH) is required for sqlite"))
+		}
+	case DriverPostgres, DriverMySQL:
+		if c.DB.Host == "" {
+			errs = append(errs, errors.New("db.host (DB_HOST) is required"))
+		}
+		if c.DB.Port < 0 || c.DB.Port > 65535 {
+			errs = append(errs, fmt.Errorf("db.port (DB_PORT) must be between 1 and 65535, or 0 for the default, got %d", c.DB.Port))
+		}
+		if c.DB.User == "" {
+			errs = append(errs, errors.New("db.user (DB_USER) is required"))
+		}
+		if c.DB.Name == "" {
+			errs = append(errs, errors.New("db.name (DB_NAME) is required"))
+		}
+		if c.DB.Driver == DriverPostgres && !slices.Contains(sslModes, c.DB.SSLMode) {
+			errs = append(errs, fmt.Errorf("db.sslmode (DB_SSLMODE) must be one of %s, got %q", strings.Join(sslModes, ", "), c.DB.SSLMode))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("db.driver (DB_DRIVER) must be one of %s, %s, %s, got %q", DriverPostgres, DriverMySQL, DriverSQLite, c.DB.Driver))
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("db.max_open_conns (DB_MAX_OPEN_CONNS) must not be negative, got %d", c.DB.MaxOpenConns))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("db.max_idle_conns (DB_MAX_IDLE_CONNS) must not be negative, got %d", c.DB.MaxIdleConns))
+	}
+	if c.DB.PingTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("db.ping_timeout (DB_PING_TIMEOUT) must be positive, got %s", time.Duration(c.DB.PingTimeout)))
+	}
+	if c.DB.BreakerFailures < 0 {
+		errs = append(errs, fmt.Errorf("db.breaker_failures (DB_BREAKER_FAILURES) must not be negative, got %d", c.DB.BreakerFailures))
+	}
+	if c.DB.BreakerFailures > 0 && c.DB.BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("db.breaker_cooldown (DB_BREAKER_COOLDOWN) must be positive, got %s", time.Duration(c.DB.BreakerCooldown)))
+	}
+	errs = append(errs, c.DB.validateReplicas()...)
+	for _, ref := range []struct{ name, value string }{
+		{"db.user_secret (DB_USER_SECRET)", c.DB.UserSecret},
+		{"db.password_secret (DB_PASSWORD_SECRET)", c.DB.PasswordSecret},
+	} {
+		if strings.HasPrefix(ref.value, "vault:") && c.Secrets.VaultAddr == "" {
+			errs = append(errs, fmt.Errorf("%s refers to Vault, which needs secrets.vault_addr (VAULT_ADDR)", ref.name))
+		}
+	}
+	if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("http.addr (HTTP_ADDR) must be host:port or :port, got %q", c.HTTP.Addr))
+	}
+	if c.HTTP.MaxBodyBytes < 1 {
+		errs = append(errs, fmt.Errorf("http.max_body_bytes (HTTP_MAX_BODY_BYTES) must be at least 1, got %d", c.HTTP.MaxBodyBytes))
+	}
+	if c.HTTP.MaxUploadBytes < c.HTTP.MaxBodyBytes {
+		errs = append(errs, fmt.Errorf("http.max_upload_bytes (HTTP_MAX_UPLOAD_BYTES) must be at least http.max_body_bytes, got %d", c.HTTP.MaxUploadBytes))
+	}
+	if c.HTTP.CompressMinBytes < 0 {
+		errs = append(errs, fmt.Errorf("http.compress_min_bytes (HTTP_COMPRESS_MIN_BYTES) must not be negative, got %d", c.HTTP.CompressMinBytes))
+	}
+	if c.HTTP.MaxBatchGet < 1 {
+		errs = append(errs, fmt.Errorf("http.max_batch_get (HTTP_MAX_BATCH_GET) must be at least 1, got %d", c.HTTP.MaxBatchGet))
+	}
+	errs = append(errs, c.TLS.validate()...)
+	if _, _, err := net.SplitHostPort(c.GRPC.Addr); c.GRPC.Addr != "" && err != nil {
+		errs = append(errs, fmt.Errorf("grpc.addr (GRPC_ADDR) must be host:port or :port, got %q", c.GRPC.Addr))
+	}
+	if !slices.Contains(logFormats, c.Log.Format) {
+		errs = append(errs, fmt.Errorf("log.format (LOG_FORMAT) must be one of %s, got %q", strings.Join(logFormats, ", "), c.Log.Format))
+	}
+	if !slices.Contains(logLevels, c.Log.Level) {
+		errs = append(errs, fmt.Errorf("log.level (LOG_LEVEL) must be one of %s, got %q", strings.Join(logLevels, ", "), c.Log.Level))
+	}
+	if c.Environment == "" {
+		errs = append(errs, errors.New("environment (ENVIRONMENT) is required"))
+	}
+	if c.Log.SQLParams && c.Environment == EnvProduction {
+		errs = append(errs, errors.New("log.sql_params (LOG_SQL_PARAMS) is refused in production; set environment (ENVIRONMENT) to another, such as development"))
+	}
+	if c.RateLimit.ReadsPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.reads_per_minute (RATE_LIMIT_READS) must not be negative, got %d", c.RateLimit.ReadsPerMinute))
+	}
+	if c.RateLimit.WritesPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.writes_per_minute (RATE_LIMIT_WRITES) must not be negative, got %d", c.RateLimit.WritesPerMinute))
+	}
+	if c.Cache.Size < 1 {
+		errs = append(errs, fmt.Errorf("cache.size (CACHE_SIZE) must be at least 1, got %d", c.Cache.Size))
+	}
+	errs = append(errs, c.CORS.validate()...)
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency.ttl (IDEMPOTENCY_TTL) must be positive, got %s", time.Duration(c.Idempotency.TTL)))
+	}
+	errs = append(errs, c.Tracing.validate()...)
+	errs = append(errs, c.Storage.validate()...)
+	if c.Storage.MaxImageBytes > c.HTTP.MaxUploadBytes {
+		errs = append(errs, fmt.Errorf("storage.max_image_bytes (STORAGE_MAX_IMAGE_BYTES) must not exceed http.max_upload_bytes, got %d", c.Storage.MaxImageBytes))
+	}
+	if c.Jobs.Workers < 1 {
+		errs = append(errs, fmt.Errorf("jobs.workers (JOBS_WORKERS) must be at least 1, got %d", c.Jobs.Workers))
+	}
+	if c.Jobs.QueueSize < 0 {
+		errs = append(errs, fmt.Errorf("jobs.queue_size (JOBS_QUEUE_SIZE) must not be negative, got %d", c.Jobs.QueueSize))
+	}
+	if c.Jobs.Retention <= 0 {
+		errs = append(errs, fmt.Errorf("jobs.retention (JOBS_RETENTION) must be positive, got %s", time.Duration(c.Jobs.Retention)))
+	}
+	if tag, err := language.Parse(c.Catalog.Locale); err != nil || tag == language.Und {
+		errs = append(errs, fmt.Errorf("catalog.locale (CATALOG_LOCALE) must be a BCP 47 language tag such as en or pt-BR, got %q", c.Catalog.Locale))
+	}
+	for _, t := range []struct {
+		name string
+		d    Duration
+	}{
+		{"db.statement_timeout (DB_STATEMENT_TIMEOUT)", c.DB.StatementTimeout},
+		{"db.conn_max_lifetime (DB_CONN_MAX_LIFETIME)", c.DB.ConnMaxLifetime},
+		{"db.connect_timeout (DB_CONNECT_TIMEOUT)", c.DB.ConnectTimeout},
+		{"cache.ttl (CACHE_TTL)", c.Cache.TTL},
+		{"log.slow_query (LOG_SLOW_QUERY)", c.Log.SlowQuery},
+		{"cors.max_age (CORS_MAX_AGE)", c.CORS.MaxAge},
+		{"http.read_header_timeout (HTTP_READ_HEADER_TIMEOUT)", c.HTTP.ReadHeaderTimeout},
+		{"http.read_timeout (HTTP_READ_TIMEOUT)", c.HTTP.ReadTimeout},
+		{"http.write_timeout (HTTP_WRITE_TIMEOUT)", c.HTTP.WriteTimeout},
+		{"http.idle_timeout (HTTP_IDLE_TIMEOUT)", c.HTTP.IdleTimeout},
+		{"http.request_timeout (HTTP_REQUEST_TIMEOUT)", c.HTTP.RequestTimeout},
+		{"http.long_request_timeout (HTTP_LONG_REQUEST_TIMEOUT)", c.HTTP.LongRequestTimeout},
+		{"http.cache_max_age (HTTP_CACHE_MAX_AGE)", c.HTTP.CacheMaxAge},
+	} {
+		if t.d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", t.name, time.Duration(t.d)))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config: invalid configuration:\n  %w", joinIndented(errs))
+	}
+	return nil
+}
+
+// validateReplicas reports the invalid read replica settings.
+func (c DB) validateReplicas() []error {
+	if len(c.Replicas) == 0 {
+		return nil
+	}
+	var errs []error
+	if c.Driver == DriverSQLite {
+		errs = append(errs, errors.New("db.replicas (DB_REPLICAS) are not supported for sqlite"))
+	}
+	for _, addr := range c.Replicas {
+		host, port := addr, "0"
+		if h, p, err := net.SplitHostPort(addr); err == nil {
+			host, port = h, p
+		}
+		if n, err := strconv.Atoi(port); host == "" || strings.ContainsAny(host, "/:") || err != nil || n < 0 || n > 65535 {
+			errs = append(errs, fmt.Errorf("db.replicas (DB_REPLICAS) must be host or host:port addresses, got %q", addr))
+		}
+	}
+	if c.ReplicaMaxLag <= 0 {
+		errs = append(errs, fmt.Errorf("db.replica_max_lag (DB_REPLICA_MAX_LAG) must be positive, got %s", time.Duration(c.ReplicaMaxLag)))
+	}
+	if c.ReplicaCheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("db.replica_check_interval (DB_REPLICA_CHECK_INTERVAL) must be positive, got %s", time.Duration(c.ReplicaCheckInterval)))
+	}
+	return errs
+}
+
+// validate reports the invalid CORS settings.
+func (c CORS) validate() []error {
+	var errs []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = append(errs, errors.New("cors.allowed_origins (CORS_ALLOWED_ORIGINS) can't be * when cors.allow_credentials (CORS_ALLOW_CREDENTIALS) is set"))
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins (CORS_ALLOWED_ORIGINS) must hold * or origins such as https://example.com, got %q", origin))
+		}
+	}
+	if len(c.AllowedOrigins) > 0 && len(c.AllowedMethods) == 0 {
+		errs = append(errs, errors.New("cors.allowed_methods (CORS_ALLOWED_METHODS) is required when cors.allowed_origins is set"))
+	}
+	return errs
+}
+
+// validate reports the invalid TLS settings.
+func (c TLS) validate() []error {
+	var errs []error
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file (TLS_CERT_FILE) and tls.key_file (TLS_KEY_FILE) must be set together"))
+	}
+	if c.CertFile != "" && len(c.AutocertDomains) > 0 {
+		errs = append(errs, errors.New("tls.cert_file (TLS_CERT_FILE) and tls.autocert_domains (TLS_AUTOCERT_DOMAINS) can't both be set"))
+	}
+	if len(c.AutocertDomains) > 0 && c.AutocertCacheDir == "" {
+		errs = append(errs, errors.New("tls.autocert_cache_dir (TLS_AUTOCERT_CACHE_DIR) is required with tls.autocert_domains"))
+	}
+	if c.AutocertDirectoryURL != "" {
+		u, err := url.Parse(c.AutocertDirectoryURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tls.autocert_directory_url (TLS_AUTOCERT_DIRECTORY_URL) must be an https URL, got %q", c.AutocertDirectoryURL))
+		}
+	}
+	if _, _, err := net.SplitHostPort(c.RedirectAddr); c.RedirectAddr != "" && err != nil {
+		errs = append(errs, fmt.Errorf("tls.redirect_addr (TLS_REDIRECT_ADDR) must be host:port or :port, got %q", c.RedirectAddr))
+	}
+	if c.RedirectAddr != "" && !c.Enabled() {
+		errs = append(errs, errors.New("tls.redirect_addr (TLS_REDIRECT_ADDR) needs a certificate, from tls.cert_file or tls.autocert_domains"))
+	}
+	if !slices.Contains(tlsVersions, c.MinVersion) {
+		errs = append(errs, fmt.Errorf("tls.min_version (TLS_MIN_VERSION) must be one of %s, got %q", strings.Join(tlsVersions, ", "), c.MinVersion))
+	}
+	return errs
+}
+
+// validate reports the invalid tracing settings.
+func (c Tracing) validate() []error {
+	var errs []error
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint (TRACING_ENDPOINT) must be a URL such as http://localhost:4317, got %q", c.Endpoint))
+		}
+	}
+	if !slices.Contains(tracingProtocols, c.Protocol) {
+		errs = append(errs, fmt.Errorf("tracing.protocol (TRACING_PROTOCOL) must be one of %s, got %q", strings.Join(tracingProtocols, ", "), c.Protocol))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1, got %g", c.SampleRatio))
+	}
+	if c.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.service_name (TRACING_SERVICE_NAME) is required"))
+	}
+	return errs
+}
+
+// validate reports the invalid storage settings.
+func (c Storage) validate() []error {
+	var errs []error
+	switch c.Driver {
+	case StorageLocal:
+		if c.Dir == "" {
+			errs = append(errs, errors.New("storage.dir (STORAGE_DIR) is required for the local driver"))
+		}
+	case StorageS3:
+		if c.S3Endpoint == "" || strings.Contains(c.S3Endpoint, "/") {
+			errs = append(errs, fmt.Errorf("storage.s3_endpoint (STORAGE_S3_ENDPOINT) must be host or host:port for the s3 driver, got %q", c.S3Endpoint))
+		}
+		if c.S3Bucket == "" {
+			errs = append(errs, errors.New("storage.s3_bucket (STORAGE_S3_BUCKET) is required for the s3 driver"))
+		}
+		if c.URLExpiry <= 0 || time.Duration(c.URLExpiry) > 7*24*time.Hour {
+			errs = append(errs, fmt.Errorf("storage.url_expiry (STORAGE_URL_EXPIRY) must be positive and at most 168h, got %s", time.Duration(c.URLExpiry)))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.driver (STORAGE_DRIVER) must be one of %s, %s, got %q", StorageLocal, StorageS3, c.Driver))
+	}
+	if c.PublicURL != "" {
+		u, err := url.Parse(c.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("storage.public_url (STORAGE_PUBLIC_URL) must be a URL such as https://cdn.example.com/images/, got %q", c.PublicURL))
+		}
+	}
+	if c.MaxImageBytes < 1 {
+		errs = append(errs, fmt.Errorf("storage.max_image_bytes (STORAGE_MAX_IMAGE_BYTES) must be at least 1, got %d", c.MaxImageBytes))
+	}
+	return errs
+}
+
+// DSN returns the PostgreSQL connection string for c.
+func (c DB) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		dsnQuote(c.Host), c.PortOrDefault(), dsnQuote(c.User), dsnQuote(string(c.Password)), dsnQuote(c.Name), dsnQuote(c.SSLMode))
+}
+
+// dsnQuote quotes a value for a libpq key=value connection string.
+func dsnQuote(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// joinIndented joins errors one per line, for a readable startup message.
+func joinIndented(errs []error) error {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "\n  "))
+}
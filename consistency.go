@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// primaryPinDuration is how long after a write a client's reads stay on
+// the primary, comfortably longer than normal replication lag.
+const primaryPinDuration = 5 * time.Second
+
+// recentWriters remembers which clients wrote recently, so their next
+// reads see their own writes even if the replica hasn't caught up.
+var recentWriters = &writerPins{until: map[string]time.Time{}}
+
+type writerPins struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func (p *writerPins) pin(client string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for c, t := range p.until {
+		if now.After(t) {
+			delete(p.until, c)
+		}
+	}
+	p.until[client] = now.Add(primaryPinDuration)
+}
+
+func (p *writerPins) pinned(client string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Now().Before(p.until[client])
+}
+
+// clientKey identifies a client for read-your-writes: its tenant if it
+// names one, otherwise its address.
+func clientKey(r *http.Request) string {
+	if tenant := namedTenant(r); tenant != "" {
+		return "tenant:" + tenant
+	}
+	return "addr:" + remoteHost(r)
+}
+
+// remoteHost returns the address r came from, without its port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// readDBFor returns the connection a read-only handler should use. Reads go
+// to a healthy replica unless the client asked for ?consistency=strong or
+// wrote within primaryPinDuration.
+func readDBFor(r *http.Request) *gorm.DB {
+	if _, inTx := r.Context().Value(txKey{}).(*gorm.DB); inTx {
+		return dbFor(r)
+	}
+	if r.URL.Query().Get("consistency") != "strong" && !recentWriters.pinned(clientKey(r)) {
+		if replica := depsFor(r).replicas.pick(); replica != nil {
+			return replica.WithContext(r.Context())
+		}
+	}
+	return dbFor(r)
+}
+
+// pinWriters is middleware that pins a client to the primary after every
+// successful write it makes.
+func pinWriters(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status < 400 {
+			recentWriters.pin(clientKey(r))
+		}
+	})
+}
+
+// statusRecorder remembers the status code and body size written through
+// it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Flush passes through so streamed responses still stream.
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
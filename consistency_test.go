@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/testutil"
+)
+
+// A replica that hasn't caught up serves reads, except a strong read or one
+// by a client that just wrote, which go to the primary.
+func TestReadYourWrites(t *testing.T) {
+	// An empty database stands for a replica lagging behind every write
+	lagging := &replica{name: "replica-1"}
+	lagging.db.Store(testutil.DB(t))
+	lagging.healthy.Store(true)
+	c, _ := newTestAPI(t, func(d *deps) { d.replicas = &replicaSet{replicas: []*replica{lagging}} })
+	recentWriters = &writerPins{until: map[string]time.Time{}}
+
+	writer := c.WithHeader("X-Tenant-ID", "1")
+	var p Product
+	writer.Post("/api/v1/products", map[string]any{"name": "Hammer", "price": "9.99"}).Expect(201).Decode(&p)
+	path := fmt.Sprintf("/api/v1/products/%d", p.ID)
+
+	// Other clients read the replica unless they ask otherwise
+	expectProblem(t, c.Get(path), 404, "product_not_found")
+	c.Get(path + "?consistency=strong").Expect(200)
+	writer.Get(path).Expect(200)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// routeContentTypes overrides the accepted request media types for routes
+// that don't take JSON, keyed by *mux.Route.
+var routeContentTypes sync.Map
+
+// acceptContentTypes declares the media types a non-JSON route accepts.
+func acceptContentTypes(route *mux.Route, types ...string) {
+	routeContentTypes.Store(route, types)
+}
+
+// requireContentType answers 415 when a POST, PUT or PATCH with a body
+// doesn't declare a media type its route accepts; application/json unless
+// overridden with acceptContentTypes. A charset, if given, must be UTF-8,
+// the only one the API decodes. It runs as mux middleware, after routing,
+// so the matched route is known.
+func requireContentType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		accepted := []string{"application/json"}
+		if route := mux.CurrentRoute(r); route != nil {
+			if types, ok := routeContentTypes.Load(route); ok {
+				accepted = types.([]string)
+			}
+		}
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err == nil && slices.Contains(accepted, mediaType) {
+			if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+				writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_charset", charset)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_media_type", strings.Join(accepted, " or "))
+	})
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestRequireContentType(t *testing.T) {
+	c, _ := newTestAPI(t)
+	body := `{"name": "Hammer", "price": "9.99"}`
+
+	form := c.WithHeader("Content-Type", "application/x-www-form-urlencoded")
+	expectProblem(t, form.Post("/api/v1/products", "name=Hammer&price=9.99"), 415, "unsupported_media_type")
+	expectProblem(t, c.WithHeader("Content-Type", "text/plain").Post("/api/v1/products", body), 415, "unsupported_media_type")
+	expectProblem(t, c.WithHeader("Content-Type", "application/json; charset=latin1").Post("/api/v1/products", body), 415, "unsupported_charset")
+
+	c.WithHeader("Content-Type", "application/json; charset=utf-8").Post("/api/v1/products", body).Expect(201)
+	// PATCH also takes merge patches, but not other media types
+	c.WithHeader("Content-Type", "application/merge-patch+json").Patch("/api/v1/products/1", `{"quantity": 3}`).Expect(200)
+	expectProblem(t, form.Patch("/api/v1/products/1", "quantity=3"), 415, "unsupported_media_type")
+	// Requests without a body need no media type
+	form.Post("/api/v1/products/1/restore", nil).Expect(200)
+}
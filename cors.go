@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mjpvl-ai/golangdb/config"
+)
+
+// corsExposedHeaders are the response headers browser clients may read
+// besides the CORS-safelisted ones.
+var corsExposedHeaders = []string{
+	"Content-Disposition", "ETag", idempotentReplayedHeader, "Location",
+	"Preference-Applied", "Retry-After", requestIDHeader, "X-Truncated",
+}
+
+// corsPolicy lets browsers on other origins call the API, as configured by
+// config.CORS.
+type corsPolicy struct {
+	cfg       config.CORS
+	anyOrigin bool
+	methods   string
+	headers   []string
+	maxAge    string
+}
+
+// newCORSPolicy returns the policy configured by cfg, or nil if CORS is
+// off.
+func newCORSPolicy(cfg config.CORS) *corsPolicy {
+	if len(cfg.AllowedOrigins) == 0 {
+		return nil
+	}
+	c := &corsPolicy{
+		cfg:       cfg,
+		anyOrigin: slices.Contains(cfg.AllowedOrigins, "*"),
+		methods:   strings.Join(cfg.AllowedMethods, ", "),
+		maxAge:    strconv.Itoa(int(time.Duration(cfg.MaxAge).Seconds())),
+	}
+	for _, h := range cfg.AllowedHeaders {
+		c.headers = append(c.headers, http.CanonicalHeaderKey(h))
+	}
+	return c
+}
+
+// register routes the preflight requests of router to c, which mux would
+// otherwise answer with 405 before any middleware runs.
+func (c *corsPolicy) register(router *mux.Router) {
+	router.Methods(http.MethodOptions).MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return isPreflight(r)
+	}).HandlerFunc(c.preflight)
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// allowOrigin sets the headers that let the request's origin read the
+// response, if it is allowed, and reports whether it is.
+func (c *corsPolicy) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if !c.anyOrigin {
+		// The response depends on the origin even when it isn't allowed
+		w.Header().Add("Vary", "Origin")
+	}
+	switch {
+	case origin == "":
+		return false
+	case c.anyOrigin:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	case c.allowsOrigin(origin):
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	default:
+		return false
+	}
+	if c.cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// allowsOrigin reports whether origin may call the API.
+func (c *corsPolicy) allowsOrigin(origin string) bool {
+	return c.anyOrigin || slices.ContainsFunc(c.cfg.AllowedOrigins, func(o string) bool { return strings.EqualFold(o, origin) })
+}
+
+// middleware lets allowed origins read the responses to their requests;
+// preflights are left to preflight. It runs as mux middleware, first, so
+// that errors from the rest of the chain are readable too.
+func (c *corsPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isPreflight(r) && c.allowOrigin(w, r) {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Answer a CORS preflight request. A disallowed origin, method or header
+// gets no CORS headers, which makes the browser refuse the actual request.
+func (c *corsPolicy) preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+	if !c.allows(r) || !c.allowOrigin(w, r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", c.methods)
+	if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+	}
+	w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allows reports whether the method and headers of preflight r are allowed.
+func (c *corsPolicy) allows(r *http.Request) bool {
+	if !slices.Contains(c.cfg.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+		return false
+	}
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" && !slices.Contains(c.headers, http.CanonicalHeaderKey(h)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+// Package database opens the GORM connection for the configured driver.
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/secrets"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Backoff between connection attempts: it starts at minRetryBackoff and
+// doubles up to maxRetryBackoff.
+const (
+	minRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff = 10 * time.Second
+)
+
+// Connect opens the database described by cfg like Open, retrying with
+// exponential backoff for up to cfg.ConnectTimeout while it can't be
+// reached, as happens when the service starts alongside its database. It
+// gives up early if ctx is done.
+func Connect(ctx context.Context, cfg config.DB, gormConfig *gorm.Config) (*gorm.DB, error) {
+	deadline := time.Now().Add(time.Duration(cfg.ConnectTimeout))
+	backoff := minRetryBackoff
+	for attempt := 1; ; attempt++ {
+		// A fresh copy each time, as gorm.Open fills in the one it is given
+		attemptConfig := *gormConfig
+		db, err := Open(cfg, &attemptConfig)
+		var cfgErr *configError
+		if err == nil || errors.As(err, &cfgErr) {
+			return db, err
+		}
+		wait := min(backoff, time.Until(deadline))
+		if wait <= 0 {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "database not ready, retrying", "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ctx.Err(), err)
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// Open connects to the database described by cfg, sizes its connection
+// pool, and checks that it answers, within cfg.PingTimeout if set. Its
+// errors never quote the password, as a driver's might.
+func Open(cfg config.DB, gormConfig *gorm.Config) (*gorm.DB, error) {
+	db, err := open(cfg, gormConfig)
+	return db, secrets.Redact(err, string(cfg.Password))
+}
+
+func open(cfg config.DB, gormConfig *gorm.Config) (*gorm.DB, error) {
+	dialector, err := dialectorFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Pinged below, with a timeout
+	gormConfig.DisableAutomaticPing = true
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if InMemory(cfg) {
+		// Every connection to :memory: is a separate, empty database, so
+		// keep exactly one open for the life of the process
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxIdleTime(0)
+		sqlDB.SetConnMaxLifetime(0)
+	} else {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	}
+	ctx := context.Background()
+	if cfg.PingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.PingTimeout))
+		defer cancel()
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("database: ping: %w", err)
+	}
+	return db, nil
+}
+
+// configError is a problem with the settings rather than the database, so
+// retrying can't help.
+type configError struct {
+	msg string
+}
+
+func (e *configError) Error() string { return e.msg }
+
+func dialectorFor(cfg config.DB) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DriverPostgres:
+		return postgres.Open(cfg.DSN()), nil
+	case config.DriverMySQL:
+		dsn := mysqldriver.NewConfig()
+		dsn.Net = "tcp"
+		dsn.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.PortOrDefault()))
+		dsn.User = cfg.User
+		dsn.Passwd = string(cfg.Password)
+		dsn.DBName = cfg.Name
+		dsn.ParseTime = true
+		dsn.Params = map[string]string{"charset": "utf8mb4"}
+		return mysql.Open(dsn.FormatDSN()), nil
+	case config.DriverSQLite:
+		return sqlite.Open(sqliteDSN(cfg.Path)), nil
+	}
+	return nil, &configError{fmt.Sprintf("database: unknown driver %q", cfg.Driver)}
+}
+
+// sqliteDSN adds the pragmas the service relies on: enforced foreign keys,
+// and for files WAL mode with a busy timeout, so concurrent requests wait
+// for each other instead of failing with "database is locked".
+func sqliteDSN(path string) string {
+	pragmas := []string{"foreign_keys(1)"}
+	if !isMemory(path) {
+		pragmas = append(pragmas, "journal_mode(WAL)", "busy_timeout(5000)")
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=" + strings.Join(pragmas, "&_pragma=")
+}
+
+// InMemory reports whether cfg is an in-memory SQLite database, which
+// starts out empty every time it is opened.
+func InMemory(cfg config.DB) bool {
+	return cfg.Driver == config.DriverSQLite && isMemory(cfg.Path)
+}
+
+func isMemory(path string) bool {
+	return path == ":memory:" || strings.Contains(path, "mode=memory")
+}
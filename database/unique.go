@@ -0,0 +1,42 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// UniqueViolation reports whether err is a violation of a unique index,
+// and of which: its name on PostgreSQL and MySQL, and on SQLite, which
+// doesn't name it, its columns, as in "products.tenant_id, products.sku".
+func UniqueViolation(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.ConstraintName, pgErr.Code == "23505"
+	}
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// Duplicate entry '1-A' for key 'products.idx_products_tenant_id_sku',
+		// with the table only from MySQL 8
+		i := strings.LastIndex(mysqlErr.Message, "for key '")
+		if mysqlErr.Number != 1062 || i < 0 {
+			return "", false
+		}
+		key := strings.TrimSuffix(mysqlErr.Message[i+len("for key '"):], "'")
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			key = key[dot+1:]
+		}
+		return key, true
+	}
+	if _, columns, ok := strings.Cut(err.Error(), "UNIQUE constraint failed: "); ok {
+		// Followed by the extended result code, as in " (2067)"
+		columns, _, _ = strings.Cut(columns, " (")
+		return columns, true
+	}
+	return "", false
+}
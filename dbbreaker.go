@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var dbBreakerOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "golangdb_db_breaker_open",
+	Help: "Whether the circuit breaker of a connection pool is open, failing its statements fast, by pool.",
+}, []string{"pool"})
+
+// errDBUnavailable is the error of a statement the circuit breaker failed
+// without sending it.
+var errDBUnavailable = errors.New("database unavailable: circuit breaker open")
+
+const dbBreakerAdmittedKey = "breaker:admitted"
+
+// dbBreaker is a GORM plugin that stops sending statements to a database
+// that is down or saturated, so that requests fail fast instead of piling
+// up on the connection pool. After failures statements in a row fail for
+// want of the database, by not getting a working connection or by timing
+// out, it opens: every statement fails at once with errDBUnavailable, and
+// its request with 503. Every cooldown while open it lets one statement
+// through to try again, and closes once one succeeds. Any other result,
+// even an error such as a constraint violation, shows the database is up;
+// statements cancelled by their caller count neither way.
+type dbBreaker struct {
+	pool     string
+	failures int
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	failed int
+	// retryAt is when the open breaker next lets a statement through; it
+	// is zero while the breaker is closed.
+	retryAt time.Time
+}
+
+func newDBBreaker(pool string, failures int, cooldown time.Duration) *dbBreaker {
+	dbBreakerOpen.WithLabelValues(pool).Set(0)
+	return &dbBreaker{pool: pool, failures: failures, cooldown: cooldown}
+}
+
+func (*dbBreaker) Name() string { return "breaker" }
+
+func (b *dbBreaker) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("breaker:before_create", b.before),
+		cb.Create().After("gorm:create").Register("breaker:after_create", b.after),
+		cb.Query().Before("gorm:query").Register("breaker:before_query", b.before),
+		cb.Query().After("gorm:query").Register("breaker:after_query", b.after),
+		cb.Update().Before("gorm:update").Register("breaker:before_update", b.before),
+		cb.Update().After("gorm:update").Register("breaker:after_update", b.after),
+		cb.Delete().Before("gorm:delete").Register("breaker:before_delete", b.before),
+		cb.Delete().After("gorm:delete").Register("breaker:after_delete", b.after),
+		cb.Row().Before("gorm:row").Register("breaker:before_row", b.before),
+		cb.Row().After("gorm:row").Register("breaker:after_row", b.after),
+		cb.Raw().Before("gorm:raw").Register("breaker:before_raw", b.before),
+		cb.Raw().After("gorm:raw").Register("breaker:after_raw", b.after),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allow reports whether a statement may be sent now, and if not, when the
+// next one may be.
+func (b *dbBreaker) allow() (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.retryAt.IsZero() {
+		return true, time.Time{}
+	}
+	now := time.Now()
+	if now.Before(b.retryAt) {
+		return false, b.retryAt
+	}
+	// This one tries again; the rest wait for its result or the next turn
+	b.retryAt = now.Add(b.cooldown)
+	return true, time.Time{}
+}
+
+// record counts the result of a statement that was sent, and returns when
+// the breaker next lets a statement through if that leaves it open.
+func (b *dbBreaker) record(ok bool) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ok {
+		b.failed = 0
+		if !b.retryAt.IsZero() {
+			b.retryAt = time.Time{}
+			dbBreakerOpen.WithLabelValues(b.pool).Set(0)
+		}
+		return time.Time{}
+	}
+	b.failed++
+	if b.failed >= b.failures {
+		if b.retryAt.IsZero() {
+			dbBreakerOpen.WithLabelValues(b.pool).Set(1)
+		}
+		b.retryAt = time.Now().Add(b.cooldown)
+	}
+	return b.retryAt
+}
+
+// markDBUnavailable has the request of ctx answered 503, as the database
+// couldn't take one of its statements, with Retry-After pointing at
+// retryAt unless it is zero.
+func markDBUnavailable(ctx context.Context, retryAt time.Time) {
+	if failure, ok := ctx.Value(dbFailureKey{}).(*dbFailure); ok {
+		failure.unavailable.Store(true)
+		if !retryAt.IsZero() {
+			failure.retryAt.Store(retryAt.UnixNano())
+		}
+	}
+}
+
+func (b *dbBreaker) before(tx *gorm.DB) {
+	if tx.Error != nil {
+		return
+	}
+	if ok, retryAt := b.allow(); !ok {
+		markDBUnavailable(tx.Statement.Context, retryAt)
+		tx.AddError(errDBUnavailable)
+		return
+	}
+	tx.InstanceSet(dbBreakerAdmittedKey, true)
+}
+
+func (b *dbBreaker) after(tx *gorm.DB) {
+	if _, ok := tx.InstanceGet(dbBreakerAdmittedKey); !ok {
+		return
+	}
+	// The caller's context, rather than the statement's own deadline
+	ctx := tx.Statement.Context
+	if parent, ok := tx.InstanceGet(statementParentKey); ok {
+		ctx = parent.(context.Context)
+	}
+	switch err := tx.Error; {
+	case err == nil || errors.Is(err, gorm.ErrRecordNotFound):
+		b.record(true)
+	case ctx.Err() != nil:
+		// The caller gave up, which says nothing of the database
+	case database.Unavailable(err):
+		markDBUnavailable(ctx, b.record(false))
+	case errors.Is(err, context.DeadlineExceeded):
+		// Timed out, which statementTimeout reports
+		b.record(false)
+	default:
+		b.record(true)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Once the breaker opens, requests get 503 with a Retry-After of the
+// cooldown left.
+func TestDBBreakerRetryAfter(t *testing.T) {
+	c, db := newTestAPI(t)
+	if err := db.Use(newDBBreaker("test", 2, 20*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// Every query fails for want of a connection
+	err := db.Callback().Query().Before("gorm:query").After("breaker:before_query").Register("test:fail", func(tx *gorm.DB) {
+		if tx.Error == nil {
+			tx.AddError(driver.ErrBadConn)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		c.Get("/api/v1/products/1").Expect(503)
+	}
+	r := c.Get("/api/v1/products/1")
+	expectProblem(t, r, 503, "database_unavailable")
+	if got, _ := strconv.Atoi(r.Header().Get("Retry-After")); got < 19 || got > 20 {
+		t.Errorf("Retry-After %q, want the 20s cooldown", r.Header().Get("Retry-After"))
+	}
+}
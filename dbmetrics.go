@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	dbQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "golangdb_db_queries_total",
+		Help: "Database statements executed, by operation, table, and result.",
+	}, []string{"operation", "table", "result"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "golangdb_db_query_duration_seconds",
+		Help:    "Database statement latency, by operation and table.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14), // 0.5ms .. ~4s
+	}, []string{"operation", "table"})
+)
+
+const dbMetricsStartKey = "metrics:start"
+
+// dbMetrics is a GORM plugin that counts and times every statement. The
+// labels are the GORM operation and the model's table, both fixed sets, so
+// cardinality stays bounded.
+type dbMetrics struct{}
+
+func (dbMetrics) Name() string { return "metrics" }
+
+func (dbMetrics) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", dbMetricsBefore),
+		cb.Create().After("gorm:create").Register("metrics:after_create", dbMetricsAfter("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", dbMetricsBefore),
+		cb.Query().After("gorm:query").Register("metrics:after_query", dbMetricsAfter("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", dbMetricsBefore),
+		cb.Update().After("gorm:update").Register("metrics:after_update", dbMetricsAfter("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", dbMetricsBefore),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", dbMetricsAfter("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", dbMetricsBefore),
+		cb.Row().After("gorm:row").Register("metrics:after_row", dbMetricsAfter("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", dbMetricsBefore),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", dbMetricsAfter("raw")),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dbMetricsBefore(tx *gorm.DB) {
+	tx.InstanceSet(dbMetricsStartKey, time.Now())
+}
+
+func dbMetricsAfter(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(dbMetricsStartKey)
+		if !ok {
+			return
+		}
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		result := "ok"
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			result = "error"
+		}
+		dbQueriesTotal.WithLabelValues(operation, table, result).Inc()
+		dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(v.(time.Time)).Seconds())
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/testutil"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDBMetricsCountCreates(t *testing.T) {
+	db := testutil.DB(t, dbMetrics{})
+	created := dbQueriesTotal.WithLabelValues("create", "products", "ok")
+	before := promtestutil.ToFloat64(created)
+
+	if err := db.Create(&Product{TenantID: model.DefaultTenantID, Name: "Widget", Currency: "USD"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := promtestutil.ToFloat64(created) - before; got != 1 {
+		t.Errorf("create counter went up by %v, want 1", got)
+	}
+	if n := promtestutil.CollectAndCount(dbQueryDuration); n == 0 {
+		t.Error("no query durations observed")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// statusClientClosedRequest is the non-standard status, borrowed from
+// nginx, recorded for requests whose client went away before the response.
+const statusClientClosedRequest = 499
+
+const (
+	statementCancelKey = "timeout:cancel"
+	statementParentKey = "timeout:parent"
+)
+
+// statementTimeout is a GORM plugin that gives each statement at most
+// timeout to run, on top of any deadline its context already has. Row
+// queries are left alone: their rows are read after the callbacks return.
+type statementTimeout struct {
+	timeout time.Duration
+}
+
+func (statementTimeout) Name() string { return "statement_timeout" }
+
+func (p statementTimeout) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("timeout:before_create", p.before),
+		cb.Create().After("gorm:create").Register("timeout:after_create", statementTimeoutAfter),
+		cb.Query().Before("gorm:query").Register("timeout:before_query", p.before),
+		cb.Query().After("gorm:query").Register("timeout:after_query", statementTimeoutAfter),
+		cb.Update().Before("gorm:update").Register("timeout:before_update", p.before),
+		cb.Update().After("gorm:update").Register("timeout:after_update", statementTimeoutAfter),
+		cb.Delete().Before("gorm:delete").Register("timeout:before_delete", p.before),
+		cb.Delete().After("gorm:delete").Register("timeout:after_delete", statementTimeoutAfter),
+		cb.Raw().Before("gorm:raw").Register("timeout:before_raw", p.before),
+		cb.Raw().After("gorm:raw").Register("timeout:after_raw", statementTimeoutAfter),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p statementTimeout) before(tx *gorm.DB) {
+	parent := tx.Statement.Context
+	ctx, cancel := context.WithTimeout(parent, p.timeout)
+	tx.Statement.Context = ctx
+	tx.InstanceSet(statementParentKey, parent)
+	tx.InstanceSet(statementCancelKey, cancel)
+}
+
+func statementTimeoutAfter(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(statementCancelKey)
+	if !ok {
+		return
+	}
+	v.(context.CancelFunc)()
+	ctx := tx.Statement.Context
+	parent, _ := tx.InstanceGet(statementParentKey)
+	tx.Statement.Context = parent.(context.Context)
+	// Only our own deadline counts as a statement timeout, not the
+	// caller's
+	if tx.Error != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && tx.Statement.Context.Err() == nil {
+		if failure, ok := tx.Statement.Context.Value(dbFailureKey{}).(*dbFailure); ok {
+			failure.timedOut.Store(true)
+		}
+	}
+}
+
+// dbFailureKey holds the *dbFailure of a request.
+type dbFailureKey struct{}
+
+// dbFailure records how the database failed the statements of a request:
+// statementTimeout sets timedOut when one runs out of time, and dbBreaker
+// sets unavailable when one can't reach the database or is failed fast.
+type dbFailure struct {
+	timedOut    atomic.Bool
+	unavailable atomic.Bool
+	// retryAt is when the open breaker next lets a statement through, in
+	// Unix nanoseconds, or 0 if it isn't open.
+	retryAt atomic.Int64
+}
+
+// trackDBFailures is middleware that lets error responses tell a
+// statement timeout or an unavailable database apart from other failures.
+func trackDBFailures(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), dbFailureKey{}, new(dbFailure))))
+	})
+}
+
+// contextFailure reports why the request of ctx failed if it was cut short
+// rather than broken: 503 if the database was unavailable, 504 if a
+// database statement or the request itself timed out, 499 if the client
+// gave up first.
+func contextFailure(ctx context.Context) (status int, code string, ok bool) {
+	if failure, _ := ctx.Value(dbFailureKey{}).(*dbFailure); failure != nil {
+		if failure.unavailable.Load() {
+			return http.StatusServiceUnavailable, "database_unavailable", true
+		}
+		if failure.timedOut.Load() {
+			return http.StatusGatewayTimeout, "database_timeout", true
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, "request_timeout", true
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return statusClientClosedRequest, "client_closed_request", true
+	}
+	return 0, "", false
+}
+
+// dbRetryWait returns how long until the database is tried again, if the
+// request of ctx found the circuit breaker open.
+func dbRetryWait(ctx context.Context) (time.Duration, bool) {
+	failure, _ := ctx.Value(dbFailureKey{}).(*dbFailure)
+	if failure == nil || !failure.unavailable.Load() || failure.retryAt.Load() == 0 {
+		return 0, false
+	}
+	return time.Until(time.Unix(0, failure.retryAt.Load())), true
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The spec is maintained by hand; update it with the routes.
+//
+//go:embed docs/openapi.json
+var openAPISpec []byte
+
+// swaggerPage loads Swagger UI's assets from unpkg and points it at the
+// spec.
+//
+//go:embed docs/swagger.html
+var swaggerPage []byte
+
+// Serve the OpenAPI description of the API
+func getOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// Serve the interactive API documentation
+func getDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(swaggerPage)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// dryRunHeader marks the response of a dry run.
+const dryRunHeader = "X-Dry-Run"
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// dryRun reports whether r only asks, with ?dry_run=true, what it would do.
+func dryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dry_run") == "true"
+}
+
+// dryRunnable lets a client try next with ?dry_run=true: it runs as usual,
+// validation and database constraints included, in a transaction that is
+// then rolled back, and the client gets the response it would have had,
+// marked with X-Dry-Run: true. next must not change anything outside the
+// database through anything but dbFor.
+func dryRunnable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !dryRun(r) {
+			next(w, r)
+			return
+		}
+		rec := &batchRecorder{header: http.Header{}, status: http.StatusOK}
+		err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+			next(rec, r.WithContext(context.WithValue(r.Context(), txKey{}, tx)))
+			return errDryRun
+		})
+		if !errors.Is(err, errDryRun) {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		// Nothing was created there
+		w.Header().Del("Location")
+		w.Header().Set(dryRunHeader, "true")
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+// jsonEncoder is the subset of *json.Encoder that responses need. Every
+// response body is written through newJSONEncoder so the JSON library can be
+// swapped in one place.
+type jsonEncoder interface {
+	Encode(v any) error
+}
@@ -0,0 +1,14 @@
+//go:build !gojson
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// newJSONEncoder returns the standard library encoder. Build with
+// -tags gojson to use github.com/goccy/go-json instead.
+func newJSONEncoder(w io.Writer) jsonEncoder {
+	return json.NewEncoder(w)
+}
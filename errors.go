@@ -0,0 +1,994 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/service"
+	"golang.org/x/text/language"
+)
+
+// apiError is an error a client can act on. Code is stable and
+// machine-readable; the human message is looked up in errorMessages in the
+// client's language, with Args filled into its format verbs. Fields lists
+// per-field problems for validation errors.
+type apiError struct {
+	Code   string
+	Args   []any
+	Fields []fieldError
+}
+
+// fieldError is a problem with one field of the request body.
+type fieldError struct {
+	Field string
+	Err   *apiError
+}
+
+func newAPIError(code string, args ...any) *apiError {
+	return &apiError{Code: code, Args: args}
+}
+
+// Error returns the English message.
+func (e *apiError) Error() string {
+	return e.message(language.English)
+}
+
+func (e *apiError) message(lang language.Tag) string {
+	msgs, ok := errorMessages[e.Code]
+	if !ok {
+		return e.Code
+	}
+	format, ok := msgs[lang]
+	if !ok {
+		format = msgs[language.English]
+	}
+	return fmt.Sprintf(format, e.Args...)
+}
+
+// errorLanguages are the languages errorMessages is translated into. The
+// first one is the fallback.
+var errorLanguages = []language.Tag{language.English, language.Spanish, language.French, language.German}
+
+var errorLanguageMatcher = language.NewMatcher(errorLanguages)
+
+// errorMessages maps each error code to its message per language. Every
+// code must have an English entry.
+var errorMessages = map[string]map[language.Tag]string{
+	"invalid_payload": {
+		language.English: "Invalid request payload",
+		language.Spanish: "Cuerpo de la solicitud no válido",
+		language.French:  "Corps de la requête invalide",
+		language.German:  "Ungültiger Anfrageinhalt",
+	},
+	"empty_body": {
+		language.English: "Request body is empty",
+		language.Spanish: "El cuerpo de la solicitud está vacío",
+		language.French:  "Le corps de la requête est vide",
+		language.German:  "Der Anfrageinhalt ist leer",
+	},
+	"malformed_json": {
+		language.English: "Request body is not valid JSON: error at byte %d",
+		language.Spanish: "El cuerpo de la solicitud no es JSON válido: error en el byte %d",
+		language.French:  "Le corps de la requête n'est pas du JSON valide : erreur à l'octet %d",
+		language.German:  "Der Anfrageinhalt ist kein gültiges JSON: Fehler bei Byte %d",
+	},
+	"truncated_json": {
+		language.English: "Request body ends in the middle of a JSON value",
+		language.Spanish: "El cuerpo de la solicitud termina en medio de un valor JSON",
+		language.French:  "Le corps de la requête se termine au milieu d'une valeur JSON",
+		language.German:  "Der Anfrageinhalt endet mitten in einem JSON-Wert",
+	},
+	"trailing_data": {
+		language.English: "Request body must hold a single JSON value",
+		language.Spanish: "El cuerpo de la solicitud debe contener un único valor JSON",
+		language.French:  "Le corps de la requête doit contenir une seule valeur JSON",
+		language.German:  "Der Anfrageinhalt darf nur einen JSON-Wert enthalten",
+	},
+	"invalid_body_type": {
+		language.English: "Request body must be a JSON %s",
+		language.Spanish: "El cuerpo de la solicitud debe ser un %s JSON",
+		language.French:  "Le corps de la requête doit être un %s JSON",
+		language.German:  "Der Anfrageinhalt muss ein JSON-%s sein",
+	},
+	"invalid_field_type": {
+		language.English: "Field %s must be a JSON %s",
+		language.Spanish: "El campo %s debe ser un %s JSON",
+		language.French:  "Le champ %s doit être un %s JSON",
+		language.German:  "Feld %s muss ein JSON-%s sein",
+	},
+	"body_too_large": {
+		language.English: "Request body must not exceed %d bytes",
+		language.Spanish: "El cuerpo de la solicitud no debe superar los %d bytes",
+		language.French:  "Le corps de la requête ne doit pas dépasser %d octets",
+		language.German:  "Der Anfrageinhalt darf %d Bytes nicht überschreiten",
+	},
+	"internal_error": {
+		language.English: "Internal server error",
+		language.Spanish: "Error interno del servidor",
+		language.French:  "Erreur interne du serveur",
+		language.German:  "Interner Serverfehler",
+	},
+	"product_not_found": {
+		language.English: "Product not found",
+		language.Spanish: "Producto no encontrado",
+		language.French:  "Produit introuvable",
+		language.German:  "Produkt nicht gefunden",
+	},
+	"category_not_found": {
+		language.English: "Category does not exist",
+		language.Spanish: "La categoría no existe",
+		language.French:  "La catégorie n'existe pas",
+		language.German:  "Kategorie existiert nicht",
+	},
+	"supplier_not_found": {
+		language.English: "Supplier does not exist",
+		language.Spanish: "El proveedor no existe",
+		language.French:  "Le fournisseur n'existe pas",
+		language.German:  "Lieferant existiert nicht",
+	},
+	"empty_search": {
+		language.English: "q must contain at least one letter or digit",
+		language.Spanish: "q debe contener al menos una letra o un dígito",
+		language.French:  "q doit contenir au moins une lettre ou un chiffre",
+		language.German:  "q muss mindestens einen Buchstaben oder eine Ziffer enthalten",
+	},
+	"too_many_search_terms": {
+		language.English: "Search for at most %d words",
+		language.Spanish: "Busque como máximo %d palabras",
+		language.French:  "Recherchez au plus %d mots",
+		language.German:  "Nach höchstens %d Wörtern suchen",
+	},
+	"invalid_expand": {
+		language.English: "Cannot expand %q; use category or supplier",
+		language.Spanish: "No se puede expandir %q; use category o supplier",
+		language.French:  "Impossible de développer %q ; utilisez category ou supplier",
+		language.German:  "%q kann nicht erweitert werden; category oder supplier verwenden",
+	},
+	"unauthorized": {
+		language.English: "Unauthorized",
+		language.Spanish: "No autorizado",
+		language.French:  "Non autorisé",
+		language.German:  "Nicht autorisiert",
+	},
+	"forbidden": {
+		language.English: "You don't have permission to do this",
+		language.Spanish: "No tiene permiso para hacer esto",
+		language.French:  "Vous n'avez pas l'autorisation de faire cela",
+		language.German:  "Dazu fehlt Ihnen die Berechtigung",
+	},
+	"invalid_token": {
+		language.English: "Token is invalid or expired",
+		language.Spanish: "El token no es válido o ha caducado",
+		language.French:  "Le jeton est invalide ou expiré",
+		language.German:  "Token ist ungültig oder abgelaufen",
+	},
+	"invalid_credentials": {
+		language.English: "Incorrect email or password",
+		language.Spanish: "Correo electrónico o contraseña incorrectos",
+		language.French:  "E-mail ou mot de passe incorrect",
+		language.German:  "E-Mail-Adresse oder Passwort falsch",
+	},
+	"email_taken": {
+		language.English: "An account with this email already exists",
+		language.Spanish: "Ya existe una cuenta con este correo electrónico",
+		language.French:  "Un compte existe déjà avec cet e-mail",
+		language.German:  "Für diese E-Mail-Adresse gibt es bereits ein Konto",
+	},
+	"invalid_email": {
+		language.English: "Invalid email address",
+		language.Spanish: "Dirección de correo electrónico no válida",
+		language.French:  "Adresse e-mail invalide",
+		language.German:  "Ungültige E-Mail-Adresse",
+	},
+	"weak_password": {
+		language.English: "Password must be at least %d characters",
+		language.Spanish: "La contraseña debe tener al menos %d caracteres",
+		language.French:  "Le mot de passe doit comporter au moins %d caractères",
+		language.German:  "Das Passwort muss mindestens %d Zeichen lang sein",
+	},
+	"unsupported_media_type": {
+		language.English: "Content-Type must be %s",
+		language.Spanish: "El Content-Type debe ser %s",
+		language.French:  "Le Content-Type doit être %s",
+		language.German:  "Content-Type muss %s sein",
+	},
+	"unsupported_charset": {
+		language.English: "Charset %s is not supported, send UTF-8",
+		language.Spanish: "El charset %s no es compatible, envíe UTF-8",
+		language.French:  "Le charset %s n'est pas pris en charge, envoyez de l'UTF-8",
+		language.German:  "Zeichensatz %s wird nicht unterstützt, senden Sie UTF-8",
+	},
+	"invalid_sort": {
+		language.English: "Cannot sort by %q",
+		language.Spanish: "No se puede ordenar por %q",
+		language.French:  "Impossible de trier par %q",
+		language.German:  "Sortierung nach %q nicht möglich",
+	},
+	"invalid_limit": {
+		language.English: "limit must be between 1 and %d",
+		language.Spanish: "limit debe estar entre 1 y %d",
+		language.French:  "limit doit être compris entre 1 et %d",
+		language.German:  "limit muss zwischen 1 und %d liegen",
+	},
+	"invalid_cursor": {
+		language.English: "Invalid cursor",
+		language.Spanish: "Cursor no válido",
+		language.French:  "Curseur invalide",
+		language.German:  "Ungültiger Cursor",
+	},
+	"cursor_sort_mismatch": {
+		language.English: "Cursor was issued for a different sort",
+		language.Spanish: "El cursor se emitió para otro orden",
+		language.French:  "Le curseur a été émis pour un autre tri",
+		language.German:  "Der Cursor wurde für eine andere Sortierung ausgestellt",
+	},
+	"invalid_page": {
+		language.English: "page must be a positive integer",
+		language.Spanish: "page debe ser un entero positivo",
+		language.French:  "page doit être un entier positif",
+		language.German:  "page muss eine positive ganze Zahl sein",
+	},
+	"page_and_cursor": {
+		language.English: "Use either page or cursor, not both",
+		language.Spanish: "Use page o cursor, no ambos",
+		language.French:  "Utilisez page ou cursor, pas les deux",
+		language.German:  "Entweder page oder cursor verwenden, nicht beides",
+	},
+	"invalid_return": {
+		language.English: "return must be minimal or representation",
+		language.Spanish: "return debe ser minimal o representation",
+		language.French:  "return doit valoir minimal ou representation",
+		language.German:  "return muss minimal oder representation sein",
+	},
+	"validation_failed": {
+		language.English: "Some fields are invalid",
+		language.Spanish: "Algunos campos no son válidos",
+		language.French:  "Certains champs sont invalides",
+		language.German:  "Einige Felder sind ungültig",
+	},
+	"invalid_amount": {
+		language.English: "Amounts must be decimals with at most %d decimal places, such as \"19.99\"",
+		language.Spanish: "Los importes deben ser decimales con como máximo %d decimales, como \"19.99\"",
+		language.French:  "Les montants doivent être des décimaux d'au plus %d décimales, comme \"19.99\"",
+		language.German:  "Beträge müssen Dezimalzahlen mit höchstens %d Nachkommastellen sein, etwa \"19.99\"",
+	},
+	"unknown_field": {
+		language.English: "Unknown field %s",
+		language.Spanish: "Campo desconocido %s",
+		language.French:  "Champ inconnu %s",
+		language.German:  "Unbekanntes Feld %s",
+	},
+	"required_field": {
+		language.English: "must not be empty",
+		language.Spanish: "no puede estar vacío",
+		language.French:  "ne doit pas être vide",
+		language.German:  "darf nicht leer sein",
+	},
+	"too_long": {
+		language.English: "must be at most %d characters",
+		language.Spanish: "debe tener como máximo %d caracteres",
+		language.French:  "doit comporter au plus %d caractères",
+		language.German:  "darf höchstens %d Zeichen lang sein",
+	},
+	"negative_value": {
+		language.English: "must not be negative",
+		language.Spanish: "no puede ser negativo",
+		language.French:  "ne doit pas être négatif",
+		language.German:  "darf nicht negativ sein",
+	},
+	"zero_value": {
+		language.English: "must not be 0",
+		language.Spanish: "no puede ser 0",
+		language.French:  "ne doit pas être 0",
+		language.German:  "darf nicht 0 sein",
+	},
+	"not_positive": {
+		language.English: "must be positive",
+		language.Spanish: "debe ser positivo",
+		language.French:  "doit être positif",
+		language.German:  "muss positiv sein",
+	},
+	"invalid_currency": {
+		language.English: "must be an ISO 4217 currency code such as USD",
+		language.Spanish: "debe ser un código de moneda ISO 4217 como USD",
+		language.French:  "doit être un code de devise ISO 4217 tel que USD",
+		language.German:  "muss ein ISO-4217-Währungscode wie USD sein",
+	},
+	"too_precise": {
+		language.English: "must have at most %d decimal places",
+		language.Spanish: "debe tener como máximo %d decimales",
+		language.French:  "doit avoir au plus %d décimales",
+		language.German:  "darf höchstens %d Nachkommastellen haben",
+	},
+	"invalid_number": {
+		language.English: "must be a number",
+		language.Spanish: "debe ser un número",
+		language.French:  "doit être un nombre",
+		language.German:  "muss eine Zahl sein",
+	},
+	"invalid_integer": {
+		language.English: "must be a whole number",
+		language.Spanish: "debe ser un número entero",
+		language.French:  "doit être un nombre entier",
+		language.German:  "muss eine ganze Zahl sein",
+	},
+	"unknown_category": {
+		language.English: "category %d does not exist",
+		language.Spanish: "la categoría %d no existe",
+		language.French:  "la catégorie %d n'existe pas",
+		language.German:  "Kategorie %d existiert nicht",
+	},
+	"unknown_supplier": {
+		language.English: "supplier %d does not exist",
+		language.Spanish: "el proveedor %d no existe",
+		language.French:  "le fournisseur %d n'existe pas",
+		language.German:  "Lieferant %d existiert nicht",
+	},
+	"min_stock_exceeds_max": {
+		language.English: "min_stock must not exceed max_stock",
+		language.Spanish: "min_stock no puede superar max_stock",
+		language.French:  "min_stock ne doit pas dépasser max_stock",
+		language.German:  "min_stock darf max_stock nicht überschreiten",
+	},
+	"below_reserved_stock": {
+		language.English: "quantity %d is below reserved stock %d",
+		language.Spanish: "la cantidad %d es inferior al stock reservado %d",
+		language.French:  "la quantité %d est inférieure au stock réservé %d",
+		language.German:  "Menge %d liegt unter dem reservierten Bestand %d",
+	},
+	"insufficient_stock": {
+		language.English: "Only %d in stock are not reserved",
+		language.Spanish: "Solo %d unidades en stock no están reservadas",
+		language.French:  "Seules %d unités en stock ne sont pas réservées",
+		language.German:  "Nur %d Stück im Bestand sind nicht reserviert",
+	},
+	"insufficient_reserved_stock": {
+		language.English: "Only %d are reserved",
+		language.Spanish: "Solo hay %d unidades reservadas",
+		language.French:  "Seules %d unités sont réservées",
+		language.German:  "Nur %d Stück sind reserviert",
+	},
+	"invalid_sku": {
+		language.English: "Invalid SKU %q: use letters, digits, '.', '_' and '-', at most 64 characters",
+		language.Spanish: "SKU %q no válido: use letras, dígitos, '.', '_' y '-', como máximo 64 caracteres",
+		language.French:  "SKU %q invalide : lettres, chiffres, '.', '_' et '-', 64 caractères au plus",
+		language.German:  "Ungültige SKU %q: Buchstaben, Ziffern, '.', '_' und '-', höchstens 64 Zeichen",
+	},
+	"empty_skus": {
+		language.English: "skus must not be empty",
+		language.Spanish: "skus no puede estar vacío",
+		language.French:  "skus ne doit pas être vide",
+		language.German:  "skus darf nicht leer sein",
+	},
+	"too_many_skus": {
+		language.English: "At most %d SKUs can be looked up at once",
+		language.Spanish: "Se pueden consultar como máximo %d SKU a la vez",
+		language.French:  "Au plus %d SKU peuvent être recherchés à la fois",
+		language.German:  "Es können höchstens %d SKUs auf einmal abgefragt werden",
+	},
+	"invalid_filter": {
+		language.English: "Invalid value for filter %s",
+		language.Spanish: "Valor no válido para el filtro %s",
+		language.French:  "Valeur invalide pour le filtre %s",
+		language.German:  "Ungültiger Wert für Filter %s",
+	},
+	"empty_filter": {
+		language.English: "filter must not be empty",
+		language.Spanish: "filter no puede estar vacío",
+		language.French:  "filter ne doit pas être vide",
+		language.German:  "filter darf nicht leer sein",
+	},
+	"invalid_category_id": {
+		language.English: "category_id must be a positive integer",
+		language.Spanish: "category_id debe ser un entero positivo",
+		language.French:  "category_id doit être un entier positif",
+		language.German:  "category_id muss eine positive ganze Zahl sein",
+	},
+	"too_many_operations": {
+		language.English: "A batch may contain at most %d operations",
+		language.Spanish: "Un lote puede contener como máximo %d operaciones",
+		language.French:  "Un lot peut contenir au plus %d opérations",
+		language.German:  "Ein Batch darf höchstens %d Operationen enthalten",
+	},
+	"version_required": {
+		language.English: "Send the version of the product being replaced, in the body or as If-Match",
+		language.Spanish: "Envíe la versión del producto que se reemplaza, en el cuerpo o como If-Match",
+		language.French:  "Envoyez la version du produit remplacé, dans le corps ou en If-Match",
+		language.German:  "Senden Sie die Version des ersetzten Produkts im Inhalt oder als If-Match",
+	},
+	"invalid_if_match": {
+		language.English: "If-Match must be a single product ETag such as \"3\"",
+		language.Spanish: "If-Match debe ser un único ETag de producto, como \"3\"",
+		language.French:  "If-Match doit être un seul ETag de produit, par exemple \"3\"",
+		language.German:  "If-Match muss ein einzelnes Produkt-ETag wie \"3\" sein",
+	},
+	"invalid_idempotency_key": {
+		language.English: "Idempotency-Key must be 1 to 255 printable ASCII characters",
+		language.Spanish: "Idempotency-Key debe tener de 1 a 255 caracteres ASCII imprimibles",
+		language.French:  "Idempotency-Key doit comporter de 1 à 255 caractères ASCII imprimables",
+		language.German:  "Idempotency-Key muss aus 1 bis 255 druckbaren ASCII-Zeichen bestehen",
+	},
+	"idempotency_key_reused": {
+		language.English: "This Idempotency-Key was already used for a different request",
+		language.Spanish: "Esta Idempotency-Key ya se usó para una solicitud distinta",
+		language.French:  "Cette Idempotency-Key a déjà été utilisée pour une autre requête",
+		language.German:  "Dieser Idempotency-Key wurde bereits für eine andere Anfrage verwendet",
+	},
+	"idempotency_key_in_use": {
+		language.English: "A request with this Idempotency-Key is still in progress; retry later",
+		language.Spanish: "Una solicitud con esta Idempotency-Key aún está en curso; reintente más tarde",
+		language.French:  "Une requête avec cette Idempotency-Key est encore en cours ; réessayez plus tard",
+		language.German:  "Eine Anfrage mit diesem Idempotency-Key wird noch bearbeitet; versuchen Sie es später erneut",
+	},
+	"stale_version": {
+		language.English: "The product was changed by someone else and is now at version %d; reload it and retry",
+		language.Spanish: "Otra persona modificó el producto, que ahora está en la versión %d; vuelva a cargarlo y reintente",
+		language.French:  "Le produit a été modifié par quelqu'un d'autre et est maintenant en version %d ; rechargez-le et réessayez",
+		language.German:  "Das Produkt wurde von jemand anderem geändert und hat jetzt Version %d; laden Sie es neu und versuchen Sie es erneut",
+	},
+	"version_conflict": {
+		language.English: "The product was changed by someone else; reload it and retry",
+		language.Spanish: "Otra persona modificó el producto; vuelva a cargarlo y reintente",
+		language.French:  "Le produit a été modifié par quelqu'un d'autre ; rechargez-le et réessayez",
+		language.German:  "Das Produkt wurde von jemand anderem geändert; laden Sie es neu und versuchen Sie es erneut",
+	},
+	"database_timeout": {
+		language.English: "The database took too long to respond",
+		language.Spanish: "La base de datos tardó demasiado en responder",
+		language.French:  "La base de données a mis trop de temps à répondre",
+		language.German:  "Die Datenbank hat zu lange für die Antwort gebraucht",
+	},
+	"client_closed_request": {
+		language.English: "The client closed the request before it completed",
+		language.Spanish: "El cliente cerró la solicitud antes de que terminara",
+		language.French:  "Le client a fermé la requête avant la fin",
+		language.German:  "Der Client hat die Anfrage vor ihrem Abschluss geschlossen",
+	},
+	"route_not_found": {
+		language.English: "No such endpoint",
+		language.Spanish: "No existe ese endpoint",
+		language.French:  "Ce point de terminaison n'existe pas",
+		language.German:  "Diesen Endpunkt gibt es nicht",
+	},
+	"method_not_allowed": {
+		language.English: "Method %s is not allowed here",
+		language.Spanish: "El método %s no está permitido aquí",
+		language.French:  "La méthode %s n'est pas autorisée ici",
+		language.German:  "Die Methode %s ist hier nicht erlaubt",
+	},
+	"too_many_items": {
+		language.English: "A bulk request may contain at most %d items",
+		language.Spanish: "Una solicitud masiva puede contener como máximo %d elementos",
+		language.French:  "Une requête groupée peut contenir au plus %d éléments",
+		language.German:  "Eine Sammelanfrage darf höchstens %d Elemente enthalten",
+	},
+	"bulk_product_not_found": {
+		language.English: "Product %d not found; nothing was deleted",
+		language.Spanish: "Producto %d no encontrado; no se eliminó nada",
+		language.French:  "Produit %d introuvable ; rien n'a été supprimé",
+		language.German:  "Produkt %d nicht gefunden; nichts wurde gelöscht",
+	},
+	"invalid_operation": {
+		language.English: "Operation %d: %s",
+		language.Spanish: "Operación %d: %s",
+		language.French:  "Opération %d : %s",
+		language.German:  "Operation %d: %s",
+	},
+	"restore_unconfirmed": {
+		language.English: "Restore deletes all existing data; repeat with ?confirm=true",
+		language.Spanish: "La restauración borra todos los datos existentes; repita con ?confirm=true",
+		language.French:  "La restauration supprime toutes les données existantes ; réessayez avec ?confirm=true",
+		language.German:  "Die Wiederherstellung löscht alle vorhandenen Daten; mit ?confirm=true wiederholen",
+	},
+	"request_quota_exceeded": {
+		language.English: "Request quota of %d per minute exceeded",
+		language.Spanish: "Se superó la cuota de %d solicitudes por minuto",
+		language.French:  "Quota de %d requêtes par minute dépassé",
+		language.German:  "Kontingent von %d Anfragen pro Minute überschritten",
+	},
+	"product_quota_exceeded": {
+		language.English: "Product quota of %d reached",
+		language.Spanish: "Se alcanzó la cuota de %d productos",
+		language.French:  "Quota de %d produits atteint",
+		language.German:  "Kontingent von %d Produkten erreicht",
+	},
+	"rate_limited": {
+		language.English: "Too many requests; slow down",
+		language.Spanish: "Demasiadas solicitudes; reduzca el ritmo",
+		language.French:  "Trop de requêtes ; ralentissez",
+		language.German:  "Zu viele Anfragen; bitte langsamer",
+	},
+	"job_not_found": {
+		language.English: "Job not found",
+		language.Spanish: "Tarea no encontrada",
+		language.French:  "Tâche introuvable",
+		language.German:  "Auftrag nicht gefunden",
+	},
+	"job_queue_full": {
+		language.English: "Too many background jobs are queued; try again later",
+		language.Spanish: "Hay demasiadas tareas en cola; inténtelo más tarde",
+		language.French:  "Trop de tâches en file d'attente ; réessayez plus tard",
+		language.German:  "Zu viele Hintergrundaufträge in der Warteschlange; später erneut versuchen",
+	},
+	"webhook_not_found": {
+		language.English: "Webhook not found",
+		language.Spanish: "Webhook no encontrado",
+		language.French:  "Webhook introuvable",
+		language.German:  "Webhook nicht gefunden",
+	},
+	"invalid_webhook_url": {
+		language.English: "url must be an absolute http or https URL",
+		language.Spanish: "url debe ser una URL http o https absoluta",
+		language.French:  "url doit être une URL http ou https absolue",
+		language.German:  "url muss eine absolute http- oder https-URL sein",
+	},
+	"webhook_url_not_public": {
+		language.English: "url host %s must resolve to public IP addresses only",
+		language.Spanish: "el host %s de url debe resolverse solo a direcciones IP públicas",
+		language.French:  "l'hôte %s de url doit se résoudre uniquement en adresses IP publiques",
+		language.German:  "der Host %s von url darf nur zu öffentlichen IP-Adressen auflösen",
+	},
+	"invalid_webhook_events": {
+		language.English: "events must list one or more of %s",
+		language.Spanish: "events debe incluir uno o más de %s",
+		language.French:  "events doit contenir un ou plusieurs de %s",
+		language.German:  "events muss einen oder mehrere der Werte %s enthalten",
+	},
+	"invalid_webhook_secret": {
+		language.English: "secret must be at most %d characters",
+		language.Spanish: "secret debe tener como máximo %d caracteres",
+		language.French:  "secret doit comporter au plus %d caractères",
+		language.German:  "secret darf höchstens %d Zeichen lang sein",
+	},
+	"invalid_backup": {
+		language.English: "Invalid backup: %s",
+		language.Spanish: "Copia de seguridad no válida: %s",
+		language.French:  "Sauvegarde invalide : %s",
+		language.German:  "Ungültige Sicherung: %s",
+	},
+	"unsupported_sheet_format": {
+		language.English: "Spreadsheet format must be one of %s",
+		language.Spanish: "El formato de hoja de cálculo debe ser uno de %s",
+		language.French:  "Le format de feuille de calcul doit être l'un de %s",
+		language.German:  "Das Tabellenformat muss eines von %s sein",
+	},
+	"missing_image_file": {
+		language.English: "The upload has no file part",
+		language.Spanish: "La subida no tiene una parte file",
+		language.French:  "L'envoi n'a pas de partie file",
+		language.German:  "Der Upload hat keinen Teil file",
+	},
+	"image_not_found": {
+		language.English: "Image not found",
+		language.Spanish: "Imagen no encontrada",
+		language.French:  "Image introuvable",
+		language.German:  "Bild nicht gefunden",
+	},
+	"unsupported_image_type": {
+		language.English: "The image must be %s",
+		language.Spanish: "La imagen debe ser %s",
+		language.French:  "L'image doit être au format %s",
+		language.German:  "Das Bild muss %s sein",
+	},
+	"invalid_image": {
+		language.English: "The image is damaged or incomplete",
+		language.Spanish: "La imagen está dañada o incompleta",
+		language.French:  "L'image est endommagée ou incomplète",
+		language.German:  "Das Bild ist beschädigt oder unvollständig",
+	},
+	"image_too_large": {
+		language.English: "The image must not exceed %d bytes",
+		language.Spanish: "La imagen no debe superar los %d bytes",
+		language.French:  "L'image ne doit pas dépasser %d octets",
+		language.German:  "Das Bild darf %d Bytes nicht überschreiten",
+	},
+	"storage_unavailable": {
+		language.English: "File storage is unavailable, try again later",
+		language.Spanish: "El almacenamiento de archivos no está disponible, inténtelo más tarde",
+		language.French:  "Le stockage des fichiers est indisponible, réessayez plus tard",
+		language.German:  "Der Dateispeicher ist nicht verfügbar, versuchen Sie es später erneut",
+	},
+	"missing_sheet_file": {
+		language.English: "The upload has no file part",
+		language.Spanish: "La subida no tiene una parte file",
+		language.French:  "L'envoi n'a pas de partie file",
+		language.German:  "Der Upload hat keinen Teil file",
+	},
+	"invalid_sheet": {
+		language.English: "Invalid spreadsheet: %s",
+		language.Spanish: "Hoja de cálculo no válida: %s",
+		language.French:  "Feuille de calcul invalide : %s",
+		language.German:  "Ungültige Tabelle: %s",
+	},
+	"tenant_mismatch": {
+		language.English: "X-Tenant-ID names a tenant other than the token's",
+		language.Spanish: "X-Tenant-ID indica un inquilino distinto del del token",
+		language.French:  "X-Tenant-ID désigne un autre locataire que celui du jeton",
+		language.German:  "X-Tenant-ID nennt einen anderen Mandanten als das Token",
+	},
+	"invalid_tenant": {
+		language.English: "X-Tenant-ID must be a tenant ID",
+		language.Spanish: "X-Tenant-ID debe ser un ID de inquilino",
+		language.French:  "X-Tenant-ID doit être un identifiant de locataire",
+		language.German:  "X-Tenant-ID muss eine Mandanten-ID sein",
+	},
+	"tenant_not_found": {
+		language.English: "Tenant not found",
+		language.Spanish: "Inquilino no encontrado",
+		language.French:  "Locataire introuvable",
+		language.German:  "Mandant nicht gefunden",
+	},
+	"invalid_tenant_name": {
+		language.English: "Tenant name must be 1-%d characters",
+		language.Spanish: "El nombre del inquilino debe tener entre 1 y %d caracteres",
+		language.French:  "Le nom du locataire doit comporter de 1 à %d caractères",
+		language.German:  "Der Mandantenname muss 1-%d Zeichen lang sein",
+	},
+	"invalid_api_key": {
+		language.English: "API key is invalid, revoked or expired",
+		language.Spanish: "La clave de API no es válida, está revocada o ha caducado",
+		language.French:  "La clé d'API est invalide, révoquée ou expirée",
+		language.German:  "API-Schlüssel ist ungültig, widerrufen oder abgelaufen",
+	},
+	"insufficient_scope": {
+		language.English: "The API key's scopes (%s) don't allow this request",
+		language.Spanish: "Los ámbitos de la clave de API (%s) no permiten esta solicitud",
+		language.French:  "Les portées de la clé d'API (%s) ne permettent pas cette requête",
+		language.German:  "Die Berechtigungen des API-Schlüssels (%s) erlauben diese Anfrage nicht",
+	},
+	"api_key_not_found": {
+		language.English: "API key not found",
+		language.Spanish: "Clave de API no encontrada",
+		language.French:  "Clé d'API introuvable",
+		language.German:  "API-Schlüssel nicht gefunden",
+	},
+	"api_key_forbidden": {
+		language.English: "API keys can only be managed by signed-in admins, not with an API key",
+		language.Spanish: "Las claves de API solo pueden gestionarlas administradores que hayan iniciado sesión, no con una clave de API",
+		language.French:  "Les clés d'API ne peuvent être gérées que par des administrateurs connectés, pas avec une clé d'API",
+		language.German:  "API-Schlüssel können nur von angemeldeten Administratoren verwaltet werden, nicht mit einem API-Schlüssel",
+	},
+	"invalid_api_key_name": {
+		language.English: "name must be 1-%d characters",
+		language.Spanish: "name debe tener entre 1 y %d caracteres",
+		language.French:  "name doit comporter de 1 à %d caractères",
+		language.German:  "name muss 1-%d Zeichen lang sein",
+	},
+	"invalid_api_key_scopes": {
+		language.English: "scopes must list one or more of %s",
+		language.Spanish: "scopes debe incluir uno o más de %s",
+		language.French:  "scopes doit contenir un ou plusieurs de %s",
+		language.German:  "scopes muss einen oder mehrere der Werte %s enthalten",
+	},
+	"invalid_api_key_expiry": {
+		language.English: "expires_at must be in the future",
+		language.Spanish: "expires_at debe estar en el futuro",
+		language.French:  "expires_at doit être dans le futur",
+		language.German:  "expires_at muss in der Zukunft liegen",
+	},
+	"invalid_range": {
+		language.English: "%s must not be greater than %s",
+		language.Spanish: "%s no puede ser mayor que %s",
+		language.French:  "%s ne doit pas être supérieur à %s",
+		language.German:  "%s darf nicht größer als %s sein",
+	},
+	"conflicting_filters": {
+		language.English: "%s and %s are the same filter; give only one",
+		language.Spanish: "%s y %s son el mismo filtro; indique solo uno",
+		language.French:  "%s et %s sont le même filtre ; n'en indiquez qu'un",
+		language.German:  "%s und %s sind derselbe Filter; bitte nur einen angeben",
+	},
+	"invalid_field": {
+		language.English: "Unknown field %s in fields",
+		language.Spanish: "Campo desconocido %s en fields",
+		language.French:  "Champ inconnu %s dans fields",
+		language.German:  "Unbekanntes Feld %s in fields",
+	},
+	"duplicate_value": {
+		language.English: "Another product already has this %s",
+		language.Spanish: "Otro producto ya tiene este valor de %s",
+		language.French:  "Un autre produit a déjà cette valeur de %s",
+		language.German:  "Ein anderes Produkt hat bereits diesen Wert für %s",
+	},
+	"already_taken": {
+		language.English: "Must be unique; this value is already taken",
+		language.Spanish: "Debe ser único; este valor ya está en uso",
+		language.French:  "Doit être unique ; cette valeur est déjà utilisée",
+		language.German:  "Muss eindeutig sein; dieser Wert ist bereits vergeben",
+	},
+	"unsupported_export_format": {
+		language.English: "Export format must be one of %s",
+		language.Spanish: "El formato de exportación debe ser uno de %s",
+		language.French:  "Le format d'export doit être l'un de %s",
+		language.German:  "Das Exportformat muss eines von %s sein",
+	},
+	"config_reload_failed": {
+		language.English: "The configuration could not be reloaded; the current one stays in effect: %s",
+		language.Spanish: "No se pudo recargar la configuración; la actual sigue en vigor: %s",
+		language.French:  "La configuration n'a pas pu être rechargée ; l'actuelle reste en vigueur : %s",
+		language.German:  "Die Konfiguration konnte nicht neu geladen werden; die aktuelle bleibt in Kraft: %s",
+	},
+	"order_not_found": {
+		language.English: "Order not found",
+		language.Spanish: "Pedido no encontrado",
+		language.French:  "Commande introuvable",
+		language.German:  "Bestellung nicht gefunden",
+	},
+	"unknown_product": {
+		language.English: "product %d does not exist",
+		language.Spanish: "el producto %d no existe",
+		language.French:  "le produit %d n'existe pas",
+		language.German:  "Produkt %d existiert nicht",
+	},
+	"currency_mismatch": {
+		language.English: "product is priced in %s, other items in %s",
+		language.Spanish: "el producto tiene precio en %s y los demás artículos en %s",
+		language.French:  "le produit est au prix en %s, les autres articles en %s",
+		language.German:  "das Produkt hat einen Preis in %s, die übrigen Positionen in %s",
+	},
+	"duplicate_order_item": {
+		language.English: "product %d is already in the order",
+		language.Spanish: "el producto %d ya está en el pedido",
+		language.French:  "le produit %d figure déjà dans la commande",
+		language.German:  "Produkt %d ist bereits in der Bestellung",
+	},
+	"too_many_order_items": {
+		language.English: "must have at most %d items",
+		language.Spanish: "debe tener como máximo %d artículos",
+		language.French:  "doit comporter au plus %d articles",
+		language.German:  "darf höchstens %d Positionen haben",
+	},
+	"order_insufficient_stock": {
+		language.English: "Only %[2]d of product %[1]d in stock are not reserved",
+		language.Spanish: "Solo %[2]d unidades del producto %[1]d en stock no están reservadas",
+		language.French:  "Seules %[2]d unités du produit %[1]d en stock ne sont pas réservées",
+		language.German:  "Nur %[2]d Stück von Produkt %[1]d im Bestand sind nicht reserviert",
+	},
+	"invalid_order_transition": {
+		language.English: "An order that is %s can't become %s",
+		language.Spanish: "Un pedido en estado %s no puede pasar a %s",
+		language.French:  "Une commande à l'état %s ne peut pas passer à %s",
+		language.German:  "Eine Bestellung im Status %s kann nicht zu %s wechseln",
+	},
+	"invalid_product_id": {
+		language.English: "Invalid product ID %q",
+		language.Spanish: "ID de producto %q no válido",
+		language.French:  "ID de produit %q invalide",
+		language.German:  "Ungültige Produkt-ID %q",
+	},
+	"empty_ids": {
+		language.English: "ids must not be empty",
+		language.Spanish: "ids no puede estar vacío",
+		language.French:  "ids ne doit pas être vide",
+		language.German:  "ids darf nicht leer sein",
+	},
+	"too_many_ids": {
+		language.English: "At most %d products can be fetched at once",
+		language.Spanish: "Se pueden obtener como máximo %d productos a la vez",
+		language.French:  "Au plus %d produits peuvent être récupérés à la fois",
+		language.German:  "Es können höchstens %d Produkte auf einmal abgerufen werden",
+	},
+	"invalid_locale": {
+		language.English: "Invalid locale %q; use a language tag such as fr or pt-BR",
+		language.Spanish: "Configuración regional %q no válida; use una etiqueta de idioma como fr o pt-BR",
+		language.French:  "Locale %q invalide ; utilisez une balise de langue comme fr ou pt-BR",
+		language.German:  "Ungültiges Gebietsschema %q; verwenden Sie ein Sprach-Tag wie fr oder pt-BR",
+	},
+	"translation_not_found": {
+		language.English: "The product has no translation into %s",
+		language.Spanish: "El producto no tiene traducción a %s",
+		language.French:  "Le produit n'a pas de traduction en %s",
+		language.German:  "Das Produkt hat keine Übersetzung in %s",
+	},
+	"invalid_as_of": {
+		language.English: "as_of must be an RFC 3339 timestamp such as 2024-05-01T12:00:00Z",
+		language.Spanish: "as_of debe ser una marca de tiempo RFC 3339 como 2024-05-01T12:00:00Z",
+		language.French:  "as_of doit être un horodatage RFC 3339 tel que 2024-05-01T12:00:00Z",
+		language.German:  "as_of muss ein RFC-3339-Zeitstempel wie 2024-05-01T12:00:00Z sein",
+	},
+	"price_not_recorded": {
+		language.English: "The product had no price on record at that time",
+		language.Spanish: "El producto no tenía ningún precio registrado en ese momento",
+		language.French:  "Le produit n'avait aucun prix enregistré à ce moment-là",
+		language.German:  "Für das Produkt war zu diesem Zeitpunkt kein Preis erfasst",
+	},
+	"database_unavailable": {
+		language.English: "The database is unavailable; try again shortly",
+		language.Spanish: "La base de datos no está disponible; inténtelo de nuevo en breve",
+		language.French:  "La base de données est indisponible ; réessayez dans un instant",
+		language.German:  "Die Datenbank ist nicht verfügbar; bitte gleich erneut versuchen",
+	},
+	"request_timeout": {
+		language.English: "The request took too long to complete",
+		language.Spanish: "La solicitud tardó demasiado en completarse",
+		language.French:  "La requête a mis trop de temps à aboutir",
+		language.German:  "Die Anfrage hat zu lange gedauert",
+	},
+	"invalid_category_ids": {
+		language.English: "Invalid category ID %q",
+		language.Spanish: "ID de categoría %q no válido",
+		language.French:  "ID de catégorie %q invalide",
+		language.German:  "Ungültige Kategorie-ID %q",
+	},
+	"websocket_handshake_failed": {
+		language.English: "The WebSocket handshake failed: %s",
+		language.Spanish: "El protocolo de enlace WebSocket falló: %s",
+		language.French:  "La négociation WebSocket a échoué : %s",
+		language.German:  "Der WebSocket-Handshake ist fehlgeschlagen: %s",
+	},
+	"invalid_message": {
+		language.English: "Messages must be JSON objects of type subscribe, unsubscribe or ping",
+		language.Spanish: "Los mensajes deben ser objetos JSON de tipo subscribe, unsubscribe o ping",
+		language.French:  "Les messages doivent être des objets JSON de type subscribe, unsubscribe ou ping",
+		language.German:  "Nachrichten müssen JSON-Objekte vom Typ subscribe, unsubscribe oder ping sein",
+	},
+}
+
+// problemTypePrefix prefixes the error code to form a problem's type URI.
+const problemTypePrefix = "urn:golangdb:problem:"
+
+// problem is the body of every error response, an RFC 7807 problem
+// details object. Code, also the last part of Type, is stable and
+// machine-readable; Detail is the message in the client's language.
+type problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail"`
+	Instance string         `json:"instance,omitempty"`
+	Code     string         `json:"code"`
+	Fields   []fieldProblem `json:"fields,omitempty"`
+}
+
+type fieldProblem struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// problem returns the body for e answering r with status.
+func (e *apiError) problem(r *http.Request, status int) problem {
+	lang := requestLanguage(r)
+	p := problem{
+		Type:     problemTypePrefix + e.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   e.message(lang),
+		Instance: r.URL.Path,
+		Code:     e.Code,
+	}
+	if p.Title == "" {
+		p.Title = e.Code
+	}
+	for _, f := range e.Fields {
+		p.Fields = append(p.Fields, fieldProblem{Field: f.Field, Code: f.Err.Code, Detail: f.Err.message(lang)})
+	}
+	return p
+}
+
+// requestLanguage picks the best supported language for r's
+// Accept-Language header, defaulting to English.
+func requestLanguage(r *http.Request) language.Tag {
+	return matchLanguage(r.Header.Get("Accept-Language"))
+}
+
+// matchLanguage picks the best supported language for an Accept-Language
+// value.
+func matchLanguage(acceptLanguage string) language.Tag {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, index, _ := errorLanguageMatcher.Match(tags...)
+	return errorLanguages[index]
+}
+
+// writeError writes the error with the given code, localized for r.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string, args ...any) {
+	writeAPIError(w, r, status, newAPIError(code, args...))
+}
+
+// writeServiceError writes an error from the service or repository layer
+// with the status that matches its kind.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	writeAPIError(w, r, errorStatus(err), err)
+}
+
+// errorStatus maps an error to its HTTP status: not found, validation,
+// conflict, other client errors, and anything else as internal.
+func errorStatus(err error) int {
+	var apiErr *apiError
+	var queryErr *query.Error
+	var serviceErr *service.Error
+	var validationErr *service.ValidationError
+	var conflictErr *service.ConflictError
+	var duplicateErr *service.DuplicateError
+	var quotaErr *productQuotaError
+	switch {
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrNoPrice):
+		return http.StatusNotFound
+	case errors.As(err, &quotaErr):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrEmailTaken), errors.As(err, &conflictErr), errors.As(err, &duplicateErr):
+		return http.StatusConflict
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &apiErr), errors.As(err, &queryErr), errors.As(err, &serviceErr):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// asAPIError converts err to an apiError. Errors from the query, service
+// and repository packages keep their meaning; anything else becomes
+// internal_error so its details don't leak.
+func asAPIError(err error) *apiError {
+	var apiErr *apiError
+	var queryErr *query.Error
+	var serviceErr *service.Error
+	var validationErr *service.ValidationError
+	var duplicateErr *service.DuplicateError
+	var quotaErr *productQuotaError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &quotaErr):
+		return newAPIError("product_quota_exceeded", quotaErr.limit)
+	case errors.Is(err, service.ErrOrderNotFound):
+		return newAPIError("order_not_found")
+	case errors.Is(err, service.ErrNotFound):
+		return newAPIError("product_not_found")
+	case errors.Is(err, service.ErrNoPrice):
+		return newAPIError("price_not_recorded")
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return newAPIError("invalid_credentials")
+	case errors.Is(err, service.ErrEmailTaken):
+		return newAPIError("email_taken")
+	case errors.As(err, &duplicateErr):
+		apiErr := newAPIError("duplicate_value", duplicateErr.Field)
+		apiErr.Fields = []fieldError{{Field: duplicateErr.Field, Err: newAPIError("already_taken")}}
+		return apiErr
+	case errors.As(err, &queryErr):
+		return newAPIError(queryErr.Code, queryErr.Args...)
+	case errors.As(err, &serviceErr):
+		return newAPIError(serviceErr.Code, serviceErr.Args...)
+	case errors.As(err, &validationErr):
+		apiErr := newAPIError("validation_failed")
+		for _, f := range validationErr.Fields {
+			apiErr.Fields = append(apiErr.Fields, fieldError{Field: f.Field, Err: newAPIError(f.Code, f.Args...)})
+		}
+		return apiErr
+	}
+	return newAPIError("internal_error")
+}
+
+// writeAPIError writes err localized for r as application/problem+json. A
+// server error caused by an unavailable database, a timeout, or a client
+// that went away is reported as such, and any error after the body was cut
+// off at its limit as 413.
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	apiErr := asAPIError(err)
+	if limit, ok := bodyTooLarge(r.Context()); ok {
+		status, apiErr = http.StatusRequestEntityTooLarge, newAPIError("body_too_large", limit)
+	} else if status == http.StatusInternalServerError {
+		if ctxStatus, code, ok := contextFailure(r.Context()); ok {
+			status, apiErr = ctxStatus, newAPIError(code)
+			if wait, ok := dbRetryWait(r.Context()); ok && status == http.StatusServiceUnavailable {
+				setRetryAfter(w, wait)
+			}
+		}
+	}
+	w.Header().Set("Content-Language", requestLanguage(r).String())
+	writeJSONAs(w, r, status, "application/problem+json", apiErr.problem(r, status))
+}
+
+// routeNotFound and methodNotAllowed answer requests the router has no
+// route for, as problems like every other error.
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, "route_not_found")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", r.Method)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+// The code stays the same in every language; the details are translated.
+func TestLocalizedErrors(t *testing.T) {
+	c, _ := newTestAPI(t)
+	tests := []struct {
+		acceptLanguage string
+		want           language.Tag
+	}{
+		{"", language.English},
+		{"es", language.Spanish},
+		{"es-MX,es;q=0.9,en;q=0.5", language.Spanish},
+		{"fr-CA", language.French},
+		{"ja", language.English},
+	}
+	for _, tt := range tests {
+		r := c.WithHeader("Accept-Language", tt.acceptLanguage).Post("/api/v1/products", map[string]any{"name": "", "price": "9.99"})
+		p := expectProblem(t, r, 422, "validation_failed")
+		if want := newAPIError("validation_failed").message(tt.want); p.Detail != want {
+			t.Errorf("%q: detail %q, want %q", tt.acceptLanguage, p.Detail, want)
+		}
+		if len(p.Fields) != 1 || p.Fields[0].Field != "name" {
+			t.Fatalf("%q: fields %+v, want name", tt.acceptLanguage, p.Fields)
+		}
+		f := p.Fields[0]
+		if want := newAPIError(f.Code).message(tt.want); f.Detail != want {
+			t.Errorf("%q: %s detail %q, want %q", tt.acceptLanguage, f.Code, f.Detail, want)
+		}
+	}
+	if es, en := newAPIError("validation_failed").message(language.Spanish), newAPIError("validation_failed").message(language.English); es == en {
+		t.Errorf("validation_failed isn't translated to Spanish: %q", es)
+	}
+}
+
+func TestErrorMessagesHaveEnglish(t *testing.T) {
+	for code, msgs := range errorMessages {
+		if _, ok := msgs[language.English]; !ok {
+			t.Errorf("%s has no English message", code)
+		}
+	}
+}
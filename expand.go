@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// productExpansions maps the names ?expand= accepts on product reads to
+// the associations they load.
+var productExpansions = map[string]string{
+	"category": "Category",
+	"supplier": "Supplier",
+	"images":   "Images",
+}
+
+// expansions returns the associations r asked for with a comma-separated
+// ?expand=, skipping any it can't load.
+func expansions(r *http.Request) []string {
+	var assocs []string
+	for _, name := range expandNames(r) {
+		if assoc, ok := productExpansions[name]; ok && !slices.Contains(assocs, assoc) {
+			assocs = append(assocs, assoc)
+		}
+	}
+	return assocs
+}
+
+func expandNames(r *http.Request) []string {
+	v := r.URL.Query().Get("expand")
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// expandable rejects a ?expand= naming anything but the product
+// associations.
+func expandable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range expandNames(r) {
+			if _, ok := productExpansions[name]; !ok {
+				writeError(w, r, http.StatusBadRequest, "invalid_expand", name)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// productSchema describes how products can be filtered, sorted and paged.
+// Filter parameters are the field name for equality or the field name with
+// a _like, _gte or _lte suffix, plus min_price, max_price and in_stock.
+var productSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":          {Column: "id", Kind: query.Uint, Sortable: true},
+		"name":        {Column: "name", Kind: query.String, Sortable: true, Ops: []query.Op{query.Like}},
+		"price":       {Column: "price", Kind: query.Money, Sortable: true, Ops: []query.Op{query.Gte, query.Lte}, Aliases: map[query.Op]string{query.Gte: "min_price", query.Lte: "max_price"}},
+		"quantity":    {Column: "quantity", Kind: query.Int, Sortable: true, Ops: []query.Op{query.Gte, query.Lte}},
+		"category_id": {Column: "category_id", Kind: query.Uint, Ops: []query.Op{query.Eq}},
+		"supplier_id": {Column: "supplier_id", Kind: query.Uint, Ops: []query.Op{query.Eq}},
+		"created_at":  {Column: "created_at", Kind: query.Time, Sortable: true, Ops: []query.Op{query.Gte, query.Lte}},
+	},
+	// Reserved units count as in stock
+	Flags:        map[string]string{"in_stock": "quantity > 0"},
+	Key:          "id",
+	DefaultLimit: defaultPageLimit,
+	MaxLimit:     maxPageLimit,
+}
+
+// productFilter selects products by field in request bodies. Nil fields
+// don't constrain the result. It is shared by every endpoint that works on
+// "all products matching X".
+type productFilter struct {
+	NameLike    *string       `json:"name_like,omitempty"`
+	PriceGte    *money.Amount `json:"price_gte,omitempty"`
+	PriceLte    *money.Amount `json:"price_lte,omitempty"`
+	QuantityGte *int          `json:"quantity_gte,omitempty"`
+	QuantityLte *int          `json:"quantity_lte,omitempty"`
+	CategoryID  *uint         `json:"category_id,omitempty"`
+	InStock     *bool         `json:"in_stock,omitempty"`
+}
+
+// isEmpty reports whether the filter matches every product.
+func (f productFilter) isEmpty() bool {
+	return f == productFilter{}
+}
+
+// parse returns the filter's conditions, checked by the same rules as the
+// list query parameters.
+func (f productFilter) parse() (query.Filters, error) {
+	return productSchema.ParseFilters(f.values())
+}
+
+// values returns the filter as list query parameters.
+func (f productFilter) values() url.Values {
+	v := url.Values{}
+	if f.NameLike != nil {
+		v.Set("name_like", *f.NameLike)
+	}
+	if f.PriceGte != nil {
+		v.Set("price_gte", f.PriceGte.String())
+	}
+	if f.PriceLte != nil {
+		v.Set("price_lte", f.PriceLte.String())
+	}
+	if f.QuantityGte != nil {
+		v.Set("quantity_gte", strconv.Itoa(*f.QuantityGte))
+	}
+	if f.QuantityLte != nil {
+		v.Set("quantity_lte", strconv.Itoa(*f.QuantityLte))
+	}
+	if f.CategoryID != nil {
+		v.Set("category_id", strconv.FormatUint(uint64(*f.CategoryID), 10))
+	}
+	if f.InStock != nil {
+		v.Set("in_stock", strconv.FormatBool(*f.InStock))
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"gorm.io/gorm"
+)
+
+// generateBatchSize is how many rows go into a single INSERT; each batch is
+// its own transaction so a large run doesn't hold one huge transaction open.
+const generateBatchSize = 1000
+
+var generateAdjectives = []string{"Compact", "Deluxe", "Portable", "Wireless", "Ergonomic", "Heavy-Duty", "Smart", "Classic", "Eco", "Pro"}
+
+// generateNouns are the kinds of product generated, with the category each
+// goes in.
+var generateNouns = []struct{ name, category string }{
+	{"Laptop", "Computers"},
+	{"Keyboard", "Peripherals"},
+	{"Mouse", "Peripherals"},
+	{"Monitor", "Displays"},
+	{"Headset", "Audio"},
+	{"Chair", "Furniture"},
+	{"Desk", "Furniture"},
+	{"Lamp", "Lighting"},
+	{"Speaker", "Audio"},
+	{"Webcam", "Peripherals"},
+	{"Router", "Networking"},
+	{"Charger", "Power"},
+}
+
+// generateProducts inserts n synthetic products, in the categories their
+// kind goes in, into the tenant db's statements are confined to, or the
+// default tenant if they aren't. Categories the tenant doesn't have by
+// name yet are created. The same seed always produces the same rows, so
+// load tests can be repeated exactly.
+func generateProducts(db *gorm.DB, n int, seed int64) error {
+	tenant, ok := repository.TenantFromContext(db.Statement.Context)
+	if !ok {
+		tenant = model.DefaultTenantID
+	}
+	categories, err := generateCategories(db, tenant)
+	if err != nil {
+		return err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for done := 0; done < n; done += generateBatchSize {
+		size := min(generateBatchSize, n-done)
+		batch := make([]Product, size)
+		for i := range batch {
+			batch[i] = randomProduct(rng, done+i+1, tenant, categories)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(batch, generateBatchSize).Error
+		})
+		if err != nil {
+			return fmt.Errorf("insert batch at %d: %w", done, err)
+		}
+	}
+	return nil
+}
+
+// generateCategories returns the IDs of the categories of generateNouns in
+// tenant, by name, creating those it doesn't have.
+func generateCategories(db *gorm.DB, tenant uint) (map[string]uint, error) {
+	var names []string
+	for _, noun := range generateNouns {
+		if !slices.Contains(names, noun.category) {
+			names = append(names, noun.category)
+		}
+	}
+	var existing []model.Category
+	if err := db.Where("tenant_id = ? AND name IN ?", tenant, names).Order("id").Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("look up categories: %w", err)
+	}
+	ids := make(map[string]uint, len(names))
+	for _, c := range existing {
+		if _, ok := ids[c.Name]; !ok {
+			ids[c.Name] = c.ID
+		}
+	}
+	var missing []model.Category
+	for _, name := range names {
+		if _, ok := ids[name]; !ok {
+			missing = append(missing, model.Category{TenantID: tenant, Name: name})
+		}
+	}
+	if len(missing) > 0 {
+		if err := db.Create(&missing).Error; err != nil {
+			return nil, fmt.Errorf("create categories: %w", err)
+		}
+		for _, c := range missing {
+			ids[c.Name] = c.ID
+		}
+	}
+	return ids, nil
+}
+
+func randomProduct(rng *rand.Rand, serial int, tenant uint, categories map[string]uint) Product {
+	noun := generateNouns[rng.Intn(len(generateNouns))]
+	name := fmt.Sprintf("%s %s %d",
+		generateAdjectives[rng.Intn(len(generateAdjectives))],
+		noun.name,
+		serial)
+	// Prices cluster at the low end like a real catalog: 1.00 to ~5000.00
+	price := money.FromFloat(math.Exp(rng.Float64() * math.Log(5000)))
+	category := categories[noun.category]
+	return Product{
+		TenantID:   tenant,
+		Name:       name,
+		Price:      price,
+		Quantity:   rng.Intn(500),
+		CategoryID: &category,
+	}
+}
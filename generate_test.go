@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/testutil"
+	"gorm.io/gorm"
+)
+
+func TestGenerateProducts(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), model.DefaultTenantID)
+	generate := func(db *gorm.DB, n int, seed int64) []Product {
+		t.Helper()
+		if err := generateProducts(db, n, seed); err != nil {
+			t.Fatal(err)
+		}
+		var products []Product
+		if err := db.Order("id").Find(&products).Error; err != nil {
+			t.Fatal(err)
+		}
+		var categories int64
+		if err := db.Model(&Category{}).Count(&categories).Error; err != nil {
+			t.Fatal(err)
+		}
+		if categories != 8 {
+			t.Errorf("%d categories, want 8", categories)
+		}
+		return products
+	}
+
+	// More than a batch
+	n := generateBatchSize + 7
+	db := testutil.DB(t).WithContext(ctx)
+	products := generate(db, n, 42)
+	if len(products) != n {
+		t.Fatalf("inserted %d products, want %d", len(products), n)
+	}
+	for _, p := range products {
+		if p.CategoryID == nil || p.TenantID != model.DefaultTenantID {
+			t.Fatalf("product %+v has no category or the wrong tenant", p)
+		}
+	}
+	// The categories are reused
+	if more := generate(db, 3, 7); len(more) != n+3 {
+		t.Errorf("inserted %d more products, want 3", len(more)-n)
+	}
+
+	again := generate(testutil.DB(t).WithContext(ctx), n, 42)
+	for i := range again {
+		if again[i].Name != products[i].Name || again[i].Price != products[i].Price || again[i].Quantity != products[i].Quantity {
+			t.Fatalf("the same seed generated %+v, then %+v", products[i], again[i])
+		}
+	}
+}
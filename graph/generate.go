@@ -0,0 +1,6 @@
+// Package graph is the GraphQL schema of the product API and the executor
+// generated from it by gqlgen. Its resolvers are implemented in package
+// main, on the same service layer as the REST and gRPC APIs.
+package graph
+
+//go:generate go run github.com/99designs/gqlgen@v0.17.49 generate --config gqlgen.yml
@@ -0,0 +1,58 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+// Mutations need an admin access token.
+type Mutation struct {
+}
+
+type PageInfo struct {
+	HasNextPage bool `json:"hasNextPage"`
+	// The cursor of the last edge; pass it as after for the next page.
+	EndCursor *string `json:"endCursor,omitempty"`
+}
+
+type ProductConnection struct {
+	Edges    []*ProductEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+	// The number of products matching the filter.
+	TotalCount int `json:"totalCount"`
+}
+
+type ProductEdge struct {
+	Cursor string         `json:"cursor"`
+	Node   *model.Product `json:"node"`
+}
+
+// Selects products like the filters of GET /products. Unset fields don't constrain the result.
+type ProductFilter struct {
+	NameLike    *string       `json:"nameLike,omitempty"`
+	PriceGte    *money.Amount `json:"priceGte,omitempty"`
+	PriceLte    *money.Amount `json:"priceLte,omitempty"`
+	QuantityGte *int          `json:"quantityGte,omitempty"`
+	QuantityLte *int          `json:"quantityLte,omitempty"`
+	CategoryID  *uint         `json:"categoryId,omitempty"`
+	SupplierID  *uint         `json:"supplierId,omitempty"`
+}
+
+// The editable fields of a product.
+type ProductInput struct {
+	Name  string       `json:"name"`
+	Price money.Amount `json:"price"`
+	// USD if unset on create; unset keeps the current currency on update.
+	Currency   *string `json:"currency,omitempty"`
+	Quantity   int     `json:"quantity"`
+	MinStock   *int    `json:"minStock,omitempty"`
+	MaxStock   *int    `json:"maxStock,omitempty"`
+	Sku        *string `json:"sku,omitempty"`
+	CategoryID *uint   `json:"categoryId,omitempty"`
+	SupplierID *uint   `json:"supplierId,omitempty"`
+}
+
+type Query struct {
+}
@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// MarshalID writes a record ID as the string the GraphQL ID type is
+// serialized as.
+func MarshalID(id uint) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(strconv.FormatUint(uint64(id), 10)))
+	})
+}
+
+// UnmarshalID reads a record ID given as a string or an integer. Its
+// errors, like UnmarshalMoney's, are GraphQL errors, so that they are
+// reported as invalid input rather than as failures of the server.
+func UnmarshalID(v any) (uint, error) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = string(v)
+	case int:
+		s = strconv.Itoa(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return 0, gqlerror.Errorf("%T is not an ID", v)
+	}
+	id, err := strconv.ParseUint(s, 10, 0)
+	if err != nil || id == 0 {
+		return 0, gqlerror.Errorf("%q is not an ID", s)
+	}
+	return uint(id), nil
+}
+
+// MarshalMoney writes an amount as a decimal string, as the REST API does.
+func MarshalMoney(a money.Amount) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(a.String()))
+	})
+}
+
+// UnmarshalMoney reads an amount given as a decimal string or a number.
+func UnmarshalMoney(v any) (money.Amount, error) {
+	switch v := v.(type) {
+	case string:
+		a, err := money.Parse(v)
+		if err != nil {
+			return 0, gqlerror.Errorf("%q is not an amount", v)
+		}
+		return a, nil
+	case json.Number:
+		return unmarshalAmount(string(v))
+	case int:
+		return unmarshalAmount(strconv.Itoa(v))
+	case int64:
+		return unmarshalAmount(strconv.FormatInt(v, 10))
+	case float64:
+		return unmarshalAmount(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return 0, gqlerror.Errorf("%T is not an amount", v)
+	}
+}
+
+// unmarshalAmount reads an amount from the text of a number, so that 19.99
+// is exactly 1999 minor units.
+func unmarshalAmount(number string) (money.Amount, error) {
+	var a money.Amount
+	if err := a.UnmarshalJSON([]byte(number)); err != nil {
+		return 0, gqlerror.Errorf("%s is not an amount", number)
+	}
+	return a, nil
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/graph"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+// maxGraphQLComplexity bounds the cost of a GraphQL query: one per field,
+// with the fields of a list counted once per item it can return.
+const maxGraphQLComplexity = 20000
+
+// newGraphQLHandler builds the GraphQL API on d. It is mounted under the
+// versioned API, so requests are authenticated, scoped to their tenant and
+// rate limited like the REST API's.
+func newGraphQLHandler(d *deps) http.Handler {
+	cfg := graph.Config{Resolvers: &gqlResolver{d: d}}
+	cfg.Complexity.Query.Products = func(childComplexity int, first *int, _ *string, _ *string, _ *graph.ProductFilter) int {
+		return listComplexity(childComplexity, first)
+	}
+	cfg.Complexity.Query.Search = func(childComplexity int, _ string, first *int, _ *graph.ProductFilter) int {
+		return listComplexity(childComplexity, first)
+	}
+	srv := handler.New(graph.NewExecutableSchema(cfg))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(maxGraphQLComplexity))
+	srv.SetErrorPresenter(presentGraphQLError)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), languageKey{}, requestLanguage(r))
+		srv.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func listComplexity(childComplexity int, first *int) int {
+	if first == nil || *first < 1 {
+		return childComplexity
+	}
+	return childComplexity * *first
+}
+
+// languageKey holds the language a GraphQL request's errors are localized
+// for.
+type languageKey struct{}
+
+// presentGraphQLError is the GraphQL counterpart of writeAPIError: the
+// message is localized for the request, and the error code and any field
+// errors are in the extensions. Errors in the request itself, such as a
+// syntax error, are left as gqlgen reports them.
+func presentGraphQLError(ctx context.Context, err error) *gqlerror.Error {
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) && gqlErr.Err == nil {
+		return gqlErr
+	}
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+	apiErr := asAPIError(err)
+	if errorStatus(err) == http.StatusInternalServerError {
+		if _, code, ok := contextFailure(ctx); ok {
+			apiErr = newAPIError(code)
+		}
+	}
+	lang, _ := ctx.Value(languageKey{}).(language.Tag)
+	presented.Message = apiErr.message(lang)
+	presented.Extensions = map[string]any{"code": apiErr.Code}
+	if len(apiErr.Fields) > 0 {
+		fields := make([]map[string]string, 0, len(apiErr.Fields))
+		for _, f := range apiErr.Fields {
+			fields = append(fields, map[string]string{"field": f.Field, "message": f.Err.message(lang)})
+		}
+		presented.Extensions["fields"] = fields
+	}
+	return presented
+}
+
+// gqlResolver resolves the GraphQL schema with the same service layer and
+// cache as the REST handlers. Like grpcProducts, its reads always use the
+// primary.
+type gqlResolver struct {
+	d *deps
+}
+
+func (r *gqlResolver) Query() graph.QueryResolver       { return gqlQuery{r} }
+func (r *gqlResolver) Mutation() graph.MutationResolver { return gqlMutation{r} }
+func (r *gqlResolver) Product() graph.ProductResolver   { return gqlProduct{r} }
+
+// service returns the product service for ctx's writes and reads of plain
+// products.
+func (r *gqlResolver) service(ctx context.Context) *service.ProductService {
+	repo := repository.NewProductRepository(r.d.db.WithContext(ctx))
+	return service.NewProductService(r.d.cachedProducts(ctx, repo)).As(actorFor(ctx)).WithContext(ctx)
+}
+
+// reader returns the product service for reads of products whose fields
+// at path under the current field are selected. The associations among
+// them are loaded with the products, as with ?expand=; only reads of plain
+// products are cached.
+func (r *gqlResolver) reader(ctx context.Context, path ...string) *service.ProductService {
+	assocs := selectedAssociations(ctx, path...)
+	if len(assocs) == 0 {
+		return r.service(ctx)
+	}
+	conn := r.d.db.WithContext(ctx)
+	for _, assoc := range assocs {
+		conn = conn.Preload(assoc)
+	}
+	return service.NewProductService(repository.NewProductRepository(conn)).WithContext(ctx)
+}
+
+// selectedAssociations returns the product associations selected at path
+// under the current field.
+func selectedAssociations(ctx context.Context, path ...string) []string {
+	opCtx := graphql.GetOperationContext(ctx)
+	fields := graphql.CollectFieldsCtx(ctx, nil)
+	for _, name := range path {
+		var next []graphql.CollectedField
+		for _, f := range fields {
+			if f.Name == name {
+				next = append(next, graphql.CollectFields(opCtx, f.Selections, nil)...)
+			}
+		}
+		fields = next
+	}
+	var assocs []string
+	for _, f := range fields {
+		if assoc, ok := productExpansions[f.Name]; ok && !slices.Contains(assocs, assoc) {
+			assocs = append(assocs, assoc)
+		}
+	}
+	return assocs
+}
+
+// requireAdminGraphQL is the GraphQL counterpart of requireAdmin.
+func requireAdminGraphQL(ctx context.Context) error {
+	claims, _ := ctx.Value(claimsKey{}).(*auth.Claims)
+	if claims == nil {
+		return newAPIError("unauthorized")
+	}
+	if claims.Role != model.RoleAdmin {
+		return newAPIError("forbidden")
+	}
+	return nil
+}
+
+type gqlQuery struct{ *gqlResolver }
+
+func (r gqlQuery) Product(ctx context.Context, id uint) (*Product, error) {
+	product, err := r.reader(ctx).Get(id)
+	if errors.Is(err, service.ErrNotFound) {
+		return nil, nil
+	}
+	return product, err
+}
+
+func (r gqlQuery) Products(ctx context.Context, first *int, after *string, sort *string, filter *graph.ProductFilter) (*graph.ProductConnection, error) {
+	// Reuse the REST query parameters, so both APIs filter, sort and
+	// paginate alike
+	values := filterValues(filter)
+	if first != nil {
+		values.Set("limit", strconv.Itoa(*first))
+	}
+	if after != nil {
+		values.Set("cursor", *after)
+	}
+	if sort != nil {
+		values.Set("sort", *sort)
+	}
+	params, err := productSchema.Parse(values)
+	if err != nil {
+		return nil, err
+	}
+	products, total, err := r.reader(ctx, "edges", "node").List(params)
+	if err != nil {
+		return nil, err
+	}
+	products, next, err := query.Next(params, products)
+	if err != nil {
+		return nil, err
+	}
+	conn := &graph.ProductConnection{
+		Edges:      make([]*graph.ProductEdge, len(products)),
+		PageInfo:   &graph.PageInfo{HasNextPage: next != ""},
+		TotalCount: int(total),
+	}
+	for i := range products {
+		cursor, err := query.After(params, products[i])
+		if err != nil {
+			return nil, err
+		}
+		conn.Edges[i] = &graph.ProductEdge{Cursor: cursor, Node: &products[i]}
+		conn.PageInfo.EndCursor = &cursor
+	}
+	return conn, nil
+}
+
+func (r gqlQuery) Search(ctx context.Context, text string, first *int, filter *graph.ProductFilter) ([]*Product, error) {
+	filters, err := productSchema.ParseFilters(filterValues(filter))
+	if err != nil {
+		return nil, err
+	}
+	limit := defaultSearchLimit
+	if first != nil {
+		limit = *first
+	}
+	if limit < 1 || limit > maxSearchLimit {
+		return nil, newAPIError("invalid_limit", maxSearchLimit)
+	}
+	products, err := r.reader(ctx).Search(text, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Product, len(products))
+	for i := range products {
+		results[i] = &products[i]
+	}
+	return results, nil
+}
+
+func (r gqlQuery) Categories(ctx context.Context) ([]*Category, error) {
+	var categories []*Category
+	if err := r.d.db.WithContext(ctx).Order("id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r gqlQuery) Category(ctx context.Context, id uint) (*Category, error) {
+	var category Category
+	err := r.d.db.WithContext(ctx).First(&category, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// filterValues returns filter as list query parameters.
+func filterValues(filter *graph.ProductFilter) url.Values {
+	if filter == nil {
+		return url.Values{}
+	}
+	values := productFilter{
+		NameLike:    filter.NameLike,
+		PriceGte:    filter.PriceGte,
+		PriceLte:    filter.PriceLte,
+		QuantityGte: filter.QuantityGte,
+		QuantityLte: filter.QuantityLte,
+		CategoryID:  filter.CategoryID,
+	}.values()
+	if filter.SupplierID != nil {
+		values.Set("supplier_id", strconv.FormatUint(uint64(*filter.SupplierID), 10))
+	}
+	return values
+}
+
+type gqlMutation struct{ *gqlResolver }
+
+func (r gqlMutation) CreateProduct(ctx context.Context, input graph.ProductInput) (*Product, error) {
+	if err := requireAdminGraphQL(ctx); err != nil {
+		return nil, err
+	}
+	product := productFromInput(input)
+	if err := r.service(ctx).Create(product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r gqlMutation) UpdateProduct(ctx context.Context, id uint, version int, input graph.ProductInput) (*Product, error) {
+	if err := requireAdminGraphQL(ctx); err != nil {
+		return nil, err
+	}
+	if version < 1 {
+		return nil, newAPIError("version_required")
+	}
+	return r.service(ctx).Update(id, uint(version), productFromInput(input))
+}
+
+func (r gqlMutation) DeleteProduct(ctx context.Context, id uint) (bool, error) {
+	if err := requireAdminGraphQL(ctx); err != nil {
+		return false, err
+	}
+	if err := r.service(ctx).Delete(id, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r gqlMutation) AdjustStock(ctx context.Context, id uint, delta int, reason string) (*Product, error) {
+	if err := requireAdminGraphQL(ctx); err != nil {
+		return nil, err
+	}
+	return r.service(ctx).AdjustStock(id, delta, reason)
+}
+
+func (r gqlMutation) ReserveStock(ctx context.Context, id uint, quantity int, reason *string) (*Product, error) {
+	if err := requireAdminGraphQL(ctx); err != nil {
+		return nil, err
+	}
+	return r.service(ctx).Reserve(id, quantity, stringValue(reason))
+}
+
+func (r gqlMutation) ReleaseStock(ctx context.Context, id uint, quantity int, reason *string) (*Product, error) {
+	if err := requireAdminGraphQL(ctx); err != nil {
+		return nil, err
+	}
+	return r.service(ctx).Release(id, quantity, stringValue(reason))
+}
+
+// productFromInput returns the product with the editable fields of input.
+func productFromInput(input graph.ProductInput) *Product {
+	product := &Product{
+		Name:       input.Name,
+		Price:      input.Price,
+		Currency:   stringValue(input.Currency),
+		Quantity:   input.Quantity,
+		CategoryID: input.CategoryID,
+		SupplierID: input.SupplierID,
+		SKU:        input.Sku,
+	}
+	if input.MinStock != nil {
+		product.MinStock = *input.MinStock
+	}
+	if input.MaxStock != nil {
+		product.MaxStock = *input.MaxStock
+	}
+	return product
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// gqlProduct resolves the associations of a product: from the product if
+// the query loaded them, or else one at a time, as for the product a
+// mutation returns.
+type gqlProduct struct{ *gqlResolver }
+
+func (r gqlProduct) Category(ctx context.Context, product *Product) (*Category, error) {
+	if product.Category != nil || product.CategoryID == nil {
+		return product.Category, nil
+	}
+	return gqlQuery(r).Category(ctx, *product.CategoryID)
+}
+
+func (r gqlProduct) Supplier(ctx context.Context, product *Product) (*Supplier, error) {
+	if product.Supplier != nil || product.SupplierID == nil {
+		return product.Supplier, nil
+	}
+	var supplier Supplier
+	err := r.d.db.WithContext(ctx).First(&supplier, *product.SupplierID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
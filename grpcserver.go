@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/logging"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/productpb"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpccreds "google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newGRPCServer builds the gRPC API on d: the product service, the
+// standard health service, and reflection for tools such as grpcurl. It
+// serves TLS with tlsConfig, if not nil.
+func newGRPCServer(d *deps, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler()), grpc.ChainUnaryInterceptor(logRPCs, recoverRPC, authenticateRPC, scopeTenantRPC(d))}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(grpccreds.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
+	productpb.RegisterProductServiceServer(srv, &grpcProducts{d: d})
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+	return srv
+}
+
+// logRPCs is the gRPC counterpart of logRequests: it gives every call an
+// ID, from the x-request-id metadata if valid, and logs the call when it
+// completes.
+func logRPCs(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDHeader); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+	ctx = logging.WithRequestID(ctx, id)
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown || code == codes.DeadlineExceeded {
+		level = slog.LevelError
+	}
+	logging.FromContext(ctx).Log(ctx, level, "rpc",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"duration_ms", float64(time.Since(start).Microseconds())/1000,
+	)
+	return resp, err
+}
+
+// authenticateRPC is the gRPC counterpart of authenticate: it verifies the
+// bearer access token in the authorization metadata, if any, and makes its
+// claims available to handlers. It also sets up database failure tracking
+// like trackDBFailures.
+func authenticateRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = context.WithValue(ctx, dbFailureKey{}, new(dbFailure))
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		claims, err := tokens.VerifyAccess(token)
+		if !ok || err != nil {
+			return nil, rpcError(ctx, newAPIError("invalid_token"), http.StatusUnauthorized)
+		}
+		ctx = context.WithValue(ctx, claimsKey{}, claims)
+	}
+	return handler(ctx, req)
+}
+
+// scopeTenantRPC returns the gRPC counterpart of scopeTenant, taking the
+// tenant from the token or the x-tenant-id metadata.
+func scopeTenantRPC(d *deps) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var header string
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(strings.ToLower(tenantHeader)); len(values) > 0 {
+			header = values[0]
+		}
+		claims, _ := ctx.Value(claimsKey{}).(*auth.Claims)
+		tenant, code, err := resolveTenant(d.db.WithContext(ctx), claims, header)
+		if err != nil {
+			return nil, rpcError(ctx, err, code)
+		}
+		return handler(repository.WithTenant(ctx, tenant), req)
+	}
+}
+
+// requireAdminRPC is the gRPC counterpart of requireAdmin.
+func requireAdminRPC(ctx context.Context) error {
+	claims, _ := ctx.Value(claimsKey{}).(*auth.Claims)
+	if claims == nil {
+		return rpcError(ctx, newAPIError("unauthorized"), http.StatusUnauthorized)
+	}
+	if claims.Role != model.RoleAdmin {
+		return rpcError(ctx, newAPIError("forbidden"), http.StatusForbidden)
+	}
+	return nil
+}
+
+// rpcCodes maps the HTTP statuses of errorStatus and contextFailure to gRPC
+// codes. Anything else is Internal.
+var rpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:           codes.InvalidArgument,
+	http.StatusUnauthorized:         codes.Unauthenticated,
+	http.StatusForbidden:            codes.PermissionDenied,
+	http.StatusNotFound:             codes.NotFound,
+	http.StatusConflict:             codes.FailedPrecondition,
+	http.StatusPreconditionRequired: codes.FailedPrecondition,
+	http.StatusUnprocessableEntity:  codes.InvalidArgument,
+	http.StatusServiceUnavailable:   codes.Unavailable,
+	http.StatusGatewayTimeout:       codes.DeadlineExceeded,
+	statusClientClosedRequest:       codes.Canceled,
+}
+
+// rpcError is the gRPC counterpart of writeAPIError. The status message is
+// localized for the accept-language metadata; the error code and any
+// field errors are attached as ErrorInfo and BadRequest details.
+func rpcError(ctx context.Context, err error, httpStatus int) error {
+	apiErr := asAPIError(err)
+	if httpStatus == http.StatusInternalServerError {
+		if ctxStatus, code, ok := contextFailure(ctx); ok {
+			httpStatus, apiErr = ctxStatus, newAPIError(code)
+		}
+	}
+	code, ok := rpcCodes[httpStatus]
+	if !ok {
+		code = codes.Internal
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	lang := matchLanguage(strings.Join(md.Get("accept-language"), ","))
+
+	st := status.New(code, apiErr.message(lang))
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: apiErr.Code, Domain: "golangdb"}}
+	if len(apiErr.Fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range apiErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Err.message(lang),
+			})
+		}
+		details = append(details, badRequest)
+	}
+	withDetails, detailErr := st.WithDetails(details...)
+	if detailErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// rpcServiceError is the gRPC counterpart of writeServiceError.
+func rpcServiceError(ctx context.Context, err error) error {
+	return rpcError(ctx, err, errorStatus(err))
+}
+
+// grpcProducts serves productpb.ProductService with the same service layer
+// and cache as the REST handlers. Its reads always use the primary.
+type grpcProducts struct {
+	productpb.UnimplementedProductServiceServer
+	d *deps
+}
+
+func (s *grpcProducts) service(ctx context.Context) *service.ProductService {
+	repo := repository.NewProductRepository(s.d.db.WithContext(ctx))
+	return service.NewProductService(s.d.cachedProducts(ctx, repo)).As(actorFor(ctx)).WithContext(ctx)
+}
+
+func (s *grpcProducts) GetProduct(ctx context.Context, req *productpb.GetProductRequest) (*productpb.Product, error) {
+	product, err := s.service(ctx).Get(uint(req.Id))
+	if err != nil {
+		return nil, rpcServiceError(ctx, err)
+	}
+	return productToProto(product), nil
+}
+
+func (s *grpcProducts) ListProducts(ctx context.Context, req *productpb.ListProductsRequest) (*productpb.ListProductsResponse, error) {
+	// Reuse the REST query parameters, so both transports filter, sort and
+	// paginate alike
+	values := url.Values{}
+	for name, value := range req.Filters {
+		values.Set(name, value)
+	}
+	for _, name := range []string{"limit", "page", "cursor", "sort"} {
+		values.Del(name)
+	}
+	if req.PageSize != 0 {
+		values.Set("limit", strconv.Itoa(int(req.PageSize)))
+	}
+	if req.PageToken != "" {
+		values.Set("cursor", req.PageToken)
+	}
+	if req.Sort != "" {
+		values.Set("sort", req.Sort)
+	}
+	params, err := productSchema.Parse(values)
+	if err != nil {
+		return nil, rpcError(ctx, err, http.StatusBadRequest)
+	}
+	products, total, err := s.service(ctx).List(params)
+	if err != nil {
+		return nil, rpcServiceError(ctx, err)
+	}
+	products, next, err := query.Next(params, products)
+	if err != nil {
+		return nil, rpcServiceError(ctx, err)
+	}
+	resp := &productpb.ListProductsResponse{NextPageToken: next, TotalSize: total}
+	for i := range products {
+		resp.Products = append(resp.Products, productToProto(&products[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcProducts) CreateProduct(ctx context.Context, req *productpb.CreateProductRequest) (*productpb.Product, error) {
+	if err := requireAdminRPC(ctx); err != nil {
+		return nil, err
+	}
+	product := productFromProto(req.Product)
+	if err := s.service(ctx).Create(product); err != nil {
+		return nil, rpcServiceError(ctx, err)
+	}
+	return productToProto(product), nil
+}
+
+func (s *grpcProducts) UpdateProduct(ctx context.Context, req *productpb.UpdateProductRequest) (*productpb.Product, error) {
+	if err := requireAdminRPC(ctx); err != nil {
+		return nil, err
+	}
+	if req.Version == 0 {
+		return nil, rpcError(ctx, newAPIError("version_required"), http.StatusPreconditionRequired)
+	}
+	product, err := s.service(ctx).Update(uint(req.Id), uint(req.Version), productFromProto(req.Product))
+	if err != nil {
+		return nil, rpcServiceError(ctx, err)
+	}
+	return productToProto(product), nil
+}
+
+func (s *grpcProducts) DeleteProduct(ctx context.Context, req *productpb.DeleteProductRequest) (*emptypb.Empty, error) {
+	if err := requireAdminRPC(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.service(ctx).Delete(uint(req.Id), 0); err != nil {
+		return nil, rpcServiceError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func productToProto(p *Product) *productpb.Product {
+	return &productpb.Product{
+		Id:         uint64(p.ID),
+		Name:       p.Name,
+		Price:      p.Price.Float(),
+		PriceMinor: int64(p.Price),
+		Currency:   p.Currency,
+		Quantity:   int64(p.Quantity),
+		Reserved:   int64(p.Reserved),
+		MinStock:   int64(p.MinStock),
+		MaxStock:   int64(p.MaxStock),
+		CategoryId: optionalToProto(p.CategoryID),
+		SupplierId: optionalToProto(p.SupplierID),
+		Sku:        p.SKU,
+		Version:    uint64(p.Version),
+		CreatedAt:  timestamppb.New(p.CreatedAt),
+		UpdatedAt:  timestamppb.New(p.UpdatedAt),
+	}
+}
+
+// productFromProto returns the product with the editable fields of in,
+// which may be nil.
+func productFromProto(in *productpb.ProductInput) *Product {
+	if in == nil {
+		return &Product{}
+	}
+	price := money.FromFloat(in.Price)
+	if in.PriceMinor != nil {
+		price = money.Amount(*in.PriceMinor)
+	}
+	return &Product{
+		Name:       in.Name,
+		Price:      price,
+		Currency:   in.Currency,
+		Quantity:   int(in.Quantity),
+		MinStock:   int(in.MinStock),
+		MaxStock:   int(in.MaxStock),
+		CategoryID: optionalFromProto(in.CategoryId),
+		SupplierID: optionalFromProto(in.SupplierId),
+		SKU:        in.Sku,
+	}
+}
+
+func optionalToProto(id *uint) *uint64 {
+	if id == nil {
+		return nil
+	}
+	v := uint64(*id)
+	return &v
+}
+
+func optionalFromProto(id *uint64) *uint {
+	if id == nil {
+		return nil
+	}
+	v := uint(*id)
+	return &v
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/logging"
+)
+
+const readinessPingTimeout = 2 * time.Second
+
+var (
+	// migrated is set once the schema is known to be up to date.
+	migrated atomic.Bool
+	// draining is set when shutdown begins, so load balancers stop sending
+	// traffic while in-flight requests finish.
+	draining atomic.Bool
+)
+
+// healthStatus is the body of /healthz and /readyz.
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Report that the process is up
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, r, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// Report whether the service can serve traffic: the database answers, the
+// schema is migrated, and shutdown hasn't started
+func readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"database": "ok", "migrations": "ok"}
+	ready := true
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+	defer cancel()
+	sqlDB, err := depsFor(r).db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		// The error may name hosts; keep it in the logs only
+		logging.FromContext(r.Context()).Warn("readiness: database ping failed", "error", err)
+		checks["database"] = "unreachable"
+		ready = false
+	}
+	// Reads fall back to the primary, so replicas only ever degrade
+	if replicas := depsFor(r).replicas; replicas != nil {
+		switch healthy, total := replicas.healthy(); healthy {
+		case total:
+			checks["replicas"] = "ok"
+		case 0:
+			checks["replicas"] = "unavailable"
+		default:
+			checks["replicas"] = "degraded"
+		}
+	}
+	if !migrated.Load() {
+		checks["migrations"] = "pending"
+		ready = false
+	}
+	if draining.Load() {
+		checks["shutdown"] = "draining"
+		ready = false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready {
+		writeJSON(w, r, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Checks: checks})
+		return
+	}
+	writeJSON(w, r, http.StatusOK, healthStatus{Status: "ready", Checks: checks})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotentHeaders are the response headers replayed along with the
+// status and body. The rest, such as X-Request-ID, belong to each request.
+var idempotentHeaders = []string{"Content-Type", "Content-Language", "ETag", "Location", "Preference-Applied"}
+
+// idempotencyKey is a row of idempotency_keys: the request a client sent
+// with a key and, once it has completed, the response to replay. Keys are
+// per tenant and user, so that one can't replay another's response.
+type idempotencyKey struct {
+	TenantID       uint   `gorm:"primaryKey"`
+	Owner          string `gorm:"primaryKey"`
+	IdempotencyKey string `gorm:"primaryKey"`
+	RequestHash    string
+	// Status is the response status, or 0 while the request is in
+	// progress.
+	Status    int
+	Header    http.Header `gorm:"serializer:json"`
+	Body      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// idempotent makes retries of next with the same Idempotency-Key header
+// safe: the first request runs, and later ones with the same key and
+// payload get its response back instead of running again. Reusing a key
+// for a different payload gets 422, and one whose first request is still
+// running 409. Server errors and panics aren't kept, so those can be
+// retried. Requests
+// without the header, and dry runs, which change nothing, run as usual.
+func idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || dryRun(r) {
+			next(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			writeError(w, r, http.StatusBadRequest, "invalid_idempotency_key")
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_payload")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		d := depsFor(r)
+		db := d.db.WithContext(r.Context())
+		now := time.Now().UTC()
+		claim := idempotencyKey{
+			TenantID:       tenantFor(r),
+			Owner:          actorFor(r.Context()),
+			IdempotencyKey: key,
+			RequestHash:    requestHash(r, body),
+			CreatedAt:      now,
+			ExpiresAt:      now.Add(time.Duration(d.cfg.Idempotency.TTL)),
+		}
+		stored, err := claimIdempotencyKey(db, &claim, now)
+		switch {
+		case err != nil:
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		case stored == nil:
+		case stored.RequestHash != claim.RequestHash:
+			writeError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused")
+			return
+		case stored.Status == 0:
+			writeError(w, r, http.StatusConflict, "idempotency_key_in_use")
+			return
+		default:
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			io.WriteString(w, stored.Body)
+			return
+		}
+
+		// Keep the response even if the client has gone, since the request
+		// may well have taken effect
+		db = d.db.WithContext(context.WithoutCancel(r.Context()))
+		where := db.Model(&idempotencyKey{}).Where("tenant_id = ? AND owner = ? AND idempotency_key = ?", claim.TenantID, claim.Owner, key)
+		completed := false
+		defer func() {
+			if !completed {
+				// next panicked: release the key so that a retry runs again
+				if err := where.Delete(&idempotencyKey{}).Error; err != nil {
+					slog.ErrorContext(r.Context(), "failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+		rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		completed = true
+		if rec.status >= http.StatusInternalServerError {
+			err = where.Delete(&idempotencyKey{}).Error
+		} else {
+			header := http.Header{}
+			for _, name := range idempotentHeaders {
+				if values := w.Header().Values(name); len(values) > 0 {
+					header[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			err = where.Updates(&idempotencyKey{Status: rec.status, Header: header, Body: rec.body.String()}).Error
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+// claimIdempotencyKey stores claim, for a request about to run, unless its
+// key is already taken. It returns the row that holds the key, or nil if
+// claim now does. Expired keys are purged first.
+func claimIdempotencyKey(db *gorm.DB, claim *idempotencyKey, now time.Time) (*idempotencyKey, error) {
+	if err := db.Where("expires_at <= ?", now).Delete(&idempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(claim)
+	if res.Error != nil || res.RowsAffected == 1 {
+		return nil, res.Error
+	}
+	var stored idempotencyKey
+	err := db.Where("tenant_id = ? AND owner = ? AND idempotency_key = ?", claim.TenantID, claim.Owner, claim.IdempotencyKey).Take(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Completed with a server error and released in the meantime
+		return claimIdempotencyKey(db, claim, now)
+	}
+	return &stored, err
+}
+
+// validIdempotencyKey reports whether key is 1 to 255 printable ASCII
+// characters.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHash identifies a request's payload: its method, path, query and
+// body.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyRecorder passes a response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/service"
+	"github.com/mjpvl-ai/golangdb/storage"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+// mediaPrefix is where the API serves the files of local storage.
+const mediaPrefix = "/media/"
+
+// maxAltLength bounds the alternative text of an image, as its column
+// does.
+const maxAltLength = 255
+
+// imageTypes are the image formats accepted, by the media type sniffed
+// from the upload, with the extension they are stored under.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// newStorage returns the storage for product images and export files
+// configured by cfg.
+func newStorage(cfg config.Storage) (storage.Storage, error) {
+	if cfg.Driver == config.StorageS3 {
+		return storage.NewS3(storage.S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Insecure:  cfg.S3Insecure,
+			PublicURL: cfg.PublicURL,
+			URLExpiry: time.Duration(cfg.URLExpiry),
+		})
+	}
+	baseURL := mediaPrefix
+	if cfg.PublicURL != "" {
+		baseURL = cfg.PublicURL
+	}
+	return storage.NewLocal(cfg.Dir, baseURL)
+}
+
+// setImageURLs fills in the URL of each of product's images, for responses
+// that loaded them.
+func setImageURLs(r *http.Request, product *Product) error {
+	for i := range product.Images {
+		if err := setImageURL(r, &product.Images[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setImageURL(r *http.Request, img *model.ProductImage) error {
+	url, err := depsFor(r).storage.URL(r.Context(), img.Key)
+	img.URL = url
+	return err
+}
+
+// routeProduct loads the product of a route under /products/{id}, such as
+// its images or translations, answering 404 if there is none.
+func routeProduct(w http.ResponseWriter, r *http.Request) (*Product, bool) {
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return nil, false
+	}
+	var product Product
+	if err := dbFor(r).First(&product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "product_not_found")
+		} else {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+		}
+		return nil, false
+	}
+	return &product, true
+}
+
+// Get the images of a product
+func getProductImages(w http.ResponseWriter, r *http.Request) {
+	product, ok := routeProduct(w, r)
+	if !ok {
+		return
+	}
+	images := []model.ProductImage{}
+	if err := readDBFor(r).Where("product_id = ?", product.ID).Order("id").Find(&images).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	for i := range images {
+		if err := setImageURL(r, &images[i]); err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
+			return
+		}
+	}
+	writeJSON(w, r, http.StatusOK, map[string][]model.ProductImage{"data": images})
+}
+
+// Upload an image of a product as the "file" part of a
+// multipart/form-data body, with optional "alt" text
+func uploadProductImage(w http.ResponseWriter, r *http.Request) {
+	product, ok := routeProduct(w, r)
+	if !ok {
+		return
+	}
+	d := depsFor(r)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	var size int64
+	img := model.ProductImage{ProductID: product.ID}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_payload")
+			return
+		}
+		switch part.FormName() {
+		case "alt":
+			alt, err := io.ReadAll(io.LimitReader(part, 4*maxAltLength+1))
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "invalid_payload")
+				return
+			}
+			img.Alt = string(alt)
+		case "file":
+			if file != nil {
+				continue
+			}
+			// Spooled to disk, to be checked before it is stored
+			if file, err = os.CreateTemp("", "golangdb-image-*"); err != nil {
+				writeError(w, r, http.StatusInternalServerError, "internal_error")
+				return
+			}
+			limit := int64(d.cfg.Storage.MaxImageBytes)
+			if size, err = io.Copy(file, io.LimitReader(part, limit+1)); err != nil {
+				writeError(w, r, http.StatusBadRequest, "invalid_payload")
+				return
+			}
+			if size > limit {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "image_too_large", limit)
+				return
+			}
+		}
+	}
+	if file == nil {
+		writeError(w, r, http.StatusBadRequest, "missing_image_file")
+		return
+	}
+	if !utf8.ValidString(img.Alt) || utf8.RuneCountInString(img.Alt) > maxAltLength {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, &service.ValidationError{Fields: []service.FieldError{
+			{Field: "alt", Code: "too_long", Args: []any{maxAltLength}},
+		}})
+		return
+	}
+
+	head := make([]byte, 512)
+	n, _ := file.ReadAt(head, 0)
+	img.ContentType = http.DetectContentType(head[:n])
+	ext, ok := imageTypes[img.ContentType]
+	if !ok {
+		writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_image_type", "JPEG, PNG, GIF or WebP")
+		return
+	}
+	dims, _, err := image.DecodeConfig(io.NewSectionReader(file, 0, size))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_image")
+		return
+	}
+	img.Size, img.Width, img.Height = size, dims.Width, dims.Height
+
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	img.Key = fmt.Sprintf("products/%d/%d/%s%s", tenantFor(r), product.ID, hex.EncodeToString(name), ext)
+	if err := d.storage.Put(r.Context(), img.Key, io.NewSectionReader(file, 0, size), size, img.ContentType); err != nil {
+		slog.ErrorContext(r.Context(), "failed to store image", "key", img.Key, "error", err)
+		writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
+		return
+	}
+	if err := dbFor(r).Create(&img).Error; err != nil {
+		if err := d.storage.Delete(r.Context(), img.Key); err != nil {
+			slog.WarnContext(r.Context(), "failed to delete orphaned image", "key", img.Key, "error", err)
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if err := setImageURL(r, &img); err != nil {
+		writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, img)
+}
+
+// Delete an image of a product
+func deleteProductImage(w http.ResponseWriter, r *http.Request) {
+	product, ok := routeProduct(w, r)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.ParseUint(mux.Vars(r)["image"], 10, 0)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "image_not_found")
+		return
+	}
+	var img model.ProductImage
+	err = dbFor(r).Where("product_id = ?", product.ID).First(&img, imageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, r, http.StatusNotFound, "image_not_found")
+		return
+	}
+	if err == nil {
+		err = dbFor(r).Delete(&img).Error
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	// The row is gone, so a file left behind is only wasted space
+	if err := depsFor(r).storage.Delete(r.Context(), img.Key); err != nil {
+		slog.WarnContext(r.Context(), "failed to delete image", "key", img.Key, "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const importBatchSize = 500
+
+// Import a JSON array of products, or a CSV or XLSX spreadsheet uploaded
+// as multipart/form-data, in the background with ?async=true
+func importProducts(w http.ResponseWriter, r *http.Request) {
+	var products []Product
+	// lines, for a spreadsheet, are the lines the products came from
+	var lines []int
+	invalid := &service.ValidationError{}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		var err error
+		if products, lines, invalid, err = decodeSheetUpload(r); err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	} else if err := decodeJSON(r, &products); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// Report the invalid fields and unknown references of every row, named
+	// like "[3].price", or "line 3: price" in a spreadsheet
+	checkReferences := service.ReferenceChecker(repository.NewProductRepository(dbFor(r)))
+	for i := range products {
+		products[i].ID = 0
+		products[i].Version = 0
+		products[i].Category, products[i].Supplier, products[i].Images = nil, nil, nil
+		service.SetDefaults(&products[i])
+		err := service.Validate(&products[i])
+		if err == nil {
+			err = checkReferences(&products[i])
+		}
+		var verr *service.ValidationError
+		if err != nil && !errors.As(err, &verr) {
+			writeServiceError(w, r, err)
+			return
+		}
+		if verr != nil {
+			prefix := fmt.Sprintf("[%d].", i)
+			if lines != nil {
+				prefix = fmt.Sprintf("line %d: ", lines[i])
+			}
+			invalid.Fields = append(invalid.Fields, verr.Prefix(prefix).Fields...)
+		}
+	}
+	if len(invalid.Fields) > 0 {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, invalid)
+		return
+	}
+
+	actor := actorFor(r.Context())
+	// A dry run has to finish within its transaction
+	if r.URL.Query().Get("async") == "true" && !dryRun(r) {
+		d := depsFor(r)
+		tenant := tenantFor(r)
+		job, err := d.jobs.enqueue(r.Context(), "product_import", len(products), func(ctx context.Context, progress func(int)) (map[string]any, error) {
+			n, err := insertProducts(d.db.WithContext(repository.WithTenant(ctx, tenant)), products, actor, progress)
+			if err == nil {
+				invalidateProducts(ctx, d)
+			}
+			return map[string]any{"imported": n}, err
+		})
+		writeJobAccepted(w, r, job, err)
+		return
+	}
+
+	n, err := insertProducts(dbFor(r), products, actor, nil)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	invalidateProducts(r.Context(), depsFor(r))
+	writeJSON(w, r, http.StatusCreated, map[string]int{"imported": n})
+}
+
+// insertProducts inserts products in batches within one transaction, so an
+// import is all-or-nothing, and records their creation by actor. progress,
+// if set, is called after each batch.
+func insertProducts(conn *gorm.DB, products []Product, actor string, progress func(int)) (int, error) {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(products); start += importBatchSize {
+			end := min(start+importBatchSize, len(products))
+			if err := tx.Omit(clause.Associations).Create(products[start:end]).Error; err != nil {
+				return repository.AsDuplicate(err)
+			}
+			events := make([]*model.AuditEvent, 0, end-start)
+			for i := start; i < end; i++ {
+				events = append(events, service.NewAuditEvent(model.AuditCreate, actor, nil, &products[i]))
+			}
+			if err := repository.NewProductRepository(tx).Record(events); err != nil {
+				return err
+			}
+			if progress != nil {
+				progress(end)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(products), nil
+}
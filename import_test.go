@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"testing"
+)
+
+// Imported rows may only refer to categories and suppliers of the
+// importing tenant.
+func TestImportChecksReferences(t *testing.T) {
+	c, _ := newTestAPI(t)
+	c.Post("/api/v1/tenants", map[string]any{"name": "Acme"}).Expect(201)
+	var other Category
+	c.WithHeader("X-Tenant-ID", "2").Post("/api/v1/categories", map[string]any{"name": "Acme tools"}).Expect(201).Decode(&other)
+	var own Category
+	c.Post("/api/v1/categories", map[string]any{"name": "Tools"}).Expect(201).Decode(&own)
+
+	products := []map[string]any{
+		{"name": "Hammer", "price": "9.99", "category_id": own.ID},
+		{"name": "Saw", "price": "5", "category_id": other.ID},
+		{"name": "Drill", "price": "40", "category_id": 999},
+		{"name": "File", "price": "3", "supplier_id": 999},
+	}
+	invalid := expectProblem(t, c.Post("/api/v1/products/import", products), 422, "validation_failed")
+	want := map[string]string{"[1].category_id": "unknown_category", "[2].category_id": "unknown_category", "[3].supplier_id": "unknown_supplier"}
+	if len(invalid.Fields) != len(want) {
+		t.Errorf("invalid fields %+v, want %v", invalid.Fields, want)
+	}
+	for _, f := range invalid.Fields {
+		if want[f.Field] != f.Code {
+			t.Errorf("field %s code %s, want %q", f.Field, f.Code, want[f.Field])
+		}
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	file, err := mw.CreateFormFile("file", "products.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(file, "name,price,category_id\nHammer,9.99,%d\nSaw,5,%d\n", own.ID, other.ID)
+	mw.Close()
+	sheet := expectProblem(t, c.WithHeader("Content-Type", mw.FormDataContentType()).Post("/api/v1/products/import", body.Bytes()), 422, "validation_failed")
+	if len(sheet.Fields) != 1 || sheet.Fields[0].Field != "line 3: category_id" {
+		t.Errorf("invalid fields %+v, want line 3: category_id", sheet.Fields)
+	}
+
+	var list struct {
+		Data []Product `json:"data"`
+	}
+	c.Get("/api/v1/products").Expect(200).Decode(&list)
+	if len(list.Data) != 0 {
+		t.Errorf("%d products imported, want none", len(list.Data))
+	}
+	c.Post("/api/v1/products/import", products[:1]).Expect(201)
+}
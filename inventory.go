@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+const (
+	inventoryPollInterval = time.Second
+	inventoryBatchSize    = 500
+	// inventoryPingInterval is how often a dashboard is pinged; one that
+	// sends nothing, not even a pong, for inventoryPongWait is dropped.
+	inventoryPingInterval = 30 * time.Second
+	inventoryPongWait     = 60 * time.Second
+	inventoryWriteWait    = 10 * time.Second
+	// inventorySendBuffer is how many messages a dashboard may fall behind
+	// by before it is dropped.
+	inventorySendBuffer = 64
+	inventoryMaxMessage = 4096
+	// inventoryGapWait is how long the feed looks for a row whose ID was
+	// skipped before taking it as rolled back, and inventoryMaxGaps how
+	// many such rows it looks for at once.
+	inventoryGapWait = 5 * time.Minute
+	inventoryMaxGaps = 10000
+)
+
+var inventoryConnections = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "golangdb_inventory_connections",
+	Help: "Number of dashboards connected to the inventory feed.",
+})
+
+// stockPush is the message a dashboard gets for a stock movement.
+type stockPush struct {
+	Type       string    `json:"type"`
+	ProductID  uint      `json:"product_id"`
+	CategoryID *uint     `json:"category_id"`
+	Kind       string    `json:"kind"`
+	Delta      int       `json:"delta"`
+	Quantity   int       `json:"quantity"`
+	Reserved   int       `json:"reserved"`
+	At         time.Time `json:"at"`
+}
+
+// pricePush is the message a dashboard gets for a price change.
+type pricePush struct {
+	Type       string       `json:"type"`
+	ProductID  uint         `json:"product_id"`
+	CategoryID *uint        `json:"category_id"`
+	Price      money.Amount `json:"price"`
+	Currency   string       `json:"currency"`
+	At         time.Time    `json:"at"`
+}
+
+// inventoryRequest is a message from a dashboard: subscribe, unsubscribe
+// or ping.
+type inventoryRequest struct {
+	Type       string `json:"type"`
+	Categories []uint `json:"categories"`
+}
+
+// inventoryReply answers an inventoryRequest. A subscription without
+// categories is to every category.
+type inventoryReply struct {
+	Type       string `json:"type"`
+	Categories []uint `json:"categories,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// inventoryFeed pushes stock movements and price changes to the dashboards
+// connected to /ws/inventory, as they are recorded. It polls the stock
+// ledger and the price history, so it sees the changes made through every
+// instance sharing the database.
+type inventoryFeed struct {
+	db     *gorm.DB
+	cancel context.CancelFunc
+	// wg waits for the poller and the connections.
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	clients  map[*inventoryClient]bool
+	stopping bool
+
+	// Where the stock ledger and the price history have been read up to,
+	// once seeded
+	movements, prices inventoryCursor
+	seeded            bool
+}
+
+// inventoryCursor is how far the feed has read a table: up to the highest
+// ID pushed, except for the lower IDs it hasn't seen. An ID is taken when
+// a row is inserted but the row only shows once its transaction commits,
+// so a transaction that started first can commit a lower ID after a
+// higher one was read. Such gaps are looked for until inventoryGapWait has
+// passed.
+type inventoryCursor struct {
+	last uint
+	gaps map[uint]time.Time
+}
+
+func newInventoryCursor(last uint) inventoryCursor {
+	return inventoryCursor{last: last, gaps: map[uint]time.Time{}}
+}
+
+// unread limits q to the rows of table past the cursor.
+func (c *inventoryCursor) unread(q *gorm.DB, table string) *gorm.DB {
+	if len(c.gaps) == 0 {
+		return q.Where(table+".id > ?", c.last)
+	}
+	gaps := make([]uint, 0, len(c.gaps))
+	for id := range c.gaps {
+		gaps = append(gaps, id)
+	}
+	return q.Where(table+".id > ? OR "+table+".id IN ?", c.last, gaps)
+}
+
+// read moves the cursor past id, noting the IDs it skipped at now.
+func (c *inventoryCursor) read(id uint, now time.Time) {
+	if id <= c.last {
+		delete(c.gaps, id)
+		return
+	}
+	for gap := c.last + 1; gap < id && len(c.gaps) < inventoryMaxGaps; gap++ {
+		c.gaps[gap] = now
+	}
+	c.last = id
+}
+
+// expire gives up on the gaps noted before now less inventoryGapWait.
+func (c *inventoryCursor) expire(now time.Time) {
+	for id, at := range c.gaps {
+		if now.Sub(at) > inventoryGapWait {
+			delete(c.gaps, id)
+		}
+	}
+}
+
+func newInventoryFeed(db *gorm.DB) *inventoryFeed {
+	return &inventoryFeed{db: db, clients: map[*inventoryClient]bool{}}
+}
+
+// start starts polling for changes.
+func (f *inventoryFeed) start(ctx context.Context) error {
+	workerCtx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(inventoryPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-workerCtx.Done():
+				return
+			case <-ticker.C:
+				if err := f.poll(workerCtx); err != nil && workerCtx.Err() == nil {
+					slog.Error("failed to poll the inventory feed", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stop disconnects every dashboard, which the HTTP server's shutdown leaves
+// alone, and waits for the poller and the connections to end.
+func (f *inventoryFeed) stop(ctx context.Context) error {
+	if f.cancel == nil {
+		return nil
+	}
+	f.cancel()
+	f.mu.Lock()
+	f.stopping = true
+	for c := range f.clients {
+		c.disconnect(websocket.CloseGoingAway, "server shutting down")
+	}
+	f.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// inventoryMovement is a stock movement with its product's category.
+type inventoryMovement struct {
+	model.StockMovement
+	CategoryID *uint
+}
+
+// inventoryPriceChange is a price change with its product's category.
+type inventoryPriceChange struct {
+	model.PriceChange
+	CategoryID *uint
+}
+
+// poll pushes the changes committed since the last poll. With no dashboard
+// connected it skips them, and starts over from the latest once one is.
+func (f *inventoryFeed) poll(ctx context.Context) error {
+	f.mu.Lock()
+	idle := len(f.clients) == 0
+	f.mu.Unlock()
+	if idle {
+		f.seeded = false
+		return nil
+	}
+	db := f.db.WithContext(ctx)
+	if !f.seeded {
+		var lastMovement, lastPrice uint
+		if err := db.Model(&model.StockMovement{}).Select("COALESCE(MAX(id), 0)").Scan(&lastMovement).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&model.PriceChange{}).Select("COALESCE(MAX(id), 0)").Scan(&lastPrice).Error; err != nil {
+			return err
+		}
+		f.movements, f.prices = newInventoryCursor(lastMovement), newInventoryCursor(lastPrice)
+		f.seeded = true
+		return nil
+	}
+	now := time.Now()
+	f.movements.expire(now)
+	f.prices.expire(now)
+	for {
+		var movements []inventoryMovement
+		q := db.Model(&model.StockMovement{}).Select("stock_movements.*, products.category_id").
+			Joins("LEFT JOIN products ON products.id = stock_movements.product_id")
+		err := f.movements.unread(q, "stock_movements").Order("stock_movements.id").Limit(inventoryBatchSize).
+			Scan(&movements).Error
+		if err != nil {
+			return err
+		}
+		for _, m := range movements {
+			f.broadcast(m.TenantID, m.CategoryID, stockPush{
+				Type:       "stock",
+				ProductID:  m.ProductID,
+				CategoryID: m.CategoryID,
+				Kind:       m.Kind,
+				Delta:      m.Delta,
+				Quantity:   m.Quantity,
+				Reserved:   m.Reserved,
+				At:         m.CreatedAt,
+			})
+			f.movements.read(m.ID, now)
+		}
+		if len(movements) < inventoryBatchSize {
+			break
+		}
+	}
+	for {
+		var changes []inventoryPriceChange
+		q := db.Model(&model.PriceChange{}).Select("price_history.*, products.category_id").
+			Joins("LEFT JOIN products ON products.id = price_history.product_id")
+		err := f.prices.unread(q, "price_history").Order("price_history.id").Limit(inventoryBatchSize).
+			Scan(&changes).Error
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			f.broadcast(c.TenantID, c.CategoryID, pricePush{
+				Type:       "price",
+				ProductID:  c.ProductID,
+				CategoryID: c.CategoryID,
+				Price:      c.Price,
+				Currency:   c.Currency,
+				At:         c.ChangedAt,
+			})
+			f.prices.read(c.ID, now)
+		}
+		if len(changes) < inventoryBatchSize {
+			return nil
+		}
+	}
+}
+
+// broadcast sends push to the dashboards of tenant subscribed to category.
+func (f *inventoryFeed) broadcast(tenant uint, category *uint, push any) {
+	msg, err := json.Marshal(push)
+	if err != nil {
+		slog.Error("failed to encode an inventory push", "error", err)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.clients {
+		if c.wants(tenant, category) {
+			c.send(msg)
+		}
+	}
+}
+
+// add connects c, unless the feed is stopping.
+func (f *inventoryFeed) add(c *inventoryClient) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopping {
+		return false
+	}
+	f.clients[c] = true
+	f.wg.Add(1)
+	inventoryConnections.Inc()
+	return true
+}
+
+func (f *inventoryFeed) remove(c *inventoryClient) {
+	f.mu.Lock()
+	delete(f.clients, c)
+	f.mu.Unlock()
+	inventoryConnections.Dec()
+	f.wg.Done()
+}
+
+// serve pushes changes to the dashboard on c until either side closes the
+// connection.
+func (f *inventoryFeed) serve(c *inventoryClient) {
+	defer c.conn.Close()
+	if !f.add(c) {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(inventoryWriteWait))
+		return
+	}
+	defer f.remove(c)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		c.write()
+	}()
+	c.read()
+	c.disconnect(websocket.CloseNormalClosure, "")
+	<-written
+}
+
+// inventoryClient is a dashboard connected to the feed. Only its writer
+// goroutine writes to conn; everything else queues messages with send.
+type inventoryClient struct {
+	conn   *websocket.Conn
+	tenant uint
+	lang   language.Tag
+	queue  chan []byte
+	// closed is closed, with closeMsg set, to have the writer close the
+	// connection.
+	closed    chan struct{}
+	closeOnce sync.Once
+	closeMsg  []byte
+
+	mu sync.Mutex
+	// subscribed is false once the dashboard unsubscribes, and categories
+	// are those it subscribed to, or nil for every one.
+	subscribed bool
+	categories map[uint]bool
+}
+
+func newInventoryClient(conn *websocket.Conn, tenant uint, lang language.Tag, categories []uint) *inventoryClient {
+	c := &inventoryClient{
+		conn:   conn,
+		tenant: tenant,
+		lang:   lang,
+		queue:  make(chan []byte, inventorySendBuffer),
+		closed: make(chan struct{}),
+	}
+	c.subscribe(categories)
+	return c
+}
+
+// subscribe replaces the categories c gets changes for; none means every
+// category.
+func (c *inventoryClient) subscribe(categories []uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed = true
+	c.categories = nil
+	if len(categories) > 0 {
+		c.categories = make(map[uint]bool, len(categories))
+		for _, id := range categories {
+			c.categories[id] = true
+		}
+	}
+}
+
+func (c *inventoryClient) unsubscribe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed = false
+	c.categories = nil
+}
+
+// wants reports whether c gets the changes to the products of tenant in
+// category. Products without a category only go to subscriptions to every
+// category.
+func (c *inventoryClient) wants(tenant uint, category *uint) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.subscribed || tenant != c.tenant {
+		return false
+	}
+	return c.categories == nil || (category != nil && c.categories[*category])
+}
+
+// send queues msg, or drops c if it is too far behind to take it.
+func (c *inventoryClient) send(msg []byte) {
+	select {
+	case c.queue <- msg:
+	default:
+		c.disconnect(websocket.CloseTryAgainLater, "too slow to keep up")
+	}
+}
+
+func (c *inventoryClient) reply(reply inventoryReply) {
+	msg, err := json.Marshal(reply)
+	if err != nil {
+		slog.Error("failed to encode an inventory reply", "error", err)
+		return
+	}
+	c.send(msg)
+}
+
+func (c *inventoryClient) replyError(code string, args ...any) {
+	c.reply(inventoryReply{Type: "error", Code: code, Message: newAPIError(code, args...).message(c.lang)})
+}
+
+// disconnect has the writer close the connection with code and text. Only
+// the first call counts.
+func (c *inventoryClient) disconnect(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeMsg = websocket.FormatCloseMessage(code, text)
+		close(c.closed)
+	})
+}
+
+// read handles the dashboard's messages until the connection closes or
+// goes quiet for inventoryPongWait.
+func (c *inventoryClient) read() {
+	c.conn.SetReadLimit(inventoryMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(inventoryPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(inventoryPongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(inventoryPongWait))
+		var req inventoryRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.replyError("invalid_message")
+			continue
+		}
+		switch req.Type {
+		case "subscribe":
+			slices.Sort(req.Categories)
+			req.Categories = slices.Compact(req.Categories)
+			c.subscribe(req.Categories)
+			c.reply(inventoryReply{Type: "subscribed", Categories: req.Categories})
+		case "unsubscribe":
+			c.unsubscribe()
+			c.reply(inventoryReply{Type: "unsubscribed"})
+		case "ping":
+			c.reply(inventoryReply{Type: "pong"})
+		default:
+			c.replyError("invalid_message")
+		}
+	}
+}
+
+// write sends the queued messages and a ping every inventoryPingInterval,
+// until the connection fails or c is disconnected.
+func (c *inventoryClient) write() {
+	// Closing the connection ends read too
+	defer c.conn.Close()
+	ticker := time.NewTicker(inventoryPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			c.conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(inventoryWriteWait))
+			return
+		case msg := <-c.queue:
+			c.conn.SetWriteDeadline(time.Now().Add(inventoryWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(inventoryWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// hijacker lets the WebSocket upgrader take over the connection under the
+// response writers the middleware wraps it in, which unwrap to it.
+type hijacker struct {
+	http.ResponseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(h.ResponseWriter).Hijack()
+}
+
+var inventoryUpgrader = websocket.Upgrader{
+	CheckOrigin: checkWebSocketOrigin,
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		writeError(w, r, status, "websocket_handshake_failed", reason.Error())
+	},
+}
+
+// checkWebSocketOrigin lets browsers connect from the API's own origin or
+// one CORS allows. Browsers don't apply CORS to WebSocket handshakes, so
+// the server has to; clients that aren't browsers send no Origin.
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	cors := depsFor(r).cors
+	return cors != nil && cors.allowsOrigin(origin)
+}
+
+// Stream stock and price changes to an inventory dashboard
+func streamInventory(w http.ResponseWriter, r *http.Request) {
+	var categories []uint
+	for _, raw := range strings.Split(r.URL.Query().Get("categories"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_category_ids", raw)
+			return
+		}
+		categories = append(categories, uint(id))
+	}
+	conn, err := inventoryUpgrader.Upgrade(hijacker{w}, r, nil)
+	if err != nil {
+		// The upgrader has answered
+		return
+	}
+	depsFor(r).inventory.serve(newInventoryClient(conn, tenantFor(r), requestLanguage(r), categories))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/testutil"
+	"golang.org/x/text/language"
+)
+
+// A movement whose transaction commits after one with a higher ID is
+// still pushed, once.
+func TestInventoryFeedPushesLateCommits(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := repository.WithTenant(context.Background(), model.DefaultTenantID)
+	feed := newInventoryFeed(db)
+	client := newInventoryClient(nil, model.DefaultTenantID, language.English, nil)
+	feed.add(client)
+	defer feed.remove(client)
+	if err := feed.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	poll := func() []uint {
+		t.Helper()
+		if err := feed.poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var pushed []uint
+		for len(client.queue) > 0 {
+			var push stockPush
+			if err := json.Unmarshal(<-client.queue, &push); err != nil {
+				t.Fatal(err)
+			}
+			pushed = append(pushed, uint(push.Delta))
+		}
+		return pushed
+	}
+	// Each movement's delta is its ID, to tell the pushes apart
+	move := func(id uint) {
+		t.Helper()
+		if err := db.WithContext(ctx).Create(&model.StockMovement{ID: id, ProductID: 1, Kind: "adjust", Delta: int(id)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	move(2)
+	if got := poll(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("pushed %v, want [2]", got)
+	}
+	move(1)
+	move(3)
+	if got := poll(); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("pushed %v, want [1 3]", got)
+	}
+	if got := poll(); len(got) != 0 {
+		t.Fatalf("pushed %v again", got)
+	}
+}
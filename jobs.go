@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/storage"
+	"gorm.io/gorm"
+)
+
+// Job statuses.
+const (
+	jobPending   = "pending"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+)
+
+// Job tracks work that runs in the background after the request that
+// started it has returned. It belongs to the tenant the request was made
+// for, and only that tenant's users can follow it; Actor started it.
+type Job struct {
+	ID        string         `json:"id" gorm:"primaryKey;size:32"`
+	TenantID  uint           `json:"tenant_id" gorm:"not null"`
+	Actor     string         `json:"actor"`
+	Kind      string         `json:"kind"`
+	Status    string         `json:"status" gorm:"index"`
+	Total     int            `json:"total"`
+	Processed int            `json:"processed"`
+	Result    map[string]any `json:"result,omitempty" gorm:"serializer:json"`
+	Error     string         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+
+	// File is the storage key of the file the job produced, if any, and
+	// URL where to fetch it.
+	File string `json:"-"`
+	URL  string `json:"url,omitempty" gorm:"-"`
+}
+
+// jobFunc does a job's work. It reports progress through the callback and
+// returns the job's result. A job that produces a file puts it in storage
+// and returns its key as result[jobFileKey], which becomes the job's URL.
+type jobFunc func(ctx context.Context, progress func(processed int)) (map[string]any, error)
+
+const (
+	jobFileKey = "file"
+	// jobCleanupInterval is how often jobs past their retention are
+	// deleted.
+	jobCleanupInterval = time.Hour
+	jobCleanupBatch    = 100
+)
+
+var errJobQueueFull = errors.New("job queue is full")
+
+// jobRunner executes queued jobs on a pool of background goroutines.
+// Progress of the running jobs is kept in memory rather than written to
+// the jobs table, since a job's own transaction may be holding the
+// database.
+type jobRunner struct {
+	db        *gorm.DB
+	storage   storage.Storage
+	workers   int
+	retention time.Duration
+	queue     chan queuedJob
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+
+	mu       sync.Mutex
+	progress map[string]int
+}
+
+type queuedJob struct {
+	id string
+	fn jobFunc
+}
+
+// newJobRunner returns a runner that records its jobs in db and keeps the
+// files they produce in store.
+func newJobRunner(db *gorm.DB, cfg config.Jobs, store storage.Storage) *jobRunner {
+	return &jobRunner{
+		db:        db,
+		storage:   store,
+		workers:   cfg.Workers,
+		retention: time.Duration(cfg.Retention),
+		queue:     make(chan queuedJob, cfg.QueueSize),
+		progress:  map[string]int{},
+	}
+}
+
+// start marks jobs left over from a previous process as failed, since their
+// work was lost with it, and starts the workers and the cleanup of old
+// jobs.
+func (j *jobRunner) start(ctx context.Context) error {
+	err := j.db.Model(&Job{}).Where("status IN ?", []string{jobPending, jobRunning}).
+		Updates(map[string]any{"status": jobFailed, "error": "interrupted by server restart"}).Error
+	if err != nil {
+		return err
+	}
+	workerCtx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	for range j.workers {
+		j.wg.Add(1)
+		go func() {
+			defer j.wg.Done()
+			for {
+				select {
+				case <-workerCtx.Done():
+					return
+				case qj := <-j.queue:
+					j.run(workerCtx, qj)
+				}
+			}
+		}()
+	}
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(jobCleanupInterval)
+		defer ticker.Stop()
+		for {
+			if err := j.cleanup(workerCtx); err != nil && workerCtx.Err() == nil {
+				slog.Error("failed to delete old jobs", "error", err)
+			}
+			select {
+			case <-workerCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// stop cancels the running jobs and waits for the workers to exit.
+func (j *jobRunner) stop(ctx context.Context) error {
+	if j.cancel == nil {
+		return nil
+	}
+	j.cancel()
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue records a pending job, for the tenant and actor of ctx, and
+// queues fn to run it.
+func (j *jobRunner) enqueue(ctx context.Context, kind string, total int, fn jobFunc) (*Job, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	job := &Job{ID: hex.EncodeToString(id), Actor: actorFor(ctx), Kind: kind, Status: jobPending, Total: total}
+	if err := j.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+	select {
+	case j.queue <- queuedJob{id: job.ID, fn: fn}:
+		return job, nil
+	default:
+		j.db.Model(job).Updates(map[string]any{"status": jobFailed, "error": errJobQueueFull.Error()})
+		return nil, errJobQueueFull
+	}
+}
+
+func (j *jobRunner) run(ctx context.Context, qj queuedJob) {
+	update := func(job *Job, fields ...string) {
+		if err := j.db.Model(&Job{ID: qj.id}).Select(fields).Updates(job).Error; err != nil {
+			slog.Error("failed to update job", "job_id", qj.id, "error", err)
+		}
+	}
+	update(&Job{Status: jobRunning}, "status")
+	result, err := qj.fn(ctx, func(processed int) {
+		j.mu.Lock()
+		j.progress[qj.id] = processed
+		j.mu.Unlock()
+	})
+	processed, _ := j.processed(qj.id)
+	j.mu.Lock()
+	delete(j.progress, qj.id)
+	j.mu.Unlock()
+	if err != nil {
+		update(&Job{Status: jobFailed, Processed: processed, Error: err.Error()}, "status", "processed", "error")
+		return
+	}
+	file, _ := result[jobFileKey].(string)
+	delete(result, jobFileKey)
+	update(&Job{Status: jobCompleted, Processed: processed, Result: result, File: file}, "status", "processed", "result", "file")
+}
+
+// cleanup deletes the jobs that finished longer than the retention ago,
+// with their files.
+func (j *jobRunner) cleanup(ctx context.Context) error {
+	for {
+		var jobs []Job
+		err := j.db.WithContext(ctx).Where("status IN ? AND updated_at < ?", []string{jobCompleted, jobFailed}, time.Now().Add(-j.retention)).
+			Limit(jobCleanupBatch).Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		ids := make([]string, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.ID
+			if job.File == "" {
+				continue
+			}
+			if err := j.storage.Delete(ctx, job.File); err != nil {
+				// Kept, to be tried again next time
+				return err
+			}
+		}
+		if err := j.db.WithContext(ctx).Delete(&Job{}, "id IN ?", ids).Error; err != nil {
+			return err
+		}
+	}
+}
+
+// processed reports the progress of a job that is currently running.
+func (j *jobRunner) processed(id string) (int, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n, ok := j.progress[id]
+	return n, ok
+}
+
+// Get the status of a background job of the caller's tenant
+func getJob(w http.ResponseWriter, r *http.Request) {
+	var job Job
+	if err := dbFor(r).First(&job, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "job_not_found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	d := depsFor(r)
+	if n, ok := d.jobs.processed(job.ID); ok {
+		job.Processed = n
+	}
+	if job.File != "" {
+		url, err := d.storage.URL(r.Context(), job.File)
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
+			return
+		}
+		job.URL = url
+	}
+	writeJSON(w, r, http.StatusOK, job)
+}
+
+// writeJobAccepted answers a request whose work was handed to a job with
+// 202 and where to follow it, given what enqueueing it returned.
+func writeJobAccepted(w http.ResponseWriter, r *http.Request, job *Job, err error) {
+	if errors.Is(err, errJobQueueFull) {
+		writeError(w, r, http.StatusServiceUnavailable, "job_queue_full")
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	w.Header().Set("Location", apiV1Prefix+"/jobs/"+job.ID)
+	writeJSON(w, r, http.StatusAccepted, map[string]string{"job_id": job.ID, "status": job.Status})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+)
+
+// An async import is pending until a worker is free, then completes with
+// the products inserted.
+func TestAsyncImport(t *testing.T) {
+	var runner *jobRunner
+	c, db := newTestAPI(t, func(d *deps) {
+		runner = newJobRunner(d.db, config.Jobs{Workers: 1, QueueSize: 1, Retention: config.Duration(time.Hour)}, nil)
+		d.jobs = runner
+	})
+	if err := runner.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer runner.stop(context.Background())
+	// Keep the only worker busy until the import has been seen pending
+	busy, release := make(chan struct{}), make(chan struct{})
+	if _, err := runner.enqueue(context.Background(), "test", 0, func(ctx context.Context, _ func(int)) (map[string]any, error) {
+		close(busy)
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-busy
+
+	var products []map[string]any
+	for i := range 250 {
+		products = append(products, map[string]any{"name": fmt.Sprintf("P%d", i), "price": "1.50"})
+	}
+	var accepted struct {
+		JobID  string `json:"job_id"`
+		Status string `json:"status"`
+	}
+	r := c.Post("/api/v1/products/import?async=true", products).Expect(202)
+	r.Decode(&accepted)
+	if accepted.Status != jobPending || r.Header().Get("Location") != "/api/v1/jobs/"+accepted.JobID {
+		t.Fatalf("accepted %+v at %q", accepted, r.Header().Get("Location"))
+	}
+	var job Job
+	c.Get("/api/v1/jobs/" + accepted.JobID).Expect(200).Decode(&job)
+	if job.Status != jobPending || job.Total != 250 {
+		t.Fatalf("before a worker runs it, job %+v", job)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); job.Status != jobCompleted; {
+		if job.Status == jobFailed || time.Now().After(deadline) {
+			t.Fatalf("job %+v, want completed", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		c.Get("/api/v1/jobs/" + accepted.JobID).Expect(200).Decode(&job)
+	}
+	if job.Processed != 250 || job.Result["imported"] != float64(250) {
+		t.Errorf("completed job %+v, want 250 imported", job)
+	}
+	var count int64
+	if err := db.Model(&Product{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 250 {
+		t.Errorf("%d products inserted, want 250", count)
+	}
+}
+
+// A job can only be followed by signed-in users of the tenant it was
+// started for.
+func TestJobsScopedToTenant(t *testing.T) {
+	c, _ := newTestAPI(t, func(d *deps) {
+		d.jobs = newJobRunner(d.db, config.Jobs{Workers: 1, QueueSize: 1}, nil)
+	})
+	c.Post("/api/v1/tenants", map[string]any{"name": "Acme"}).Expect(201)
+	acme := c.WithHeader("X-Tenant-ID", "2")
+
+	var accepted struct {
+		JobID string `json:"job_id"`
+	}
+	acme.Post("/api/v1/products/import?async=true", []map[string]any{{"name": "P", "price": "1"}}).Expect(202).Decode(&accepted)
+	path := "/api/v1/jobs/" + accepted.JobID
+	var job Job
+	acme.Get(path).Expect(200).Decode(&job)
+	if job.TenantID != 2 || job.Actor != "user:1" {
+		t.Errorf("job of tenant %d by %q, want tenant 2 by user:1", job.TenantID, job.Actor)
+	}
+	expectProblem(t, c.Get(path), 404, "job_not_found")
+	expectProblem(t, c.WithHeader("Authorization", "").WithHeader("X-Tenant-ID", "2").Get(path), 401, "unauthorized")
+}
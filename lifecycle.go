@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// component is a long-running part of the service that has to be started
+// before the server accepts traffic and stopped cleanly on shutdown.
+type component struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// lifecycle starts components in registration order and stops them in
+// reverse, so anything registered later may depend on what came before it.
+type lifecycle struct {
+	components []component
+	started    int
+}
+
+// register adds a component. Either function may be nil.
+func (l *lifecycle) register(name string, start, stop func(ctx context.Context) error) {
+	l.components = append(l.components, component{name: name, start: start, stop: stop})
+}
+
+// startAll starts every component. If one fails, the ones already started
+// are stopped again before the error is returned.
+func (l *lifecycle) startAll(ctx context.Context) error {
+	for _, c := range l.components {
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				stopErr := l.stopAll(ctx)
+				return errors.Join(fmt.Errorf("start %s: %w", c.name, err), stopErr)
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// stopAll stops the started components in reverse order. Every stopper is
+// called even if an earlier one fails; the errors are joined.
+func (l *lifecycle) stopAll(ctx context.Context) error {
+	var errs []error
+	for i := l.started - 1; i >= 0; i-- {
+		c := l.components[i]
+		if c.stop == nil {
+			continue
+		}
+		if err := c.stop(ctx); err != nil {
+			slog.Error("failed to stop component", "component", c.name, "error", err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+		}
+	}
+	l.started = 0
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recordLifecycle registers a component for each name that notes its
+// starts and stops in calls, failing as fail says.
+func recordLifecycle(calls *[]string, fail map[string]bool, names ...string) *lifecycle {
+	var l lifecycle
+	for _, name := range names {
+		l.register(name, func(context.Context) error {
+			*calls = append(*calls, "start "+name)
+			if fail["start "+name] {
+				return errors.New("failed")
+			}
+			return nil
+		}, func(context.Context) error {
+			*calls = append(*calls, "stop "+name)
+			if fail["stop "+name] {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+	return &l
+}
+
+func TestLifecycleStopsInReverse(t *testing.T) {
+	var calls []string
+	l := recordLifecycle(&calls, map[string]bool{"stop b": true}, "a", "b", "c")
+	if err := l.startAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.stopAll(context.Background()); err == nil {
+		t.Error("stopAll hid b's failure")
+	}
+	want := []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+}
+
+func TestLifecycleStopsStartedOnFailure(t *testing.T) {
+	var calls []string
+	l := recordLifecycle(&calls, map[string]bool{"start c": true}, "a", "b", "c")
+	if err := l.startAll(context.Background()); err == nil {
+		t.Fatal("startAll hid c's failure")
+	}
+	want := []string{"start a", "start b", "start c", "stop b", "stop a"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger sends GORM's log output to slog with the request ID of the
+// statement's context. Failed statements are logged as errors with their
+// SQL, statements slower than SlowThreshold as warnings, and the others
+// only at debug level. The SQL keeps its placeholders unless Params is
+// set, so that logs don't hold the data written or looked up.
+type GormLogger struct {
+	// SlowThreshold is how long a statement can take before it is logged
+	// as slow; 0 logs none.
+	SlowThreshold time.Duration
+	// Params logs statements with their bound parameters.
+	Params bool
+}
+
+var (
+	_ logger.Interface  = GormLogger{}
+	_ gorm.ParamsFilter = GormLogger{}
+)
+
+func (l GormLogger) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).Info("gorm: "+msg, "args", args)
+}
+
+func (GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).Warn("gorm: "+msg, "args", args)
+}
+
+func (GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).Error("gorm: "+msg, "args", args)
+}
+
+func (l GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	log := FromContext(ctx)
+	elapsed := time.Since(begin)
+	switch {
+	// A missing row is an ordinary outcome that handlers turn into 404
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := l.statement(fc)
+		log.Error("database error", "error", err, "sql", sql, "rows", rows, "duration_ms", ms(elapsed))
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold:
+		sql, rows := l.statement(fc)
+		log.Warn("slow query", "sql", sql, "rows", rows, "duration_ms", ms(elapsed), "threshold_ms", ms(l.SlowThreshold))
+	case log.Enabled(ctx, slog.LevelDebug):
+		sql, rows := l.statement(fc)
+		log.Debug("query", "sql", sql, "rows", rows, "duration_ms", ms(elapsed))
+	}
+}
+
+// unboundPlaceholder is a PostgreSQL placeholder such as $1 as GORM
+// writes it when it has no parameter to put in its place: $1$.
+var unboundPlaceholder = regexp.MustCompile(`\$(\d+)\$`)
+
+// statement returns the SQL and row count fc reports, with placeholders
+// as the driver writes them.
+func (l GormLogger) statement(fc func() (string, int64)) (string, int64) {
+	sql, rows := fc()
+	if !l.Params {
+		sql = unboundPlaceholder.ReplaceAllString(sql, "$$$1")
+	}
+	return sql, rows
+}
+
+// ParamsFilter drops the parameters of the SQL logged, unless Params is
+// set. GORM calls it to build the SQL it passes to Trace.
+func (l GormLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if !l.Params {
+		return sql, nil
+	}
+	return sql, params
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
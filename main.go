@@ -1,124 +1,673 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
-	"gorm.io/driver/postgres"
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/database"
+	"github.com/mjpvl-ai/golangdb/logging"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
 	"gorm.io/gorm"
 )
 
-// Product represents the product model
-type Product struct {
-	ID       uint    `json:"id" gorm:"primaryKey"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
+// Product is the product model. Handlers use it unqualified.
+type Product = model.Product
+
+// productService returns the product service for r, bound to its batch
+// transaction if there is one, with writes attributed to r's user.
+func productService(r *http.Request) *service.ProductService {
+	return service.NewProductService(cachedRepository(r, repository.NewProductRepository(dbFor(r)))).As(actorFor(r.Context())).WithContext(r.Context())
 }
 
-var db *gorm.DB
+// productReader returns the product service for r's reads. Deleted
+// products and associations are included if r asked for them; only reads
+// of plain products are cached.
+func productReader(r *http.Request) *service.ProductService {
+	conn := readDBFor(r)
+	if includeDeleted(r) {
+		conn = conn.Unscoped()
+	}
+	assocs := expansions(r)
+	for _, assoc := range assocs {
+		// Images in the order they were uploaded
+		conn = conn.Preload(assoc, func(tx *gorm.DB) *gorm.DB { return tx.Order("id") })
+	}
+	repo := repository.NewProductRepository(conn)
+	if !includeDeleted(r) && len(assocs) == 0 {
+		repo = cachedRepository(r, repo)
+	}
+	return service.NewProductService(repo).WithContext(r.Context())
+}
 
-func initDB() {
-	var err error
-	// Set up PostgreSQL connection
-	dsn := "host=localhost user=postgres password=yourpassword dbname=crud_db port=5432 sslmode=disable"
+// includeDeleted reports whether r asked for deleted products too, with
+// ?include_deleted=true.
+func includeDeleted(r *http.Request) bool {
+	return r.URL.Query().Get("include_deleted") == "true"
+}
 
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+// adminForDeleted lets only admins use ?include_deleted=true.
+func adminForDeleted(next http.HandlerFunc) http.HandlerFunc {
+	admin := requireAdmin(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if includeDeleted(r) {
+			admin(w, r)
+			return
+		}
+		next(w, r)
 	}
+}
 
-	// Migrate the Product model
-	err = db.AutoMigrate(&Product{})
+// routeID returns the {id} route variable. The route pattern guarantees
+// it is numeric; ok is false if it overflows.
+func routeID(r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+	return uint(id), err == nil
+}
+
+// productID returns the ID of the product a route is for.
+func productID(r *http.Request) (uint, bool) {
+	return routeID(r)
+}
+
+// productList is the envelope for list responses. Meta has the total
+// number of matches and, with ?page=, the page position. NextCursor is
+// passed back as ?cursor= to fetch the following page. Truncated means the
+// page was cut short to stay under the response size cap and the client
+// should ask for a smaller limit. Data is the products, trimmed to the
+// ?fields= asked for.
+type productList struct {
+	Data       any        `json:"data"`
+	Meta       query.Meta `json:"meta"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Truncated  bool       `json:"truncated,omitempty"`
+}
+
+// initDB connects with the configured driver and migrates or checks the
+// schema, logging statements as log says.
+func initDB(cfg config.DB, log config.Log) *gorm.DB {
+	// Connect with the configured driver, waiting for the database to come
+	// up if need be
+	db, err := database.Connect(context.Background(), cfg, &gorm.Config{Logger: gormLogger(log)})
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		fatal("failed to connect to database", err)
+	}
+
+	if err := instrumentDB(db, cfg, "primary", webhookOutbox{}, repository.PriceHistory{}); err != nil {
+		fatal("failed to set up database", err)
+	}
+
+	// Check the schema is the one this binary expects
+	if err := migrateSchema(db, cfg); err != nil {
+		fatal("failed to migrate database", err)
 	}
-	fmt.Println("Database connected and migrated!")
+	migrated.Store(true)
+	slog.Info("database connected and migrated")
+	return db
+}
+
+// gormLogger returns the logger of database statements configured by cfg.
+func gormLogger(cfg config.Log) logging.GormLogger {
+	return logging.GormLogger{SlowThreshold: time.Duration(cfg.SlowQuery), Params: cfg.SQLParams}
+}
+
+// instrumentDB registers the plugins every connection needs, with plugins
+// after tenant scoping, and the connection pool's metrics and circuit
+// breaker under pool.
+func instrumentDB(db *gorm.DB, cfg config.DB, pool string, plugins ...gorm.Plugin) error {
+	plugins = append([]gorm.Plugin{
+		dbMetrics{},
+		otelgorm.NewPlugin(otelgorm.WithDBName(cfg.Name), otelgorm.WithoutQueryVariables(), otelgorm.WithoutMetrics()),
+		repository.TenantScope{},
+	}, plugins...)
+	if cfg.BreakerFailures > 0 {
+		plugins = append(plugins, newDBBreaker(pool, cfg.BreakerFailures, time.Duration(cfg.BreakerCooldown)))
+	}
+	// Last, so its context is only cancelled once the others are done
+	if cfg.StatementTimeout > 0 {
+		plugins = append(plugins, statementTimeout{timeout: time.Duration(cfg.StatementTimeout)})
+	}
+	for _, plugin := range plugins {
+		if err := db.Use(plugin); err != nil {
+			return fmt.Errorf("register %s plugin: %w", plugin.Name(), err)
+		}
+	}
+	if err := registerPoolMetrics(db, pool); err != nil {
+		return fmt.Errorf("register connection pool metrics: %w", err)
+	}
+	return nil
 }
 
 // Handlers for CRUD Operations
 
 // Get all products
 func getProducts(w http.ResponseWriter, r *http.Request) {
-	var products []Product
-	db.Find(&products)
-	json.NewEncoder(w).Encode(products)
+	if r.URL.Query().Has("skus") {
+		getProductsBySKU(w, r)
+		return
+	}
+	if r.URL.Query().Has("ids") {
+		getProductsByID(w, r)
+		return
+	}
+	params, err := productSchema.Parse(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	fields, err := query.ParseFields[Product](r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	products, total, err := productReader(r).List(params)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	products, next, err := query.Next(params, products)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if err := localize(r, products); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	for i := range products {
+		if err := setImageURLs(r, &products[i]); err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
+			return
+		}
+	}
+	truncated, err := fitPayload(&products, fields)
+	if err == nil && truncated {
+		next, err = query.After(params, products[len(products)-1])
+		w.Header().Set("X-Truncated", "true")
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	var lastModified time.Time
+	for _, p := range products {
+		if p.UpdatedAt.After(lastModified) {
+			lastModified = p.UpdatedAt
+		}
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	writeConditionalJSON(w, r, "", productList{Data: fields.Select(products), Meta: params.Meta(total), NextCursor: next, Truncated: truncated})
 }
 
 // Get a single product by ID
 func getProduct(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	var product Product
-	if err := db.First(&product, params["id"]).Error; err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	writeProduct(w, r, id)
+}
+
+// writeProduct writes the product with id, as GET /products/{id} does.
+func writeProduct(w http.ResponseWriter, r *http.Request, id uint) {
+	fields, err := query.ParseFields[Product](r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	at, err := asOf(r)
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	reader := productReader(r)
+	product, err := reader.Get(id)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			writeError(w, r, http.StatusNotFound, "product_not_found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if !at.IsZero() {
+		change, err := reader.PriceAt(id, at)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		product.Price, product.Currency = change.Price, change.Currency
+	}
+	if err := setImageURLs(r, product); err != nil {
+		writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
 		return
 	}
-	json.NewEncoder(w).Encode(product)
+	localized := []Product{*product}
+	if err := localize(r, localized); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	product = &localized[0]
+	w.Header().Set("Last-Modified", product.UpdatedAt.UTC().Format(http.TimeFormat))
+	// Expanded associations and translations change without the
+	// product's version, and each set of fields and each past price is a
+	// representation of its own
+	etag := productETag(product)
+	if len(expansions(r)) > 0 || fields != nil || r.Header.Get("Accept-Language") != "" || !at.IsZero() {
+		etag = ""
+	}
+	if product.Locale != "" {
+		w.Header().Set("Content-Language", product.Locale)
+	}
+	if etag != "" && notModifiedSince(r, product.UpdatedAt) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeConditionalJSON(w, r, etag, fields.Select(product))
 }
 
 // Create a new product
 func createProduct(w http.ResponseWriter, r *http.Request) {
 	var product Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request payload"})
+	if err := decodeJSON(r, &product); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := productService(r).Create(&product); err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
-	db.Create(&product)
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(product)
+	w.Header().Set("ETag", productETag(&product))
+	writeJSON(w, r, http.StatusCreated, product)
 }
 
 // Update an existing product
 func updateProduct(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	var product Product
-	if err := db.First(&product, params["id"]).Error; err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+	mode, ok := returnMode(r)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_return")
 		return
 	}
 	var updatedProduct Product
-	if err := json.NewDecoder(r.Body).Decode(&updatedProduct); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request payload"})
+	if err := decodeJSON(r, &updatedProduct); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
 		return
 	}
-	product.Name = updatedProduct.Name
-	product.Price = updatedProduct.Price
-	product.Quantity = updatedProduct.Quantity
-	db.Save(&product)
-	json.NewEncoder(w).Encode(product)
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	version, ok := expectedVersion(r, updatedProduct.Version)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_if_match")
+		return
+	}
+	if version == 0 {
+		writeError(w, r, http.StatusPreconditionRequired, "version_required")
+		return
+	}
+	product, err := productService(r).Update(id, version, &updatedProduct)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeUpdatedProduct(w, r, mode, product)
+}
+
+// Update only the fields present in the body
+func patchProduct(w http.ResponseWriter, r *http.Request) {
+	mode, ok := returnMode(r)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_return")
+		return
+	}
+	var patch service.ProductPatch
+	if err := decodeJSON(r, &patch); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	version, ok := expectedVersion(r, patch.Version)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_if_match")
+		return
+	}
+	product, err := productService(r).Patch(id, version, &patch)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeUpdatedProduct(w, r, mode, product)
+}
+
+// writeUpdatedProduct answers a successful update in the requested return
+// mode.
+func writeUpdatedProduct(w http.ResponseWriter, r *http.Request, mode string, product *Product) {
+	w.Header().Set("Preference-Applied", "return="+mode)
+	w.Header().Set("ETag", productETag(product))
+	if mode == returnMinimal {
+		writeJSON(w, r, http.StatusOK, minimalProduct{ID: product.ID, Version: product.Version, UpdatedAt: product.UpdatedAt})
+		return
+	}
+	writeJSON(w, r, http.StatusOK, product)
 }
 
 // Delete a product by ID
 func deleteProduct(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	var product Product
-	if err := db.First(&product, params["id"]).Error; err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	version, ok := expectedVersion(r, 0)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_if_match")
+		return
+	}
+	if err := productService(r).Delete(id, version); err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
-	db.Delete(&product)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Undelete a product
+func restoreProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	product, err := productService(r).Restore(id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", productETag(product))
+	writeJSON(w, r, http.StatusOK, product)
+}
+
+// registerResourceRoutes adds the product, category and supplier routes. They are
+// also what a /batch request may call. Reads are public; writes need an
+// admin.
+func registerResourceRoutes(router *mux.Router) {
+	router.HandleFunc("/products", cacheable(expandable(adminForDeleted(getProducts)))).Methods("GET", "HEAD")
+	router.HandleFunc("/products/alerts", getStockAlerts).Methods("GET")
+	router.HandleFunc("/products/price-stats", getPriceStats).Methods("GET")
+	router.HandleFunc("/products/preview", previewProducts).Methods("GET")
+	router.HandleFunc("/products/search", cacheable(expandable(adminForDeleted(searchProducts)))).Methods("GET")
+	router.HandleFunc("/products/batch-get", batchGetProducts).Methods("POST")
+	acceptContentTypes(allowLongRequests(acceptUploads(router.HandleFunc("/products/import", requireAdmin(dryRunnable(importProducts))).Methods("POST"))),
+		"application/json", "multipart/form-data")
+	allowLongRequests(router.HandleFunc("/products/bulk", requireAdmin(dryRunnable(bulkCreateProducts))).Methods("POST"))
+	allowLongRequests(router.HandleFunc("/products/bulk", requireAdmin(dryRunnable(bulkDeleteProducts))).Methods("DELETE"))
+	router.HandleFunc("/products/assign-category", requireAdmin(assignCategory)).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}", cacheable(expandable(adminForDeleted(getProduct)))).Methods("GET", "HEAD")
+	router.HandleFunc("/products/sku/{sku}", cacheable(expandable(adminForDeleted(getProductBySKU)))).Methods("GET", "HEAD")
+	router.HandleFunc("/products/{id:[0-9]+}/history", requireAdmin(getProductHistory)).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/prices", requireAdmin(getProductPrices)).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/images", getProductImages).Methods("GET")
+	acceptContentTypes(acceptUploads(router.HandleFunc("/products/{id:[0-9]+}/images", requireAdmin(uploadProductImage)).Methods("POST")),
+		"multipart/form-data")
+	router.HandleFunc("/products/{id:[0-9]+}/images/{image:[0-9]+}", requireAdmin(deleteProductImage)).Methods("DELETE")
+	router.HandleFunc("/products/{id:[0-9]+}/translations", getProductTranslations).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/translations/{locale}", requireAdmin(dryRunnable(putProductTranslation))).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/translations/{locale}", requireAdmin(dryRunnable(deleteProductTranslation))).Methods("DELETE")
+	router.HandleFunc("/products", requireAdmin(dryRunnable(idempotent(createProduct)))).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}", requireAdmin(dryRunnable(updateProduct))).Methods("PUT")
+	acceptContentTypes(router.HandleFunc("/products/{id:[0-9]+}", requireAdmin(dryRunnable(patchProduct))).Methods("PATCH"),
+		"application/json", "application/merge-patch+json")
+	router.HandleFunc("/products/{id:[0-9]+}", requireAdmin(dryRunnable(deleteProduct))).Methods("DELETE")
+	router.HandleFunc("/products/{id:[0-9]+}/restore", requireAdmin(dryRunnable(restoreProduct))).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/adjust", requireAdmin(dryRunnable(idempotent(adjustStock)))).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/reserve", requireAdmin(dryRunnable(idempotent(reserveStock)))).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/release", requireAdmin(dryRunnable(idempotent(releaseStock)))).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/stock-movements", requireAdmin(getStockMovements)).Methods("GET")
+	router.HandleFunc("/categories", cacheable(getCategories)).Methods("GET")
+	router.HandleFunc("/categories", requireAdmin(dryRunnable(createCategory))).Methods("POST")
+	router.HandleFunc("/categories/{id:[0-9]+}", cacheable(getCategory)).Methods("GET")
+	router.HandleFunc("/categories/{id:[0-9]+}", requireAdmin(dryRunnable(updateCategory))).Methods("PUT")
+	router.HandleFunc("/categories/{id:[0-9]+}", requireAdmin(dryRunnable(deleteCategory))).Methods("DELETE")
+	router.HandleFunc("/suppliers", cacheable(getSuppliers)).Methods("GET")
+	router.HandleFunc("/suppliers", requireAdmin(dryRunnable(createSupplier))).Methods("POST")
+	router.HandleFunc("/suppliers/{id:[0-9]+}", cacheable(getSupplier)).Methods("GET")
+	router.HandleFunc("/suppliers/{id:[0-9]+}", requireAdmin(dryRunnable(updateSupplier))).Methods("PUT")
+	router.HandleFunc("/suppliers/{id:[0-9]+}", requireAdmin(dryRunnable(deleteSupplier))).Methods("DELETE")
+	router.HandleFunc("/orders", requireUser(getOrders)).Methods("GET")
+	router.HandleFunc("/orders", requireAdmin(dryRunnable(idempotent(placeOrder)))).Methods("POST")
+	router.HandleFunc("/orders/{id:[0-9]+}", requireUser(getOrder)).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/pay", requireAdmin(dryRunnable(idempotent(payOrder)))).Methods("POST")
+	router.HandleFunc("/orders/{id:[0-9]+}/cancel", requireAdmin(dryRunnable(idempotent(cancelOrder)))).Methods("POST")
+	router.HandleFunc("/jobs/{id}", requireUser(getJob)).Methods("GET")
+}
+
 // Main function
 func main() {
-	initDB()
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// serve runs the API server with cfg until it is signalled to stop.
+func serve(cfg config.Config, logger *slog.Logger, opts serveOptions) {
+	var err error
+	if tokens, err = auth.NewIssuer(cfg.JWTSecret); err != nil {
+		fatal("failed to set up token signing", err)
+	}
+
+	shutdownTracing, err := setupTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	db := initDB(cfg.DB, cfg.Log)
+
+	if opts.generate > 0 {
+		if err := generateProducts(db, opts.generate, opts.seed); err != nil {
+			fatal("failed to generate products", err)
+		}
+		slog.Info("generated products", "count", opts.generate, "seed", opts.seed)
+	}
+
+	if err := validateFlags(cfg.Flags); err != nil {
+		fatal("invalid configuration", err)
+	}
+	d := &deps{db: db, logger: logger, cfg: cfg, cors: newCORSPolicy(cfg.CORS)}
+	d.live = newLiveConfig(func() (config.Config, error) { return config.Load(opts.configFile) })
+	if len(cfg.DB.Replicas) > 0 {
+		d.replicas = newReplicaSet(cfg.DB, gormLogger(cfg.Log))
+	}
+	if opts.quotaFile != "" {
+		if d.quotas, err = loadTenantQuotas(opts.quotaFile); err != nil {
+			fatal("failed to load tenant quotas", err)
+		}
+		if err := db.Use(productQuota{quotas: d.quotas}); err != nil {
+			fatal("failed to set up tenant quotas", err)
+		}
+	}
+	if d.limiter, err = newRateLimiter(cfg.RateLimit); err != nil {
+		fatal("failed to set up rate limiting", err)
+	}
+	if d.cache, err = newProductCache(cfg.Cache); err != nil {
+		fatal("failed to set up the product cache", err)
+	}
+	if d.storage, err = newStorage(cfg.Storage); err != nil {
+		fatal("failed to set up storage", err)
+	}
+	d.jobs = newJobRunner(db, cfg.Jobs, d.storage)
+	d.inventory = newInventoryFeed(db)
+	handler, err := trailingSlash(opts.slashMode, newRouter(d))
+	if err != nil {
+		fatal("invalid --trailing-slash", err)
+	}
+	handler = traceRequests(logRequests(handler))
+	serverTLS, err := newServerTLS(cfg.TLS)
+	if err != nil {
+		fatal("failed to set up TLS", err)
+	}
+	var tlsConfig *tls.Config
+	if serverTLS != nil {
+		tlsConfig = serverTLS.config
+		handler = strictTransportSecurity(handler)
+	}
+
+	var app lifecycle
+	// First, so spans are flushed after everything else has stopped
+	app.register("tracing", nil, shutdownTracing)
+	app.register("database", nil, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+	if d.replicas != nil {
+		app.register("read replicas", d.replicas.start, d.replicas.stop)
+	}
+
+	startReloads, stopReloads := d.reloadOnHangup()
+	app.register("config reload", startReloads, stopReloads)
+	app.register("job runner", d.jobs.start, d.jobs.stop)
+	webhooks := newWebhookDispatcher(db)
+	app.register("webhook dispatcher", webhooks.start, webhooks.stop)
+	app.register("inventory feed", d.inventory.start, d.inventory.stop)
+
+	if cfg.GRPC.Addr != "" {
+		grpcSrv := newGRPCServer(d, tlsConfig)
+		app.register("grpc server", func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", cfg.GRPC.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := grpcSrv.Serve(ln); err != nil {
+					fatal("grpc server failed", err)
+				}
+			}()
+			slog.Info("grpc server running", "addr", ln.Addr().String())
+			return nil
+		}, func(ctx context.Context) error {
+			// Let in-flight calls finish; past the deadline, cut them off
+			stopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				grpcSrv.Stop()
+				return ctx.Err()
+			}
+		})
+	}
 
-	router := mux.NewRouter()
-	router.HandleFunc("/products", getProducts).Methods("GET")
-	router.HandleFunc("/products/{id}", getProduct).Methods("GET")
-	router.HandleFunc("/products", createProduct).Methods("POST")
-	router.HandleFunc("/products/{id}", updateProduct).Methods("PUT")
-	router.HandleFunc("/products/{id}", deleteProduct).Methods("DELETE")
+	srv := &http.Server{
+		Addr:              cfg.HTTP.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.HTTP.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.HTTP.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.HTTP.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.HTTP.IdleTimeout),
+		TLSConfig:         tlsConfig,
+	}
+	app.register("http server", func(ctx context.Context) error {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			run := srv.Serve
+			if tlsConfig != nil {
+				// The certificate is in TLSConfig
+				run = func(ln net.Listener) error { return srv.ServeTLS(ln, "", "") }
+			}
+			if err := run(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("server failed", err)
+			}
+		}()
+		slog.Info("server running", "addr", ln.Addr().String(), "tls", tlsConfig != nil)
+		return nil
+	}, func(ctx context.Context) error {
+		// Drain in-flight requests; past the deadline, cut the rest off
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+			return err
+		}
+		return nil
+	})
+	if cfg.TLS.RedirectAddr != "" {
+		redirectSrv := &http.Server{
+			Addr:              cfg.TLS.RedirectAddr,
+			Handler:           serverTLS.redirectHandler(cfg.HTTP.Addr),
+			ReadHeaderTimeout: time.Duration(cfg.HTTP.ReadHeaderTimeout),
+			IdleTimeout:       time.Duration(cfg.HTTP.IdleTimeout),
+		}
+		app.register("http redirect server", func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", redirectSrv.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := redirectSrv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					fatal("redirect server failed", err)
+				}
+			}()
+			slog.Info("redirecting to https", "addr", ln.Addr().String())
+			return nil
+		}, redirectSrv.Shutdown)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.startAll(ctx); err != nil {
+		fatal("failed to start", err)
+	}
+	<-ctx.Done()
+	// Restore default signal handling so a second signal exits at once
+	stop()
+	draining.Store(true)
+	if opts.drainDelay > 0 {
+		slog.Info("draining", "delay", opts.drainDelay.String())
+		time.Sleep(opts.drainDelay)
+	}
+
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
+	defer cancel()
+	if err := app.stopAll(shutdownCtx); err != nil {
+		fatal("shutdown failed", err)
+	}
+}
 
-	fmt.Println("Server running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
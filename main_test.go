@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/testutil"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) { os.Exit(testutil.Main(m)) }
+
+// newTestAPI returns a database with the plugins the server registers and
+// a client of the API over it, signed in as a platform admin. configure
+// may set up the API's dependencies further.
+func newTestAPI(t *testing.T, configure ...func(d *deps)) (*testutil.Client, *gorm.DB) {
+	t.Helper()
+	db := testutil.DB(t, webhookOutbox{}, repository.PriceHistory{})
+	var err error
+	if tokens, err = auth.NewIssuer("test"); err != nil {
+		t.Fatal(err)
+	}
+	admin, err := tokens.Issue(1, "admin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &deps{db: db, cfg: config.Default()}
+	for _, f := range configure {
+		f(d)
+	}
+	return testutil.NewClient(t, newRouter(d)).WithToken(admin.AccessToken), db
+}
+
+// expectProblem fails the test unless r is an error with status and code.
+func expectProblem(t *testing.T, r *testutil.Response, status int, code string) problem {
+	t.Helper()
+	var p problem
+	r.Expect(status).Decode(&p)
+	if p.Code != code {
+		t.Fatalf("code %q, want %q; body: %s", p.Code, code, r.Body)
+	}
+	return p
+}
+
+func TestProductCRUD(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	var created Product
+	c.Post("/api/v1/products", map[string]any{"name": "Hammer", "sku": "HAM-1", "price": "9.99", "quantity": 4}).Expect(201).Decode(&created)
+	if created.ID == 0 || created.Name != "Hammer" || created.Version != 1 {
+		t.Fatalf("created %+v", created)
+	}
+	path := fmt.Sprintf("/api/v1/products/%d", created.ID)
+
+	var got Product
+	c.Get(path).Expect(200).Decode(&got)
+	if got.Name != "Hammer" || got.Price.String() != "9.99" {
+		t.Errorf("got %+v", got)
+	}
+	c.Get("/api/v1/products/sku/HAM-1").Expect(200)
+
+	c.Post("/api/v1/products", map[string]any{"name": "Saw", "price": "5"}).Expect(201)
+	var list struct {
+		Data []Product
+		Meta struct{ Total int }
+	}
+	c.Get("/api/v1/products?sort=name").Expect(200).Decode(&list)
+	if list.Meta.Total != 2 || len(list.Data) != 2 || list.Data[0].Name != "Hammer" {
+		t.Errorf("listed %+v", list)
+	}
+
+	var updated Product
+	c.Put(path, map[string]any{"name": "Claw hammer", "price": "12.50", "quantity": 4, "version": 1}).Expect(200).Decode(&updated)
+	if updated.Name != "Claw hammer" || updated.Version != 2 {
+		t.Errorf("updated %+v", updated)
+	}
+	c.Patch(path, map[string]any{"quantity": 6, "version": 2}).Expect(200).Decode(&updated)
+	if updated.Quantity != 6 || updated.Version != 3 {
+		t.Errorf("patched %+v", updated)
+	}
+
+	c.Delete(path).Expect(204)
+	expectProblem(t, c.Get(path), 404, "product_not_found")
+	c.Post(path+"/restore", nil).Expect(200)
+	c.Get(path).Expect(200)
+}
+
+func TestProductNotFound(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	expectProblem(t, c.Get("/api/v1/products/99"), 404, "product_not_found")
+	expectProblem(t, c.Get("/api/v1/products/sku/NOPE"), 404, "product_not_found")
+	expectProblem(t, c.Put("/api/v1/products/99", map[string]any{"name": "x", "price": "1", "version": 1}), 404, "product_not_found")
+	expectProblem(t, c.Patch("/api/v1/products/99", map[string]any{"name": "x", "version": 1}), 404, "product_not_found")
+	expectProblem(t, c.Delete("/api/v1/products/99"), 404, "product_not_found")
+}
+
+func TestProductConflicts(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	var p Product
+	c.Post("/api/v1/products", map[string]any{"name": "Hammer", "sku": "HAM-1", "price": "9.99"}).Expect(201).Decode(&p)
+	path := fmt.Sprintf("/api/v1/products/%d", p.ID)
+
+	dup := expectProblem(t, c.Post("/api/v1/products", map[string]any{"name": "Other", "sku": "HAM-1", "price": "1"}), 409, "duplicate_value")
+	if len(dup.Fields) != 1 || dup.Fields[0].Field != "sku" {
+		t.Errorf("duplicate fields %+v", dup.Fields)
+	}
+	c.Patch(path, map[string]any{"price": "11", "version": 1}).Expect(200)
+	expectProblem(t, c.Patch(path, map[string]any{"price": "12", "version": 1}), 409, "stale_version")
+
+	c.Post(path+"/adjust", map[string]any{"delta": 10, "reason": "restock"}).Expect(200)
+	c.Post(path+"/reserve", map[string]any{"quantity": 4, "reason": "order 1"}).Expect(200)
+	expectProblem(t, c.Patch(path, map[string]any{"quantity": 3}), 409, "below_reserved_stock")
+}
+
+func TestProductValidation(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	invalid := expectProblem(t, c.Post("/api/v1/products", map[string]any{"name": "", "price": "-1"}), 422, "validation_failed")
+	fields := map[string]bool{}
+	for _, f := range invalid.Fields {
+		fields[f.Field] = true
+	}
+	if !fields["name"] || !fields["price"] {
+		t.Errorf("invalid fields %+v, want name and price", invalid.Fields)
+	}
+
+	var p Product
+	c.Post("/api/v1/products", map[string]any{"name": "Hammer", "price": "9.99"}).Expect(201).Decode(&p)
+	expectProblem(t, c.Put(fmt.Sprintf("/api/v1/products/%d", p.ID), map[string]any{"name": "Hammer", "price": "-5", "version": 1}), 422, "validation_failed")
+	expectProblem(t, c.Post("/api/v1/products", `{"name": `), 400, "truncated_json")
+}
+
+func TestCategoryCRUD(t *testing.T) {
+	c, _ := newTestAPI(t)
+
+	var created Category
+	c.Post("/api/v1/categories", map[string]any{"name": "Tools"}).Expect(201).Decode(&created)
+	if created.ID == 0 || created.Name != "Tools" {
+		t.Fatalf("created %+v", created)
+	}
+	path := fmt.Sprintf("/api/v1/categories/%d", created.ID)
+
+	var got Category
+	c.Get(path).Expect(200).Decode(&got)
+	if got.Name != "Tools" {
+		t.Errorf("got %+v", got)
+	}
+	c.Post("/api/v1/categories", map[string]any{"name": "Garden"}).Expect(201)
+	var list []Category
+	c.Get("/api/v1/categories").Expect(200).Decode(&list)
+	if len(list) != 2 {
+		t.Errorf("listed %+v", list)
+	}
+
+	var updated Category
+	c.Put(path, map[string]any{"name": "Hand tools"}).Expect(200).Decode(&updated)
+	if updated.Name != "Hand tools" {
+		t.Errorf("updated %+v", updated)
+	}
+
+	c.Delete(path).Expect(204)
+	expectProblem(t, c.Get(path), 404, "category_not_found")
+	expectProblem(t, c.Put(path, map[string]any{"name": "x"}), 404, "category_not_found")
+	expectProblem(t, c.Delete(path), 404, "category_not_found")
+	expectProblem(t, c.Post("/api/v1/categories", `{"name": `), 400, "truncated_json")
+}
+
+func TestProductCursorPages(t *testing.T) {
+	c, _ := newTestAPI(t)
+	for i, price := range []string{"5", "20", "10", "20", "1"} {
+		c.Post("/api/v1/products", map[string]any{"name": fmt.Sprintf("P%d", i+1), "price": price}).Expect(201)
+	}
+
+	type page struct {
+		Data       []Product
+		NextCursor string `json:"next_cursor"`
+	}
+	var names []string
+	next := ""
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatalf("still paging after 3 pages: %v", names)
+		}
+		var p page
+		c.Get("/api/v1/products?sort=-price&limit=2&cursor=" + next).Expect(200).Decode(&p)
+		for _, product := range p.Data {
+			names = append(names, product.Name)
+		}
+		if p.NextCursor == "" {
+			break
+		}
+		next = p.NextCursor
+	}
+	if want := []string{"P2", "P4", "P3", "P1", "P5"}; fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("paged %v, want %v", names, want)
+	}
+
+	var first page
+	c.Get("/api/v1/products?sort=-price&limit=2").Expect(200).Decode(&first)
+	expectProblem(t, c.Get("/api/v1/products?sort=name&limit=2&cursor="+first.NextCursor), 400, "cursor_sort_mismatch")
+	expectProblem(t, c.Get("/api/v1/products?sort=-price&limit=2&cursor=garbage"), 400, "invalid_cursor")
+}
+
+func TestProductHead(t *testing.T) {
+	c, _ := newTestAPI(t)
+	var p Product
+	c.Post("/api/v1/products", map[string]any{"name": "Hammer", "price": "9.99"}).Expect(201).Decode(&p)
+	path := fmt.Sprintf("/api/v1/products/%d", p.ID)
+
+	get := c.Get(path).Expect(200)
+	head := c.Do(http.MethodHead, path, nil).Expect(200)
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD sent a body: %s", head.Body)
+	}
+	for _, name := range []string{"Content-Type", "Content-Length", "ETag"} {
+		if head.Header().Get(name) == "" || head.Header().Get(name) != get.Header().Get(name) {
+			t.Errorf("HEAD %s %q, GET %q", name, head.Header().Get(name), get.Header().Get(name))
+		}
+	}
+	if missing := c.Do(http.MethodHead, "/api/v1/products/99", nil).Expect(404); missing.Body.Len() != 0 {
+		t.Errorf("HEAD of a missing product sent a body: %s", missing.Body)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/database"
+	"github.com/mjpvl-ai/golangdb/migrations"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// migrateSchema applies the pending migrations if cfg asks for it, or the
+// database is in memory and so always starts empty, then checks that the
+// schema is the one the binary expects.
+func migrateSchema(db *gorm.DB, cfg config.DB) error {
+	m, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	if cfg.Migrate || database.InMemory(cfg) {
+		done, err := m.Up()
+		for _, mig := range done {
+			slog.Info("applied migration", "version", mig.Version, "name", mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return m.Check()
+}
+
+// newMigrateCommand returns the migrate command, which applies, reverts
+// or lists the schema migrations of the database configured in cfg,
+// reporting to stdout.
+func newMigrateCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database schema",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "up",
+		Short: "Apply every pending migration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(cfg.DB, cfg.Log, func(m *migrations.Migrator) error {
+				done, err := m.Up()
+				for _, mig := range done {
+					fmt.Printf("applied %d_%s\n", mig.Version, mig.Name)
+				}
+				if err == nil && len(done) == 0 {
+					fmt.Printf("already at version %d\n", m.Latest())
+				}
+				return err
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "down [n]",
+		Short: "Revert the last n migrations, 1 by default",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n := 1
+			if len(args) == 1 {
+				var err error
+				if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+					return fmt.Errorf("migrate down: n must be a positive integer, got %q", args[0])
+				}
+			}
+			return withMigrator(cfg.DB, cfg.Log, func(m *migrations.Migrator) error {
+				done, err := m.Down(n)
+				for _, mig := range done {
+					fmt.Printf("reverted %d_%s\n", mig.Version, mig.Name)
+				}
+				return err
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "List the migrations and when they were applied",
+		Long:  "List the migrations and when they were applied. Fails if the schema doesn't match the binary.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(cfg.DB, cfg.Log, func(m *migrations.Migrator) error {
+				statuses, err := m.Status()
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+				for _, s := range statuses {
+					applied := "pending"
+					if s.AppliedAt != nil {
+						applied = s.AppliedAt.Format(time.RFC3339)
+					}
+					fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+				}
+				if err := tw.Flush(); err != nil {
+					return err
+				}
+				// Also report versions applied by a newer binary
+				return m.Check()
+			})
+		},
+	})
+	return cmd
+}
+
+// withMigrator connects to the database configured in cfg, without
+// checking its schema, and runs fn with its migrations, logging statements
+// as log says.
+func withMigrator(cfg config.DB, log config.Log, fn func(m *migrations.Migrator) error) error {
+	db, err := database.Connect(context.Background(), cfg, &gorm.Config{Logger: gormLogger(log)})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	m, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	return fn(m)
+}
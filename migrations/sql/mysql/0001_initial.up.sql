@@ -0,0 +1,63 @@
+-- The schema as AutoMigrate created it, so existing databases adopt it as is
+CREATE TABLE IF NOT EXISTS categories (
+	id bigint unsigned AUTO_INCREMENT,
+	name longtext NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+	id bigint unsigned AUTO_INCREMENT,
+	name longtext NOT NULL,
+	email varchar(254),
+	phone varchar(32),
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id bigint unsigned AUTO_INCREMENT,
+	name longtext,
+	price double,
+	quantity bigint,
+	reserved bigint,
+	min_stock bigint,
+	max_stock bigint,
+	category_id bigint unsigned,
+	supplier_id bigint unsigned,
+	sku varchar(64),
+	version bigint unsigned NOT NULL DEFAULT 1,
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	deleted_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_products_sku (sku),
+	INDEX idx_products_deleted_at (deleted_at),
+	CONSTRAINT fk_products_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL,
+	CONSTRAINT fk_products_supplier FOREIGN KEY (supplier_id) REFERENCES suppliers (id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id varchar(32),
+	kind longtext,
+	status varchar(191),
+	total bigint,
+	processed bigint,
+	result longtext,
+	error longtext,
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_jobs_status (status)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id bigint unsigned AUTO_INCREMENT,
+	email varchar(254),
+	password_hash longtext,
+	role varchar(16),
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_users_email (email)
+);
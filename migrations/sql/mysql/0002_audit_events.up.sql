@@ -0,0 +1,11 @@
+CREATE TABLE audit_events (
+	id bigint unsigned AUTO_INCREMENT,
+	product_id bigint unsigned NOT NULL,
+	action varchar(16) NOT NULL,
+	actor varchar(64) NOT NULL,
+	old_values longtext,
+	new_values longtext,
+	created_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX idx_audit_events_product (product_id, id)
+);
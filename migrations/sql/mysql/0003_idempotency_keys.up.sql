@@ -0,0 +1,12 @@
+CREATE TABLE idempotency_keys (
+	owner varchar(64) NOT NULL,
+	idempotency_key varchar(255) NOT NULL,
+	request_hash char(64) NOT NULL,
+	status int NOT NULL DEFAULT 0,
+	header longtext,
+	body longtext,
+	created_at datetime(3) NOT NULL,
+	expires_at datetime(3) NOT NULL,
+	PRIMARY KEY (owner, idempotency_key),
+	INDEX idx_idempotency_keys_expires_at (expires_at)
+);
@@ -0,0 +1,28 @@
+CREATE TABLE webhooks (
+	id bigint unsigned AUTO_INCREMENT,
+	url text NOT NULL,
+	events text NOT NULL,
+	secret varchar(64) NOT NULL,
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE webhook_deliveries (
+	id bigint unsigned AUTO_INCREMENT,
+	webhook_id bigint unsigned NOT NULL,
+	event varchar(32) NOT NULL,
+	payload longtext NOT NULL,
+	status varchar(16) NOT NULL,
+	attempts int NOT NULL DEFAULT 0,
+	response_status int NOT NULL DEFAULT 0,
+	response_body text,
+	error text,
+	next_attempt_at datetime(3) NULL,
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_webhook_deliveries_webhook (webhook_id, id),
+	INDEX idx_webhook_deliveries_next_attempt_at (next_attempt_at),
+	CONSTRAINT fk_webhook_deliveries_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+ALTER TABLE products
+	MODIFY price double,
+	DROP COLUMN currency;
+
+UPDATE products SET price = price / 100;
@@ -0,0 +1,7 @@
+-- Prices become whole hundredths of their currency's unit. Every existing
+-- price was in the one currency the service had, taken to be USD.
+UPDATE products SET price = COALESCE(ROUND(price * 100), 0);
+
+ALTER TABLE products
+	MODIFY price bigint NOT NULL DEFAULT 0,
+	ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,25 @@
+ALTER TABLE users
+	DROP FOREIGN KEY fk_users_tenant,
+	DROP COLUMN tenant_id;
+
+ALTER TABLE webhooks
+	DROP FOREIGN KEY fk_webhooks_tenant,
+	DROP INDEX idx_webhooks_tenant_id,
+	DROP COLUMN tenant_id;
+
+ALTER TABLE suppliers
+	DROP FOREIGN KEY fk_suppliers_tenant,
+	DROP INDEX idx_suppliers_tenant_id,
+	DROP COLUMN tenant_id;
+
+ALTER TABLE categories
+	DROP FOREIGN KEY fk_categories_tenant,
+	DROP INDEX idx_categories_tenant_id,
+	DROP COLUMN tenant_id;
+
+ALTER TABLE products
+	DROP FOREIGN KEY fk_products_tenant,
+	DROP INDEX idx_products_tenant_id_id,
+	DROP COLUMN tenant_id;
+
+DROP TABLE tenants;
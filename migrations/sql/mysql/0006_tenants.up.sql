@@ -0,0 +1,44 @@
+-- Products, categories, suppliers and webhooks belong to a tenant. What
+-- existed before goes to the default tenant, 1. Users without a tenant work
+-- across all of them.
+CREATE TABLE tenants (
+	id bigint unsigned AUTO_INCREMENT,
+	name varchar(100) NOT NULL,
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id)
+);
+
+INSERT INTO tenants (id, name, created_at, updated_at) VALUES (1, 'Default', NOW(3), NOW(3));
+
+ALTER TABLE products
+	ADD COLUMN tenant_id bigint unsigned NOT NULL DEFAULT 1,
+	ADD INDEX idx_products_tenant_id_id (tenant_id, id),
+	ADD CONSTRAINT fk_products_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id);
+
+ALTER TABLE products ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE categories
+	ADD COLUMN tenant_id bigint unsigned NOT NULL DEFAULT 1,
+	ADD INDEX idx_categories_tenant_id (tenant_id),
+	ADD CONSTRAINT fk_categories_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id);
+
+ALTER TABLE categories ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE suppliers
+	ADD COLUMN tenant_id bigint unsigned NOT NULL DEFAULT 1,
+	ADD INDEX idx_suppliers_tenant_id (tenant_id),
+	ADD CONSTRAINT fk_suppliers_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id);
+
+ALTER TABLE suppliers ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE webhooks
+	ADD COLUMN tenant_id bigint unsigned NOT NULL DEFAULT 1,
+	ADD INDEX idx_webhooks_tenant_id (tenant_id),
+	ADD CONSTRAINT fk_webhooks_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id);
+
+ALTER TABLE webhooks ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE users
+	ADD COLUMN tenant_id bigint unsigned NULL,
+	ADD CONSTRAINT fk_users_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id);
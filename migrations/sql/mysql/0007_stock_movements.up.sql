@@ -0,0 +1,17 @@
+-- The ledger of stock movements: every adjustment of a product's quantity
+-- and every reservation or release of its stock.
+CREATE TABLE stock_movements (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NOT NULL,
+	product_id bigint unsigned NOT NULL,
+	kind varchar(16) NOT NULL,
+	delta bigint NOT NULL,
+	reason varchar(255) NOT NULL,
+	actor varchar(64) NOT NULL,
+	quantity bigint NOT NULL,
+	reserved bigint NOT NULL,
+	created_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX idx_stock_movements_product (tenant_id, product_id, id),
+	CONSTRAINT fk_stock_movements_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+);
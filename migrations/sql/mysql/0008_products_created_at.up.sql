@@ -0,0 +1,3 @@
+-- Lets products be paged in creation order, newest first or oldest
+-- first, by seeking the index rather than sorting the catalog.
+CREATE INDEX idx_products_tenant_id_created_at ON products (tenant_id, created_at, id);
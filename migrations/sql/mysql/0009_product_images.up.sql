@@ -0,0 +1,18 @@
+-- Images of products. The files are in storage under key; deleting a
+-- product for good deletes its rows, though not the files.
+CREATE TABLE product_images (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NOT NULL,
+	product_id bigint unsigned NOT NULL,
+	`key` varchar(255) NOT NULL,
+	content_type varchar(64) NOT NULL,
+	size bigint NOT NULL,
+	width bigint NOT NULL,
+	height bigint NOT NULL,
+	alt varchar(255) NOT NULL DEFAULT '',
+	created_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX idx_product_images_product (tenant_id, product_id, id),
+	CONSTRAINT fk_product_images_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id),
+	CONSTRAINT fk_product_images_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- A job that produces a file, such as an export, keeps its storage key,
+-- so that its URL can be given and the file deleted with the job.
+ALTER TABLE jobs ADD COLUMN file varchar(255);
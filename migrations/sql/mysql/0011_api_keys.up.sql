@@ -0,0 +1,20 @@
+-- API keys of machine clients. Only a SHA-256 hash of each key is kept;
+-- prefix, its first characters, tells keys apart in listings.
+CREATE TABLE api_keys (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NULL,
+	name varchar(100) NOT NULL,
+	prefix varchar(16) NOT NULL,
+	hash varchar(64) NOT NULL,
+	scopes text NOT NULL,
+	created_by varchar(64) NOT NULL DEFAULT '',
+	expires_at datetime(3) NULL,
+	last_used_at datetime(3) NULL,
+	revoked_at datetime(3) NULL,
+	created_at datetime(3) NOT NULL,
+	updated_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_api_keys_hash (hash),
+	INDEX idx_api_keys_tenant_id (tenant_id, id),
+	CONSTRAINT fk_api_keys_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+);
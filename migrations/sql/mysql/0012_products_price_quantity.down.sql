@@ -0,0 +1,2 @@
+DROP INDEX idx_products_tenant_id_quantity ON products;
+DROP INDEX idx_products_tenant_id_price ON products;
@@ -0,0 +1,5 @@
+-- Lets products be filtered and sorted by price and by quantity, as with
+-- ?min_price=, ?max_price= and ?in_stock=true, by seeking an index rather
+-- than scanning the catalog.
+CREATE INDEX idx_products_tenant_id_price ON products (tenant_id, price, id);
+CREATE INDEX idx_products_tenant_id_quantity ON products (tenant_id, quantity, id);
@@ -0,0 +1,2 @@
+DROP INDEX idx_products_tenant_id_sku ON products;
+CREATE INDEX idx_products_sku ON products (sku);
@@ -0,0 +1,29 @@
+-- Orders and their items. Items keep the name and price their product had
+-- when the order was placed, and outlive the product.
+CREATE TABLE orders (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NOT NULL,
+	status varchar(16) NOT NULL,
+	total bigint NOT NULL,
+	currency varchar(3) NOT NULL,
+	created_by varchar(64) NOT NULL DEFAULT '',
+	created_at datetime(3) NOT NULL,
+	updated_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX idx_orders_tenant_id (tenant_id, id),
+	CONSTRAINT fk_orders_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+);
+
+CREATE TABLE order_items (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NOT NULL,
+	order_id bigint unsigned NOT NULL,
+	product_id bigint unsigned NOT NULL,
+	name varchar(255) NOT NULL,
+	quantity bigint NOT NULL,
+	unit_price bigint NOT NULL,
+	PRIMARY KEY (id),
+	INDEX idx_order_items_order_id (order_id, id),
+	CONSTRAINT fk_order_items_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id),
+	CONSTRAINT fk_orders_items FOREIGN KEY (order_id) REFERENCES orders (id) ON DELETE CASCADE
+);
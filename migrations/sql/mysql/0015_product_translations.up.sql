@@ -0,0 +1,15 @@
+-- Names and descriptions of products in other locales, one per locale.
+CREATE TABLE product_translations (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NOT NULL,
+	product_id bigint unsigned NOT NULL,
+	locale varchar(35) NOT NULL,
+	name varchar(255) NOT NULL,
+	description text NOT NULL,
+	created_at datetime(3) NOT NULL,
+	updated_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_product_translations_locale (product_id, locale),
+	CONSTRAINT fk_product_translations_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id),
+	CONSTRAINT fk_product_translations_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+-- Every price a product has had, from the time it took effect. Products
+-- that already exist start from their current price, as of their last
+-- update.
+CREATE TABLE price_history (
+	id bigint unsigned AUTO_INCREMENT,
+	tenant_id bigint unsigned NOT NULL,
+	product_id bigint unsigned NOT NULL,
+	price bigint NOT NULL,
+	currency varchar(3) NOT NULL,
+	changed_at datetime(3) NOT NULL,
+	PRIMARY KEY (id),
+	INDEX idx_price_history_product (tenant_id, product_id, changed_at, id),
+	CONSTRAINT fk_price_history_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id)
+);
+
+INSERT INTO price_history (tenant_id, product_id, price, currency, changed_at)
+SELECT tenant_id, id, price, currency, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP(3)) FROM products ORDER BY id;
@@ -0,0 +1,6 @@
+DELETE FROM idempotency_keys WHERE tenant_id <> 1;
+
+ALTER TABLE idempotency_keys
+	DROP PRIMARY KEY,
+	ADD PRIMARY KEY (owner, idempotency_key),
+	DROP COLUMN tenant_id;
@@ -0,0 +1,5 @@
+ALTER TABLE jobs
+	DROP FOREIGN KEY fk_jobs_tenant,
+	DROP INDEX idx_jobs_tenant_id,
+	DROP COLUMN actor,
+	DROP COLUMN tenant_id;
@@ -0,0 +1,9 @@
+-- Jobs belong to the tenant they were started for, and record who started
+-- them. Jobs that existed before go to the default tenant, 1.
+ALTER TABLE jobs
+	ADD COLUMN tenant_id bigint unsigned NOT NULL DEFAULT 1,
+	ADD COLUMN actor varchar(64) NOT NULL DEFAULT '',
+	ADD INDEX idx_jobs_tenant_id (tenant_id),
+	ADD CONSTRAINT fk_jobs_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id);
+
+ALTER TABLE jobs ALTER COLUMN tenant_id DROP DEFAULT;
@@ -0,0 +1,62 @@
+-- The schema as AutoMigrate created it, so existing databases adopt it as is
+CREATE TABLE IF NOT EXISTS categories (
+	id bigserial PRIMARY KEY,
+	name text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+	id bigserial PRIMARY KEY,
+	name text NOT NULL,
+	email varchar(254),
+	phone varchar(32),
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id bigserial PRIMARY KEY,
+	name text,
+	price decimal,
+	quantity bigint,
+	reserved bigint,
+	min_stock bigint,
+	max_stock bigint,
+	category_id bigint,
+	supplier_id bigint,
+	sku varchar(64),
+	version bigint NOT NULL DEFAULT 1,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	CONSTRAINT fk_products_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL,
+	CONSTRAINT fk_products_supplier FOREIGN KEY (supplier_id) REFERENCES suppliers (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+CREATE INDEX IF NOT EXISTS idx_products_name_search ON products USING GIN (to_tsvector('simple', name));
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id varchar(32) PRIMARY KEY,
+	kind text,
+	status text,
+	total bigint,
+	processed bigint,
+	result text,
+	error text,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+
+CREATE TABLE IF NOT EXISTS users (
+	id bigserial PRIMARY KEY,
+	email varchar(254),
+	password_hash text,
+	role varchar(16),
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
@@ -0,0 +1,11 @@
+CREATE TABLE audit_events (
+	id bigserial PRIMARY KEY,
+	product_id bigint NOT NULL,
+	action varchar(16) NOT NULL,
+	actor varchar(64) NOT NULL,
+	old_values text,
+	new_values text,
+	created_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_audit_events_product ON audit_events (product_id, id);
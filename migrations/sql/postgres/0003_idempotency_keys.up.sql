@@ -0,0 +1,13 @@
+CREATE TABLE idempotency_keys (
+	owner varchar(64) NOT NULL,
+	idempotency_key varchar(255) NOT NULL,
+	request_hash char(64) NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	header text,
+	body text,
+	created_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL,
+	PRIMARY KEY (owner, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,27 @@
+CREATE TABLE webhooks (
+	id bigserial PRIMARY KEY,
+	url text NOT NULL,
+	events text NOT NULL,
+	secret varchar(64) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE TABLE webhook_deliveries (
+	id bigserial PRIMARY KEY,
+	webhook_id bigint NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event varchar(32) NOT NULL,
+	payload text NOT NULL,
+	status varchar(16) NOT NULL,
+	attempts integer NOT NULL DEFAULT 0,
+	response_status integer NOT NULL DEFAULT 0,
+	response_body text,
+	error text,
+	next_attempt_at timestamptz,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
@@ -0,0 +1,7 @@
+ALTER TABLE products DROP COLUMN currency;
+
+ALTER TABLE products ALTER COLUMN price DROP NOT NULL;
+
+ALTER TABLE products ALTER COLUMN price DROP DEFAULT;
+
+ALTER TABLE products ALTER COLUMN price TYPE decimal USING price / 100.0;
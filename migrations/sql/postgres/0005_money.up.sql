@@ -0,0 +1,11 @@
+-- Prices become whole hundredths of their currency's unit. Every existing
+-- price was in the one currency the service had, taken to be USD.
+UPDATE products SET price = 0 WHERE price IS NULL;
+
+ALTER TABLE products ALTER COLUMN price TYPE bigint USING ROUND(price * 100);
+
+ALTER TABLE products ALTER COLUMN price SET DEFAULT 0;
+
+ALTER TABLE products ALTER COLUMN price SET NOT NULL;
+
+ALTER TABLE products ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'USD';
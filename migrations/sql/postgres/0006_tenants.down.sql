@@ -0,0 +1,11 @@
+ALTER TABLE users DROP COLUMN tenant_id;
+
+ALTER TABLE webhooks DROP COLUMN tenant_id;
+
+ALTER TABLE suppliers DROP COLUMN tenant_id;
+
+ALTER TABLE categories DROP COLUMN tenant_id;
+
+ALTER TABLE products DROP COLUMN tenant_id;
+
+DROP TABLE tenants;
@@ -0,0 +1,39 @@
+-- Products, categories, suppliers and webhooks belong to a tenant. What
+-- existed before goes to the default tenant, 1. Users without a tenant work
+-- across all of them.
+CREATE TABLE tenants (
+	id bigserial PRIMARY KEY,
+	name varchar(100) NOT NULL,
+	created_at timestamptz,
+	updated_at timestamptz
+);
+
+INSERT INTO tenants (id, name, created_at, updated_at) VALUES (1, 'Default', now(), now());
+
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), 1);
+
+ALTER TABLE products ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 CONSTRAINT fk_products_tenant REFERENCES tenants (id);
+
+ALTER TABLE products ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_products_tenant_id_id ON products (tenant_id, id);
+
+ALTER TABLE categories ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 CONSTRAINT fk_categories_tenant REFERENCES tenants (id);
+
+ALTER TABLE categories ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_categories_tenant_id ON categories (tenant_id);
+
+ALTER TABLE suppliers ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 CONSTRAINT fk_suppliers_tenant REFERENCES tenants (id);
+
+ALTER TABLE suppliers ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_suppliers_tenant_id ON suppliers (tenant_id);
+
+ALTER TABLE webhooks ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 CONSTRAINT fk_webhooks_tenant REFERENCES tenants (id);
+
+ALTER TABLE webhooks ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX idx_webhooks_tenant_id ON webhooks (tenant_id);
+
+ALTER TABLE users ADD COLUMN tenant_id bigint CONSTRAINT fk_users_tenant REFERENCES tenants (id);
@@ -0,0 +1,16 @@
+-- The ledger of stock movements: every adjustment of a product's quantity
+-- and every reservation or release of its stock.
+CREATE TABLE stock_movements (
+	id bigserial PRIMARY KEY,
+	tenant_id bigint NOT NULL CONSTRAINT fk_stock_movements_tenant REFERENCES tenants (id),
+	product_id bigint NOT NULL,
+	kind varchar(16) NOT NULL,
+	delta bigint NOT NULL,
+	reason varchar(255) NOT NULL,
+	actor varchar(64) NOT NULL,
+	quantity bigint NOT NULL,
+	reserved bigint NOT NULL,
+	created_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_stock_movements_product ON stock_movements (tenant_id, product_id, id);
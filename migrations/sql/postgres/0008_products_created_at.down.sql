@@ -0,0 +1 @@
+DROP INDEX idx_products_tenant_id_created_at;
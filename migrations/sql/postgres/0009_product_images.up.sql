@@ -0,0 +1,16 @@
+-- Images of products. The files are in storage under key; deleting a
+-- product for good deletes its rows, though not the files.
+CREATE TABLE product_images (
+	id bigserial PRIMARY KEY,
+	tenant_id bigint NOT NULL CONSTRAINT fk_product_images_tenant REFERENCES tenants (id),
+	product_id bigint NOT NULL CONSTRAINT fk_product_images_product REFERENCES products (id) ON DELETE CASCADE,
+	key varchar(255) NOT NULL,
+	content_type varchar(64) NOT NULL,
+	size bigint NOT NULL,
+	width bigint NOT NULL,
+	height bigint NOT NULL,
+	alt varchar(255) NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_product_images_product ON product_images (tenant_id, product_id, id);
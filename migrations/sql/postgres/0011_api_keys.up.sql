@@ -0,0 +1,20 @@
+-- API keys of machine clients. Only a SHA-256 hash of each key is kept;
+-- prefix, its first characters, tells keys apart in listings.
+CREATE TABLE api_keys (
+	id bigserial PRIMARY KEY,
+	tenant_id bigint CONSTRAINT fk_api_keys_tenant REFERENCES tenants (id),
+	name varchar(100) NOT NULL,
+	prefix varchar(16) NOT NULL,
+	hash varchar(64) NOT NULL,
+	scopes text NOT NULL,
+	created_by varchar(64) NOT NULL DEFAULT '',
+	expires_at timestamptz,
+	last_used_at timestamptz,
+	revoked_at timestamptz,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_api_keys_hash ON api_keys (hash);
+
+CREATE INDEX idx_api_keys_tenant_id ON api_keys (tenant_id, id);
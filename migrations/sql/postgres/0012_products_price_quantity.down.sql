@@ -0,0 +1,2 @@
+DROP INDEX idx_products_tenant_id_quantity;
+DROP INDEX idx_products_tenant_id_price;
@@ -0,0 +1,6 @@
+-- Makes SKUs unique within a tenant, so two products can't be sold under
+-- one code. Products without a SKU don't count, and deleted products keep
+-- theirs, so restoring one can't collide. Fails if the catalog already has
+-- duplicates; clear those first.
+DROP INDEX idx_products_sku;
+CREATE UNIQUE INDEX idx_products_tenant_id_sku ON products (tenant_id, sku);
@@ -0,0 +1,26 @@
+-- Orders and their items. Items keep the name and price their product had
+-- when the order was placed, and outlive the product.
+CREATE TABLE orders (
+	id bigserial PRIMARY KEY,
+	tenant_id bigint NOT NULL CONSTRAINT fk_orders_tenant REFERENCES tenants (id),
+	status varchar(16) NOT NULL,
+	total bigint NOT NULL,
+	currency varchar(3) NOT NULL,
+	created_by varchar(64) NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_orders_tenant_id ON orders (tenant_id, id);
+
+CREATE TABLE order_items (
+	id bigserial PRIMARY KEY,
+	tenant_id bigint NOT NULL CONSTRAINT fk_order_items_tenant REFERENCES tenants (id),
+	order_id bigint NOT NULL CONSTRAINT fk_orders_items REFERENCES orders (id) ON DELETE CASCADE,
+	product_id bigint NOT NULL,
+	name varchar(255) NOT NULL,
+	quantity bigint NOT NULL,
+	unit_price bigint NOT NULL
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items (order_id, id);
@@ -0,0 +1,13 @@
+-- Names and descriptions of products in other locales, one per locale.
+CREATE TABLE product_translations (
+	id bigserial PRIMARY KEY,
+	tenant_id bigint NOT NULL CONSTRAINT fk_product_translations_tenant REFERENCES tenants (id),
+	product_id bigint NOT NULL CONSTRAINT fk_product_translations_product REFERENCES products (id) ON DELETE CASCADE,
+	locale varchar(35) NOT NULL,
+	name varchar(255) NOT NULL,
+	description text NOT NULL DEFAULT '',
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_product_translations_locale ON product_translations (product_id, locale);
@@ -0,0 +1,7 @@
+DELETE FROM idempotency_keys WHERE tenant_id <> 1;
+
+ALTER TABLE idempotency_keys
+	DROP CONSTRAINT idempotency_keys_pkey,
+	ADD PRIMARY KEY (owner, idempotency_key);
+
+ALTER TABLE idempotency_keys DROP COLUMN tenant_id;
@@ -0,0 +1,10 @@
+-- Idempotency keys are per tenant as well as per user, as anonymous
+-- callers of every tenant share an owner. Keys already stored go to the
+-- default tenant, 1.
+ALTER TABLE idempotency_keys ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1;
+
+ALTER TABLE idempotency_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE idempotency_keys
+	DROP CONSTRAINT idempotency_keys_pkey,
+	ADD PRIMARY KEY (tenant_id, owner, idempotency_key);
@@ -0,0 +1,3 @@
+ALTER TABLE jobs DROP COLUMN actor;
+
+ALTER TABLE jobs DROP COLUMN tenant_id;
@@ -0,0 +1,9 @@
+-- Jobs belong to the tenant they were started for, and record who started
+-- them. Jobs that existed before go to the default tenant, 1.
+ALTER TABLE jobs ADD COLUMN tenant_id bigint NOT NULL DEFAULT 1 CONSTRAINT fk_jobs_tenant REFERENCES tenants (id);
+
+ALTER TABLE jobs ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE jobs ADD COLUMN actor varchar(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_jobs_tenant_id ON jobs (tenant_id);
@@ -0,0 +1,61 @@
+-- The schema as AutoMigrate created it, so existing databases adopt it as is
+CREATE TABLE IF NOT EXISTS categories (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS suppliers (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	email text,
+	phone text,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text,
+	price real,
+	quantity integer,
+	reserved integer,
+	min_stock integer,
+	max_stock integer,
+	category_id integer,
+	supplier_id integer,
+	sku text,
+	version integer NOT NULL DEFAULT 1,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	CONSTRAINT fk_products_category FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL,
+	CONSTRAINT fk_products_supplier FOREIGN KEY (supplier_id) REFERENCES suppliers (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_products_sku ON products (sku);
+
+CREATE TABLE IF NOT EXISTS jobs (
+	id text PRIMARY KEY,
+	kind text,
+	status text,
+	total integer,
+	processed integer,
+	result text,
+	error text,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+
+CREATE TABLE IF NOT EXISTS users (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	email text,
+	password_hash text,
+	role text,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
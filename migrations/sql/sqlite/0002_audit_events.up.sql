@@ -0,0 +1,11 @@
+CREATE TABLE audit_events (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	product_id integer NOT NULL,
+	action text NOT NULL,
+	actor text NOT NULL,
+	old_values text,
+	new_values text,
+	created_at datetime NOT NULL
+);
+
+CREATE INDEX idx_audit_events_product ON audit_events (product_id, id);
@@ -0,0 +1,13 @@
+CREATE TABLE idempotency_keys (
+	owner text NOT NULL,
+	idempotency_key text NOT NULL,
+	request_hash text NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	header text,
+	body text,
+	created_at datetime NOT NULL,
+	expires_at datetime NOT NULL,
+	PRIMARY KEY (owner, idempotency_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,27 @@
+CREATE TABLE webhooks (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	url text NOT NULL,
+	events text NOT NULL,
+	secret text NOT NULL,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE TABLE webhook_deliveries (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	webhook_id integer NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event text NOT NULL,
+	payload text NOT NULL,
+	status text NOT NULL,
+	attempts integer NOT NULL DEFAULT 0,
+	response_status integer NOT NULL DEFAULT 0,
+	response_body text,
+	error text,
+	next_attempt_at datetime,
+	created_at datetime,
+	updated_at datetime
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+
+CREATE INDEX idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
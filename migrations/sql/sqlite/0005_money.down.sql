@@ -0,0 +1,9 @@
+ALTER TABLE products DROP COLUMN currency;
+
+ALTER TABLE products ADD COLUMN price_major real;
+
+UPDATE products SET price_major = price / 100.0;
+
+ALTER TABLE products DROP COLUMN price;
+
+ALTER TABLE products RENAME COLUMN price_major TO price;
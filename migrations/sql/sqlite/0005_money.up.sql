@@ -0,0 +1,12 @@
+-- Prices become whole hundredths of their currency's unit. Every existing
+-- price was in the one currency the service had, taken to be USD. SQLite
+-- can't change a column's type, so the column is replaced.
+ALTER TABLE products ADD COLUMN price_minor integer NOT NULL DEFAULT 0;
+
+UPDATE products SET price_minor = CAST(ROUND(COALESCE(price, 0) * 100) AS integer);
+
+ALTER TABLE products DROP COLUMN price;
+
+ALTER TABLE products RENAME COLUMN price_minor TO price;
+
+ALTER TABLE products ADD COLUMN currency text NOT NULL DEFAULT 'USD';
@@ -0,0 +1,19 @@
+ALTER TABLE users DROP COLUMN tenant_id;
+
+DROP INDEX idx_webhooks_tenant_id;
+
+ALTER TABLE webhooks DROP COLUMN tenant_id;
+
+DROP INDEX idx_suppliers_tenant_id;
+
+ALTER TABLE suppliers DROP COLUMN tenant_id;
+
+DROP INDEX idx_categories_tenant_id;
+
+ALTER TABLE categories DROP COLUMN tenant_id;
+
+DROP INDEX idx_products_tenant_id_id;
+
+ALTER TABLE products DROP COLUMN tenant_id;
+
+DROP TABLE tenants;
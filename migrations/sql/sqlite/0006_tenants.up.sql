@@ -0,0 +1,30 @@
+-- Products, categories, suppliers and webhooks belong to a tenant. What
+-- existed before goes to the default tenant, 1. Users without a tenant work
+-- across all of them. SQLite can't add a column that references another
+-- table with a non-null default, so tenant_id has no foreign key here.
+CREATE TABLE tenants (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	created_at datetime,
+	updated_at datetime
+);
+
+INSERT INTO tenants (id, name, created_at, updated_at) VALUES (1, 'Default', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+ALTER TABLE products ADD COLUMN tenant_id integer NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_products_tenant_id_id ON products (tenant_id, id);
+
+ALTER TABLE categories ADD COLUMN tenant_id integer NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_categories_tenant_id ON categories (tenant_id);
+
+ALTER TABLE suppliers ADD COLUMN tenant_id integer NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_suppliers_tenant_id ON suppliers (tenant_id);
+
+ALTER TABLE webhooks ADD COLUMN tenant_id integer NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_webhooks_tenant_id ON webhooks (tenant_id);
+
+ALTER TABLE users ADD COLUMN tenant_id integer REFERENCES tenants (id);
@@ -0,0 +1,16 @@
+-- The ledger of stock movements: every adjustment of a product's quantity
+-- and every reservation or release of its stock.
+CREATE TABLE stock_movements (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer NOT NULL REFERENCES tenants (id),
+	product_id integer NOT NULL,
+	kind text NOT NULL,
+	delta integer NOT NULL,
+	reason text NOT NULL,
+	actor text NOT NULL,
+	quantity integer NOT NULL,
+	reserved integer NOT NULL,
+	created_at datetime NOT NULL
+);
+
+CREATE INDEX idx_stock_movements_product ON stock_movements (tenant_id, product_id, id);
@@ -0,0 +1,16 @@
+-- Images of products. The files are in storage under key; deleting a
+-- product for good deletes its rows, though not the files.
+CREATE TABLE product_images (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer NOT NULL REFERENCES tenants (id),
+	product_id integer NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+	key text NOT NULL,
+	content_type text NOT NULL,
+	size integer NOT NULL,
+	width integer NOT NULL,
+	height integer NOT NULL,
+	alt text NOT NULL DEFAULT '',
+	created_at datetime NOT NULL
+);
+
+CREATE INDEX idx_product_images_product ON product_images (tenant_id, product_id, id);
@@ -0,0 +1,20 @@
+-- API keys of machine clients. Only a SHA-256 hash of each key is kept;
+-- prefix, its first characters, tells keys apart in listings.
+CREATE TABLE api_keys (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer REFERENCES tenants (id),
+	name text NOT NULL,
+	prefix text NOT NULL,
+	hash text NOT NULL,
+	scopes text NOT NULL,
+	created_by text NOT NULL DEFAULT '',
+	expires_at datetime,
+	last_used_at datetime,
+	revoked_at datetime,
+	created_at datetime NOT NULL,
+	updated_at datetime NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_api_keys_hash ON api_keys (hash);
+
+CREATE INDEX idx_api_keys_tenant_id ON api_keys (tenant_id, id);
@@ -0,0 +1,26 @@
+-- Orders and their items. Items keep the name and price their product had
+-- when the order was placed, and outlive the product.
+CREATE TABLE orders (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer NOT NULL REFERENCES tenants (id),
+	status text NOT NULL,
+	total integer NOT NULL,
+	currency text NOT NULL,
+	created_by text NOT NULL DEFAULT '',
+	created_at datetime NOT NULL,
+	updated_at datetime NOT NULL
+);
+
+CREATE INDEX idx_orders_tenant_id ON orders (tenant_id, id);
+
+CREATE TABLE order_items (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer NOT NULL REFERENCES tenants (id),
+	order_id integer NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	product_id integer NOT NULL,
+	name text NOT NULL,
+	quantity integer NOT NULL,
+	unit_price integer NOT NULL
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items (order_id, id);
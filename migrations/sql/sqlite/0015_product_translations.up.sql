@@ -0,0 +1,13 @@
+-- Names and descriptions of products in other locales, one per locale.
+CREATE TABLE product_translations (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer NOT NULL REFERENCES tenants (id),
+	product_id integer NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+	locale text NOT NULL,
+	name text NOT NULL,
+	description text NOT NULL DEFAULT '',
+	created_at datetime NOT NULL,
+	updated_at datetime NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_product_translations_locale ON product_translations (product_id, locale);
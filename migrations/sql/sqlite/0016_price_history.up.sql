@@ -0,0 +1,16 @@
+-- Every price a product has had, from the time it took effect. Products
+-- that already exist start from their current price, as of their last
+-- update.
+CREATE TABLE price_history (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant_id integer NOT NULL REFERENCES tenants (id),
+	product_id integer NOT NULL,
+	price integer NOT NULL,
+	currency text NOT NULL,
+	changed_at datetime NOT NULL
+);
+
+CREATE INDEX idx_price_history_product ON price_history (tenant_id, product_id, changed_at, id);
+
+INSERT INTO price_history (tenant_id, product_id, price, currency, changed_at)
+SELECT tenant_id, id, price, currency, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM products ORDER BY id;
@@ -0,0 +1,20 @@
+CREATE TABLE idempotency_keys_old (
+	owner text NOT NULL,
+	idempotency_key text NOT NULL,
+	request_hash text NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	header text,
+	body text,
+	created_at datetime NOT NULL,
+	expires_at datetime NOT NULL,
+	PRIMARY KEY (owner, idempotency_key)
+);
+
+INSERT INTO idempotency_keys_old (owner, idempotency_key, request_hash, status, header, body, created_at, expires_at)
+SELECT owner, idempotency_key, request_hash, status, header, body, created_at, expires_at FROM idempotency_keys WHERE tenant_id = 1;
+
+DROP TABLE idempotency_keys;
+
+ALTER TABLE idempotency_keys_old RENAME TO idempotency_keys;
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
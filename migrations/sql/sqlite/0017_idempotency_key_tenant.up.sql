@@ -0,0 +1,25 @@
+-- Idempotency keys are per tenant as well as per user, as anonymous
+-- callers of every tenant share an owner. Keys already stored go to the
+-- default tenant, 1. SQLite can't change a primary key, so the table is
+-- rebuilt.
+CREATE TABLE idempotency_keys_new (
+	tenant_id integer NOT NULL,
+	owner text NOT NULL,
+	idempotency_key text NOT NULL,
+	request_hash text NOT NULL,
+	status integer NOT NULL DEFAULT 0,
+	header text,
+	body text,
+	created_at datetime NOT NULL,
+	expires_at datetime NOT NULL,
+	PRIMARY KEY (tenant_id, owner, idempotency_key)
+);
+
+INSERT INTO idempotency_keys_new (tenant_id, owner, idempotency_key, request_hash, status, header, body, created_at, expires_at)
+SELECT 1, owner, idempotency_key, request_hash, status, header, body, created_at, expires_at FROM idempotency_keys;
+
+DROP TABLE idempotency_keys;
+
+ALTER TABLE idempotency_keys_new RENAME TO idempotency_keys;
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,5 @@
+DROP INDEX idx_jobs_tenant_id;
+
+ALTER TABLE jobs DROP COLUMN actor;
+
+ALTER TABLE jobs DROP COLUMN tenant_id;
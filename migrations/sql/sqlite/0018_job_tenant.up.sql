@@ -0,0 +1,9 @@
+-- Jobs belong to the tenant they were started for, and record who started
+-- them. Jobs that existed before go to the default tenant, 1. SQLite can't
+-- add a column that references another table with a non-null default, so
+-- tenant_id has no foreign key here.
+ALTER TABLE jobs ADD COLUMN tenant_id integer NOT NULL DEFAULT 1;
+
+ALTER TABLE jobs ADD COLUMN actor text NOT NULL DEFAULT '';
+
+CREATE INDEX idx_jobs_tenant_id ON jobs (tenant_id);
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// API key scopes. A read key can only make GET and HEAD requests; a write
+// key can make any request an admin can.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// APIKeyScopes are the scopes an API key can have.
+var APIKeyScopes = []string{ScopeRead, ScopeWrite}
+
+// APIKey lets a machine client call the API as an admin, within its
+// scopes, without signing in. Only the hash of the key is stored; the key
+// itself is only shown when it is created.
+type APIKey struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// TenantID is the tenant the key works in, or nil for a key of the
+	// whole service, like its creator.
+	TenantID   *uint      `json:"tenant_id"`
+	Name       string     `json:"name" gorm:"size:100"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`
+	Hash       string     `json:"-" gorm:"size:64;uniqueIndex"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	CreatedBy  string     `json:"created_by" gorm:"size:64"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Key is the key itself, only set in the response that creates it.
+	Key string `json:"key,omitempty" gorm:"-"`
+}
+
+// Active reports whether the key can be used at t.
+func (k *APIKey) Active(t time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || t.Before(*k.ExpiresAt))
+}
+
+// Allows reports whether the key's scopes allow a request with method.
+func (k *APIKey) Allows(method string) bool {
+	if slices.Contains(k.Scopes, ScopeWrite) {
+		return true
+	}
+	return slices.Contains(k.Scopes, ScopeRead) && (method == "GET" || method == "HEAD" || method == "OPTIONS")
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Audit actions.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// AuditEvent records one change to a product: who made it, when, and the
+// fields it changed, before and after. A create has no Before and a delete
+// no After.
+type AuditEvent struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	ProductID uint           `json:"product_id"`
+	Action    string         `json:"action"`
+	Actor     string         `json:"actor"`
+	Before    map[string]any `json:"before" gorm:"column:old_values;serializer:json"`
+	After     map[string]any `json:"after" gorm:"column:new_values;serializer:json"`
+	CreatedAt time.Time      `json:"created_at"`
+}
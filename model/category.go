@@ -0,0 +1,9 @@
+package model
+
+// Category groups products in the catalog. A product belongs to at most
+// one category.
+type Category struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	TenantID uint   `json:"tenant_id" gorm:"not null"`
+	Name     string `json:"name" gorm:"not null"`
+}
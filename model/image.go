@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// ProductImage is an image of a product, kept in storage under Key. URL
+// isn't stored: it is filled in for each response, since a signed one
+// expires.
+type ProductImage struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TenantID    uint      `json:"tenant_id" gorm:"not null"`
+	ProductID   uint      `json:"product_id" gorm:"not null"`
+	Key         string    `json:"-" gorm:"size:255;not null"`
+	ContentType string    `json:"content_type" gorm:"size:64;not null"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Alt         string    `json:"alt" gorm:"size:255"`
+	URL         string    `json:"url" gorm:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+// Order statuses. An order is placed pending, and is then paid or
+// cancelled. A paid order can still be cancelled, as for a refund; a
+// cancelled one is final.
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderCancelled = "cancelled"
+)
+
+// Order is a sale of products. Placing it takes its items out of stock,
+// and cancelling it puts them back.
+type Order struct {
+	ID       uint         `json:"id" gorm:"primaryKey"`
+	TenantID uint         `json:"tenant_id" gorm:"not null"`
+	Status   string       `json:"status" gorm:"size:16;not null"`
+	Total    money.Amount `json:"total" gorm:"not null"`
+	Currency string       `json:"currency" gorm:"size:3;not null"` // of every item
+	// CreatedBy is the actor who placed the order.
+	CreatedBy string      `json:"created_by" gorm:"size:64"`
+	Items     []OrderItem `json:"items" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderItem is a line of an order: how many of a product were sold, and
+// the product's name and price when they were.
+type OrderItem struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	TenantID  uint         `json:"-" gorm:"not null"`
+	OrderID   uint         `json:"-" gorm:"not null"`
+	ProductID uint         `json:"product_id" gorm:"not null"`
+	Name      string       `json:"name" gorm:"size:255;not null"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice money.Amount `json:"unit_price" gorm:"not null"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+// PriceChange is an entry of a product's price history: the price and
+// currency it had from ChangedAt until its next entry.
+type PriceChange struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	TenantID  uint         `json:"tenant_id" gorm:"not null"`
+	ProductID uint         `json:"product_id" gorm:"not null"`
+	Price     money.Amount `json:"price" gorm:"not null"`
+	Currency  string       `json:"currency" gorm:"size:3;not null"`
+	ChangedAt time.Time    `json:"changed_at" gorm:"not null"`
+}
+
+func (PriceChange) TableName() string { return "price_history" }
@@ -0,0 +1,65 @@
+// Package model defines the records the service stores.
+package model
+
+import (
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/money"
+	"gorm.io/gorm"
+)
+
+// DefaultCurrency is the currency of products created without one.
+const DefaultCurrency = "USD"
+
+// Product represents the product model
+type Product struct {
+	ID       uint         `json:"id" gorm:"primaryKey"`
+	TenantID uint         `json:"tenant_id" gorm:"not null"`
+	Name     string       `json:"name"`
+	Price    money.Amount `json:"price" gorm:"not null"`
+	Currency string       `json:"currency" gorm:"size:3;not null"` // ISO 4217
+	Quantity int          `json:"quantity"`
+	Reserved int          `json:"reserved"`
+	MinStock int          `json:"min_stock"`
+	MaxStock int          `json:"max_stock"` // 0 means no upper limit
+
+	CategoryID *uint   `json:"category_id"`
+	SupplierID *uint   `json:"supplier_id"`
+	SKU        *string `json:"sku,omitempty" gorm:"size:64;index"`
+
+	// Locale and Description aren't stored with the product: they are only
+	// set on reads localized for the client, from the translation into
+	// Locale, which also replaces Name.
+	Locale      string `json:"locale,omitempty" gorm:"-"`
+	Description string `json:"description,omitempty" gorm:"-"`
+
+	// Category and Supplier are only loaded when asked for, and are never
+	// written through a product.
+	Category *Category `json:"category,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	Supplier *Supplier `json:"supplier,omitempty" gorm:"constraint:OnDelete:SET NULL"`
+	// Images are likewise only loaded when asked for, oldest first, and
+	// are uploaded separately.
+	Images []ProductImage `json:"images,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+
+	// Version is incremented by every update, so a client can tell whether
+	// the product changed since it read it.
+	Version uint `json:"version" gorm:"not null;default:1"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set when the product is deleted. Deleted products are
+	// left out of every query unless it is Unscoped, and can be restored.
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// BeforeCreate starts new products at version 1, in DefaultCurrency
+// unless they have one.
+func (p *Product) BeforeCreate(tx *gorm.DB) error {
+	if p.Version == 0 {
+		p.Version = 1
+	}
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Stock movement kinds.
+const (
+	MovementAdjust  = "adjust"
+	MovementReserve = "reserve"
+	MovementRelease = "release"
+)
+
+// StockMovement is an entry of a product's stock ledger. Delta is the
+// change to the quantity for an adjustment, and to the reserved stock for
+// a reservation or release. Quantity and Reserved are the product's after
+// the movement.
+type StockMovement struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null"`
+	ProductID uint      `json:"product_id" gorm:"not null"`
+	Kind      string    `json:"kind" gorm:"size:16;not null"`
+	Delta     int       `json:"delta"`
+	Reason    string    `json:"reason" gorm:"size:255"`
+	Actor     string    `json:"actor"`
+	Quantity  int       `json:"quantity"`
+	Reserved  int       `json:"reserved"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Supplier is who a product is bought from. A product has at most one
+// supplier.
+type Supplier struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null"`
+	Name      string    `json:"name" gorm:"not null"`
+	Email     string    `json:"email" gorm:"size:254"`
+	Phone     string    `json:"phone" gorm:"size:32"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
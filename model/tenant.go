@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// DefaultTenantID is the tenant of requests that name none, and of the data
+// stored before there were tenants.
+const DefaultTenantID = 1
+
+// Tenant is an organization whose products, categories, suppliers and
+// webhooks are kept apart from every other tenant's.
+type Tenant struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// ProductTranslation is a product's name and description in one locale, a
+// BCP 47 tag such as "fr" or "pt-BR". Reads localized for a client use the
+// translation that best matches its Accept-Language.
+type ProductTranslation struct {
+	ID          uint      `json:"-" gorm:"primaryKey"`
+	TenantID    uint      `json:"-" gorm:"not null"`
+	ProductID   uint      `json:"product_id" gorm:"not null"`
+	Locale      string    `json:"locale" gorm:"size:35;not null"`
+	Name        string    `json:"name" gorm:"size:255;not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Roles a user can have.
+const (
+	RoleAdmin  = "admin"
+	RoleViewer = "viewer"
+)
+
+// User is an account that can sign in.
+type User struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Email        string    `json:"email" gorm:"size:254;uniqueIndex"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role" gorm:"size:16"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// TenantID is the tenant the user works in, or nil for a user of the
+	// whole service, who picks a tenant per request.
+	TenantID *uint `json:"tenant_id"`
+}
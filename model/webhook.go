@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types, one per audit action.
+const (
+	EventProductCreated  = "product.created"
+	EventProductUpdated  = "product.updated"
+	EventProductDeleted  = "product.deleted"
+	EventProductRestored = "product.restored"
+)
+
+// WebhookEvents maps each audit action to the event it triggers.
+var WebhookEvents = map[string]string{
+	AuditCreate:  EventProductCreated,
+	AuditUpdate:  EventProductUpdated,
+	AuditDelete:  EventProductDeleted,
+	AuditRestore: EventProductRestored,
+}
+
+// Webhook is a URL that is sent the events it subscribes to. Secret signs
+// the deliveries; it is only shown when the webhook is created.
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events" gorm:"serializer:json"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event to send to a webhook, and how sending it
+// has gone so far. NextAttemptAt is nil once it succeeded or failed for
+// good.
+type WebhookDelivery struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	WebhookID      uint            `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload" gorm:"serializer:json"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
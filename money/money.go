@@ -0,0 +1,117 @@
+// Package money represents prices exactly, as integer counts of hundredths
+// of a currency unit, so sums and comparisons don't drift the way floats
+// do.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places an Amount holds.
+const Scale = 2
+
+// unit is the Amount of one whole currency unit.
+const unit = 100
+
+// Amount is a sum of money in minor units: hundredths of the currency's
+// unit, whatever the currency. It is written in JSON as a decimal string
+// such as "19.99", and read from one or from a JSON number.
+type Amount int64
+
+// ErrInvalid is returned for text that isn't an amount.
+var ErrInvalid = errors.New("money: invalid amount")
+
+// Parse reads a decimal such as "19.99", "-5" or "0.5". It rejects more
+// than Scale decimal places rather than round them.
+func Parse(s string) (Amount, error) {
+	digits, neg := strings.CutPrefix(s, "-")
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" || len(frac) > Scale || !allDigits(whole) || !allDigits(frac) {
+		return 0, ErrInvalid
+	}
+	frac += strings.Repeat("0", Scale-len(frac))
+	var n uint64
+	for _, part := range []string{whole, frac} {
+		for i := 0; i < len(part); i++ {
+			d := uint64(part[i] - '0')
+			if n > (math.MaxInt64-d)/10 {
+				return 0, ErrInvalid
+			}
+			n = n*10 + d
+		}
+	}
+	if neg {
+		return -Amount(n), nil
+	}
+	return Amount(n), nil
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromFloat converts f to the nearest Amount, for callers that only have a
+// float, such as clients of older APIs.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * unit))
+}
+
+// Float returns a as a float, which may not be exact.
+func (a Amount) Float() float64 {
+	return float64(a) / unit
+}
+
+// String formats a with exactly Scale decimal places, e.g. "19.90".
+func (a Amount) String() string {
+	n := int64(a)
+	sign := ""
+	if n < 0 {
+		sign = "-"
+	}
+	// Unsigned, so that the smallest Amount has an absolute value
+	u := uint64(n)
+	if n < 0 {
+		u = -u
+	}
+	return sign + strconv.FormatUint(u/unit, 10) + "." + strconv.FormatUint(u%unit+unit, 10)[1:]
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts a decimal string or a JSON number, which is read
+// from its text so that 19.99 is exactly 1999 minor units.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else if strings.ContainsAny(s, "eE") {
+		// An exponent, as some encoders write large numbers; exact
+		// enough for any real price
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.Abs(f*unit) >= math.MaxInt64 {
+			return ErrInvalid
+		}
+		*a = FromFloat(f)
+		return nil
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
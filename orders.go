@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+)
+
+// orderSchema describes how orders can be listed.
+var orderSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Kind: query.Uint, Sortable: true},
+		"status":     {Column: "status", Kind: query.String, Ops: []query.Op{query.Eq}},
+		"total":      {Column: "total", Kind: query.Money, Sortable: true, Ops: []query.Op{query.Gte, query.Lte}},
+		"created_at": {Column: "created_at", Kind: query.Time, Sortable: true, Ops: []query.Op{query.Gte, query.Lte}},
+	},
+	Key:          "id",
+	DefaultLimit: defaultPageLimit,
+	MaxLimit:     maxPageLimit,
+}
+
+// orderList is the envelope of a page of orders, paged like productList.
+type orderList struct {
+	Data       []model.Order `json:"data"`
+	Meta       query.Meta    `json:"meta"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// orderRequest is the body of POST /orders.
+type orderRequest struct {
+	Items []struct {
+		ProductID uint `json:"product_id"`
+		Quantity  int  `json:"quantity"`
+	} `json:"items"`
+}
+
+func orderService(r *http.Request) *service.OrderService {
+	return service.NewOrderService(repository.NewOrderRepository(dbFor(r))).As(actorFor(r.Context())).WithContext(r.Context())
+}
+
+func orderReader(r *http.Request) *service.OrderService {
+	return service.NewOrderService(repository.NewOrderRepository(readDBFor(r))).WithContext(r.Context())
+}
+
+// Get all orders
+func getOrders(w http.ResponseWriter, r *http.Request) {
+	params, err := orderSchema.Parse(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	orders, total, err := orderReader(r).List(params)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	orders, next, err := query.Next(params, orders)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if orders == nil {
+		orders = []model.Order{}
+	}
+	writeJSON(w, r, http.StatusOK, orderList{Data: orders, Meta: params.Meta(total), NextCursor: next})
+}
+
+// Get a single order by ID
+func getOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "order_not_found")
+		return
+	}
+	order, err := orderReader(r).Get(id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// Place an order, taking its items out of stock
+func placeOrder(w http.ResponseWriter, r *http.Request) {
+	var req orderRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	items := make([]model.OrderItem, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = model.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+	order, err := orderService(r).Place(items)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	invalidateProducts(r.Context(), depsFor(r))
+	w.Header().Set("Location", apiV1Prefix+"/orders/"+strconv.FormatUint(uint64(order.ID), 10))
+	writeJSON(w, r, http.StatusCreated, order)
+}
+
+// Mark a pending order paid
+func payOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "order_not_found")
+		return
+	}
+	order, err := orderService(r).Pay(id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+// Cancel an order, putting its items back in stock
+func cancelOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "order_not_found")
+		return
+	}
+	order, err := orderService(r).Cancel(id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	invalidateProducts(r.Context(), depsFor(r))
+	writeJSON(w, r, http.StatusOK, order)
+}
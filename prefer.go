@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Values accepted for ?return= and the Prefer: return= header.
+const (
+	returnMinimal        = "minimal"
+	returnRepresentation = "representation"
+)
+
+// minimalProduct is the body written for return=minimal.
+type minimalProduct struct {
+	ID        uint      `json:"id"`
+	Version   uint      `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// returnMode reports how much of the written resource the client wants
+// back. The ?return= query parameter wins over a Prefer header; without
+// either the full representation is returned. ok is false for an unknown
+// query value. Unknown Prefer values are ignored, as RFC 7240 requires.
+func returnMode(r *http.Request) (mode string, ok bool) {
+	if v := r.URL.Query().Get("return"); v != "" {
+		if v != returnMinimal && v != returnRepresentation {
+			return "", false
+		}
+		return v, true
+	}
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+			if strings.EqualFold(name, "return") && (value == returnMinimal || value == returnRepresentation) {
+				return value, true
+			}
+		}
+	}
+	return returnRepresentation, true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/testutil"
+)
+
+func TestUpdateReturnModes(t *testing.T) {
+	c, _ := newTestAPI(t)
+	var p Product
+	c.Post("/api/v1/products", map[string]any{"name": "Hammer", "price": "9.99"}).Expect(201).Decode(&p)
+	path := fmt.Sprintf("/api/v1/products/%d", p.ID)
+
+	// expect checks that r applied mode and returned a body with fields
+	expect := func(r *testutil.Response, mode string, fields ...string) {
+		t.Helper()
+		r.Expect(200)
+		if got := r.Header().Get("Preference-Applied"); got != "return="+mode {
+			t.Errorf("%s: Preference-Applied %q, want return=%s", r.Body, got, mode)
+		}
+		var body map[string]json.RawMessage
+		r.Decode(&body)
+		for _, field := range fields {
+			if _, ok := body[field]; !ok {
+				t.Errorf("%s: no %s", r.Body, field)
+			}
+		}
+		if mode == returnMinimal && len(body) != len(fields) {
+			t.Errorf("minimal body %s, want only %v", r.Body, fields)
+		}
+	}
+	minimal := []string{"id", "version", "updated_at"}
+	full := []string{"id", "version", "updated_at", "name", "price", "quantity"}
+
+	expect(c.Patch(path, map[string]any{"quantity": 1}), returnRepresentation, full...)
+	expect(c.Patch(path+"?return=minimal", map[string]any{"quantity": 2}), returnMinimal, minimal...)
+	expect(c.WithHeader("Prefer", "return=minimal").Patch(path, map[string]any{"quantity": 3}), returnMinimal, minimal...)
+	expect(c.WithHeader("Prefer", "respond-async, return=minimal").Put(path, map[string]any{"name": "Hammer", "price": "9.99", "version": 4}), returnMinimal, minimal...)
+	// The query parameter wins over the header
+	expect(c.WithHeader("Prefer", "return=minimal").Patch(path+"?return=representation", map[string]any{"quantity": 4}), returnRepresentation, full...)
+	expect(c.WithHeader("Prefer", "return=everything").Patch(path, map[string]any{"quantity": 5}), returnRepresentation, full...)
+
+	var got minimalProduct
+	c.Patch(path+"?return=minimal", map[string]any{"quantity": 6}).Expect(200).Decode(&got)
+	if got.ID != p.ID || got.Version != 8 || got.UpdatedAt.IsZero() {
+		t.Errorf("minimal body %+v, want product %d at version 8", got, p.ID)
+	}
+	expectProblem(t, c.Patch(path+"?return=everything", map[string]any{"quantity": 7}), 400, "invalid_return")
+}
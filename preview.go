@@ -0,0 +1,41 @@
+package main
+
+import "net/http"
+
+// previewSize is how many matches a preview lists.
+const previewSize = 5
+
+// productPreview is the response of GET /products/preview.
+type productPreview struct {
+	Count int64            `json:"count"`
+	First []previewProduct `json:"first"`
+}
+
+type previewProduct struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// Count the products matching the list filters and name the first few
+func previewProducts(w http.ResponseWriter, r *http.Request) {
+	filter, err := productSchema.ParseFilters(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	preview := productPreview{First: []previewProduct{}}
+	query := filter.Apply(readDBFor(r).Model(&Product{}))
+	if err := query.Count(&preview.Count).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if preview.Count > 0 {
+		err := filter.Apply(readDBFor(r).Model(&Product{})).Select("id", "name").
+			Order("id").Limit(previewSize).Find(&preview.First).Error
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+	}
+	writeJSON(w, r, http.StatusOK, preview)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+// A preview counts what the list with the same filters returns.
+func TestPreviewMatchesList(t *testing.T) {
+	c, _ := newTestAPI(t)
+	for i := range 12 {
+		name := "Hammer"
+		if i%3 == 0 {
+			name = "Saw"
+		}
+		c.Post("/api/v1/products", map[string]any{"name": fmt.Sprintf("%s %d", name, i), "price": fmt.Sprint(5 * i), "quantity": i % 4}).Expect(201)
+	}
+
+	for filters, want := range map[string]int64{
+		"":                 12,
+		"name_like=hammer": 8,
+		"name_like=hammer&price_gte=20&in_stock=true": 4,
+		"min_price=10&max_price=40&quantity_lte=2":    5,
+		"name_like=drill": 0,
+	} {
+		var preview productPreview
+		c.Get("/api/v1/products/preview?" + filters).Expect(200).Decode(&preview)
+		var list struct {
+			Data []Product  `json:"data"`
+			Meta query.Meta `json:"meta"`
+		}
+		c.Get("/api/v1/products?sort=id&limit=100&" + filters).Expect(200).Decode(&list)
+
+		if preview.Count != want {
+			t.Errorf("%q: preview count %d, want %d", filters, preview.Count, want)
+		}
+		if preview.Count != list.Meta.Total || preview.Count != int64(len(list.Data)) {
+			t.Errorf("%q: preview count %d, list total %d of %d rows", filters, preview.Count, list.Meta.Total, len(list.Data))
+			continue
+		}
+		if want := min(len(list.Data), previewSize); len(preview.First) != want {
+			t.Errorf("%q: preview lists %d products, want %d", filters, len(preview.First), want)
+			continue
+		}
+		for i, p := range preview.First {
+			if p.ID != list.Data[i].ID || p.Name != list.Data[i].Name {
+				t.Errorf("%q: preview %d is %+v, want %d %q", filters, i, p, list.Data[i].ID, list.Data[i].Name)
+			}
+		}
+	}
+	expectProblem(t, c.Get("/api/v1/products/preview?price_gte=cheap"), 400, "invalid_filter")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"gorm.io/gorm"
+)
+
+// priceSchema describes how a product's price history can be listed.
+// ?from= and ?to= keep the changes made in a time range.
+var priceSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id": {Column: "id", Kind: query.Uint, Sortable: true},
+		"changed_at": {Column: "changed_at", Kind: query.Time, Sortable: true, Ops: []query.Op{query.Gte, query.Lte},
+			Aliases: map[query.Op]string{query.Gte: "from", query.Lte: "to"}},
+	},
+	Key:          "id",
+	DefaultLimit: defaultPageLimit,
+	MaxLimit:     maxPageLimit,
+}
+
+// priceList is the envelope of a product's price history, paged like
+// productList.
+type priceList struct {
+	Data       []model.PriceChange `json:"data"`
+	Meta       query.Meta          `json:"meta"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// Get the price history of a product, deleted or not
+func getProductPrices(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	params, err := priceSchema.Parse(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// A new session, as the history takes several queries
+	repo := repository.NewProductRepository(readDBFor(r).Unscoped().Session(&gorm.Session{}))
+	changes, total, err := service.NewProductService(repo).WithContext(r.Context()).Prices(id, params)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	changes, next, err := query.Next(params, changes)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if changes == nil {
+		changes = []model.PriceChange{}
+	}
+	writeJSON(w, r, http.StatusOK, priceList{Data: changes, Meta: params.Meta(total), NextCursor: next})
+}
+
+// asOf returns the time r asks for a product's price at with ?as_of=, or
+// the zero time if it asks for the current price.
+func asOf(r *http.Request) (time.Time, error) {
+	raw := r.URL.Query().Get("as_of")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, newAPIError("invalid_as_of")
+	}
+	return t.UTC(), nil
+}
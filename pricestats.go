@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+// priceStats are the statistics of the prices in one currency; GET
+// /products/price-stats answers with those of each currency that has
+// products, ordered by currency, since prices in different currencies
+// can't be compared. Percentiles need Postgres' percentile_cont and are
+// null on other databases. The average and percentiles are rounded to
+// minor units.
+type priceStats struct {
+	Currency string        `json:"currency"`
+	Count    int64         `json:"count"`
+	Min      *money.Amount `json:"min"`
+	Max      *money.Amount `json:"max"`
+	Avg      *money.Amount `json:"avg"`
+	Median   *money.Amount `json:"median"`
+	P90      *money.Amount `json:"p90"`
+	P95      *money.Amount `json:"p95"`
+}
+
+// priceAggregates are the raw aggregates of a currency, in minor units.
+type priceAggregates struct {
+	Currency                        string
+	Count                           int64
+	Min, Max, Avg, Median, P90, P95 *float64
+}
+
+// amountOrNil rounds an aggregate to an amount.
+func amountOrNil(v *float64) *money.Amount {
+	if v == nil {
+		return nil
+	}
+	a := money.Amount(math.Round(*v))
+	return &a
+}
+
+// Get price distribution statistics per currency, optionally for one
+// category or currency
+func getPriceStats(w http.ResponseWriter, r *http.Request) {
+	tx := readDBFor(r)
+	query := tx.Model(&Product{})
+	if c := r.URL.Query().Get("category_id"); c != "" {
+		id, err := strconv.ParseUint(c, 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_category_id")
+			return
+		}
+		query = query.Where("category_id = ?", id)
+	}
+	if c := r.URL.Query().Get("currency"); c != "" {
+		query = query.Where("currency = ?", c)
+	}
+
+	selects := "currency, COUNT(*) AS count, MIN(price) AS min, MAX(price) AS max, AVG(price) AS avg"
+	if tx.Dialector.Name() == "postgres" {
+		selects += ", percentile_cont(0.5) WITHIN GROUP (ORDER BY price) AS median" +
+			", percentile_cont(0.9) WITHIN GROUP (ORDER BY price) AS p90" +
+			", percentile_cont(0.95) WITHIN GROUP (ORDER BY price) AS p95"
+	}
+	var aggs []priceAggregates
+	if err := query.Select(selects).Group("currency").Order("currency").Scan(&aggs).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	stats := make([]priceStats, 0, len(aggs))
+	for _, agg := range aggs {
+		stats = append(stats, priceStats{
+			Currency: agg.Currency,
+			Count:    agg.Count,
+			Min:      amountOrNil(agg.Min),
+			Max:      amountOrNil(agg.Max),
+			Avg:      amountOrNil(agg.Avg),
+			Median:   amountOrNil(agg.Median),
+			P90:      amountOrNil(agg.P90),
+			P95:      amountOrNil(agg.P95),
+		})
+	}
+	writeJSON(w, r, http.StatusOK, stats)
+}
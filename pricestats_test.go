@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+func TestPriceStats(t *testing.T) {
+	c, db := newTestAPI(t)
+	var category Category
+	c.Post("/api/v1/categories", map[string]any{"name": "Tools"}).Expect(201).Decode(&category)
+	for _, p := range []map[string]any{
+		{"name": "Hammer", "price": "10", "category_id": category.ID},
+		{"name": "Saw", "price": "20", "category_id": category.ID},
+		{"name": "Drill", "price": "45.50"},
+		{"name": "Chisel", "price": "7", "currency": "EUR"},
+		{"name": "Plane", "price": "100", "currency": "EUR", "category_id": category.ID},
+	} {
+		c.Post("/api/v1/products", p).Expect(201)
+	}
+
+	amount := func(a *money.Amount) string {
+		if a == nil {
+			return "null"
+		}
+		return fmt.Sprint(int64(*a))
+	}
+	type stats struct {
+		currency      string
+		count         int64
+		min, max, avg string
+		// percentiles, on Postgres only
+		median, p90, p95 string
+	}
+	tests := []struct {
+		query string
+		want  []stats
+	}{
+		{"", []stats{
+			{"EUR", 2, "700", "10000", "5350", "5350", "9070", "9535"},
+			{"USD", 3, "1000", "4550", "2517", "2000", "4040", "4295"},
+		}},
+		{fmt.Sprintf("?category_id=%d", category.ID), []stats{
+			{"EUR", 1, "10000", "10000", "10000", "10000", "10000", "10000"},
+			{"USD", 2, "1000", "2000", "1500", "1500", "1900", "1950"},
+		}},
+		{"?currency=USD", []stats{
+			{"USD", 3, "1000", "4550", "2517", "2000", "4040", "4295"},
+		}},
+		{"?currency=GBP", nil},
+	}
+	for _, tt := range tests {
+		var got []priceStats
+		c.Get("/api/v1/products/price-stats" + tt.query).Expect(200).Decode(&got)
+		if got == nil || len(got) != len(tt.want) {
+			t.Errorf("%q: got %+v, want %d currencies", tt.query, got, len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if db.Dialector.Name() != "postgres" {
+				want.median, want.p90, want.p95 = "null", "null", "null"
+			}
+			g := got[i]
+			have := stats{g.Currency, g.Count, amount(g.Min), amount(g.Max), amount(g.Avg), amount(g.Median), amount(g.P90), amount(g.P95)}
+			if have != want {
+				t.Errorf("%q: got %+v, want %+v", tt.query, have, want)
+			}
+		}
+	}
+	expectProblem(t, c.Get("/api/v1/products/price-stats?category_id=x"), 400, "invalid_category_id")
+}
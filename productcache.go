@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/cache"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"gorm.io/gorm"
+)
+
+// newProductCache returns the product read cache configured by cfg, or nil
+// if caching is off.
+func newProductCache(cfg config.Cache) (cache.Cache, error) {
+	if cfg.TTL <= 0 {
+		return nil, nil
+	}
+	if cfg.RedisURL != "" {
+		return cache.NewRedis(cfg.RedisURL, "golangdb:cache:")
+	}
+	return cache.NewLRU(cfg.Size), nil
+}
+
+// cachedRepository puts repo behind the product cache, if there is one.
+// Batches bypass it so they never cache what they haven't committed; the
+// batch invalidates it once it has.
+func cachedRepository(r *http.Request, repo repository.ProductRepository) repository.ProductRepository {
+	if _, inTx := r.Context().Value(txKey{}).(*gorm.DB); inTx {
+		return repo
+	}
+	return depsFor(r).cachedProducts(r.Context(), repo)
+}
+
+// cachedProducts puts repo behind the product cache for the request of
+// ctx, if there is a cache.
+func (d *deps) cachedProducts(ctx context.Context, repo repository.ProductRepository) repository.ProductRepository {
+	ttl := d.settings().Cache.TTL
+	if d.cache == nil || ttl <= 0 {
+		return repo
+	}
+	return repository.NewCachedProductRepository(ctx, repo, d.cache, time.Duration(ttl))
+}
+
+// invalidateProducts discards the product cache after a write that didn't
+// go through the product repository.
+func invalidateProducts(ctx context.Context, d *deps) {
+	if d.cache == nil {
+		return
+	}
+	if err := repository.InvalidateProductCache(ctx, d.cache); err != nil {
+		slog.WarnContext(ctx, "failed to invalidate the product cache", "error", err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package productpb is the generated protobuf and gRPC code of the product
+// API, from product.proto.
+package productpb
+
+//go:generate buf generate
@@ -0,0 +1,124 @@
+package query
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// cursor is the position after the last row of a page: the row's values
+// for each sort column. It records the sort it was issued for, so it can't
+// be replayed against a different ordering.
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// value decodes the i'th sort value as kind, or returns nil if it doesn't
+// parse.
+func (c *cursor) value(i int, kind Kind) any {
+	raw := string(c.Values[i])
+	if kind == String || kind == Money || kind == Time {
+		// All are JSON strings
+		if err := json.Unmarshal(c.Values[i], &raw); err != nil {
+			return nil
+		}
+		if kind == String {
+			return raw
+		}
+	}
+	v, err := parseValue(kind, raw)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+var errInvalidCursor = &Error{Code: "invalid_cursor"}
+
+// secret signs cursors so clients can't forge positions. Until SetSecret is
+// called a random key is used, which invalidates cursors on restart.
+var secret = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// SetSecret sets the key cursors are signed with. An empty key keeps the
+// random one.
+func SetSecret(key string) {
+	if key != "" {
+		secret = []byte(key)
+	}
+}
+
+func sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodeCursor returns the opaque token for c: base64url(payload "." mac).
+func encodeCursor(c cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	token := append(payload, '.')
+	token = append(token, sign(payload)...)
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// decodeCursor verifies and unpacks a token produced by encodeCursor.
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	sep := len(raw) - sha256.Size - 1
+	if sep < 0 || raw[sep] != '.' {
+		return c, errInvalidCursor
+	}
+	payload, mac := raw[:sep], raw[sep+1:]
+	if !hmac.Equal(mac, sign(payload)) {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, errInvalidCursor
+	}
+	return c, nil
+}
+
+// Next trims the extra row Apply fetched in cursor mode and returns the
+// token for the following page, or "" if this is the last one.
+func Next[T any](p *Params, rows []T) ([]T, string, error) {
+	if p.Page > 0 || len(rows) <= p.Limit {
+		return rows, "", nil
+	}
+	rows = rows[:p.Limit]
+	token, err := After(p, rows[p.Limit-1])
+	return rows, token, err
+}
+
+// After returns the token for the page starting after last in p's order.
+// Sort values are read from last's JSON encoding by field name.
+func After[T any](p *Params, last T) (string, error) {
+	encoded, err := json.Marshal(last)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return "", err
+	}
+	c := cursor{Sort: p.sortSignature()}
+	for _, s := range p.Sort {
+		c.Values = append(c.Values, fields[s.Field])
+	}
+	return encodeCursor(c)
+}
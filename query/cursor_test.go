@@ -0,0 +1,51 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := cursor{Sort: "-price,id", Values: []json.RawMessage{json.RawMessage(`"19.99"`), json.RawMessage(`42`)}}
+	token, err := encodeCursor(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeCursor(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sort != c.Sort || len(got.Values) != 2 || string(got.Values[0]) != `"19.99"` || string(got.Values[1]) != "42" {
+		t.Errorf("decoded %+v, want %+v", got, c)
+	}
+	if v := got.value(1, Uint); v != uint64(42) {
+		t.Errorf("id value %#v, want 42", v)
+	}
+}
+
+func TestInvalidCursors(t *testing.T) {
+	token, err := encodeCursor(cursor{Sort: "id", Values: []json.RawMessage{json.RawMessage(`7`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	forged := []byte(`{"s":"id","v":[700]}.`)
+	forged = append(forged, raw[len(raw)-32:]...)
+	tampered := []byte(token)
+	tampered[3] ^= 1
+
+	for name, token := range map[string]string{
+		"garbage":   "not a cursor!",
+		"empty":     "",
+		"unsigned":  base64.RawURLEncoding.EncodeToString([]byte(`{"s":"id","v":[7]}`)),
+		"forged":    base64.RawURLEncoding.EncodeToString(forged),
+		"tampered":  string(tampered),
+		"truncated": token[:len(token)-4],
+	} {
+		if _, err := decodeCursor(token); !errors.Is(err, errInvalidCursor) {
+			t.Errorf("%s token: %v, want invalid_cursor", name, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Fields are the attributes a client asked for with ?fields=, by JSON
+// name. Nil means all of them.
+type Fields []string
+
+// ParseFields reads a comma-separated ?fields= from values, each naming a
+// JSON field of T, a struct. Fields are only ever left out of responses,
+// so rows are still loaded, cached and paged whole.
+func ParseFields[T any](values url.Values) (Fields, error) {
+	raw := values.Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	known := jsonFields(reflect.TypeFor[T]())
+	var f Fields
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.ContainsFunc(known, func(field jsonField) bool { return field.name == name }) {
+			return nil, &Error{Code: "invalid_field", Args: []any{name}}
+		}
+		if !slices.Contains(f, name) {
+			f = append(f, name)
+		}
+	}
+	return f, nil
+}
+
+// Select returns v, a struct, a pointer to one, or a slice of either, as
+// JSON with only f's fields, in the struct's order. It returns v itself if
+// f is nil.
+func (f Fields) Select(v any) any {
+	if f == nil {
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		partials := make([]partial, rv.Len())
+		for i := range partials {
+			partials[i] = f.selectOne(rv.Index(i))
+		}
+		return partials
+	}
+	return f.selectOne(rv)
+}
+
+func (f Fields) selectOne(v reflect.Value) partial {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	var p partial
+	for _, field := range jsonFields(v.Type()) {
+		if !slices.Contains(f, field.name) {
+			continue
+		}
+		// Fails only through a nil embedded pointer, whose fields are left
+		// out as encoding/json does
+		if value, err := v.FieldByIndexErr(field.index); err == nil {
+			p = append(p, member{name: field.name, value: value.Interface()})
+		}
+	}
+	return p
+}
+
+// partial is an object with some of a struct's fields.
+type partial []member
+
+type member struct {
+	name  string
+	value any
+}
+
+func (p partial) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(m.name)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type jsonField struct {
+	name  string
+	index []int
+}
+
+// jsonFields returns the fields of struct type t in the order they are
+// encoded, including those of embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for _, sf := range reflect.VisibleFields(t) {
+		if name, ok := jsonName(sf); ok {
+			fields = append(fields, jsonField{name: name, index: sf.Index})
+		}
+	}
+	return fields
+}
+
+// jsonName returns the name sf is encoded under, if it is encoded on its
+// own rather than through the fields of an embedded struct.
+func jsonName(sf reflect.StructField) (string, bool) {
+	if !sf.IsExported() {
+		return "", false
+	}
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+		return "", false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, true
+}
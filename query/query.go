@@ -0,0 +1,399 @@
+// Package query turns list request parameters into GORM queries: field
+// filters, multi-column sorting, and either page/limit or keyset cursor
+// pagination. A resource describes its queryable fields once in a Schema
+// and gets all of it.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/money"
+	"gorm.io/gorm"
+)
+
+// Kind is the type of a field's values, used to parse filter parameters.
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Uint
+	Float
+	// Money is a decimal amount such as 19.99, compared in minor units.
+	Money
+	// Time is an RFC 3339 timestamp such as 2024-05-01T12:00:00Z.
+	Time
+)
+
+// Op is a filter comparison. A filter parameter is named after its field
+// with the op as suffix, e.g. price_gte; Eq uses the bare field name.
+type Op string
+
+const (
+	Eq   Op = "eq"
+	Like Op = "like"
+	Gte  Op = "gte"
+	Lte  Op = "lte"
+)
+
+// Field is a queryable attribute of a resource.
+type Field struct {
+	Column   string
+	Kind     Kind
+	Sortable bool
+	Ops      []Op
+	// Aliases are other parameter names for some of Ops, e.g. min_price
+	// for price_gte.
+	Aliases map[Op]string
+}
+
+// Schema describes how a resource can be listed. Fields are keyed by the
+// name clients use, which should match the resource's JSON field names so
+// cursors can be built from encoded rows.
+type Schema struct {
+	Fields map[string]Field
+	// Key names a unique, sortable field appended to every sort so the
+	// order is total and cursors are unambiguous.
+	Key          string
+	DefaultLimit int
+	MaxLimit     int
+	// Flags are named SQL conditions: ?name=true keeps the rows matching
+	// the condition, ?name=false the others.
+	Flags map[string]string
+}
+
+// Error is a client error in the request parameters. Code is stable and
+// machine-readable; Args fill in the message.
+type Error struct {
+	Code string
+	Args []any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %v", e.Code, e.Args)
+}
+
+// condition is one parsed filter.
+type condition struct {
+	column string
+	op     Op
+	value  any
+}
+
+// flag is the op of a condition from Schema.Flags, whose column is the
+// SQL condition and value whether rows must match it.
+const flag Op = "flag"
+
+// Filters is a set of parsed filter conditions, combined with AND.
+type Filters []condition
+
+// Apply adds the conditions to tx.
+func (f Filters) Apply(tx *gorm.DB) *gorm.DB {
+	for _, c := range f {
+		switch c.op {
+		case Eq:
+			tx = tx.Where(c.column+" = ?", c.value)
+		case Like:
+			tx = tx.Where("LOWER("+c.column+") LIKE LOWER(?)", "%"+c.value.(string)+"%")
+		case Gte:
+			tx = tx.Where(c.column+" >= ?", c.value)
+		case Lte:
+			tx = tx.Where(c.column+" <= ?", c.value)
+		case flag:
+			if c.value.(bool) {
+				tx = tx.Where(c.column)
+			} else {
+				tx = tx.Where("NOT (" + c.column + ")")
+			}
+		}
+	}
+	return tx
+}
+
+// Key identifies the conditions, for caching their results.
+func (f Filters) Key() string {
+	parts := make([]string, len(f))
+	for i, c := range f {
+		parts[i] = fmt.Sprintf("%s:%s:%s", c.column, c.op, strconv.Quote(fmt.Sprint(c.value)))
+	}
+	return strings.Join(parts, "&")
+}
+
+// ParseFilters reads the filter parameters for s's fields and flags from
+// values. Other parameters are ignored. A field's lower bound must not be
+// above its upper bound.
+func (s Schema) ParseFilters(values url.Values) (Filters, error) {
+	var f Filters
+	for _, name := range sortedKeys(s.Fields) {
+		field := s.Fields[name]
+		bounds := map[Op]int{} // index in f, by op
+		params := map[Op]string{}
+		for _, op := range field.Ops {
+			param := name
+			if op != Eq {
+				param += "_" + string(op)
+			}
+			raw := values.Get(param)
+			if alias := field.Aliases[op]; alias != "" && values.Get(alias) != "" {
+				if raw != "" {
+					return nil, &Error{Code: "conflicting_filters", Args: []any{param, alias}}
+				}
+				param, raw = alias, values.Get(alias)
+			}
+			if raw == "" {
+				continue
+			}
+			v, err := parseValue(field.Kind, raw)
+			if err != nil || (op == Like && field.Kind != String) {
+				return nil, &Error{Code: "invalid_filter", Args: []any{param}}
+			}
+			f = append(f, condition{column: field.Column, op: op, value: v})
+			bounds[op] = len(f) - 1
+			params[op] = param
+		}
+		lo, hasLo := bounds[Gte]
+		hi, hasHi := bounds[Lte]
+		if hasLo && hasHi && less(f[hi].value, f[lo].value) {
+			return nil, &Error{Code: "invalid_range", Args: []any{params[Gte], params[Lte]}}
+		}
+	}
+	for _, name := range sortedKeys(s.Flags) {
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, &Error{Code: "invalid_filter", Args: []any{name}}
+		}
+		f = append(f, condition{column: s.Flags[name], op: flag, value: v})
+	}
+	return f, nil
+}
+
+// less reports whether a is below b, two values parsed for the same kind.
+func less(a, b any) bool {
+	switch a := a.(type) {
+	case int64:
+		return a < b.(int64)
+	case uint64:
+		return a < b.(uint64)
+	case float64:
+		return a < b.(float64)
+	case money.Amount:
+		return a < b.(money.Amount)
+	case time.Time:
+		return a.Before(b.(time.Time))
+	}
+	return false
+}
+
+func parseValue(kind Kind, raw string) (any, error) {
+	switch kind {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Uint:
+		return strconv.ParseUint(raw, 10, 64)
+	case Float:
+		return strconv.ParseFloat(raw, 64)
+	case Money:
+		return money.Parse(raw)
+	case Time:
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		return t.UTC(), err
+	}
+	return raw, nil
+}
+
+// Sort is one column of the requested order.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Params is a parsed list request.
+type Params struct {
+	schema  Schema
+	Filters Filters
+	Sort    []Sort
+	Limit   int
+	// Page is the 1-based page number in page/limit mode, 0 in cursor mode.
+	Page  int
+	after *cursor
+}
+
+// Parse reads filters, ?sort=, ?limit=, and either ?page= or ?cursor=
+// from values. Sort takes comma-separated fields, each optionally prefixed
+// with "-" for descending.
+func (s Schema) Parse(values url.Values) (*Params, error) {
+	filters, err := s.ParseFilters(values)
+	if err != nil {
+		return nil, err
+	}
+	p := &Params{schema: s, Filters: filters, Limit: s.DefaultLimit}
+
+	hasKey := false
+	if raw := values.Get("sort"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			srt := Sort{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+			if f, ok := s.Fields[srt.Field]; !ok || !f.Sortable {
+				return nil, &Error{Code: "invalid_sort", Args: []any{srt.Field}}
+			}
+			hasKey = hasKey || srt.Field == s.Key
+			p.Sort = append(p.Sort, srt)
+		}
+	}
+	if !hasKey {
+		p.Sort = append(p.Sort, Sort{Field: s.Key})
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > s.MaxLimit {
+			return nil, &Error{Code: "invalid_limit", Args: []any{s.MaxLimit}}
+		}
+		p.Limit = n
+	}
+
+	rawPage, rawCursor := values.Get("page"), values.Get("cursor")
+	if rawPage != "" && rawCursor != "" {
+		return nil, &Error{Code: "page_and_cursor"}
+	}
+	if rawPage != "" {
+		n, err := strconv.Atoi(rawPage)
+		if err != nil || n < 1 {
+			return nil, &Error{Code: "invalid_page"}
+		}
+		p.Page = n
+	}
+	if rawCursor != "" {
+		c, err := decodeCursor(rawCursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != p.sortSignature() || len(c.Values) != len(p.Sort) {
+			return nil, &Error{Code: "cursor_sort_mismatch"}
+		}
+		for i, srt := range p.Sort {
+			if c.value(i, s.Fields[srt.Field].Kind) == nil {
+				return nil, errInvalidCursor
+			}
+		}
+		p.after = &c
+	}
+	return p, nil
+}
+
+// Key identifies the rows p selects, for caching them.
+func (p *Params) Key() string {
+	key := fmt.Sprintf("%s|%s|%d|%d", p.Filters.Key(), p.sortSignature(), p.Limit, p.Page)
+	if p.after != nil {
+		for _, v := range p.after.Values {
+			key += "|" + string(v)
+		}
+	}
+	return key
+}
+
+// sortSignature identifies the order, so a cursor can only be used with
+// the order it was issued for.
+func (p *Params) sortSignature() string {
+	parts := make([]string, len(p.Sort))
+	for i, s := range p.Sort {
+		parts[i] = s.Field
+		if s.Desc {
+			parts[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Apply adds the filters, order, and page window to tx. In cursor mode one
+// extra row is fetched so Next can tell whether another page exists.
+func (p *Params) Apply(tx *gorm.DB) *gorm.DB {
+	tx = p.Filters.Apply(tx)
+	if p.after != nil {
+		tx = p.keyset(tx)
+	}
+	for _, s := range p.Sort {
+		dir := " ASC"
+		if s.Desc {
+			dir = " DESC"
+		}
+		tx = tx.Order(p.schema.Fields[s.Field].Column + dir)
+	}
+	if p.Page > 0 {
+		return tx.Offset((p.Page - 1) * p.Limit).Limit(p.Limit)
+	}
+	return tx.Limit(p.Limit + 1)
+}
+
+// keyset restricts tx to rows after the cursor in the requested order:
+// (a > va) OR (a = va AND b > vb) OR ..., with < for descending columns.
+// With several columns the condition is also bounded by a >= va, which
+// databases can seek an index on (a, b, ...) to; they can't for the OR
+// alone.
+func (p *Params) keyset(tx *gorm.DB) *gorm.DB {
+	var clauses []string
+	var args []any
+	for i, s := range p.Sort {
+		var parts []string
+		for j := 0; j < i; j++ {
+			prev := p.schema.Fields[p.Sort[j].Field]
+			parts = append(parts, prev.Column+" = ?")
+			args = append(args, p.after.value(j, prev.Kind))
+		}
+		cmp := " > ?"
+		if s.Desc {
+			cmp = " < ?"
+		}
+		field := p.schema.Fields[s.Field]
+		parts = append(parts, field.Column+cmp)
+		args = append(args, p.after.value(i, field.Kind))
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	tx = tx.Where(strings.Join(clauses, " OR "), args...)
+	if len(p.Sort) > 1 {
+		first := p.schema.Fields[p.Sort[0].Field]
+		cmp := " >= ?"
+		if p.Sort[0].Desc {
+			cmp = " <= ?"
+		}
+		tx = tx.Where(first.Column+cmp, p.after.value(0, first.Kind))
+	}
+	return tx
+}
+
+// Meta describes the returned page.
+type Meta struct {
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"`
+	Page       int   `json:"page,omitempty"`
+	TotalPages int64 `json:"total_pages,omitempty"`
+}
+
+// Meta returns the page metadata given the total number of matches.
+func (p *Params) Meta(total int64) Meta {
+	m := Meta{Limit: p.Limit, Total: total}
+	if p.Page > 0 {
+		m.Page = p.Page
+		m.TotalPages = (total + int64(p.Limit) - 1) / int64(p.Limit)
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// Deterministic order keeps the generated SQL stable
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tenantHeader identifies the tenant a request is made for.
+const tenantHeader = "X-Tenant-ID"
+
+// tenantQuota limits what a single tenant may do. Zero means unlimited.
+type tenantQuota struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// Products is how many products the tenant may keep, not counting
+	// deleted ones.
+	Products int `json:"products"`
+}
+
+// quotaWindow counts a tenant's requests in the current minute.
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+// tenantQuotas enforces per-tenant quotas, keyed by tenant ID. Tenants
+// without their own entry get the "default" entry, if there is one, except
+// the default tenant, which is only held to its own.
+type tenantQuotas struct {
+	limits map[string]tenantQuota
+
+	mu      sync.Mutex
+	windows map[uint]*quotaWindow
+	// swept is when windows was last cleared of those that have ended.
+	swept time.Time
+	now   func() time.Time
+}
+
+// loadTenantQuotas reads a JSON object mapping tenant IDs to quotas.
+func loadTenantQuotas(path string) (*tenantQuotas, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var limits map[string]tenantQuota
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &tenantQuotas{limits: limits, windows: map[uint]*quotaWindow{}, now: time.Now}, nil
+}
+
+func (q *tenantQuotas) limitFor(tenant uint) tenantQuota {
+	if l, ok := q.limits[strconv.FormatUint(uint64(tenant), 10)]; ok {
+		return l
+	}
+	if tenant == model.DefaultTenantID {
+		return tenantQuota{}
+	}
+	return q.limits["default"]
+}
+
+// allowRequest counts a request against tenant's per-minute quota. When the
+// quota is used up it returns false and how long until the window resets.
+func (q *tenantQuotas) allowRequest(tenant uint) (bool, time.Duration) {
+	limit := q.limitFor(tenant).RequestsPerMinute
+	if limit <= 0 {
+		return true, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	if now.Sub(q.swept) >= time.Minute {
+		// Forget the tenants that have gone quiet
+		for id, win := range q.windows {
+			if now.Sub(win.start) >= time.Minute {
+				delete(q.windows, id)
+			}
+		}
+		q.swept = now
+	}
+	win := q.windows[tenant]
+	if win == nil || now.Sub(win.start) >= time.Minute {
+		win = &quotaWindow{start: now}
+		q.windows[tenant] = win
+	}
+	if win.count >= limit {
+		return false, win.start.Add(time.Minute).Sub(now)
+	}
+	win.count++
+	return true, 0
+}
+
+// middleware answers 429 once a tenant has used up its request quota. It
+// runs after scopeTenant, so only tenants that exist are counted.
+func (q *tenantQuotas) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := tenantFor(r)
+		if ok, wait := q.allowRequest(tenant); !ok {
+			setRetryAfter(w, wait)
+			writeError(w, r, http.StatusTooManyRequests, "request_quota_exceeded", q.limitFor(tenant).RequestsPerMinute)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tenantUsage is one row of GET /admin/quotas.
+type tenantUsage struct {
+	Tenant            uint `json:"tenant"`
+	RequestsThisMin   int  `json:"requests_this_minute"`
+	RequestsPerMinute int  `json:"requests_per_minute"`
+	Products          int  `json:"products"`
+	ProductLimit      int  `json:"product_limit"`
+}
+
+// Report each tenant's request usage in the current window and its stored
+// products
+func (q *tenantQuotas) usage(w http.ResponseWriter, r *http.Request) {
+	var stored []struct {
+		TenantID uint
+		Count    int
+	}
+	err := dbFor(r).WithContext(repository.AllTenants(r.Context())).Model(&model.Product{}).Select("tenant_id, COUNT(*) AS count").Group("tenant_id").Scan(&stored).Error
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	byTenant := map[uint]*tenantUsage{}
+	row := func(tenant uint) *tenantUsage {
+		if u, ok := byTenant[tenant]; ok {
+			return u
+		}
+		limit := q.limitFor(tenant)
+		u := &tenantUsage{Tenant: tenant, RequestsPerMinute: limit.RequestsPerMinute, ProductLimit: limit.Products}
+		byTenant[tenant] = u
+		return u
+	}
+	for _, s := range stored {
+		row(s.TenantID).Products = s.Count
+	}
+	q.mu.Lock()
+	now := q.now()
+	for tenant, win := range q.windows {
+		if now.Sub(win.start) < time.Minute {
+			row(tenant).RequestsThisMin = win.count
+		}
+	}
+	q.mu.Unlock()
+	usage := []tenantUsage{}
+	for _, u := range byTenant {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Tenant < usage[j].Tenant })
+	writeJSON(w, r, http.StatusOK, usage)
+}
+
+// productQuotaError is the error of a create that would take a tenant past
+// the product limit of its quota.
+type productQuotaError struct {
+	limit int
+}
+
+func (e *productQuotaError) Error() string {
+	return fmt.Sprintf("product quota of %d reached", e.limit)
+}
+
+// productQuota is a GORM plugin that holds each tenant to the product limit
+// of its quota: a create, or a restore of deleted products, that would take
+// it past the limit fails with a *productQuotaError, whichever endpoint it
+// comes from. The check locks the tenant's row within the statement's
+// transaction, so concurrent writes can't both pass at limit-1.
+// Statements without a tenant in their context, such as those of a backup
+// restore, are left alone.
+type productQuota struct {
+	quotas *tenantQuotas
+}
+
+func (productQuota) Name() string { return "product_quota" }
+
+func (p productQuota) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("product_quota:check", p.check),
+		cb.Update().Before("gorm:update").Register("product_quota:check_restore", p.checkRestore),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check holds a create to the quota.
+func (p productQuota) check(tx *gorm.DB) {
+	p.enforce(tx, func(*gorm.DB) (int64, error) {
+		if v := tx.Statement.ReflectValue; v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			return int64(v.Len()), nil
+		}
+		return 1, nil
+	})
+}
+
+// checkRestore holds an update that clears deleted_at, a restore, to the
+// quota, counting the deleted products it matches.
+func (p productQuota) checkRestore(tx *gorm.DB) {
+	values, ok := tx.Statement.Dest.(map[string]any)
+	if !ok {
+		return
+	}
+	if deletedAt, ok := values["deleted_at"]; !ok || deletedAt != nil {
+		return
+	}
+	p.enforce(tx, func(conn *gorm.DB) (int64, error) {
+		restoring := conn.Unscoped().Model(&model.Product{}).Where("deleted_at IS NOT NULL")
+		if c, ok := tx.Statement.Clauses["WHERE"]; ok {
+			if where, ok := c.Expression.(clause.Where); ok {
+				restoring.Statement.AddClause(where)
+			}
+		}
+		var n int64
+		err := restoring.Count(&n).Error
+		return n, err
+	})
+}
+
+// enforce fails tx if the products it adds, as counted by adding, would
+// take its tenant past the limit.
+func (p productQuota) enforce(tx *gorm.DB, adding func(conn *gorm.DB) (int64, error)) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "products" {
+		return
+	}
+	tenant, ok := repository.TenantFromContext(tx.Statement.Context)
+	if !ok {
+		return
+	}
+	limit := p.quotas.limitFor(tenant).Products
+	if limit <= 0 {
+		return
+	}
+	// In the statement's transaction
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	var locked []uint
+	if err := conn.Model(&model.Tenant{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", tenant).Pluck("id", &locked).Error; err != nil {
+		tx.AddError(err)
+		return
+	}
+	n, err := adding(conn)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	if n == 0 {
+		return
+	}
+	var count int64
+	if err := conn.Model(&model.Product{}).Count(&count).Error; err != nil {
+		tx.AddError(err)
+		return
+	}
+	if count+n > int64(limit) {
+		tx.AddError(&productQuotaError{limit: limit})
+	}
+}
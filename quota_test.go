@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/testutil"
+)
+
+// newQuotaTestAPI is newTestAPI with the quotas in the JSON quotas, and
+// tenants 2 and 3 besides the default one.
+func newQuotaTestAPI(t *testing.T, quotas string) *testutil.Client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	if err := os.WriteFile(path, []byte(quotas), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	q, err := loadTenantQuotas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, db := newTestAPI(t, func(d *deps) { d.quotas = q })
+	if err := db.Use(productQuota{quotas: q}); err != nil {
+		t.Fatal(err)
+	}
+	c.Post("/api/v1/tenants", map[string]any{"name": "Acme"}).Expect(201)
+	c.Post("/api/v1/tenants", map[string]any{"name": "Globex"}).Expect(201)
+	return c
+}
+
+// A tenant at its product quota can't create more, and other tenants are
+// unaffected.
+func TestProductQuota(t *testing.T) {
+	c := newQuotaTestAPI(t, `{"2": {"products": 2}}`)
+	acme, globex := c.WithHeader("X-Tenant-ID", "2"), c.WithHeader("X-Tenant-ID", "3")
+
+	var first, second Product
+	acme.Post("/api/v1/products", map[string]any{"name": "A1", "price": "1"}).Expect(201).Decode(&first)
+	acme.Post("/api/v1/products", map[string]any{"name": "A2", "price": "1"}).Expect(201).Decode(&second)
+	expectProblem(t, acme.Post("/api/v1/products", map[string]any{"name": "A3", "price": "1"}), 403, "product_quota_exceeded")
+	expectProblem(t, acme.Post("/api/v1/products/bulk", []map[string]any{{"name": "A3", "price": "1"}}), 403, "product_quota_exceeded")
+
+	for i := range 3 {
+		globex.Post("/api/v1/products", map[string]any{"name": fmt.Sprintf("G%d", i), "price": "1"}).Expect(201)
+		c.Post("/api/v1/products", map[string]any{"name": fmt.Sprintf("D%d", i), "price": "1"}).Expect(201)
+	}
+
+	// Deleted products don't count, until they are restored
+	path := fmt.Sprintf("/api/v1/products/%d", first.ID)
+	acme.Delete(path).Expect(204)
+	acme.Post("/api/v1/products", map[string]any{"name": "A3", "price": "1"}).Expect(201)
+	expectProblem(t, acme.Post(path+"/restore", nil), 403, "product_quota_exceeded")
+	// Restoring a product that isn't deleted adds nothing
+	acme.Post(fmt.Sprintf("/api/v1/products/%d/restore", second.ID), nil).Expect(200)
+	acme.Delete(fmt.Sprintf("/api/v1/products/%d", second.ID)).Expect(204)
+	acme.Post(path+"/restore", nil).Expect(200)
+}
+
+// Concurrent creates can't take a tenant past its quota together.
+func TestProductQuotaConcurrent(t *testing.T) {
+	c := newQuotaTestAPI(t, `{"2": {"products": 3}}`)
+	acme := c.WithHeader("X-Tenant-ID", "2")
+
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = acme.Post("/api/v1/products", map[string]any{"name": fmt.Sprintf("A%d", i), "price": "1"}).Code
+		}()
+	}
+	wg.Wait()
+	created := 0
+	for _, code := range codes {
+		switch code {
+		case 201:
+			created++
+		case 403:
+		default:
+			t.Errorf("create got %d", code)
+		}
+	}
+	if created != 3 {
+		t.Errorf("%d products created, want the quota of 3", created)
+	}
+}
+
+// The default entry holds every tenant without one of its own, each to its
+// own count, except the default tenant.
+func TestRequestQuota(t *testing.T) {
+	c := newQuotaTestAPI(t, `{"default": {"requests_per_minute": 2}}`)
+	acme, globex := c.WithHeader("X-Tenant-ID", "2"), c.WithHeader("X-Tenant-ID", "3")
+
+	acme.Get("/api/v1/products").Expect(200)
+	acme.Get("/api/v1/products").Expect(200)
+	r := acme.Get("/api/v1/products")
+	expectProblem(t, r, 429, "request_quota_exceeded")
+	if r.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After")
+	}
+	globex.Get("/api/v1/products").Expect(200)
+	for range 3 {
+		c.Get("/api/v1/products").Expect(200)
+	}
+}
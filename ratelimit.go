@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// rateBuckets holds token buckets. A bucket holds up to limit tokens and
+// refills at limit per minute; each request takes one.
+type rateBuckets interface {
+	// take takes a token from bucket key. If it is empty it returns false
+	// and how long until it has a token again.
+	take(ctx context.Context, key string, limit int) (bool, time.Duration, error)
+}
+
+// rateLimiter limits each client to a number of requests per minute, with
+// a stricter limit for writes. A client is its user if it authenticated,
+// its address otherwise. The limits can change while it runs.
+type rateLimiter struct {
+	reads, writes atomic.Int64
+	buckets       rateBuckets
+}
+
+// newRateLimiter returns the limiter configured by cfg. It is there even
+// if cfg limits nothing, so that a reload can start limiting.
+func newRateLimiter(cfg config.RateLimit) (*rateLimiter, error) {
+	l := &rateLimiter{}
+	l.setLimits(cfg)
+	if cfg.RedisURL == "" {
+		l.buckets = newMemoryBuckets()
+		return l, nil
+	}
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	l.buckets = &redisBuckets{client: redis.NewClient(opts)}
+	return l, nil
+}
+
+// setLimits replaces the limits with those of cfg. Buckets are capped at
+// the limit as they are taken from, so a lower one applies at once.
+func (l *rateLimiter) setLimits(cfg config.RateLimit) {
+	l.reads.Store(int64(cfg.ReadsPerMinute))
+	l.writes.Store(int64(cfg.WritesPerMinute))
+}
+
+// rateLimitKey identifies the client of r for rate limiting: its user or
+// API key, or else its address. Anonymous clients naming the same tenant
+// don't share a bucket, so none can use up another's.
+func rateLimitKey(r *http.Request) string {
+	if claims := claimsFor(r); claims != nil {
+		return claims.Actor()
+	}
+	return "addr:" + remoteHost(r)
+}
+
+// middleware answers 429 once a client has used up its requests. It must
+// run after authenticate, and before scopeTenant, so that the tenant
+// lookups of unknown X-Tenant-IDs are limited too. If the buckets can't be reached requests are let
+// through rather than failed.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group, limit := "read", int(l.reads.Load())
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+			group, limit = "write", int(l.writes.Load())
+		}
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, wait, err := l.buckets.take(r.Context(), group+":"+rateLimitKey(r), limit)
+		if err != nil {
+			slog.WarnContext(r.Context(), "rate limit check failed", "error", err)
+			ok = true
+		}
+		if !ok {
+			setRetryAfter(w, wait)
+			writeError(w, r, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenBucket is a bucket as of updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// memoryBuckets keeps the buckets of one instance. Buckets that have
+// refilled completely are swept once a minute, since they are the same as
+// no bucket.
+type memoryBuckets struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newMemoryBuckets() *memoryBuckets {
+	return &memoryBuckets{buckets: map[string]*tokenBucket{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func (m *memoryBuckets) take(_ context.Context, key string, limit int) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) >= time.Minute {
+		for k, b := range m.buckets {
+			if now.Sub(b.updated) >= time.Minute {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	perSecond := float64(limit) / 60
+	b := m.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: float64(limit), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second)), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// takeScript is the token bucket of memoryBuckets.take, run atomically in
+// Redis on its clock. It returns whether a token was taken and otherwise
+// the milliseconds until one is available.
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local perMs = limit / 60000
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(b[1]) or limit
+local updated = tonumber(b[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - updated) * perMs)
+local wait = 0
+if tokens < 1 then
+	wait = math.ceil((1 - tokens) / perMs)
+else
+	tokens = tokens - 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], 60000)
+return wait
+`)
+
+// redisBuckets keeps the buckets in Redis, shared by every instance.
+type redisBuckets struct {
+	client *redis.Client
+}
+
+func (b *redisBuckets) take(ctx context.Context, key string, limit int) (bool, time.Duration, error) {
+	wait, err := takeScript.Run(ctx, b.client, []string{"golangdb:ratelimit:" + key}, strconv.Itoa(limit)).Int64()
+	if err != nil {
+		return false, 0, err
+	}
+	return wait == 0, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/testutil"
+)
+
+func TestMemoryBucketsWait(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := newMemoryBuckets()
+	m.now = func() time.Time { return now }
+	take := func() (bool, time.Duration) {
+		t.Helper()
+		ok, wait, err := m.take(context.Background(), "k", 60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok, wait
+	}
+
+	for i := range 60 {
+		if ok, _ := take(); !ok {
+			t.Fatalf("request %d of 60 refused", i+1)
+		}
+	}
+	// A token a second
+	if ok, wait := take(); ok || wait != time.Second {
+		t.Errorf("61st request: %v, wait %v; want refused for 1s", ok, wait)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if ok, wait := take(); ok || wait != 750*time.Millisecond {
+		t.Errorf("after 250ms: %v, wait %v; want refused for 750ms", ok, wait)
+	}
+	now = now.Add(750 * time.Millisecond)
+	if ok, _ := take(); !ok {
+		t.Error("refused once a token refilled")
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	limiter, err := newRateLimiter(config.RateLimit{WritesPerMinute: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := newTestAPI(t, func(d *deps) { d.limiter = limiter })
+
+	c.Post("/api/v1/categories", map[string]any{"name": "A"}).Expect(201)
+	c.Post("/api/v1/categories", map[string]any{"name": "B"}).Expect(201)
+	r := c.Post("/api/v1/categories", map[string]any{"name": "C"})
+	expectProblem(t, r, 429, "rate_limited")
+	// A token every 30 seconds, and the last was just taken
+	if got := r.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After %q, want 30", got)
+	}
+	// Reads have their own limit
+	c.Get("/api/v1/categories").Expect(200)
+}
+
+// Anonymous clients are limited by address, even when they name the same
+// tenant, and before their tenant is looked up.
+func TestRateLimitAnonymousByAddress(t *testing.T) {
+	limiter, err := newRateLimiter(config.RateLimit{ReadsPerMinute: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d *deps
+	c, _ := newTestAPI(t, func(deps *deps) { d, deps.limiter = deps, limiter })
+	c.Post("/api/v1/tenants", map[string]any{"name": "Acme"}).Expect(201)
+	router := newRouter(d)
+	// An anonymous client at addr
+	client := func(addr string) *testutil.Client {
+		return testutil.NewClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = addr
+			router.ServeHTTP(w, r)
+		})).WithHeader("X-Tenant-ID", "2")
+	}
+
+	first, second := client("198.51.100.1:5000"), client("198.51.100.2:5000")
+	first.Get("/api/v1/products").Expect(200)
+	first.Get("/api/v1/products").Expect(200)
+	expectProblem(t, first.Get("/api/v1/products"), 429, "rate_limited")
+	second.Get("/api/v1/products").Expect(200)
+
+	// Unknown tenants are limited too, not looked up every time
+	third := client("198.51.100.3:5000").WithHeader("X-Tenant-ID", "99")
+	expectProblem(t, third.Get("/api/v1/products"), 404, "tenant_not_found")
+	expectProblem(t, third.Get("/api/v1/products"), 404, "tenant_not_found")
+	expectProblem(t, third.Get("/api/v1/products"), 429, "rate_limited")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/mjpvl-ai/golangdb/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+)
+
+var panicsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "golangdb_panics_total",
+	Help: "Panics recovered while serving requests, by API (http or grpc).",
+}, []string{"api"})
+
+// recoverPanics is mux middleware that turns a panic in a handler into a
+// 500 internal_error, logged with its stack, so one bad request fails on
+// its own. A panic after the response has started can only be logged; the
+// client gets it cut short. http.ErrAbortHandler is let through, as it
+// means to abort the response.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &startRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+			panicsTotal.WithLabelValues("http").Inc()
+			logging.FromContext(r.Context()).Error("panic serving request",
+				"method", r.Method, "path", r.URL.Path, "panic", v, "stack", string(debug.Stack()))
+			if rec.started {
+				panic(http.ErrAbortHandler)
+			}
+			// Those that described what the handler meant to send
+			for _, name := range []string{"Content-Length", "Content-Disposition", "ETag", "Last-Modified", "Location"} {
+				w.Header().Del(name)
+			}
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// startRecorder notes whether the response has started.
+type startRecorder struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (rec *startRecorder) WriteHeader(status int) {
+	if status >= http.StatusOK {
+		rec.started = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *startRecorder) Write(b []byte) (int, error) {
+	rec.started = true
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *startRecorder) Flush() {
+	rec.started = true
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *startRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// recoverRPC is the gRPC counterpart of recoverPanics, answering Internal.
+// Unlike net/http, gRPC doesn't recover panics at all, so without it one
+// would stop the server.
+func recoverRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			panicsTotal.WithLabelValues("grpc").Inc()
+			logging.FromContext(ctx).Error("panic serving rpc",
+				"method", info.FullMethod, "panic", v, "stack", string(debug.Stack()))
+			resp, err = nil, rpcError(ctx, newAPIError("internal_error"), http.StatusInternalServerError)
+		}
+	}()
+	return handler(ctx, req)
+}
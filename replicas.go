@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/database"
+	"github.com/mjpvl-ai/golangdb/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	dbReplicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "golangdb_db_replica_healthy",
+		Help: "Whether a read replica is serving reads: 1 if so, 0 if it is down or lagging.",
+	}, []string{"replica"})
+
+	dbReplicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "golangdb_db_replica_lag_seconds",
+		Help: "How far a read replica was behind the primary when last checked.",
+	}, []string{"replica"})
+)
+
+// errReplicationStopped is the lag check's error for a replica that no
+// longer applies the primary's changes.
+var errReplicationStopped = errors.New("replication is stopped")
+
+// replicaSet spreads reads over the database's read replicas. It checks
+// them in the background and leaves out any that is down or lags more than
+// cfg.ReplicaMaxLag until it recovers; with none left, reads go to the
+// primary.
+type replicaSet struct {
+	cfg      config.DB
+	logger   logging.GormLogger
+	replicas []*replica
+	next     atomic.Uint32
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type replica struct {
+	addr string
+	name string // for logs and metrics, which shouldn't name hosts
+	// db is nil until the replica is first reached. From then on its pool
+	// reconnects by itself.
+	db      atomic.Pointer[gorm.DB]
+	healthy atomic.Bool
+}
+
+func newReplicaSet(cfg config.DB, logger logging.GormLogger) *replicaSet {
+	s := &replicaSet{cfg: cfg, logger: logger}
+	for i, addr := range cfg.Replicas {
+		s.replicas = append(s.replicas, &replica{addr: addr, name: "replica-" + strconv.Itoa(i+1)})
+	}
+	return s
+}
+
+// pick returns the connection of the next healthy replica, or nil if
+// there is none, or no replica set.
+func (s *replicaSet) pick() *gorm.DB {
+	if s == nil || len(s.replicas) == 0 {
+		return nil
+	}
+	n := uint32(len(s.replicas))
+	first := s.next.Add(1)
+	for i := range n {
+		if r := s.replicas[(first+i)%n]; r.healthy.Load() {
+			return r.db.Load()
+		}
+	}
+	return nil
+}
+
+// healthy returns how many replicas serve reads, out of how many.
+func (s *replicaSet) healthy() (int, int) {
+	var n int
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			n++
+		}
+	}
+	return n, len(s.replicas)
+}
+
+// start checks the replicas once, then every cfg.ReplicaCheckInterval. A
+// replica that can't be reached yet doesn't hold up startup; it is tried
+// again at the next check.
+func (s *replicaSet) start(ctx context.Context) error {
+	s.checkAll(ctx)
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Duration(s.cfg.ReplicaCheckInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.checkAll(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// stop stops the checks and closes the replicas' connections. Reads still
+// in flight on them fail, so it runs after the HTTP server has drained.
+func (s *replicaSet) stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	var errs []error
+	for _, r := range s.replicas {
+		r.healthy.Store(false)
+		if db := r.db.Load(); db != nil {
+			sqlDB, err := db.DB()
+			if err == nil {
+				err = sqlDB.Close()
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *replicaSet) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.check(ctx, r)
+		}()
+	}
+	wg.Wait()
+}
+
+// check connects to r if need be and measures its lag, taking it out of
+// or back into use.
+func (s *replicaSet) check(ctx context.Context, r *replica) {
+	lag, err := s.lag(ctx, r)
+	healthy := err == nil && lag <= time.Duration(s.cfg.ReplicaMaxLag)
+	if err == nil {
+		dbReplicaLag.WithLabelValues(r.name).Set(lag.Seconds())
+	}
+	if healthy {
+		dbReplicaHealthy.WithLabelValues(r.name).Set(1)
+	} else {
+		dbReplicaHealthy.WithLabelValues(r.name).Set(0)
+	}
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	switch {
+	case healthy:
+		slog.Info("read replica in use", "replica", r.name, "lag", lag)
+	case err != nil:
+		// The error may name hosts, as for the primary
+		slog.Warn("read replica unavailable, reading from the primary", "replica", r.name, "error", err)
+	default:
+		slog.Warn("read replica lagging, reading from the primary", "replica", r.name, "lag", lag, "max_lag", time.Duration(s.cfg.ReplicaMaxLag))
+	}
+}
+
+func (s *replicaSet) lag(ctx context.Context, r *replica) (time.Duration, error) {
+	db := r.db.Load()
+	if db == nil {
+		cfg := s.cfg.Replica(r.addr)
+		opened, err := database.Open(cfg, &gorm.Config{Logger: s.logger})
+		if err != nil {
+			return 0, err
+		}
+		if err := instrumentDB(opened, cfg, r.name); err != nil {
+			if sqlDB, err := opened.DB(); err == nil {
+				sqlDB.Close()
+			}
+			return 0, err
+		}
+		r.db.Store(opened)
+		db = opened
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.PingTimeout))
+	defer cancel()
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return replicationLag(db.WithContext(ctx), s.cfg.Driver)
+}
+
+// replicationLag returns how far the database behind conn is behind its
+// primary: zero if it is caught up, or isn't a replica at all.
+func replicationLag(conn *gorm.DB, driver string) (time.Duration, error) {
+	switch driver {
+	case config.DriverPostgres:
+		// Nothing to replay means caught up, however old the last
+		// transaction replayed
+		var seconds float64
+		err := conn.Raw(`SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END`).Scan(&seconds).Error
+		return time.Duration(seconds * float64(time.Second)), err
+	case config.DriverMySQL:
+		rows, err := conn.Raw("SHOW REPLICA STATUS").Rows()
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil || !rows.Next() {
+			return 0, errors.Join(err, rows.Err())
+		}
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		for i, column := range columns {
+			if column != "Seconds_Behind_Source" {
+				continue
+			}
+			if !values[i].Valid {
+				return 0, errReplicationStopped
+			}
+			seconds, err := strconv.Atoi(values[i].String)
+			return time.Duration(seconds) * time.Second, err
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/cache"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+// productGenerationKey holds the current generation of cached products.
+// Every write starts a new one, so everything cached before it is never
+// read again and expires. A lost generation just starts a new one too.
+const productGenerationKey = "products:generation"
+
+// cachedProducts is a ProductRepository that caches the reads of another
+// for ttl. Reads inside its transactions go straight to the database, so
+// they see the transaction's writes. The cache failing is logged and
+// otherwise ignored.
+type cachedProducts struct {
+	ProductRepository
+	ctx   context.Context
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewCachedProductRepository returns repo with its Get, List and Count
+// cached in c, for the request ctx.
+func NewCachedProductRepository(ctx context.Context, repo ProductRepository, c cache.Cache, ttl time.Duration) ProductRepository {
+	return &cachedProducts{ProductRepository: repo, ctx: ctx, cache: c, ttl: ttl}
+}
+
+// InvalidateProductCache discards everything cached in c, for writes made
+// around the repository.
+func InvalidateProductCache(ctx context.Context, c cache.Cache) error {
+	return c.Set(ctx, productGenerationKey, newGeneration(), 0)
+}
+
+func newGeneration() []byte {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return []byte(hex.EncodeToString(b))
+}
+
+func (r *cachedProducts) Get(id uint) (*model.Product, error) {
+	var product *model.Product
+	err := r.cached("get:"+strconv.FormatUint(uint64(id), 10), &product, func() (err error) {
+		product, err = r.ProductRepository.Get(id)
+		return err
+	})
+	return product, err
+}
+
+func (r *cachedProducts) List(params *query.Params) ([]model.Product, error) {
+	var products []model.Product
+	err := r.cached("list:"+params.Key(), &products, func() (err error) {
+		products, err = r.ProductRepository.List(params)
+		return err
+	})
+	return products, err
+}
+
+func (r *cachedProducts) Count(filters query.Filters) (int64, error) {
+	var count int64
+	err := r.cached("count:"+filters.Key(), &count, func() (err error) {
+		count, err = r.ProductRepository.Count(filters)
+		return err
+	})
+	return count, err
+}
+
+// cached decodes the entry for key of the current generation into dst,
+// or runs load to fill dst and caches the result. Each tenant has its own
+// entries.
+func (r *cachedProducts) cached(key string, dst any, load func() error) error {
+	gen, err := r.generation()
+	if err != nil {
+		slog.WarnContext(r.ctx, "product cache unavailable", "error", err)
+		return load()
+	}
+	if tenant, ok := TenantFromContext(r.ctx); ok {
+		key = "tenant:" + strconv.FormatUint(uint64(tenant), 10) + ":" + key
+	}
+	key = "products:" + gen + ":" + key
+	data, ok, err := r.cache.Get(r.ctx, key)
+	if err == nil && ok && json.Unmarshal(data, dst) == nil {
+		return nil
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(dst); err == nil {
+		err = r.cache.Set(r.ctx, key, data, r.ttl)
+	}
+	if err != nil {
+		slog.WarnContext(r.ctx, "failed to cache products", "error", err)
+	}
+	return nil
+}
+
+func (r *cachedProducts) generation() (string, error) {
+	for {
+		gen, ok, err := r.cache.Get(r.ctx, productGenerationKey)
+		if err != nil || ok {
+			return string(gen), err
+		}
+		// Start one, unless another request just did
+		if _, err := r.cache.Add(r.ctx, productGenerationKey, newGeneration(), 0); err != nil {
+			return "", err
+		}
+	}
+}
+
+// invalidate discards the cache after a write that returned err, unless
+// the write failed.
+func (r *cachedProducts) invalidate(err error) error {
+	if err != nil {
+		return err
+	}
+	if err := InvalidateProductCache(r.ctx, r.cache); err != nil {
+		slog.WarnContext(r.ctx, "failed to invalidate the product cache", "error", err)
+	}
+	return nil
+}
+
+func (r *cachedProducts) Create(product *model.Product) error {
+	return r.invalidate(r.ProductRepository.Create(product))
+}
+
+func (r *cachedProducts) CreateMany(products []*model.Product) error {
+	return r.invalidate(r.ProductRepository.CreateMany(products))
+}
+
+func (r *cachedProducts) Save(product *model.Product) error {
+	return r.invalidate(r.ProductRepository.Save(product))
+}
+
+func (r *cachedProducts) Delete(id uint) error {
+	return r.invalidate(r.ProductRepository.Delete(id))
+}
+
+func (r *cachedProducts) Restore(id uint) error {
+	return r.invalidate(r.ProductRepository.Restore(id))
+}
+
+// Transaction runs fn on the uncached repository and discards the cache
+// once it commits.
+func (r *cachedProducts) Transaction(fn func(repo ProductRepository) error) error {
+	return r.invalidate(r.ProductRepository.Transaction(fn))
+}
+
+func (r *cachedProducts) WithContext(ctx context.Context) ProductRepository {
+	return &cachedProducts{ProductRepository: r.ProductRepository.WithContext(ctx), ctx: ctx, cache: r.cache, ttl: r.ttl}
+}
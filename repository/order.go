@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderRepository stores orders with their items.
+type OrderRepository interface {
+	// Get returns order id with its items.
+	Get(id uint) (*model.Order, error)
+	// Lock returns order id like Get and locks its row until the
+	// transaction ends. It belongs in a transaction.
+	Lock(id uint) (*model.Order, error)
+	// List returns the orders selected by params, with their items, in
+	// its order and page window.
+	List(params *query.Params) ([]model.Order, error)
+	Count(filters query.Filters) (int64, error)
+	// Create inserts order with its items.
+	Create(order *model.Order) error
+	// SetStatus changes the status of order to status.
+	SetStatus(order *model.Order, status string) error
+	// Products returns a repository of products, deleted ones included,
+	// in the same transaction, so stock can move with an order.
+	Products() ProductRepository
+	// Transaction runs fn with a repository whose writes commit together,
+	// or not at all if fn returns an error.
+	Transaction(fn func(repo OrderRepository) error) error
+	// WithContext returns a copy of the repository whose statements run
+	// under ctx, within the same transaction if any.
+	WithContext(ctx context.Context) OrderRepository
+}
+
+// gormOrders is the OrderRepository backed by a GORM connection.
+type gormOrders struct {
+	db *gorm.DB
+}
+
+// NewOrderRepository returns an OrderRepository that uses db, which may be
+// a transaction.
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &gormOrders{db: db}
+}
+
+// withItems loads the items of orders in the order they were placed.
+func withItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") })
+}
+
+func (r *gormOrders) Get(id uint) (*model.Order, error) {
+	return r.first(withItems(r.db), id)
+}
+
+func (r *gormOrders) Lock(id uint) (*model.Order, error) {
+	return r.first(withItems(r.db).Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (r *gormOrders) first(db *gorm.DB, id uint) (*model.Order, error) {
+	var order model.Order
+	if err := db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *gormOrders) List(params *query.Params) ([]model.Order, error) {
+	var orders []model.Order
+	err := params.Apply(withItems(r.db)).Find(&orders).Error
+	return orders, err
+}
+
+func (r *gormOrders) Count(filters query.Filters) (int64, error) {
+	var count int64
+	err := filters.Apply(r.db.Model(&model.Order{})).Count(&count).Error
+	return count, err
+}
+
+// Create inserts the items itself, as GORM would with an upsert, which
+// TenantScope rejects.
+func (r *gormOrders) Create(order *model.Order) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(order).Error; err != nil {
+			return err
+		}
+		if len(order.Items) == 0 {
+			return nil
+		}
+		for i := range order.Items {
+			order.Items[i].OrderID = order.ID
+		}
+		return tx.CreateInBatches(order.Items, createBatchSize).Error
+	})
+}
+
+func (r *gormOrders) SetStatus(order *model.Order, status string) error {
+	return r.db.Model(order).Omit(clause.Associations).Update("status", status).Error
+}
+
+func (r *gormOrders) Products() ProductRepository {
+	return NewProductRepository(r.db.Unscoped().Session(&gorm.Session{}))
+}
+
+func (r *gormOrders) Transaction(fn func(repo OrderRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormOrders{db: tx})
+	})
+}
+
+func (r *gormOrders) WithContext(ctx context.Context) OrderRepository {
+	return &gormOrders{db: r.db.WithContext(ctx)}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"gorm.io/gorm"
+)
+
+// ErrNoPrice is returned when a product had no price on record at the time
+// asked for, as it didn't exist yet.
+var ErrNoPrice = errors.New("no price on record")
+
+// PriceHistory is a GORM plugin that enters every price a product is
+// created with or saved at in its price history, in the writing
+// statement's transaction. A save only adds an entry if the price or
+// currency differs from the last one. Updates that don't select the price,
+// such as those of a map of other columns, are left alone, as is raw SQL.
+type PriceHistory struct{}
+
+func (PriceHistory) Name() string { return "price_history" }
+
+func (PriceHistory) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().After("gorm:create").Register("price_history:create", recordCreatedPrices),
+		cb.Update().After("gorm:update").Register("price_history:update", recordUpdatedPrices),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writtenProducts returns the products tx wrote, if it wrote any.
+func writtenProducts(tx *gorm.DB) []*model.Product {
+	if tx.Error != nil || tx.RowsAffected == 0 || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "products" {
+		return nil
+	}
+	var products []*model.Product
+	switch v := tx.Statement.ReflectValue; v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if product, ok := reflect.Indirect(v.Index(i)).Addr().Interface().(*model.Product); ok {
+				products = append(products, product)
+			}
+		}
+	case reflect.Struct:
+		if product, ok := v.Addr().Interface().(*model.Product); ok {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+func newPriceChange(product *model.Product, at time.Time) *model.PriceChange {
+	return &model.PriceChange{
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		Price:     product.Price,
+		Currency:  product.Currency,
+		ChangedAt: at,
+	}
+}
+
+func recordCreatedPrices(tx *gorm.DB) {
+	products := writtenProducts(tx)
+	if len(products) == 0 {
+		return
+	}
+	changes := make([]*model.PriceChange, len(products))
+	for i, product := range products {
+		changes[i] = newPriceChange(product, product.CreatedAt)
+	}
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	tx.AddError(conn.CreateInBatches(changes, createBatchSize).Error)
+}
+
+func recordUpdatedPrices(tx *gorm.DB) {
+	products := writtenProducts(tx)
+	if len(products) == 0 {
+		return
+	}
+	if selected, _ := tx.Statement.SelectAndOmitColumns(false, true); !selected["price"] {
+		return
+	}
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	for _, product := range products {
+		var last model.PriceChange
+		if err := conn.Where("product_id = ?", product.ID).Order("changed_at DESC, id DESC").Limit(1).Find(&last).Error; err != nil {
+			tx.AddError(err)
+			return
+		}
+		if last.ID != 0 && last.Price == product.Price && last.Currency == product.Currency {
+			continue
+		}
+		if err := conn.Create(newPriceChange(product, product.UpdatedAt)).Error; err != nil {
+			tx.AddError(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package repository stores and loads models. Callers depend on the
+// interfaces here, so storage can be swapped or mocked.
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound is returned when the requested record doesn't exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when a record was changed since it
+	// was loaded.
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// ProductRepository stores products.
+type ProductRepository interface {
+	Get(id uint) (*model.Product, error)
+	// Lock returns product id like Get and locks its row until the
+	// transaction ends, so that writes of it made under the lock don't
+	// interleave. It belongs in a transaction.
+	Lock(id uint) (*model.Product, error)
+	// List returns the products selected by params, in its order and
+	// page window.
+	List(params *query.Params) ([]model.Product, error)
+	Count(filters query.Filters) (int64, error)
+	// Search returns up to limit products matching filters whose names
+	// contain every term, the last also as a prefix, best matches first.
+	Search(terms []string, filters query.Filters, limit int) ([]model.Product, error)
+	Create(product *model.Product) error
+	// CreateMany inserts products in batches, as one statement per batch.
+	CreateMany(products []*model.Product) error
+	// Save updates product and increments its version, or returns
+	// ErrVersionConflict if the stored version is no longer
+	// product.Version.
+	Save(product *model.Product) error
+	// Delete soft-deletes product id.
+	Delete(id uint) error
+	// Restore undeletes product id. It returns ErrNotFound if there is no
+	// deleted product id.
+	Restore(id uint) error
+	// CategoryExists and SupplierExists report whether a product can
+	// refer to category or supplier id.
+	CategoryExists(id uint) (bool, error)
+	SupplierExists(id uint) (bool, error)
+	// Record stores audit events. It belongs in the transaction of the
+	// writes they describe.
+	Record(events []*model.AuditEvent) error
+	// History returns the audit events of product id selected by params,
+	// and CountHistory how many match its filters.
+	History(id uint, params *query.Params) ([]model.AuditEvent, error)
+	CountHistory(id uint, filters query.Filters) (int64, error)
+	// RecordMovement stores an entry of a product's stock ledger. It
+	// belongs in the transaction of the change it describes.
+	RecordMovement(movement *model.StockMovement) error
+	// Movements returns the stock movements of product id selected by
+	// params, and CountMovements how many match its filters.
+	Movements(id uint, params *query.Params) ([]model.StockMovement, error)
+	CountMovements(id uint, filters query.Filters) (int64, error)
+	// Prices returns the price history of product id selected by params,
+	// and CountPrices how many entries match its filters. The PriceHistory
+	// plugin keeps the history.
+	Prices(id uint, params *query.Params) ([]model.PriceChange, error)
+	CountPrices(id uint, filters query.Filters) (int64, error)
+	// PriceAt returns the entry of product id's price history in effect at
+	// t, or ErrNoPrice if there is none.
+	PriceAt(id uint, t time.Time) (*model.PriceChange, error)
+	// Transaction runs fn with a repository whose writes commit together,
+	// or not at all if fn returns an error.
+	Transaction(fn func(repo ProductRepository) error) error
+	// WithContext returns a copy of the repository whose statements run
+	// under ctx, within the same transaction if any.
+	WithContext(ctx context.Context) ProductRepository
+}
+
+// gormProducts is the ProductRepository backed by a GORM connection.
+type gormProducts struct {
+	db *gorm.DB
+}
+
+// NewProductRepository returns a ProductRepository that uses db, which may
+// be a transaction.
+func NewProductRepository(db *gorm.DB) ProductRepository {
+	return &gormProducts{db: db}
+}
+
+func (r *gormProducts) Get(id uint) (*model.Product, error) {
+	var product model.Product
+	if err := r.db.First(&product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *gormProducts) Lock(id uint) (*model.Product, error) {
+	var product model.Product
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *gormProducts) List(params *query.Params) ([]model.Product, error) {
+	var products []model.Product
+	err := params.Apply(r.db).Find(&products).Error
+	return products, err
+}
+
+func (r *gormProducts) Count(filters query.Filters) (int64, error) {
+	var count int64
+	err := filters.Apply(r.db.Model(&model.Product{})).Count(&count).Error
+	return count, err
+}
+
+// nameVector is the full-text form of a product name on PostgreSQL. It
+// must match the expression of the idx_products_name_search migration for
+// queries to use the index.
+const nameVector = "to_tsvector('simple', name)"
+
+func (r *gormProducts) Search(terms []string, filters query.Filters, limit int) ([]model.Product, error) {
+	tx := filters.Apply(r.db)
+	var order clause.Expr
+	if r.db.Dialector.Name() == "postgres" {
+		// Every term is matched as a prefix, for typeahead
+		tsquery := strings.Join(terms, ":* & ") + ":*"
+		tx = tx.Where(nameVector+" @@ to_tsquery('simple', ?)", tsquery)
+		order = clause.Expr{SQL: "ts_rank(" + nameVector + ", to_tsquery('simple', ?)) DESC, id", Vars: []any{tsquery}}
+	} else {
+		// Elsewhere fall back to substring matches, ranking names that
+		// start with the first term first, then shorter names
+		for _, term := range terms {
+			tx = tx.Where("LOWER(name) LIKE ?", "%"+term+"%")
+		}
+		order = clause.Expr{SQL: "CASE WHEN LOWER(name) LIKE ? THEN 0 ELSE 1 END, LENGTH(name), id", Vars: []any{terms[0] + "%"}}
+	}
+	// One clause: GORM drops an expression when merging in another Order
+	var products []model.Product
+	err := tx.Order(clause.OrderBy{Expression: order}).Limit(limit).Find(&products).Error
+	return products, err
+}
+
+func (r *gormProducts) Create(product *model.Product) error {
+	return AsDuplicate(r.db.Omit(clause.Associations).Create(product).Error)
+}
+
+// createBatchSize keeps multi-row inserts below the bind parameter limits
+// of the supported databases.
+const createBatchSize = 500
+
+func (r *gormProducts) CreateMany(products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	return AsDuplicate(r.db.Omit(clause.Associations).CreateInBatches(products, createBatchSize).Error)
+}
+
+func (r *gormProducts) Save(product *model.Product) error {
+	version := product.Version
+	product.Version++
+	res := r.db.Model(product).Where("version = ?", version).
+		Select("*").Omit("id", "created_at", clause.Associations).Updates(product)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = ErrVersionConflict
+	}
+	if res.Error != nil {
+		product.Version = version
+	}
+	return AsDuplicate(res.Error)
+}
+
+func (r *gormProducts) Delete(id uint) error {
+	res := r.db.Delete(&model.Product{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormProducts) Restore(id uint) error {
+	res := r.db.Unscoped().Model(&model.Product{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]any{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormProducts) CategoryExists(id uint) (bool, error) {
+	return r.exists(&model.Category{}, id)
+}
+
+func (r *gormProducts) SupplierExists(id uint) (bool, error) {
+	return r.exists(&model.Supplier{}, id)
+}
+
+func (r *gormProducts) exists(m any, id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(m).Where("id = ?", id).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormProducts) Record(events []*model.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(events, createBatchSize).Error
+}
+
+func (r *gormProducts) History(id uint, params *query.Params) ([]model.AuditEvent, error) {
+	var events []model.AuditEvent
+	err := params.Apply(r.db.Where("product_id = ?", id)).Find(&events).Error
+	return events, err
+}
+
+func (r *gormProducts) CountHistory(id uint, filters query.Filters) (int64, error) {
+	var count int64
+	err := filters.Apply(r.db.Model(&model.AuditEvent{}).Where("product_id = ?", id)).Count(&count).Error
+	return count, err
+}
+
+func (r *gormProducts) RecordMovement(movement *model.StockMovement) error {
+	return r.db.Create(movement).Error
+}
+
+func (r *gormProducts) Movements(id uint, params *query.Params) ([]model.StockMovement, error) {
+	var movements []model.StockMovement
+	err := params.Apply(r.db.Where("product_id = ?", id)).Find(&movements).Error
+	return movements, err
+}
+
+func (r *gormProducts) CountMovements(id uint, filters query.Filters) (int64, error) {
+	var count int64
+	err := filters.Apply(r.db.Model(&model.StockMovement{}).Where("product_id = ?", id)).Count(&count).Error
+	return count, err
+}
+
+func (r *gormProducts) Prices(id uint, params *query.Params) ([]model.PriceChange, error) {
+	var changes []model.PriceChange
+	err := params.Apply(r.db.Where("product_id = ?", id)).Find(&changes).Error
+	return changes, err
+}
+
+func (r *gormProducts) CountPrices(id uint, filters query.Filters) (int64, error) {
+	var count int64
+	err := filters.Apply(r.db.Model(&model.PriceChange{}).Where("product_id = ?", id)).Count(&count).Error
+	return count, err
+}
+
+func (r *gormProducts) PriceAt(id uint, t time.Time) (*model.PriceChange, error) {
+	var change model.PriceChange
+	err := r.db.Where("product_id = ? AND changed_at <= ?", id, t).
+		Order("changed_at DESC, id DESC").Limit(1).Find(&change).Error
+	if err != nil {
+		return nil, err
+	}
+	if change.ID == 0 {
+		return nil, ErrNoPrice
+	}
+	return &change, nil
+}
+
+func (r *gormProducts) Transaction(fn func(repo ProductRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormProducts{db: tx})
+	})
+}
+
+func (r *gormProducts) WithContext(ctx context.Context) ProductRepository {
+	return &gormProducts{db: r.db.WithContext(ctx)}
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tenantTables are the tables whose rows belong to a tenant.
+var tenantTables = map[string]bool{"products": true, "categories": true, "suppliers": true, "webhooks": true, "stock_movements": true, "product_images": true, "orders": true, "order_items": true, "product_translations": true, "price_history": true, "jobs": true}
+
+// ErrTenantUpsert is returned for an upsert into a tenant's table, which
+// could overwrite the row of another tenant with the same key.
+var ErrTenantUpsert = errors.New("upsert into a tenant's table")
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx whose statements only see and write the
+// rows of tenant id.
+func WithTenant(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// AllTenants returns a copy of ctx whose statements see the rows of every
+// tenant, for the operations on the whole service such as backups.
+func AllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, uint(0))
+}
+
+// TenantFromContext returns the tenant whose rows the statements under ctx
+// are confined to. ok is false if they see every tenant's.
+func TenantFromContext(ctx context.Context) (id uint, ok bool) {
+	id, _ = ctx.Value(tenantKey{}).(uint)
+	return id, id != 0
+}
+
+// TenantScope is a GORM plugin that confines every statement on a tenant's
+// table to the tenant of the statement's context: reads, updates and
+// deletes get a tenant_id condition, and inserts get its tenant_id. Rows
+// never move between tenants. Statements without a tenant in their
+// context, such as those of background workers, are left alone, as is raw
+// SQL.
+type TenantScope struct{}
+
+func (TenantScope) Name() string { return "tenant_scope" }
+
+func (TenantScope) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("tenant:assign", assignTenant),
+		cb.Query().Before("gorm:query").Register("tenant:query", scopeToTenant),
+		cb.Update().Before("gorm:update").Register("tenant:update", scopeUpdateToTenant),
+		cb.Delete().Before("gorm:delete").Register("tenant:delete", scopeToTenant),
+		cb.Row().Before("gorm:row").Register("tenant:row", scopeToTenant),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statementTenant returns the tenant tx is confined to, if it is on a
+// tenant's table and its context has one.
+func statementTenant(tx *gorm.DB) (uint, bool) {
+	if tx.Error != nil || tx.Statement.Schema == nil || !tenantTables[tx.Statement.Schema.Table] {
+		return 0, false
+	}
+	return TenantFromContext(tx.Statement.Context)
+}
+
+func scopeToTenant(tx *gorm.DB) {
+	id, ok := statementTenant(tx)
+	if !ok {
+		return
+	}
+	where := clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"}, Value: id},
+	}}
+	if c, ok := tx.Statement.Clauses["WHERE"]; ok {
+		if existing, ok := c.Expression.(clause.Where); ok && len(existing.Exprs) > 0 {
+			// Grouped, so that an OR among them can't reach past the tenant
+			where.Exprs = append(where.Exprs, clause.AndConditions{Exprs: existing.Exprs})
+		}
+		delete(tx.Statement.Clauses, "WHERE")
+	}
+	tx.Statement.AddClause(where)
+}
+
+func scopeUpdateToTenant(tx *gorm.DB) {
+	if _, ok := statementTenant(tx); !ok {
+		return
+	}
+	scopeToTenant(tx)
+	tx.Statement.Omits = append(tx.Statement.Omits, "tenant_id")
+}
+
+func assignTenant(tx *gorm.DB) {
+	id, ok := statementTenant(tx)
+	if !ok {
+		return
+	}
+	if _, upsert := tx.Statement.Clauses["ON CONFLICT"]; upsert {
+		tx.AddError(ErrTenantUpsert)
+		return
+	}
+	field := tx.Statement.Schema.LookUpField("TenantID")
+	if field == nil {
+		tx.AddError(errors.New("repository: " + tx.Statement.Schema.Name + " has no TenantID"))
+		return
+	}
+	switch v := tx.Statement.ReflectValue; v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			tx.AddError(field.Set(tx.Statement.Context, reflect.Indirect(v.Index(i)), id))
+		}
+	case reflect.Struct:
+		tx.AddError(field.Set(tx.Statement.Context, v, id))
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/mjpvl-ai/golangdb/database"
+)
+
+// DuplicateError is a write that would give a record the value another
+// already has in a field that must be unique.
+type DuplicateError struct {
+	Field string
+	Err   error
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate %s: %v", e.Field, e.Err)
+}
+
+func (e *DuplicateError) Unwrap() error { return e.Err }
+
+// uniqueIndexes are the unique indexes a client's write can run into, by
+// name and by columns, with the field each makes unique.
+var uniqueIndexes = []struct {
+	name, columns, field string
+}{
+	{"idx_products_tenant_id_sku", "products.tenant_id, products.sku", "sku"},
+}
+
+// AsDuplicate returns err as a *DuplicateError if it is the violation of
+// one of uniqueIndexes, and err itself otherwise.
+func AsDuplicate(err error) error {
+	index, ok := database.UniqueViolation(err)
+	if !ok {
+		return err
+	}
+	for _, unique := range uniqueIndexes {
+		if index == unique.name || index == unique.columns {
+			return &DuplicateError{Field: unique.field, Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"gorm.io/gorm"
+)
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	Get(id uint) (*model.User, error)
+	GetByEmail(email string) (*model.User, error)
+	Count() (int64, error)
+	Create(user *model.User) error
+	// Transaction runs fn with a repository whose writes commit together,
+	// or not at all if fn returns an error.
+	Transaction(fn func(repo UserRepository) error) error
+}
+
+// gormUsers is the UserRepository backed by a GORM connection.
+type gormUsers struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository that uses db, which may be a
+// transaction.
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUsers{db: db}
+}
+
+func (r *gormUsers) Get(id uint) (*model.User, error) {
+	return r.first("id = ?", id)
+}
+
+func (r *gormUsers) GetByEmail(email string) (*model.User, error) {
+	return r.first("email = ?", email)
+}
+
+func (r *gormUsers) first(cond string, arg any) (*model.User, error) {
+	var user model.User
+	if err := r.db.Where(cond, arg).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.User{}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormUsers) Create(user *model.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUsers) Transaction(fn func(repo UserRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormUsers{db: tx})
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+// writeJSON encodes v and writes it with an exact Content-Length. On HEAD
+// requests the headers are identical but no body is sent.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	writeJSONAs(w, r, status, "application/json", v)
+}
+
+// writeJSONAs is writeJSON with a JSON-based media type such as
+// application/problem+json.
+func writeJSONAs(w http.ResponseWriter, r *http.Request, status int, contentType string, v any) {
+	var buf bytes.Buffer
+	if err := newJSONEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(buf.Bytes())
+	}
+}
+
+// writeConditionalJSON is writeJSON for a 200 response to a GET or HEAD
+// that the client may already have. etag, or if empty a weak tag hashed
+// from the body, is sent as the ETag, and a client whose If-None-Match
+// names it gets 304 Not Modified without the body.
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, etag string, v any) {
+	var buf bytes.Buffer
+	if err := newJSONEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(buf.Bytes())
+		etag = `W/"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	}
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(buf.Bytes())
+	}
+}
+
+// decodeJSON decodes r's body, a single JSON value, into v, rejecting
+// fields v doesn't have. The error is an apiError naming the problem: an
+// empty, malformed, truncated or oversized body, a value of the wrong
+// type, an unknown field, or data after the value.
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			return newAPIError("trailing_data")
+		}
+		return nil
+	}
+	var sizeErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &sizeErr):
+		return newAPIError("body_too_large", sizeErr.Limit)
+	case errors.Is(err, io.EOF):
+		return newAPIError("empty_body")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return newAPIError("truncated_json")
+	case errors.As(err, &syntaxErr):
+		return newAPIError("malformed_json", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return newAPIError("invalid_body_type", jsonType(typeErr.Type))
+		}
+		return newAPIError("invalid_field_type", typeErr.Field, jsonType(typeErr.Type))
+	case errors.Is(err, money.ErrInvalid):
+		return newAPIError("invalid_amount", money.Scale)
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return newAPIError("unknown_field", field)
+	}
+	return newAPIError("invalid_payload")
+}
+
+// jsonType names the JSON type that decodes into t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "object"
+}
+
+// setRetryAfter sets Retry-After to wait, rounded up to whole seconds as
+// RFC 9110 requires. 429 and 503 responses should pass the limiter's or
+// breaker's actual remaining wait rather than a fixed value, so clients
+// retry exactly when capacity is back.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	secs := int64(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
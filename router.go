@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mjpvl-ai/golangdb/cache"
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/logging"
+	"github.com/mjpvl-ai/golangdb/storage"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// apiV1Prefix is where version 1 of the API is mounted. A v2 would get its
+// own subrouter next to it.
+const apiV1Prefix = "/api/v1"
+
+// deps are what the handlers need. newRouter makes them available to every
+// request it serves through depsFor.
+type deps struct {
+	db *gorm.DB
+	// replicas, if set, serve reads that can tolerate replication lag;
+	// without a healthy one, they go to db.
+	replicas *replicaSet
+	jobs     *jobRunner
+	logger   *slog.Logger
+	cfg      config.Config
+	// quotas, if set, limits each tenant's request rate and storage.
+	quotas *tenantQuotas
+	// limiter, if set, limits each client's request rate to the API.
+	limiter *rateLimiter
+	// cache, if set, caches product reads for cfg.Cache.TTL.
+	cache cache.Cache
+	// cors, if set, lets browsers on other origins call the API.
+	cors *corsPolicy
+	// storage keeps product images and export files.
+	storage storage.Storage
+	// live, if set, lets the configuration be reloaded while the server
+	// runs; settings returns the one in effect.
+	live *liveConfig
+	// inventory, if set, pushes stock and price changes to dashboards.
+	inventory *inventoryFeed
+}
+
+type depsKey struct{}
+
+// depsFor returns the dependencies of the router serving r.
+func depsFor(r *http.Request) *deps {
+	return r.Context().Value(depsKey{}).(*deps)
+}
+
+// newRouter builds the HTTP API on d: the resources under /api/v1, and the
+// unversioned operational and documentation endpoints.
+func newRouter(d *deps) *mux.Router {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(routeNotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.HandleFunc("/healthz", healthz).Methods("GET", "HEAD")
+	router.HandleFunc("/readyz", readyz).Methods("GET", "HEAD")
+	router.HandleFunc("/openapi.json", getOpenAPISpec).Methods("GET")
+	router.HandleFunc("/docs", getDocs).Methods("GET")
+
+	v1 := router.PathPrefix(apiV1Prefix).Subrouter()
+	v1.NotFoundHandler = unmatched(v1)
+	v1.MethodNotAllowedHandler = router.MethodNotAllowedHandler
+	registerResourceRoutes(v1)
+	allowLongRequests(v1.HandleFunc("/batch", requireFeature("batch", dryRunnable(batch))).Methods("POST"))
+	v1.HandleFunc("/graphql", requireFeature("graphql", newGraphQLHandler(d))).Methods("GET", "POST")
+	v1.HandleFunc("/auth/register", register).Methods("POST")
+	v1.HandleFunc("/auth/login", login).Methods("POST")
+	v1.HandleFunc("/auth/refresh", refreshTokens).Methods("POST")
+	allowLongRequests(v1.HandleFunc("/products/export", requireAdmin(exportProducts)).Methods("GET"))
+	allowLongRequests(v1.HandleFunc("/admin/backup", requirePlatformAdmin(backup)).Methods("GET"))
+	acceptContentTypes(allowLongRequests(acceptUploads(v1.HandleFunc("/admin/restore", requirePlatformAdmin(restore)).Methods("POST"))), "application/x-ndjson")
+	v1.HandleFunc("/admin/settings", requirePlatformAdmin(getSettings)).Methods("GET")
+	v1.HandleFunc("/admin/settings/reload", requirePlatformAdmin(reloadSettings)).Methods("POST")
+	v1.HandleFunc("/tenants", requirePlatformAdmin(getTenants)).Methods("GET")
+	v1.HandleFunc("/tenants", requirePlatformAdmin(createTenant)).Methods("POST")
+	v1.HandleFunc("/tenants/{id:[0-9]+}", requirePlatformAdmin(getTenant)).Methods("GET")
+	v1.HandleFunc("/api-keys", requireAdminUser(getAPIKeys)).Methods("GET")
+	v1.HandleFunc("/api-keys", requireAdminUser(createAPIKey)).Methods("POST")
+	v1.HandleFunc("/api-keys/{id:[0-9]+}", requireAdminUser(revokeAPIKey)).Methods("DELETE")
+	v1.HandleFunc("/webhooks", requireAdmin(getWebhooks)).Methods("GET")
+	v1.HandleFunc("/webhooks", requireAdmin(createWebhook)).Methods("POST")
+	v1.HandleFunc("/webhooks/{id:[0-9]+}", requireAdmin(getWebhook)).Methods("GET")
+	v1.HandleFunc("/webhooks/{id:[0-9]+}", requireAdmin(deleteWebhook)).Methods("DELETE")
+	v1.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", requireAdmin(getWebhookDeliveries)).Methods("GET")
+	if local, ok := d.storage.(*storage.Local); ok {
+		router.PathPrefix(mediaPrefix).Handler(http.StripPrefix(mediaPrefix[:len(mediaPrefix)-1], local.Handler())).Methods("GET", "HEAD")
+	}
+	if d.inventory != nil {
+		allowLongRequests(v1.HandleFunc("/ws/inventory", requireAdmin(streamInventory)).Methods("GET"))
+	}
+	if d.quotas != nil {
+		v1.HandleFunc("/admin/quotas", requirePlatformAdmin(d.quotas.usage)).Methods("GET")
+	}
+
+	if d.cors != nil {
+		// Last, so real routes take precedence; v1 needs its own because
+		// its unmatched requests never reach the parent's routes
+		d.cors.register(v1)
+		d.cors.register(router)
+		router.Use(d.cors.middleware)
+	}
+	// Outermost first. Requests for unknown routes can skip these, so
+	// request logging and tracing wrap the router instead, in main.
+	router.Use(
+		withDeps(d),
+		instrumentHTTP,
+		compressResponses,
+		// Inside instrumentHTTP, so recovered panics count as 500s
+		recoverPanics,
+		trackDBFailures,
+		limitDuration,
+		authenticate,
+		limitBodies,
+		requireContentType,
+		pinWriters,
+	)
+	if d.limiter != nil {
+		v1.Use(d.limiter.middleware)
+	}
+	v1.Use(scopeTenant)
+	if d.quotas != nil {
+		v1.Use(d.quotas.middleware)
+	}
+	return router
+}
+
+// withDeps is middleware that makes d available to handlers, with its
+// logger tagged with the request ID.
+func withDeps(d *deps) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), depsKey{}, d)
+			if d.logger != nil {
+				ctx = logging.WithLogger(ctx, d.logger)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// unmatched answers requests router has no route for: 405 with an Allow
+// header if the path exists for other methods, 404 otherwise. mux itself
+// loses the method mismatch in a subrouter when a later route shares its
+// prefix.
+func unmatched(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			methods, err := route.GetMethods()
+			if err != nil {
+				return nil
+			}
+			probe := r.Clone(r.Context())
+			probe.Method = methods[0]
+			if route.Match(probe, &mux.RouteMatch{}) {
+				allowed = append(allowed, methods...)
+			}
+			return nil
+		})
+		if len(allowed) == 0 {
+			routeNotFound(w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		methodNotAllowed(w, r)
+	})
+}
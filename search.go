@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search products by name, best matches first
+func searchProducts(w http.ResponseWriter, r *http.Request) {
+	filters, err := productSchema.ParseFilters(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	fields, err := query.ParseFields[Product](r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			writeError(w, r, http.StatusBadRequest, "invalid_limit", maxSearchLimit)
+			return
+		}
+	}
+	products, err := productReader(r).Search(r.URL.Query().Get("q"), filters, limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if products == nil {
+		products = []Product{}
+	}
+	if err := localize(r, products); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	for i := range products {
+		if err := setImageURLs(r, &products[i]); err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, "storage_unavailable")
+			return
+		}
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{"data": fields.Select(products)})
+}
@@ -0,0 +1,123 @@
+// Package secrets reads credentials kept outside the configuration: in
+// environment variables, in files such as the Docker and Kubernetes
+// secrets mounted under /run/secrets, or in HashiCorp Vault.
+//
+// A secret is named by a reference of the form provider:name, such as
+// file:/run/secrets/db_password, env:PGPASSWORD or
+// vault:golangdb/db#password, which Providers resolves with the provider
+// registered under that prefix.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Provider looks up secrets by name. The meaning of the name is up to the
+// provider: a variable, a path, a key in a store.
+type Provider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// ErrNotFound is returned for a secret a provider doesn't have.
+var ErrNotFound = errors.New("secret not found")
+
+// Providers maps the prefix of each reference to the provider resolving
+// it.
+type Providers map[string]Provider
+
+// Resolve returns the secret ref refers to. Errors name the reference,
+// never the secret.
+func (p Providers) Resolve(ctx context.Context, ref string) (string, error) {
+	prefix, name, ok := strings.Cut(ref, ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("secret %q: want provider:name", ref)
+	}
+	provider, ok := p[prefix]
+	if !ok {
+		return "", fmt.Errorf("secret %q: unknown provider %q, want one of %s", ref, prefix, strings.Join(p.prefixes(), ", "))
+	}
+	secret, err := provider.Secret(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", ref, err)
+	}
+	return secret, nil
+}
+
+func (p Providers) prefixes() []string {
+	prefixes := make([]string, 0, len(p))
+	for prefix := range p {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// Env is the Provider of environment variables, by name.
+type Env struct{}
+
+func (Env) Secret(ctx context.Context, name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+// Files is the Provider of the contents of files, by path, relative to Dir
+// unless absolute. A trailing newline, which editors and echo add, is not
+// part of the secret.
+type Files struct {
+	Dir string
+}
+
+func (f Files) Secret(ctx context.Context, name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.Dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Redact returns err with every non-empty secret in its message replaced,
+// for errors that may quote a connection string. It still wraps err.
+func Redact(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	redacted := msg
+	for _, secret := range secrets {
+		if secret != "" {
+			redacted = strings.ReplaceAll(redacted, secret, Redacted)
+		}
+	}
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{msg: redacted, err: err}
+}
+
+// Redacted stands in for a secret in logs and messages.
+const Redacted = "[REDACTED]"
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
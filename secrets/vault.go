@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds each request to Vault.
+const vaultTimeout = 10 * time.Second
+
+// Vault is the Provider of the secrets of a KV version 2 engine of
+// HashiCorp Vault, named path#key: the key of the latest version of the
+// secret at path, under the engine's Mount.
+type Vault struct {
+	Addr  string // such as https://vault.example.com:8200
+	Token string
+	Mount string // secret unless set
+
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+
+	// Client sends the requests; nil uses one with a timeout.
+	Client *http.Client
+}
+
+func (v *Vault) Secret(ctx context.Context, name string) (string, error) {
+	path, key, ok := strings.Cut(name, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("vault: want path#key, got %q", name)
+	}
+	mount := v.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	endpoint, err := url.JoinPath(v.Addr, "v1", mount, "data", path)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: vaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("vault: GET %s: %s", req.URL.Path, resp.Status)
+	}
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: decode %s: %w", req.URL.Path, err)
+	}
+	value, ok := body.Data.Data[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault: %s#%s is not a string", path, key)
+	}
+	return secret, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+// NewAuditEvent returns the event of actor's action on a product, with
+// the fields that differ between before and after. before is nil for a
+// create or restore and after for a delete, so the event has every field.
+func NewAuditEvent(action, actor string, before, after *model.Product) *model.AuditEvent {
+	event := &model.AuditEvent{Action: action, Actor: actor, Before: auditFields(before), After: auditFields(after)}
+	if before != nil {
+		event.ProductID = before.ID
+	} else {
+		event.ProductID = after.ID
+	}
+	if before != nil && after != nil {
+		for field, value := range event.Before {
+			if reflect.DeepEqual(value, event.After[field]) {
+				delete(event.Before, field)
+				delete(event.After, field)
+			}
+		}
+	}
+	return event
+}
+
+// auditFields returns p's fields as clients see them, less those that
+// aren't changes of their own: its ID, update time and associations.
+func auditFields(p *model.Product) map[string]any {
+	if p == nil {
+		return nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	for _, field := range []string{"id", "updated_at", "category", "supplier"} {
+		delete(fields, field)
+	}
+	return fields
+}
+
+// record stores the event of s's actor's action in repo, which must be
+// the repository of the write's transaction.
+func (s *ProductService) record(repo repository.ProductRepository, action string, before, after *model.Product) error {
+	return repo.Record([]*model.AuditEvent{NewAuditEvent(action, s.actor, before, after)})
+}
+
+// History returns the page of product id's audit events selected by
+// params, oldest first by default, and the total matching its filters.
+// Deleted products have a history as long as s's repository sees them.
+func (s *ProductService) History(id uint, params *query.Params) (events []model.AuditEvent, total int64, err error) {
+	s, span := s.startSpan("History")
+	defer endSpan(span, &err)
+	if _, err := s.repo.Get(id); err != nil {
+		return nil, 0, err
+	}
+	total, err = s.repo.CountHistory(id, params.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	events, err = s.repo.History(id, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
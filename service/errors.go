@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+// ErrNotFound is returned when the product doesn't exist.
+var ErrNotFound = repository.ErrNotFound
+
+// ErrOrderNotFound is returned when the order doesn't exist. It is an
+// ErrNotFound.
+var ErrOrderNotFound = fmt.Errorf("order %w", ErrNotFound)
+
+// ErrNoPrice is returned when a product had no price on record at the
+// time asked for.
+var ErrNoPrice = repository.ErrNoPrice
+
+// DuplicateError is a write that would repeat a value another product
+// already has in a unique field.
+type DuplicateError = repository.DuplicateError
+
+// Error is a rule the input broke. Code is stable and machine-readable;
+// Args fill in the message.
+type Error struct {
+	Code string
+	Args []any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %v", e.Code, e.Args)
+}
+
+// ConflictError is a write that is valid on its own but conflicts with
+// the stored state, such as a broken invariant.
+type ConflictError struct {
+	Err *Error
+}
+
+func (e *ConflictError) Error() string { return e.Err.Error() }
+
+func (e *ConflictError) Unwrap() error { return e.Err }
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+// Invariant checks a product about to be saved against the rest of the
+// stored data. It runs inside the write transaction, so anything it reads
+// through repo is consistent with what will be committed. A broken
+// invariant is reported as a *ConflictError.
+type Invariant func(repo repository.ProductRepository, product *model.Product) error
+
+var productInvariants []Invariant
+
+// RegisterInvariant adds a check that every product update must pass
+// before commit.
+func RegisterInvariant(fn Invariant) {
+	productInvariants = append(productInvariants, fn)
+}
+
+// checkInvariants runs the registered invariants in registration order and
+// returns the first failure.
+func checkInvariants(repo repository.ProductRepository, product *model.Product) error {
+	for _, fn := range productInvariants {
+		if err := fn(repo, product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	// Stock that is already reserved for orders cannot be taken away
+	RegisterInvariant(func(repo repository.ProductRepository, product *model.Product) error {
+		if product.Quantity < product.Reserved {
+			return &ConflictError{Err: &Error{Code: "below_reserved_stock", Args: []any{product.Quantity, product.Reserved}}}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/testutil"
+)
+
+func TestQuantityBelowReservedStock(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := repository.WithTenant(context.Background(), model.DefaultTenantID)
+	s := NewProductService(repository.NewProductRepository(db)).WithContext(ctx)
+
+	product := &model.Product{Name: "Hammer", Price: money.FromFloat(9.99), Quantity: 10}
+	if err := s.Create(product); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reserve(product.ID, 4, "order 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	belowReserved := func(err error) bool {
+		var conflict *ConflictError
+		return errors.As(err, &conflict) && conflict.Err.Code == "below_reserved_stock"
+	}
+	quantity := 3
+	if _, err := s.Patch(product.ID, 0, &ProductPatch{Quantity: &quantity}); !belowReserved(err) {
+		t.Errorf("patching quantity to 3 of 4 reserved: %v, want below_reserved_stock", err)
+	}
+	input := *product
+	input.Quantity = 3
+	if _, err := s.Update(product.ID, 0, &input); !belowReserved(err) {
+		t.Errorf("updating quantity to 3 of 4 reserved: %v, want below_reserved_stock", err)
+	}
+
+	stored, err := s.Get(product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Quantity != 10 || stored.Reserved != 4 {
+		t.Errorf("stored quantity %d, reserved %d; want 10 and 4 unchanged", stored.Quantity, stored.Reserved)
+	}
+	quantity = 4
+	if _, err := s.Patch(product.ID, 0, &ProductPatch{Quantity: &quantity}); err != nil {
+		t.Errorf("patching quantity to the reserved 4: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxOrderItems bounds the items of an order, and so how many products
+// placing it locks.
+const maxOrderItems = 100
+
+// OrderService places orders and moves them through their statuses,
+// taking their items out of stock and putting them back. Every stock
+// movement is entered in its product's stock ledger and audit log,
+// attributed to the service's actor.
+type OrderService struct {
+	repo  repository.OrderRepository
+	actor string
+	ctx   context.Context
+}
+
+func NewOrderService(repo repository.OrderRepository) *OrderService {
+	return &OrderService{repo: repo, ctx: context.Background()}
+}
+
+// As returns a copy of s whose writes are attributed to actor.
+func (s *OrderService) As(actor string) *OrderService {
+	return &OrderService{repo: s.repo, actor: actor, ctx: s.ctx}
+}
+
+// WithContext returns a copy of s whose operations run under ctx.
+func (s *OrderService) WithContext(ctx context.Context) *OrderService {
+	return &OrderService{repo: s.repo.WithContext(ctx), actor: s.actor, ctx: ctx}
+}
+
+func (s *OrderService) startSpan(op string) (*OrderService, trace.Span) {
+	ctx, span := tracer.Start(s.ctx, "OrderService."+op)
+	return &OrderService{repo: s.repo.WithContext(ctx), actor: s.actor, ctx: ctx}, span
+}
+
+// products returns the product service for the stock of orders in repo's
+// transaction.
+func (s *OrderService) products(repo repository.OrderRepository) *ProductService {
+	return &ProductService{repo: repo.Products(), actor: s.actor, ctx: s.ctx}
+}
+
+func (s *OrderService) Get(id uint) (order *model.Order, err error) {
+	s, span := s.startSpan("Get")
+	defer endSpan(span, &err)
+	order, err = s.repo.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// List returns the page of orders selected by params and the total number
+// of orders matching its filters.
+func (s *OrderService) List(params *query.Params) (orders []model.Order, total int64, err error) {
+	s, span := s.startSpan("List")
+	defer endSpan(span, &err)
+	total, err = s.repo.Count(params.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	orders, err = s.repo.List(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+// Place places a pending order for items, each of which names a product
+// and a quantity, and takes them out of stock. The stock of each product
+// that isn't reserved must cover its item. Items get their product's name
+// and price, which must all be in one currency. The whole order is placed,
+// or nothing is.
+func (s *OrderService) Place(items []model.OrderItem) (order *model.Order, err error) {
+	s, span := s.startSpan("Place")
+	defer endSpan(span, &err)
+	if err := validateOrderItems(items); err != nil {
+		return nil, err
+	}
+	order = &model.Order{Status: model.OrderPending, CreatedBy: s.actor, Items: items}
+	err = s.repo.Transaction(func(repo repository.OrderRepository) error {
+		// Locked in ID order, so that concurrent orders can't deadlock
+		byProduct := orderedItems(order.Items)
+		var v validator
+		for _, i := range byProduct {
+			item := &order.Items[i]
+			product, err := repo.Products().Lock(item.ProductID)
+			if errors.Is(err, ErrNotFound) || (err == nil && product.DeletedAt.Valid) {
+				v.check(false, fmt.Sprintf("items[%d].product_id", i), "unknown_product", item.ProductID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if order.Currency == "" {
+				order.Currency = product.Currency
+			}
+			v.check(product.Currency == order.Currency, fmt.Sprintf("items[%d].product_id", i), "currency_mismatch", product.Currency, order.Currency)
+			if available := product.Quantity - product.Reserved; item.Quantity > available {
+				return &ConflictError{Err: &Error{Code: "order_insufficient_stock", Args: []any{item.ProductID, available}}}
+			}
+			item.Name, item.UnitPrice = product.Name, product.Price
+			order.Total += item.UnitPrice * money.Amount(item.Quantity)
+		}
+		if err := v.err(); err != nil {
+			return err
+		}
+		// Created first, so that the stock ledger can name it
+		if err := repo.Create(order); err != nil {
+			return err
+		}
+		products := s.products(repo)
+		reason := fmt.Sprintf("order %d", order.ID)
+		for _, i := range byProduct {
+			item := order.Items[i]
+			_, err := products.moveStock(item.ProductID, model.MovementAdjust, -item.Quantity, reason, func(product *model.Product) error {
+				if available := product.Quantity - product.Reserved; item.Quantity > available {
+					return &ConflictError{Err: &Error{Code: "order_insufficient_stock", Args: []any{item.ProductID, available}}}
+				}
+				product.Quantity -= item.Quantity
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Pay marks pending order id paid and returns it.
+func (s *OrderService) Pay(id uint) (order *model.Order, err error) {
+	s, span := s.startSpan("Pay")
+	defer endSpan(span, &err)
+	return s.transition(id, model.OrderPaid, nil)
+}
+
+// Cancel cancels order id, pending or paid, puts its items back in stock,
+// and returns it. Items of products deleted since are put back too, in
+// case the products are restored.
+func (s *OrderService) Cancel(id uint) (order *model.Order, err error) {
+	s, span := s.startSpan("Cancel")
+	defer endSpan(span, &err)
+	return s.transition(id, model.OrderCancelled, func(repo repository.OrderRepository, order *model.Order) error {
+		products := s.products(repo)
+		reason := fmt.Sprintf("order %d cancelled", order.ID)
+		for _, i := range orderedItems(order.Items) {
+			item := order.Items[i]
+			_, err := products.moveStock(item.ProductID, model.MovementAdjust, item.Quantity, reason, func(product *model.Product) error {
+				product.Quantity += item.Quantity
+				return nil
+			})
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// orderTransitions are the statuses each status can change to.
+var orderTransitions = map[string][]string{
+	model.OrderPending: {model.OrderPaid, model.OrderCancelled},
+	model.OrderPaid:    {model.OrderCancelled},
+}
+
+// transition locks order id, checks it can change to status, runs apply,
+// if any, and saves the status, all in one transaction.
+func (s *OrderService) transition(id uint, status string, apply func(repo repository.OrderRepository, order *model.Order) error) (*model.Order, error) {
+	var order *model.Order
+	err := s.repo.Transaction(func(repo repository.OrderRepository) error {
+		var err error
+		order, err = repo.Lock(id)
+		if errors.Is(err, ErrNotFound) {
+			return ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(orderTransitions[order.Status], status) {
+			return &ConflictError{Err: &Error{Code: "invalid_order_transition", Args: []any{order.Status, status}}}
+		}
+		if apply != nil {
+			if err := apply(repo, order); err != nil {
+				return err
+			}
+		}
+		order.Status = status
+		return repo.SetStatus(order, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// validateOrderItems checks that items are a list of distinct products
+// and positive quantities, of reasonable length.
+func validateOrderItems(items []model.OrderItem) error {
+	var v validator
+	v.check(len(items) > 0, "items", "required_field")
+	v.check(len(items) <= maxOrderItems, "items", "too_many_order_items", maxOrderItems)
+	seen := make(map[uint]bool, len(items))
+	for i, item := range items {
+		field := fmt.Sprintf("items[%d].", i)
+		v.check(item.ProductID != 0, field+"product_id", "required_field")
+		v.check(item.ProductID == 0 || !seen[item.ProductID], field+"product_id", "duplicate_order_item", item.ProductID)
+		v.check(item.Quantity > 0, field+"quantity", "not_positive")
+		seen[item.ProductID] = true
+	}
+	return v.err()
+}
+
+// orderedItems returns the indexes of items by product ID.
+func orderedItems(items []model.OrderItem) []int {
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortFunc(indexes, func(a, b int) int {
+		return cmp.Compare(items[a].ProductID, items[b].ProductID)
+	})
+	return indexes
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+)
+
+// Nullable is a patch field that can be left out, set to a value, or set to
+// null. Set is false when the field was absent.
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	n.Value = &v
+	return nil
+}
+
+// ProductPatch is a partial product update. Nil fields are left unchanged.
+// The nullable fields can also be cleared with null.
+type ProductPatch struct {
+	Name       *string          `json:"name"`
+	Price      *money.Amount    `json:"price"`
+	Currency   *string          `json:"currency"`
+	Quantity   *int             `json:"quantity"`
+	MinStock   *int             `json:"min_stock"`
+	MaxStock   *int             `json:"max_stock"`
+	CategoryID Nullable[uint]   `json:"category_id"`
+	SupplierID Nullable[uint]   `json:"supplier_id"`
+	SKU        Nullable[string] `json:"sku"`
+
+	// Version, if not 0, is the version of the product the patch is based
+	// on. It is not a field to change.
+	Version uint `json:"version"`
+}
+
+// apply copies the fields present in the patch onto p.
+func (patch *ProductPatch) apply(p *model.Product) {
+	if patch.Name != nil {
+		p.Name = *patch.Name
+	}
+	if patch.Price != nil {
+		p.Price = *patch.Price
+	}
+	if patch.Currency != nil {
+		p.Currency = *patch.Currency
+	}
+	if patch.Quantity != nil {
+		p.Quantity = *patch.Quantity
+	}
+	if patch.MinStock != nil {
+		p.MinStock = *patch.MinStock
+	}
+	if patch.MaxStock != nil {
+		p.MaxStock = *patch.MaxStock
+	}
+	if patch.CategoryID.Set {
+		p.CategoryID = patch.CategoryID.Value
+	}
+	if patch.SupplierID.Set {
+		p.SupplierID = patch.SupplierID.Value
+	}
+	if patch.SKU.Set {
+		p.SKU = patch.SKU.Value
+	}
+}
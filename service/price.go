@@ -0,0 +1,35 @@
+package service
+
+import (
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+// Prices returns the page of product id's price history selected by
+// params, oldest first by default, and the total matching its filters.
+func (s *ProductService) Prices(id uint, params *query.Params) (changes []model.PriceChange, total int64, err error) {
+	s, span := s.startSpan("Prices")
+	defer endSpan(span, &err)
+	if _, err := s.repo.Get(id); err != nil {
+		return nil, 0, err
+	}
+	total, err = s.repo.CountPrices(id, params.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	changes, err = s.repo.Prices(id, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return changes, total, nil
+}
+
+// PriceAt returns the price and currency product id had at t, or
+// ErrNoPrice if it had none on record then.
+func (s *ProductService) PriceAt(id uint, t time.Time) (change *model.PriceChange, err error) {
+	s, span := s.startSpan("PriceAt")
+	defer endSpan(span, &err)
+	return s.repo.PriceAt(id, t)
+}
@@ -0,0 +1,328 @@
+// Package service holds the business rules for products, independent of
+// HTTP and of how products are stored.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+// ProductService implements the product operations on top of a
+// repository. Every write is recorded in the audit log, attributed to its
+// actor. Every operation is traced as a span of its context.
+type ProductService struct {
+	repo  repository.ProductRepository
+	actor string
+	ctx   context.Context
+}
+
+func NewProductService(repo repository.ProductRepository) *ProductService {
+	return &ProductService{repo: repo, ctx: context.Background()}
+}
+
+// As returns a copy of s whose writes are attributed to actor.
+func (s *ProductService) As(actor string) *ProductService {
+	return &ProductService{repo: s.repo, actor: actor, ctx: s.ctx}
+}
+
+// WithContext returns a copy of s whose operations run under ctx, such as
+// the context of the request they serve.
+func (s *ProductService) WithContext(ctx context.Context) *ProductService {
+	return &ProductService{repo: s.repo.WithContext(ctx), actor: s.actor, ctx: ctx}
+}
+
+func (s *ProductService) Get(id uint) (product *model.Product, err error) {
+	s, span := s.startSpan("Get")
+	defer endSpan(span, &err)
+	return s.repo.Get(id)
+}
+
+// List returns the page of products selected by params and the total
+// number of products matching its filters.
+func (s *ProductService) List(params *query.Params) (products []model.Product, total int64, err error) {
+	s, span := s.startSpan("List")
+	defer endSpan(span, &err)
+	total, err = s.repo.Count(params.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	products, err = s.repo.List(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+// maxSearchTerms bounds the words of a search, and so its cost.
+const maxSearchTerms = 8
+
+// Search returns up to limit products matching filters whose names contain
+// every word of text, best matches first. Words are runs of letters and
+// digits, matched case-insensitively; the last may be incomplete.
+func (s *ProductService) Search(text string, filters query.Filters, limit int) (products []model.Product, err error) {
+	s, span := s.startSpan("Search")
+	defer endSpan(span, &err)
+	terms := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) == 0 {
+		return nil, &Error{Code: "empty_search"}
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, &Error{Code: "too_many_search_terms", Args: []any{maxSearchTerms}}
+	}
+	return s.repo.Search(terms, filters, limit)
+}
+
+// Create validates and stores a new product.
+func (s *ProductService) Create(product *model.Product) (err error) {
+	s, span := s.startSpan("Create")
+	defer endSpan(span, &err)
+	product.Version = 0
+	product.Category, product.Supplier, product.Images = nil, nil, nil
+	SetDefaults(product)
+	if err := Validate(product); err != nil {
+		return err
+	}
+	if err := newReferences(s.repo).check(product); err != nil {
+		return err
+	}
+	return s.repo.Transaction(func(repo repository.ProductRepository) error {
+		if err := repo.Create(product); err != nil {
+			return err
+		}
+		return s.record(repo, model.AuditCreate, nil, product)
+	})
+}
+
+// CreateMany validates and stores products in one transaction. If atomic,
+// nothing is stored unless every product is valid, and err is a
+// ValidationError naming the fields like "[3].price". Otherwise the valid
+// products are stored and errs[i] is the validation error of product i, or
+// nil if it was created.
+func (s *ProductService) CreateMany(products []model.Product, atomic bool) (errs []error, err error) {
+	s, span := s.startSpan("CreateMany")
+	defer endSpan(span, &err)
+	errs = make([]error, len(products))
+	var invalid ValidationError
+	var valid []*model.Product
+	refs := newReferences(s.repo)
+	for i := range products {
+		products[i].ID = 0
+		products[i].Version = 0
+		products[i].Category, products[i].Supplier, products[i].Images = nil, nil, nil
+		SetDefaults(&products[i])
+		errs[i] = Validate(&products[i])
+		if errs[i] == nil {
+			errs[i] = refs.check(&products[i])
+		}
+		var verr *ValidationError
+		if errs[i] != nil && !errors.As(errs[i], &verr) {
+			return nil, errs[i]
+		}
+		if verr != nil {
+			invalid.Fields = append(invalid.Fields, verr.Prefix(fmt.Sprintf("[%d].", i)).Fields...)
+			continue
+		}
+		valid = append(valid, &products[i])
+	}
+	if atomic && len(invalid.Fields) > 0 {
+		return errs, &invalid
+	}
+	if err := s.repo.Transaction(func(repo repository.ProductRepository) error {
+		if err := repo.CreateMany(valid); err != nil {
+			return err
+		}
+		events := make([]*model.AuditEvent, len(valid))
+		for i, product := range valid {
+			events[i] = NewAuditEvent(model.AuditCreate, s.actor, nil, product)
+		}
+		return repo.Record(events)
+	}); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// Update replaces the editable fields of product id with those of input
+// and returns the stored product. An empty currency keeps the current one.
+// If version is not 0 the product must still be at that version.
+func (s *ProductService) Update(id, version uint, input *model.Product) (product *model.Product, err error) {
+	s, span := s.startSpan("Update")
+	defer endSpan(span, &err)
+	return s.modify(id, version, func(product *model.Product) {
+		product.Name = input.Name
+		product.Price = input.Price
+		if input.Currency != "" {
+			product.Currency = input.Currency
+		}
+		product.Quantity = input.Quantity
+		product.MinStock = input.MinStock
+		product.MaxStock = input.MaxStock
+		product.CategoryID = input.CategoryID
+		product.SupplierID = input.SupplierID
+		product.SKU = input.SKU
+	})
+}
+
+// Patch changes only the fields present in patch and returns the stored
+// product. If version is not 0 the product must still be at that version.
+func (s *ProductService) Patch(id, version uint, patch *ProductPatch) (product *model.Product, err error) {
+	s, span := s.startSpan("Patch")
+	defer endSpan(span, &err)
+	return s.modify(id, version, patch.apply)
+}
+
+// modify loads product id, changes it with change, and saves it if the
+// result is valid and passes the invariants, all in one transaction. The
+// save fails with a version conflict if another write got in first. A
+// change of quantity is entered in the stock ledger.
+func (s *ProductService) modify(id, version uint, change func(product *model.Product)) (*model.Product, error) {
+	var product *model.Product
+	err := s.repo.Transaction(func(repo repository.ProductRepository) error {
+		var err error
+		product, err = repo.Get(id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && product.Version != version {
+			return versionConflict(product.Version)
+		}
+		before := *product
+		change(product)
+		if err := Validate(product); err != nil {
+			return err
+		}
+		if err := newReferences(repo).check(product); err != nil {
+			return err
+		}
+		if err := checkInvariants(repo, product); err != nil {
+			return err
+		}
+		err = repo.Save(product)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return versionConflict(0)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.record(repo, model.AuditUpdate, &before, product); err != nil {
+			return err
+		}
+		// Keep the stock ledger complete
+		if delta := product.Quantity - before.Quantity; delta != 0 {
+			return repo.RecordMovement(&model.StockMovement{
+				ProductID: id,
+				Kind:      model.MovementAdjust,
+				Delta:     delta,
+				Reason:    updateReason,
+				Actor:     s.actor,
+				Quantity:  product.Quantity,
+				Reserved:  product.Reserved,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Delete deletes product id. If version is not 0 the product must still be
+// at that version.
+func (s *ProductService) Delete(id, version uint) (err error) {
+	s, span := s.startSpan("Delete")
+	defer endSpan(span, &err)
+	return s.repo.Transaction(func(repo repository.ProductRepository) error {
+		return s.delete(repo, id, version)
+	})
+}
+
+// delete deletes product id within repo's transaction and records it. If
+// version is not 0 the product is locked, so that an update can't get in
+// between the check and the delete.
+func (s *ProductService) delete(repo repository.ProductRepository, id, version uint) error {
+	get := repo.Get
+	if version != 0 {
+		get = repo.Lock
+	}
+	product, err := get(id)
+	if err != nil {
+		return err
+	}
+	if version != 0 && product.Version != version {
+		return versionConflict(product.Version)
+	}
+	if err := repo.Delete(id); err != nil {
+		return err
+	}
+	return s.record(repo, model.AuditDelete, product, nil)
+}
+
+// Restore undeletes product id and returns it. Restoring a product that
+// isn't deleted does nothing.
+func (s *ProductService) Restore(id uint) (product *model.Product, err error) {
+	s, span := s.startSpan("Restore")
+	defer endSpan(span, &err)
+	err = s.repo.Transaction(func(repo repository.ProductRepository) error {
+		restored := repo.Restore(id)
+		if restored != nil && !errors.Is(restored, ErrNotFound) {
+			return restored
+		}
+		var err error
+		if product, err = repo.Get(id); err != nil || restored != nil {
+			return err
+		}
+		return s.record(repo, model.AuditRestore, nil, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// DeleteMany deletes the products with the given ids in one transaction.
+// errs[i] is ErrNotFound if ids[i] didn't exist. If atomic, a missing id
+// rolls back every delete and err is ErrNotFound too.
+func (s *ProductService) DeleteMany(ids []uint, atomic bool) (errs []error, err error) {
+	s, span := s.startSpan("DeleteMany")
+	defer endSpan(span, &err)
+	errs = make([]error, len(ids))
+	err = s.repo.Transaction(func(repo repository.ProductRepository) error {
+		for i, id := range ids {
+			err := s.delete(repo, id, 0)
+			if errors.Is(err, ErrNotFound) {
+				errs[i] = err
+				if atomic {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	return errs, err
+}
+
+// versionConflict reports a write based on a stale version of a product,
+// with the current version if known.
+func versionConflict(current uint) error {
+	if current == 0 {
+		return &ConflictError{Err: &Error{Code: "version_conflict"}}
+	}
+	return &ConflictError{Err: &Error{Code: "stale_version", Args: []any{current}}}
+}
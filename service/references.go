@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+// references checks that products refer to existing categories and
+// suppliers. It remembers the answers, so checking many products that
+// share them costs one query each.
+type references struct {
+	repo       repository.ProductRepository
+	categories map[uint]bool
+	suppliers  map[uint]bool
+}
+
+func newReferences(repo repository.ProductRepository) *references {
+	return &references{repo: repo, categories: map[uint]bool{}, suppliers: map[uint]bool{}}
+}
+
+// check returns a *ValidationError naming the references of p that don't
+// exist, or the error of looking them up.
+func (c *references) check(p *model.Product) error {
+	var v validator
+	for _, ref := range []struct {
+		id     *uint
+		known  map[uint]bool
+		exists func(uint) (bool, error)
+		field  string
+		code   string
+	}{
+		{p.CategoryID, c.categories, c.repo.CategoryExists, "category_id", "unknown_category"},
+		{p.SupplierID, c.suppliers, c.repo.SupplierExists, "supplier_id", "unknown_supplier"},
+	} {
+		if ref.id == nil {
+			continue
+		}
+		ok, seen := ref.known[*ref.id]
+		if !seen {
+			var err error
+			if ok, err = ref.exists(*ref.id); err != nil {
+				return err
+			}
+			ref.known[*ref.id] = ok
+		}
+		v.check(ok, ref.field, ref.code, *ref.id)
+	}
+	return v.err()
+}
+
+// ReferenceChecker returns a check that products refer to existing
+// categories and suppliers of repo's tenant, as Create and CreateMany
+// make. It returns a *ValidationError naming category_id or supplier_id,
+// or the error of looking them up.
+func ReferenceChecker(repo repository.ProductRepository) func(*model.Product) error {
+	return newReferences(repo).check
+}
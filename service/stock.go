@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+// maxReasonLength bounds the reason of a stock movement, as its column
+// does.
+const maxReasonLength = 255
+
+// updateReason is the reason in the stock ledger of a quantity set by a
+// product update rather than adjusted.
+const updateReason = "product update"
+
+// AdjustStock changes product id's quantity by delta, such as for a
+// delivery or a stocktake, and returns the product. Stock that is
+// reserved can't be taken away.
+func (s *ProductService) AdjustStock(id uint, delta int, reason string) (product *model.Product, err error) {
+	s, span := s.startSpan("AdjustStock")
+	defer endSpan(span, &err)
+	var v validator
+	v.check(delta != 0, "delta", "zero_value")
+	v.check(strings.TrimSpace(reason) != "", "reason", "required_field")
+	v.check(utf8.RuneCountInString(reason) <= maxReasonLength, "reason", "too_long", maxReasonLength)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return s.moveStock(id, model.MovementAdjust, delta, reason, func(product *model.Product) error {
+		if available := product.Quantity - product.Reserved; -delta > available {
+			return &ConflictError{Err: &Error{Code: "insufficient_stock", Args: []any{available}}}
+		}
+		product.Quantity += delta
+		return nil
+	})
+}
+
+// Reserve sets aside quantity of product id's stock, such as for an order,
+// and returns the product. Only stock that isn't reserved yet can be.
+func (s *ProductService) Reserve(id uint, quantity int, reason string) (product *model.Product, err error) {
+	s, span := s.startSpan("Reserve")
+	defer endSpan(span, &err)
+	if err := validateMovement(quantity, reason); err != nil {
+		return nil, err
+	}
+	return s.moveStock(id, model.MovementReserve, quantity, reason, func(product *model.Product) error {
+		if available := product.Quantity - product.Reserved; quantity > available {
+			return &ConflictError{Err: &Error{Code: "insufficient_stock", Args: []any{available}}}
+		}
+		product.Reserved += quantity
+		return nil
+	})
+}
+
+// Release returns quantity of product id's reserved stock, such as for a
+// cancelled order, and returns the product.
+func (s *ProductService) Release(id uint, quantity int, reason string) (product *model.Product, err error) {
+	s, span := s.startSpan("Release")
+	defer endSpan(span, &err)
+	if err := validateMovement(quantity, reason); err != nil {
+		return nil, err
+	}
+	return s.moveStock(id, model.MovementRelease, -quantity, reason, func(product *model.Product) error {
+		if quantity > product.Reserved {
+			return &ConflictError{Err: &Error{Code: "insufficient_reserved_stock", Args: []any{product.Reserved}}}
+		}
+		product.Reserved -= quantity
+		return nil
+	})
+}
+
+// StockMovements returns the page of product id's stock ledger selected
+// by params, oldest first by default, and the total matching its filters.
+func (s *ProductService) StockMovements(id uint, params *query.Params) (movements []model.StockMovement, total int64, err error) {
+	s, span := s.startSpan("StockMovements")
+	defer endSpan(span, &err)
+	if _, err := s.repo.Get(id); err != nil {
+		return nil, 0, err
+	}
+	total, err = s.repo.CountMovements(id, params.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	movements, err = s.repo.Movements(id, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	return movements, total, nil
+}
+
+// validateMovement checks the quantity and optional reason of a
+// reservation or release.
+func validateMovement(quantity int, reason string) error {
+	var v validator
+	v.check(quantity > 0, "quantity", "not_positive")
+	v.check(utf8.RuneCountInString(reason) <= maxReasonLength, "reason", "too_long", maxReasonLength)
+	return v.err()
+}
+
+// moveStock locks product id, changes its stock with change, and saves it
+// with an entry in the stock ledger and the audit log, all in one
+// transaction. Movements of the same product wait for each other's lock,
+// so each sees the stock the last one left and none can oversell.
+func (s *ProductService) moveStock(id uint, kind string, delta int, reason string, change func(product *model.Product) error) (*model.Product, error) {
+	var product *model.Product
+	err := s.repo.Transaction(func(repo repository.ProductRepository) error {
+		var err error
+		product, err = repo.Lock(id)
+		if err != nil {
+			return err
+		}
+		before := *product
+		if err := change(product); err != nil {
+			return err
+		}
+		if err := checkInvariants(repo, product); err != nil {
+			return err
+		}
+		err = repo.Save(product)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return versionConflict(0)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.record(repo, model.AuditUpdate, &before, product); err != nil {
+			return err
+		}
+		return repo.RecordMovement(&model.StockMovement{
+			ProductID: id,
+			Kind:      kind,
+			Delta:     delta,
+			Reason:    strings.TrimSpace(reason),
+			Actor:     s.actor,
+			Quantity:  product.Quantity,
+			Reserved:  product.Reserved,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/mjpvl-ai/golangdb/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/mjpvl-ai/golangdb/service")
+
+// startSpan starts a span for operation op, as a child of s's context, and
+// returns a copy of s whose statements are children of the span.
+func (s *ProductService) startSpan(op string) (*ProductService, trace.Span) {
+	ctx, span := tracer.Start(s.ctx, "ProductService."+op)
+	return &ProductService{repo: s.repo.WithContext(ctx), actor: s.actor, ctx: ctx}, span
+}
+
+// endSpan ends span, marking it failed if *err is. Rules the input broke
+// and missing products are the caller's problem, not failures, so they
+// are only recorded.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		var verr *ValidationError
+		var rerr *Error
+		if !errors.As(*err, &verr) && !errors.As(*err, &rerr) && !errors.Is(*err, repository.ErrNotFound) {
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+	}
+	span.End()
+}
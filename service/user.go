@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"sync"
+
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+)
+
+const minPasswordLength = 8
+
+var (
+	// ErrEmailTaken is returned when registering an email that already
+	// has an account.
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrInvalidCredentials is returned when the email or password is
+	// wrong. Which one is not revealed.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("not a real password")
+	return hash
+})
+
+// UserService registers and authenticates users.
+type UserService struct {
+	repo repository.UserRepository
+}
+
+func NewUserService(repo repository.UserRepository) *UserService {
+	return &UserService{repo: repo}
+}
+
+// Register creates a viewer account in tenant, or of the whole service if
+// tenant is nil. The first account ever registered is made an admin of the
+// whole service so a fresh install can be administered.
+func (s *UserService) Register(email, password string, tenant *uint) (*model.User, error) {
+	return s.create(email, password, model.RoleViewer, tenant, true)
+}
+
+// CreateAdmin creates an admin account in tenant, such as the first one
+// of a new tenant.
+func (s *UserService) CreateAdmin(email, password string, tenant uint) (*model.User, error) {
+	return s.create(email, password, model.RoleAdmin, &tenant, false)
+}
+
+// create creates an account with role in tenant. If firstIsAdmin and there
+// are no accounts yet, it is made an admin of the whole service instead.
+func (s *UserService) create(email, password, role string, tenant *uint, firstIsAdmin bool) (*model.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, &Error{Code: "invalid_email"}
+	}
+	if len(password) < minPasswordLength {
+		return nil, &Error{Code: "weak_password", Args: []any{minPasswordLength}}
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &model.User{Email: email, PasswordHash: hash, Role: role, TenantID: tenant}
+	err = s.repo.Transaction(func(repo repository.UserRepository) error {
+		if _, err := repo.GetByEmail(email); err == nil {
+			return ErrEmailTaken
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if firstIsAdmin {
+			count, err := repo.Count()
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				user.Role, user.TenantID = model.RoleAdmin, nil
+			}
+		}
+		return repo.Create(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authenticate returns the user with the given email and password.
+func (s *UserService) Authenticate(email, password string) (*model.User, error) {
+	user, err := s.repo.GetByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if errors.Is(err, repository.ErrNotFound) {
+		// Spend the same time as a wrong password so response timing
+		// doesn't reveal which emails are registered
+		auth.CheckPassword(dummyHash(), password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !auth.CheckPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (s *UserService) Get(id uint) (*model.User, error) {
+	return s.repo.Get(id)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/money"
+	"golang.org/x/text/currency"
+)
+
+const maxNameLength = 255
+
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidSKU reports whether sku is a well-formed SKU.
+func ValidSKU(sku string) bool {
+	return skuPattern.MatchString(sku)
+}
+
+// FieldError is one invalid field. Field is the JSON name; Code and Args
+// describe the problem like Error's.
+type FieldError struct {
+	Field string
+	Code  string
+	Args  []any
+}
+
+// ValidationError lists every invalid field of an input, so a client can
+// fix them all at once.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = fmt.Sprintf("%s: %s %v", f.Field, f.Code, f.Args)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Prefix returns e with prefix added to every field name, for reporting
+// errors of an element of a list.
+func (e *ValidationError) Prefix(prefix string) *ValidationError {
+	fields := make([]FieldError, len(e.Fields))
+	for i, f := range e.Fields {
+		f.Field = prefix + f.Field
+		fields[i] = f
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// validator collects field errors.
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) check(ok bool, field, code string, args ...any) {
+	if !ok {
+		v.fields = append(v.fields, FieldError{Field: field, Code: code, Args: args})
+	}
+}
+
+// err returns the collected errors, or nil if there are none.
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// SetDefaults fills in the fields a new product may leave out.
+func SetDefaults(p *model.Product) {
+	if p.Currency == "" {
+		p.Currency = model.DefaultCurrency
+	}
+}
+
+// Validate checks a product's fields before it is written. It returns a
+// *ValidationError listing every invalid field.
+func Validate(p *model.Product) error {
+	var v validator
+	name := strings.TrimSpace(p.Name)
+	v.check(name != "", "name", "required_field")
+	v.check(utf8.RuneCountInString(p.Name) <= maxNameLength, "name", "too_long", maxNameLength)
+	v.check(p.Price >= 0, "price", "negative_value")
+	if unit, err := currency.ParseISO(p.Currency); err != nil || unit.String() != p.Currency {
+		v.check(false, "currency", "invalid_currency")
+	} else if scale, _ := currency.Standard.Rounding(unit); scale < money.Scale {
+		// Amounts are in hundredths, finer than e.g. yen can be split
+		step := money.Amount(1)
+		for i := scale; i < money.Scale; i++ {
+			step *= 10
+		}
+		v.check(p.Price%step == 0, "price", "too_precise", scale)
+	}
+	v.check(p.Quantity >= 0, "quantity", "negative_value")
+	v.check(p.MinStock >= 0, "min_stock", "negative_value")
+	v.check(p.MaxStock >= 0, "max_stock", "negative_value")
+	v.check(p.MaxStock == 0 || p.MinStock <= p.MaxStock, "min_stock", "min_stock_exceeds_max")
+	if p.SKU != nil {
+		v.check(ValidSKU(*p.SKU), "sku", "invalid_sku", *p.SKU)
+	}
+	return v.err()
+}
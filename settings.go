@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/mjpvl-ai/golangdb/config"
+)
+
+// featureFlags are the features the flags section of the configuration
+// turns on or off, with their defaults.
+var featureFlags = map[string]bool{
+	"batch":   true, // POST /api/v1/batch
+	"graphql": true, // /api/v1/graphql
+}
+
+// validateFlags rejects flags for features the server doesn't have, which
+// are most likely misspelt.
+func validateFlags(flags map[string]bool) error {
+	var unknown []string
+	for name := range flags {
+		if _, ok := featureFlags[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	known := make([]string, 0, len(featureFlags))
+	for name := range featureFlags {
+		known = append(known, name)
+	}
+	slices.Sort(unknown)
+	slices.Sort(known)
+	return fmt.Errorf("unknown feature flags %s; the known ones are %s", strings.Join(unknown, ", "), strings.Join(known, ", "))
+}
+
+// liveConfig is the configuration as last reloaded. Of it, the feature
+// flags, the rate limits and the cache TTL apply while the server runs;
+// everything else keeps its value from startup until a restart.
+type liveConfig struct {
+	// load rereads the configuration from its sources.
+	load func() (config.Config, error)
+	mu   sync.Mutex // serializes reloads
+	cfg  atomic.Pointer[config.Config]
+}
+
+func newLiveConfig(load func() (config.Config, error)) *liveConfig {
+	return &liveConfig{load: load}
+}
+
+// settings returns the configuration in effect: as last reloaded, or as
+// at startup.
+func (d *deps) settings() *config.Config {
+	if d.live != nil {
+		if cfg := d.live.cfg.Load(); cfg != nil {
+			return cfg
+		}
+	}
+	return &d.cfg
+}
+
+// featureEnabled reports whether the feature with name is on.
+func (d *deps) featureEnabled(name string) bool {
+	if enabled, ok := d.settings().Flags[name]; ok {
+		return enabled
+	}
+	return featureFlags[name]
+}
+
+// requireFeature serves next only while the feature with name is on, and
+// answers as for an unknown route otherwise.
+func requireFeature(name string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !depsFor(r).featureEnabled(name) {
+			routeNotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// reloadConfig rereads the configuration and applies what can change while
+// the server runs. Other changes are logged as needing a restart. An
+// invalid configuration is rejected whole, leaving the current one in
+// effect.
+func (d *deps) reloadConfig() (*config.Config, error) {
+	if d.live == nil {
+		return nil, errors.New("the configuration can't be reloaded")
+	}
+	d.live.mu.Lock()
+	defer d.live.mu.Unlock()
+	cfg, err := d.live.load()
+	if err == nil {
+		err = validateFlags(cfg.Flags)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// What a reload doesn't apply is still as at startup
+	if sections := d.restartNeeded(d.cfg, cfg); len(sections) > 0 {
+		slog.Warn("configuration changes need a restart to apply", "sections", sections)
+	}
+	if d.limiter != nil {
+		d.limiter.setLimits(cfg.RateLimit)
+	}
+	d.live.cfg.Store(&cfg)
+	slog.Info("configuration reloaded", "flags", cfg.Flags,
+		"reads_per_minute", cfg.RateLimit.ReadsPerMinute, "writes_per_minute", cfg.RateLimit.WritesPerMinute,
+		"cache_ttl", cfg.Cache.TTL)
+	return &cfg, nil
+}
+
+// restartNeeded returns the sections of the configuration, by their names
+// in a file, that differ between old and cfg in ways a reload doesn't
+// apply. The cache TTL only applies if the cache was on at startup.
+func (d *deps) restartNeeded(old, cfg config.Config) []string {
+	for _, c := range []*config.Config{&old, &cfg} {
+		c.Flags = nil
+		c.RateLimit.ReadsPerMinute, c.RateLimit.WritesPerMinute = 0, 0
+		if d.cache != nil {
+			c.Cache.TTL = 0
+		}
+	}
+	var sections []string
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(cfg)
+	for i := range oldValue.NumField() {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("json"), ",")
+			sections = append(sections, name)
+		}
+	}
+	return sections
+}
+
+// reloadOnHangup returns the start and stop of a component that reloads
+// the configuration whenever the process gets SIGHUP.
+func (d *deps) reloadOnHangup() (start, stop func(ctx context.Context) error) {
+	hangups := make(chan os.Signal, 1)
+	start = func(ctx context.Context) error {
+		signal.Notify(hangups, syscall.SIGHUP)
+		go func() {
+			for range hangups {
+				if _, err := d.reloadConfig(); err != nil {
+					slog.Error("failed to reload configuration", "error", err)
+				}
+			}
+		}()
+		return nil
+	}
+	stop = func(ctx context.Context) error {
+		signal.Stop(hangups)
+		close(hangups)
+		return nil
+	}
+	return start, stop
+}
+
+// settingsView is the body of GET /admin/settings: the settings a reload
+// changes, as in effect, with every feature flag.
+type settingsView struct {
+	Flags     map[string]bool `json:"flags"`
+	RateLimit struct {
+		ReadsPerMinute  int `json:"reads_per_minute"`
+		WritesPerMinute int `json:"writes_per_minute"`
+	} `json:"rate_limit"`
+	CacheTTL config.Duration `json:"cache_ttl"`
+}
+
+func (d *deps) settingsView() settingsView {
+	cfg := d.settings()
+	var v settingsView
+	v.Flags = map[string]bool{}
+	for name := range featureFlags {
+		v.Flags[name] = d.featureEnabled(name)
+	}
+	v.RateLimit.ReadsPerMinute = cfg.RateLimit.ReadsPerMinute
+	v.RateLimit.WritesPerMinute = cfg.RateLimit.WritesPerMinute
+	v.CacheTTL = cfg.Cache.TTL
+	return v
+}
+
+// Get the settings that can change without a restart
+func getSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, depsFor(r).settingsView())
+}
+
+// Reload the configuration, as SIGHUP does
+func reloadSettings(w http.ResponseWriter, r *http.Request) {
+	d := depsFor(r)
+	if _, err := d.reloadConfig(); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "config_reload_failed", err.Error())
+		return
+	}
+	writeJSON(w, r, http.StatusOK, d.settingsView())
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/service"
+)
+
+const maxSKULookup = 100
+
+// skuLookup is the response of GET /products?skus=. Missing lists the
+// requested SKUs that matched no product, in request order. Data is the
+// products, trimmed to the ?fields= asked for.
+type skuLookup struct {
+	Data    any      `json:"data"`
+	Missing []string `json:"missing"`
+}
+
+// Get the products for a comma-separated list of SKUs
+func getProductsBySKU(w http.ResponseWriter, r *http.Request) {
+	var skus []string
+	seen := map[string]bool{}
+	for _, sku := range strings.Split(r.URL.Query().Get("skus"), ",") {
+		sku = strings.TrimSpace(sku)
+		if sku == "" || seen[sku] {
+			continue
+		}
+		if !service.ValidSKU(sku) {
+			writeError(w, r, http.StatusBadRequest, "invalid_sku", sku)
+			return
+		}
+		seen[sku] = true
+		skus = append(skus, sku)
+	}
+	if len(skus) == 0 {
+		writeError(w, r, http.StatusBadRequest, "empty_skus")
+		return
+	}
+	if len(skus) > maxSKULookup {
+		writeError(w, r, http.StatusBadRequest, "too_many_skus", maxSKULookup)
+		return
+	}
+	fields, err := query.ParseFields[Product](r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	products := []Product{}
+	if err := readDBFor(r).Where("sku IN ?", skus).Order("id").Find(&products).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if err := localize(r, products); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	found := map[string]bool{}
+	for _, p := range products {
+		found[*p.SKU] = true
+	}
+	missing := []string{}
+	for _, sku := range skus {
+		if !found[sku] {
+			missing = append(missing, sku)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, skuLookup{Data: fields.Select(products), Missing: missing})
+}
+
+// Get a single product by SKU
+func getProductBySKU(w http.ResponseWriter, r *http.Request) {
+	sku := mux.Vars(r)["sku"]
+	if !service.ValidSKU(sku) {
+		writeError(w, r, http.StatusBadRequest, "invalid_sku", sku)
+		return
+	}
+	conn := readDBFor(r)
+	if includeDeleted(r) {
+		conn = conn.Unscoped()
+	}
+	var ids []uint
+	if err := conn.Model(&Product{}).Where("sku = ?", sku).Limit(1).Pluck("id", &ids).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if len(ids) == 0 {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	writeProduct(w, r, ids[0])
+}
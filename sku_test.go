@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProductsBySKU(t *testing.T) {
+	c, _ := newTestAPI(t)
+	for _, sku := range []string{"ABC-1", "ABC-2", "ABC-3"} {
+		c.Post("/api/v1/products", map[string]any{"name": sku, "price": "10", "sku": sku}).Expect(201)
+	}
+
+	var got struct {
+		Data    []Product `json:"data"`
+		Missing []string  `json:"missing"`
+	}
+	c.Get("/api/v1/products?skus=ABC-3,NOPE-1,ABC-1,ABC-1,NOPE-2").Expect(200).Decode(&got)
+	var skus []string
+	for _, p := range got.Data {
+		skus = append(skus, *p.SKU)
+	}
+	if !slices.Equal(skus, []string{"ABC-1", "ABC-3"}) {
+		t.Errorf("found %v, want [ABC-1 ABC-3]", skus)
+	}
+	if !slices.Equal(got.Missing, []string{"NOPE-1", "NOPE-2"}) {
+		t.Errorf("missing %v, want [NOPE-1 NOPE-2]", got.Missing)
+	}
+
+	expectProblem(t, c.Get("/api/v1/products?skus=ABC-1,bad%20sku"), 400, "invalid_sku")
+	expectProblem(t, c.Get("/api/v1/products?skus=,"), 400, "empty_skus")
+	many := make([]string, maxSKULookup+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("SKU-%d", i)
+	}
+	expectProblem(t, c.Get("/api/v1/products?skus="+strings.Join(many, ",")), 400, "too_many_skus")
+}
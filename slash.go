@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Trailing-slash modes for --trailing-slash.
+const (
+	trailingSlashMatch    = "match"
+	trailingSlashRedirect = "redirect"
+)
+
+// trailingSlash makes "/products/" behave like "/products". In match mode
+// the slash is dropped before routing; in redirect mode the client gets a
+// 308, which unlike mux's StrictSlash 301 keeps the method and body.
+// Leading slashes are collapsed too: this runs before mux cleans the path,
+// and a redirect to "//host" would send the client to another site.
+func trailingSlash(mode string, next http.Handler) (http.Handler, error) {
+	if mode != trailingSlashMatch && mode != trailingSlashRedirect {
+		return nil, fmt.Errorf("trailing-slash must be %q or %q", trailingSlashMatch, trailingSlashRedirect)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || !strings.HasSuffix(r.URL.Path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path := "/" + strings.Trim(r.URL.Path, "/")
+		if mode == trailingSlashRedirect {
+			u := *r.URL
+			u.Path = path
+			u.RawPath = ""
+			http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	}), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/testutil"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	_, db := newTestAPI(t)
+	admin, err := tokens.Issue(1, "admin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := func(mode string) *testutil.Client {
+		handler, err := trailingSlash(mode, newRouter(&deps{db: db, cfg: config.Default()}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return testutil.NewClient(t, handler).WithToken(admin.AccessToken)
+	}
+
+	c := client(trailingSlashMatch)
+	c.Post("/api/v1/products", map[string]any{"name": "Saw", "price": "12"}).Expect(201)
+	for _, path := range []string{"/api/v1/products", "/api/v1/products/"} {
+		var list struct {
+			Data []Product `json:"data"`
+		}
+		c.Get(path).Expect(200).Decode(&list)
+		if len(list.Data) != 1 {
+			t.Errorf("GET %s listed %d products, want 1", path, len(list.Data))
+		}
+	}
+	c.Get("/api/v1/products/1/").Expect(200)
+
+	r := client(trailingSlashRedirect).Get("/api/v1/products/?limit=5")
+	r.Expect(308)
+	if got := r.Header().Get("Location"); got != "/api/v1/products?limit=5" {
+		t.Errorf("Location %q, want /api/v1/products?limit=5", got)
+	}
+
+	// Never a redirect to another host
+	for path, want := range map[string]string{"//evil.example/": "/evil.example", "///evil.example//": "/evil.example", "///": "/"} {
+		r := client(trailingSlashRedirect).Get(path)
+		r.Expect(308)
+		if got := r.Header().Get("Location"); got != want {
+			t.Errorf("%s redirected to %q, want %q", path, got, want)
+		}
+	}
+
+	if _, err := trailingSlash("strict", nil); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
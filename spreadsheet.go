@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/money"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"github.com/mjpvl-ai/golangdb/storage"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	exportBatchSize = 500
+	// sheetName is the worksheet an export writes, and the one an import
+	// reads if there is no other.
+	sheetName = "Products"
+	xlsxType  = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// sheetFormats are the spreadsheet formats products are exported and
+// imported as.
+var sheetFormats = []string{"csv", "xlsx"}
+
+// exportFormats are the formats products are exported as: the spreadsheet
+// formats, and JSON, an array of products as the API returns them.
+var exportFormats = append(slices.Clone(sheetFormats), "json")
+
+// productColumn is a column of a product spreadsheet. parse is nil for
+// columns an import ignores, such as id and the timestamps, so an export
+// can be edited and imported again as is.
+type productColumn struct {
+	name  string
+	value func(p *Product) any
+	parse func(p *Product, cell string) error
+}
+
+// productColumns are the columns of a product spreadsheet, in export order.
+var productColumns = []productColumn{
+	{name: "id", value: func(p *Product) any { return p.ID }},
+	{name: "name", value: func(p *Product) any { return p.Name }, parse: func(p *Product, cell string) error {
+		p.Name = cell
+		return nil
+	}},
+	{name: "sku", value: func(p *Product) any { return derefOrNil(p.SKU) }, parse: func(p *Product, cell string) error {
+		if cell != "" {
+			p.SKU = &cell
+		}
+		return nil
+	}},
+	{name: "price", value: func(p *Product) any { return p.Price }, parse: func(p *Product, cell string) error {
+		return parsePriceCell(cell, &p.Price)
+	}},
+	{name: "currency", value: func(p *Product) any { return p.Currency }, parse: func(p *Product, cell string) error {
+		p.Currency = cell
+		return nil
+	}},
+	{name: "quantity", value: func(p *Product) any { return p.Quantity }, parse: func(p *Product, cell string) error {
+		return parseCell(cell, &p.Quantity)
+	}},
+	{name: "reserved", value: func(p *Product) any { return p.Reserved }, parse: func(p *Product, cell string) error {
+		return parseCell(cell, &p.Reserved)
+	}},
+	{name: "min_stock", value: func(p *Product) any { return p.MinStock }, parse: func(p *Product, cell string) error {
+		return parseCell(cell, &p.MinStock)
+	}},
+	{name: "max_stock", value: func(p *Product) any { return p.MaxStock }, parse: func(p *Product, cell string) error {
+		return parseCell(cell, &p.MaxStock)
+	}},
+	{name: "category_id", value: func(p *Product) any { return derefOrNil(p.CategoryID) }, parse: func(p *Product, cell string) error {
+		return parseIDCell(cell, &p.CategoryID)
+	}},
+	{name: "supplier_id", value: func(p *Product) any { return derefOrNil(p.SupplierID) }, parse: func(p *Product, cell string) error {
+		return parseIDCell(cell, &p.SupplierID)
+	}},
+	{name: "version", value: func(p *Product) any { return p.Version }},
+	{name: "created_at", value: func(p *Product) any { return p.CreatedAt.UTC().Format(time.RFC3339) }},
+	{name: "updated_at", value: func(p *Product) any { return p.UpdatedAt.UTC().Format(time.RFC3339) }},
+}
+
+// derefOrNil returns *v, or nil for an empty cell.
+func derefOrNil[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// parseCell parses an integer cell into v, leaving it zero if the cell is
+// empty.
+func parseCell(cell string, v *int) error {
+	if cell == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(cell)
+	if err != nil {
+		// Spreadsheets may store whole numbers as 5.0
+		f, ferr := strconv.ParseFloat(cell, 64)
+		if ferr != nil || f != float64(int(f)) {
+			return newAPIError("invalid_integer")
+		}
+		n = int(f)
+	}
+	*v = n
+	return nil
+}
+
+// parsePriceCell parses a decimal price cell into v. A workbook may hold
+// the price as a float, such as 19.990000000000002, so it is rounded to
+// whole minor units if it is within rounding error of them.
+func parsePriceCell(cell string, v *money.Amount) error {
+	if cell == "" {
+		return nil
+	}
+	if a, err := money.Parse(cell); err == nil {
+		*v = a
+		return nil
+	}
+	f, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return newAPIError("invalid_number")
+	}
+	a := money.FromFloat(f)
+	if math.Abs(a.Float()-f) > 1e-9*math.Max(1, math.Abs(f)) {
+		return newAPIError("too_precise", money.Scale)
+	}
+	*v = a
+	return nil
+}
+
+// parseIDCell parses a cell holding an optional ID into v.
+func parseIDCell(cell string, v **uint) error {
+	if cell == "" {
+		return nil
+	}
+	var n int
+	if err := parseCell(cell, &n); err != nil || n <= 0 {
+		return newAPIError("invalid_integer")
+	}
+	id := uint(n)
+	*v = &id
+	return nil
+}
+
+// Export the catalog as CSV, XLSX or JSON, streamed in batches, or
+// generated in the background with ?async=true
+func exportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if !slices.Contains(exportFormats, format) {
+		writeError(w, r, http.StatusBadRequest, "unsupported_export_format", strings.Join(exportFormats, ", "))
+		return
+	}
+	filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("20060102"), format)
+
+	if r.URL.Query().Get("async") == "true" {
+		d := depsFor(r)
+		tenant := tenantFor(r)
+		var total int64
+		if err := readDBFor(r).Model(&Product{}).Count(&total).Error; err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		job, err := d.jobs.enqueue(r.Context(), "product_export", int(total), func(ctx context.Context, progress func(int)) (map[string]any, error) {
+			conn := d.db
+			if replica := d.replicas.pick(); replica != nil {
+				conn = replica
+			}
+			return exportToStorage(ctx, conn, d.storage, tenant, format, filename, progress)
+		})
+		writeJobAccepted(w, r, job, err)
+		return
+	}
+
+	var sheet sheetWriter
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+	case "csv":
+		sheet = &csvSheetWriter{w: csv.NewWriter(w), rc: http.NewResponseController(w)}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case "xlsx":
+		xw, err := newXLSXSheetWriter()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		defer xw.close()
+		sheet = xw
+		w.Header().Set("Content-Type", xlsxType)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	// A large catalog can outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if sheet == nil {
+		// Like a CSV, only cut off by a failure midway
+		writeProductJSON(readDBFor(r), w, http.NewResponseController(w).Flush, nil)
+		return
+	}
+	// A CSV's headers are sent with its first batch, so a failure midway can
+	// only truncate it. An XLSX is sent whole at the end, so it fails with
+	// a proper error.
+	_, err := writeProductSheet(readDBFor(r), sheet, nil)
+	if xw, ok := sheet.(*xlsxSheetWriter); ok {
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		xw.writeTo(w)
+	}
+}
+
+// writeProductSheet writes a header row and then every product conn reads
+// to sheet, and returns how many there were. progress, if set, is called
+// after each batch.
+func writeProductSheet(conn *gorm.DB, sheet sheetWriter, progress func(int)) (int, error) {
+	header := make([]any, len(productColumns))
+	for i, col := range productColumns {
+		header[i] = col.name
+	}
+	if err := sheet.writeRow(header); err != nil {
+		return 0, err
+	}
+	var products []Product
+	n := 0
+	row := make([]any, len(productColumns))
+	err := conn.Order("id").FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range products {
+			for j, col := range productColumns {
+				row[j] = col.value(&products[i])
+			}
+			if err := sheet.writeRow(row); err != nil {
+				return err
+			}
+		}
+		n += len(products)
+		if progress != nil {
+			progress(n)
+		}
+		return sheet.flush()
+	}).Error
+	if err != nil {
+		return n, err
+	}
+	// The header alone, if there were no products
+	return n, sheet.flush()
+}
+
+// writeProductJSON writes every product conn reads to w as a JSON array,
+// encoded a batch at a time so that the catalog is never held in memory
+// whole, and returns how many there were. flush and progress, if set, are
+// called after each batch.
+func writeProductJSON(conn *gorm.DB, w io.Writer, flush func() error, progress func(int)) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := newJSONEncoder(bw)
+	bw.WriteByte('[')
+	var products []Product
+	n := 0
+	err := conn.Order("id").FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range products {
+			if n > 0 {
+				bw.WriteByte(',')
+			}
+			if err := enc.Encode(&products[i]); err != nil {
+				return err
+			}
+			n++
+		}
+		if progress != nil {
+			progress(n)
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if flush != nil {
+			return flush()
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return n, err
+	}
+	bw.WriteString("]\n")
+	return n, bw.Flush()
+}
+
+// exportToStorage writes the products of tenant to a file in format and puts it in store as filename, under a key of its own. It
+// returns the result of an export job.
+func exportToStorage(ctx context.Context, conn *gorm.DB, store storage.Storage, tenant uint, format, filename string, progress func(int)) (map[string]any, error) {
+	conn = conn.WithContext(repository.WithTenant(ctx, tenant))
+	f, err := os.CreateTemp("", "golangdb-export-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	var n int
+	contentType := xlsxType
+	switch format {
+	case "json":
+		contentType = "application/json"
+		if n, err = writeProductJSON(conn, f, nil, progress); err != nil {
+			return nil, err
+		}
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		if n, err = writeProductSheet(conn, &csvSheetWriter{w: csv.NewWriter(f)}, progress); err != nil {
+			return nil, err
+		}
+	default:
+		xw, err := newXLSXSheetWriter()
+		if err != nil {
+			return nil, err
+		}
+		defer xw.close()
+		if n, err = writeProductSheet(conn, xw, progress); err != nil {
+			return nil, err
+		}
+		if err := xw.writeTo(f); err != nil {
+			return nil, err
+		}
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	dir := make([]byte, 16)
+	if _, err := rand.Read(dir); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("exports/%d/%s/%s", tenant, hex.EncodeToString(dir), filename)
+	if err := store.Put(ctx, key, f, size, contentType); err != nil {
+		return nil, err
+	}
+	return map[string]any{"exported": n, jobFileKey: key}, nil
+}
+
+// sheetWriter writes an export row by row. flush is called after each
+// batch.
+type sheetWriter interface {
+	writeRow(row []any) error
+	flush() error
+}
+
+// csvSheetWriter writes a CSV straight to the response, or to a file if
+// rc is nil.
+type csvSheetWriter struct {
+	w  *csv.Writer
+	rc *http.ResponseController
+}
+
+func (s *csvSheetWriter) writeRow(row []any) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		if v != nil {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return s.w.Write(record)
+}
+
+func (s *csvSheetWriter) flush() error {
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		return err
+	}
+	if s.rc != nil {
+		s.rc.Flush()
+	}
+	return nil
+}
+
+// xlsxSheetWriter builds a workbook with excelize's stream writer, which
+// spills rows to a temporary file rather than keeping them in memory.
+type xlsxSheetWriter struct {
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+func newXLSXSheetWriter() (*xlsxSheetWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+		f.Close()
+		return nil, err
+	}
+	stream, err := f.NewStreamWriter(sheetName)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxSheetWriter{file: f, stream: stream}, nil
+}
+
+func (s *xlsxSheetWriter) writeRow(row []any) error {
+	for i, v := range row {
+		if a, ok := v.(money.Amount); ok {
+			// A number, so that the sheet can sum it
+			row[i] = a.Float()
+		}
+	}
+	s.rows++
+	cell, err := excelize.CoordinatesToCellName(1, s.rows)
+	if err != nil {
+		return err
+	}
+	return s.stream.SetRow(cell, row)
+}
+
+func (s *xlsxSheetWriter) flush() error { return nil }
+
+// writeTo completes the workbook and sends it.
+func (s *xlsxSheetWriter) writeTo(w io.Writer) error {
+	if err := s.stream.Flush(); err != nil {
+		return err
+	}
+	return s.file.Write(w)
+}
+
+func (s *xlsxSheetWriter) close() { s.file.Close() }
+
+// sheetFormat returns the format of an uploaded file, from its name or
+// else its declared type, or "" if it is neither CSV nor XLSX.
+func sheetFormat(filename, contentType string) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return "csv"
+	case ".xlsx":
+		return "xlsx"
+	}
+	switch mediaType, _, _ := mime.ParseMediaType(contentType); mediaType {
+	case "text/csv":
+		return "csv"
+	case xlsxType:
+		return "xlsx"
+	}
+	return ""
+}
+
+// decodeSheetUpload reads the products of the spreadsheet uploaded as the
+// "file" part of r's multipart/form-data body. It returns them along with
+// the line each came from, and the cells that could not be parsed, named
+// like "line 3: price"; blank lines are skipped.
+func decodeSheetUpload(r *http.Request) ([]Product, []int, *service.ValidationError, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, nil, newAPIError("invalid_payload")
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil, newAPIError("missing_sheet_file")
+		}
+		if err != nil {
+			return nil, nil, nil, newAPIError("invalid_payload")
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		var rows sheetReader
+		switch sheetFormat(part.FileName(), part.Header.Get("Content-Type")) {
+		case "csv":
+			rows = newCSVSheetReader(part)
+		case "xlsx":
+			if rows, err = newXLSXSheetReader(part); err != nil {
+				return nil, nil, nil, newAPIError("invalid_sheet", "not a readable XLSX workbook")
+			}
+		default:
+			return nil, nil, nil, newAPIError("unsupported_sheet_format", strings.Join(sheetFormats, ", "))
+		}
+		defer rows.close()
+		return decodeSheet(rows)
+	}
+}
+
+// decodeSheet reads products from rows, whose first non-blank line names
+// the columns.
+func decodeSheet(rows sheetReader) ([]Product, []int, *service.ValidationError, error) {
+	var columns []*productColumn
+	var products []Product
+	var lines []int
+	var invalid service.ValidationError
+	for {
+		record, line, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, newAPIError("invalid_sheet", fmt.Sprintf("line %d: %v", line, err))
+		}
+		if blankRecord(record) {
+			continue
+		}
+		if columns == nil {
+			if columns, err = sheetColumns(record); err != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+		var p Product
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if i >= len(columns) {
+				if cell != "" {
+					return nil, nil, nil, newAPIError("invalid_sheet", fmt.Sprintf("line %d has more cells than there are columns", line))
+				}
+				continue
+			}
+			if columns[i] == nil || columns[i].parse == nil {
+				continue
+			}
+			if err := columns[i].parse(&p, cell); err != nil {
+				invalid.Fields = append(invalid.Fields, service.FieldError{
+					Field: fmt.Sprintf("line %d: %s", line, columns[i].name),
+					Code:  asAPIError(err).Code,
+				})
+			}
+		}
+		products = append(products, p)
+		lines = append(lines, line)
+	}
+	if columns == nil {
+		return nil, nil, nil, newAPIError("invalid_sheet", "no header line")
+	}
+	return products, lines, &invalid, nil
+}
+
+// sheetColumns maps a header line to productColumns. Names are matched
+// case-insensitively; name is required, and every column must be known.
+// Blank headers have nil columns, whose cells must be empty.
+func sheetColumns(header []string) ([]*productColumn, error) {
+	columns := make([]*productColumn, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, newAPIError("invalid_sheet", fmt.Sprintf("duplicate column %q", name))
+		}
+		seen[name] = true
+		for j := range productColumns {
+			if productColumns[j].name == name {
+				columns[i] = &productColumns[j]
+			}
+		}
+		if columns[i] == nil {
+			return nil, newAPIError("invalid_sheet", fmt.Sprintf("unknown column %q", name))
+		}
+	}
+	if !seen["name"] {
+		return nil, newAPIError("invalid_sheet", `missing column "name"`)
+	}
+	return columns, nil
+}
+
+func blankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sheetReader reads an uploaded spreadsheet line by line. next returns
+// io.EOF after the last line.
+type sheetReader interface {
+	next() (record []string, line int, err error)
+	close()
+}
+
+type csvSheetReader struct {
+	r *csv.Reader
+}
+
+func newCSVSheetReader(r io.Reader) *csvSheetReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &csvSheetReader{r: cr}
+}
+
+func (s *csvSheetReader) next() ([]string, int, error) {
+	record, err := s.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.StartLine, parseErr.Err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	line, _ := s.r.FieldPos(0)
+	return record, line, nil
+}
+
+func (s *csvSheetReader) close() {}
+
+// xlsxSheetReader reads the first worksheet of a workbook. excelize needs
+// the whole file to open it, but then reads the sheet row by row.
+type xlsxSheetReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+	line int
+}
+
+func newXLSXSheetReader(r io.Reader) (*xlsxSheetReader, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	sheet := sheetName
+	if idx, err := f.GetSheetIndex(sheet); err != nil || idx < 0 {
+		sheet = f.GetSheetName(0)
+	}
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxSheetReader{file: f, rows: rows}, nil
+}
+
+func (s *xlsxSheetReader) next() ([]string, int, error) {
+	if !s.rows.Next() {
+		if err := s.rows.Error(); err != nil {
+			return nil, s.line, err
+		}
+		return nil, s.line, io.EOF
+	}
+	s.line++
+	// Raw values, so a price shown as "$9.99" reads as 9.99
+	record, err := s.rows.Columns(excelize.Options{RawCellValue: true})
+	return record, s.line, err
+}
+
+func (s *xlsxSheetReader) close() {
+	s.rows.Close()
+	s.file.Close()
+}
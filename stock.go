@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"gorm.io/gorm"
+)
+
+// Reasons reported by GET /products/alerts.
+const (
+	alertBelowMinStock = "below_min_stock"
+	alertAboveMaxStock = "above_max_stock"
+)
+
+// stockAlert is a product whose quantity is outside its configured limits.
+type stockAlert struct {
+	Product Product `json:"product"`
+	Reason  string  `json:"reason"`
+}
+
+// stockAlertReason returns the alert reason for p, or "" if its quantity is
+// within limits.
+func stockAlertReason(p *Product) string {
+	switch {
+	case p.Quantity < p.MinStock:
+		return alertBelowMinStock
+	case p.MaxStock > 0 && p.Quantity > p.MaxStock:
+		return alertAboveMaxStock
+	}
+	return ""
+}
+
+// List under- and over-stocked products
+func getStockAlerts(w http.ResponseWriter, r *http.Request) {
+	var products []Product
+	err := readDBFor(r).Where("quantity < min_stock OR (max_stock > 0 AND quantity > max_stock)").
+		Order("id").Find(&products).Error
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	alerts := make([]stockAlert, 0, len(products))
+	for _, p := range products {
+		alerts = append(alerts, stockAlert{Product: p, Reason: stockAlertReason(&p)})
+	}
+	writeJSON(w, r, http.StatusOK, alerts)
+}
+
+// stockAdjustment is the body of POST /products/{id}/adjust.
+type stockAdjustment struct {
+	Delta  int    `json:"delta"`
+	Reason string `json:"reason"`
+}
+
+// stockReservation is the body of POST /products/{id}/reserve and
+// /release.
+type stockReservation struct {
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
+
+// Add to or take from a product's stock
+func adjustStock(w http.ResponseWriter, r *http.Request) {
+	var req stockAdjustment
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	product, err := productService(r).AdjustStock(id, req.Delta, req.Reason)
+	writeMovedStock(w, r, product, err)
+}
+
+// Reserve some of a product's stock
+func reserveStock(w http.ResponseWriter, r *http.Request) {
+	var req stockReservation
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	product, err := productService(r).Reserve(id, req.Quantity, req.Reason)
+	writeMovedStock(w, r, product, err)
+}
+
+// Release some of a product's reserved stock
+func releaseStock(w http.ResponseWriter, r *http.Request) {
+	var req stockReservation
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	product, err := productService(r).Release(id, req.Quantity, req.Reason)
+	writeMovedStock(w, r, product, err)
+}
+
+// writeMovedStock answers a stock movement with the product, or its error.
+func writeMovedStock(w http.ResponseWriter, r *http.Request, product *Product, err error) {
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", productETag(product))
+	writeJSON(w, r, http.StatusOK, product)
+}
+
+// movementSchema describes how a product's stock ledger can be listed.
+var movementSchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":   {Column: "id", Kind: query.Uint, Sortable: true},
+		"kind": {Column: "kind", Kind: query.String, Ops: []query.Op{query.Eq}},
+	},
+	Key:          "id",
+	DefaultLimit: defaultPageLimit,
+	MaxLimit:     maxPageLimit,
+}
+
+// movementList is the envelope of a product's stock ledger, paged like
+// productList.
+type movementList struct {
+	Data       []model.StockMovement `json:"data"`
+	Meta       query.Meta            `json:"meta"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// Get the stock ledger of a product, deleted or not
+func getStockMovements(w http.ResponseWriter, r *http.Request) {
+	id, ok := productID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "product_not_found")
+		return
+	}
+	params, err := movementSchema.Parse(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// A new session, as the ledger takes several queries
+	repo := repository.NewProductRepository(readDBFor(r).Unscoped().Session(&gorm.Session{}))
+	movements, total, err := service.NewProductService(repo).WithContext(r.Context()).StockMovements(id, params)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	movements, next, err := query.Next(params, movements)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if movements == nil {
+		movements = []model.StockMovement{}
+	}
+	writeJSON(w, r, http.StatusOK, movementList{Data: movements, Meta: params.Meta(total), NextCursor: next})
+}
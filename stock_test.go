@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestStockAlerts(t *testing.T) {
+	c, _ := newTestAPI(t)
+	for _, p := range []map[string]any{
+		{"name": "Low", "price": "1", "quantity": 2, "min_stock": 5},
+		{"name": "High", "price": "1", "quantity": 20, "max_stock": 10},
+		{"name": "Within", "price": "1", "quantity": 5, "min_stock": 1, "max_stock": 10},
+		{"name": "Unbounded", "price": "1", "quantity": 500},
+	} {
+		c.Post("/api/v1/products", p).Expect(201)
+	}
+	expectProblem(t, c.Post("/api/v1/products", map[string]any{"name": "Backwards", "price": "1", "min_stock": 10, "max_stock": 5}), 422, "validation_failed")
+
+	var alerts []stockAlert
+	c.Get("/api/v1/products/alerts").Expect(200).Decode(&alerts)
+	if len(alerts) != 2 ||
+		alerts[0].Product.Name != "Low" || alerts[0].Reason != alertBelowMinStock ||
+		alerts[1].Product.Name != "High" || alerts[1].Reason != alertAboveMaxStock {
+		t.Errorf("alerts %+v, want Low below min and High above max", alerts)
+	}
+}
@@ -0,0 +1,176 @@
+// Package storage keeps uploaded files, such as product images, either on
+// local disk or in an S3-compatible bucket, and says where clients can
+// fetch them.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Storage stores objects under slash-separated keys such as
+// "products/1/photo.jpg".
+type Storage interface {
+	// Put stores size bytes from body under key, replacing any object
+	// there.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Delete removes the object under key. Deleting a missing object is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients can fetch the object under key. It may
+	// expire.
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// Local is a Storage in a directory on disk. Its objects are served by
+// Handler, at baseURL.
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal returns the Storage in dir, creating it if need be, whose
+// objects are at baseURL followed by their key.
+func NewLocal(dir, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir, baseURL: baseURL}, nil
+}
+
+func (s *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key {
+		return "", errors.New("storage: invalid key " + key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes the object to a temporary file first, so that a failed
+// upload never leaves part of one under key.
+func (s *Local) Put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+func (s *Local) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Local) URL(_ context.Context, key string) (string, error) {
+	return s.baseURL + escapeKey(key), nil
+}
+
+// Handler serves the objects by key, without directory listings.
+func (s *Local) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || strings.Contains(r.URL.Path, "/.") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// S3Options are the connection settings of an S3 bucket.
+type S3Options struct {
+	Endpoint  string // host[:port]
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool // plain HTTP
+	// PublicURL, if set, is where the bucket's objects are public;
+	// otherwise URL signs a link that expires after URLExpiry.
+	PublicURL string
+	URLExpiry time.Duration
+}
+
+// S3 is a Storage in a bucket of S3 or a compatible service.
+type S3 struct {
+	client *minio.Client
+	opts   S3Options
+}
+
+// NewS3 returns the Storage in the bucket opts names. It doesn't connect
+// until used.
+func NewS3(opts S3Options) (*S3, error) {
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: !opts.Insecure,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{client: client, opts: opts}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.opts.Bucket, key, body, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.opts.Bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) URL(ctx context.Context, key string) (string, error) {
+	if s.opts.PublicURL != "" {
+		return strings.TrimSuffix(s.opts.PublicURL, "/") + "/" + escapeKey(key), nil
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.opts.Bucket, key, s.opts.URLExpiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// escapeKey escapes each segment of key for a URL path.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"gorm.io/gorm"
+)
+
+// Supplier is the supplier model. Handlers use it unqualified.
+type Supplier = model.Supplier
+
+var errSupplierNotFound = errors.New("supplier not found")
+
+// Get all suppliers
+func getSuppliers(w http.ResponseWriter, r *http.Request) {
+	var suppliers []Supplier
+	if err := readDBFor(r).Order("id").Find(&suppliers).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeConditionalJSON(w, r, "", suppliers)
+}
+
+// Get a single supplier by ID
+func getSupplier(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "supplier_not_found")
+		return
+	}
+	var supplier Supplier
+	if err := readDBFor(r).First(&supplier, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "supplier_not_found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeConditionalJSON(w, r, "", supplier)
+}
+
+// Create a new supplier
+func createSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier Supplier
+	if err := decodeJSON(r, &supplier); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if supplier.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	supplier.ID = 0
+	if err := dbFor(r).Create(&supplier).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, supplier)
+}
+
+// Replace a supplier's details
+func updateSupplier(w http.ResponseWriter, r *http.Request) {
+	var input Supplier
+	if err := decodeJSON(r, &input); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if input.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_payload")
+		return
+	}
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "supplier_not_found")
+		return
+	}
+	var supplier Supplier
+	err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&supplier, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSupplierNotFound
+			}
+			return err
+		}
+		supplier.Name, supplier.Email, supplier.Phone = input.Name, input.Email, input.Phone
+		return tx.Save(&supplier).Error
+	})
+	switch {
+	case errors.Is(err, errSupplierNotFound):
+		writeError(w, r, http.StatusNotFound, "supplier_not_found")
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, supplier)
+}
+
+// Delete a supplier. Its products, deleted ones included, are left without
+// a supplier.
+func deleteSupplier(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "supplier_not_found")
+		return
+	}
+	err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+		if err := unassign(tx, actorFor(r.Context()), "supplier_id", id); err != nil {
+			return err
+		}
+		res := tx.Delete(&Supplier{}, id)
+		if res.Error == nil && res.RowsAffected == 0 {
+			return errSupplierNotFound
+		}
+		return res.Error
+	})
+	switch {
+	case errors.Is(err, errSupplierNotFound):
+		writeError(w, r, http.StatusNotFound, "supplier_not_found")
+		return
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	invalidateProducts(r.Context(), depsFor(r))
+	w.WriteHeader(http.StatusNoContent)
+}
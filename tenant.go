@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mjpvl-ai/golangdb/auth"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/repository"
+	"github.com/mjpvl-ai/golangdb/service"
+	"gorm.io/gorm"
+)
+
+// Tenant is the tenant model. Handlers use it unqualified.
+type Tenant = model.Tenant
+
+// knownTenants holds the IDs of tenants found to exist. Tenants are never
+// deleted, so they stay valid.
+var knownTenants sync.Map
+
+// tenantExists reports whether tenant id exists.
+func tenantExists(db *gorm.DB, id uint) (bool, error) {
+	if _, ok := knownTenants.Load(id); ok {
+		return true, nil
+	}
+	var count int64
+	if err := db.Model(&Tenant{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		knownTenants.Store(id, true)
+	}
+	return count > 0, nil
+}
+
+// resolveTenant returns the tenant a request is made for: the tenant of
+// the caller's token, or else the one named by header, or else the
+// default tenant. A caller with a tenant can't name another. The error is
+// an API error, to answer with status.
+func resolveTenant(db *gorm.DB, claims *auth.Claims, header string) (id uint, status int, err error) {
+	header = strings.TrimSpace(header)
+	if claims != nil && claims.TenantID != 0 {
+		if header != "" && header != strconv.FormatUint(uint64(claims.TenantID), 10) {
+			return 0, http.StatusForbidden, newAPIError("tenant_mismatch")
+		}
+		return claims.TenantID, 0, nil
+	}
+	if header == "" {
+		return model.DefaultTenantID, 0, nil
+	}
+	n, err := strconv.ParseUint(header, 10, 0)
+	if err != nil || n == 0 {
+		return 0, http.StatusBadRequest, newAPIError("invalid_tenant")
+	}
+	exists, err := tenantExists(db, uint(n))
+	if err != nil {
+		return 0, http.StatusInternalServerError, newAPIError("internal_error")
+	}
+	if !exists {
+		return 0, http.StatusNotFound, newAPIError("tenant_not_found")
+	}
+	return uint(n), 0, nil
+}
+
+// scopeTenant is middleware that confines the database statements of a
+// request to its tenant, so that no handler can see or change another
+// tenant's rows.
+func scopeTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, status, err := resolveTenant(depsFor(r).db.WithContext(r.Context()), claimsFor(r), r.Header.Get(tenantHeader))
+		if err != nil {
+			writeAPIError(w, r, status, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(repository.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// tenantFor returns the tenant r is made for.
+func tenantFor(r *http.Request) uint {
+	if id, ok := repository.TenantFromContext(r.Context()); ok {
+		return id
+	}
+	return model.DefaultTenantID
+}
+
+// namedTenant returns the tenant r names, in its token or X-Tenant-ID, or
+// "" if it names none and is served for the default tenant.
+func namedTenant(r *http.Request) string {
+	if claims := claimsFor(r); claims != nil && claims.TenantID != 0 {
+		return strconv.FormatUint(uint64(claims.TenantID), 10)
+	}
+	return strings.TrimSpace(r.Header.Get(tenantHeader))
+}
+
+// requirePlatformAdmin guards the routes that act on the whole service
+// rather than on one tenant, for admins who belong to no tenant.
+func requirePlatformAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if claimsFor(r).TenantID != 0 {
+			writeError(w, r, http.StatusForbidden, "forbidden")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// tenantRequest is the body of POST /tenants. Admin, if set, is the first
+// admin account of the tenant.
+type tenantRequest struct {
+	Name  string       `json:"name"`
+	Admin *credentials `json:"admin,omitempty"`
+}
+
+// tenantCreated is the response to POST /tenants.
+type tenantCreated struct {
+	*Tenant
+	Admin *model.User `json:"admin,omitempty"`
+}
+
+// maxTenantName bounds a tenant's name, as its column does.
+const maxTenantName = 100
+
+// Get all tenants
+func getTenants(w http.ResponseWriter, r *http.Request) {
+	var tenants []Tenant
+	if err := readDBFor(r).Order("id").Find(&tenants).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, tenants)
+}
+
+// Get a single tenant by ID
+func getTenant(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "tenant_not_found")
+		return
+	}
+	var tenant Tenant
+	if err := readDBFor(r).First(&tenant, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "tenant_not_found")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, tenant)
+}
+
+// Create a tenant, and optionally its first admin
+func createTenant(w http.ResponseWriter, r *http.Request) {
+	var req tenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTenantName {
+		writeError(w, r, http.StatusBadRequest, "invalid_tenant_name", maxTenantName)
+		return
+	}
+	created := tenantCreated{Tenant: &Tenant{Name: req.Name}}
+	err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(created.Tenant).Error; err != nil {
+			return err
+		}
+		if req.Admin == nil {
+			return nil
+		}
+		var err error
+		created.Admin, err = service.NewUserService(repository.NewUserRepository(tx)).
+			CreateAdmin(req.Admin.Email, req.Admin.Password, created.ID)
+		return err
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, created)
+}
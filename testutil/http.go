@@ -0,0 +1,116 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Client sends requests straight to a handler, usually the API's router,
+// and records its responses.
+type Client struct {
+	t       testing.TB
+	handler http.Handler
+	// Header is sent with every request.
+	Header http.Header
+}
+
+// NewClient returns a client of handler for t.
+func NewClient(t testing.TB, handler http.Handler) *Client {
+	return &Client{t: t, handler: handler, Header: http.Header{}}
+}
+
+// WithToken returns a copy of c that sends token as its bearer token.
+func (c *Client) WithToken(token string) *Client {
+	return c.WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithHeader returns a copy of c that sends the header name with value.
+func (c *Client) WithHeader(name, value string) *Client {
+	clone := *c
+	clone.Header = c.Header.Clone()
+	clone.Header.Set(name, value)
+	return &clone
+}
+
+// Do sends a request for method and path. A body that isn't nil, a string
+// or a []byte is sent as JSON.
+func (c *Client) Do(method, path string, body any) *Response {
+	c.t.Helper()
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(body)
+	case []byte:
+		reader = bytes.NewReader(body)
+	default:
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("testutil: encode %s %s body: %v", method, path, err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	for name, values := range c.Header {
+		r.Header[name] = values
+	}
+	if reader != nil && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	return &Response{ResponseRecorder: w, t: c.t, request: method + " " + path}
+}
+
+func (c *Client) Get(path string) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodGet, path, nil)
+}
+
+func (c *Client) Post(path string, body any) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodPost, path, body)
+}
+
+func (c *Client) Put(path string, body any) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodPut, path, body)
+}
+
+func (c *Client) Patch(path string, body any) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodPatch, path, body)
+}
+
+func (c *Client) Delete(path string) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodDelete, path, nil)
+}
+
+// Response is a recorded response.
+type Response struct {
+	*httptest.ResponseRecorder
+	t       testing.TB
+	request string
+}
+
+// Expect fails the test unless the response has status, and returns r.
+func (r *Response) Expect(status int) *Response {
+	r.t.Helper()
+	if r.Code != status {
+		r.t.Fatalf("%s: status %d, want %d; body: %s", r.request, r.Code, status, r.Body)
+	}
+	return r
+}
+
+// Decode decodes the JSON body into v, failing the test if it can't.
+func (r *Response) Decode(v any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("%s: decode body: %v; body: %s", r.request, err, r.Body)
+	}
+}
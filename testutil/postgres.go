@@ -0,0 +1,110 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"github.com/mjpvl-ai/golangdb/database"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/gorm"
+)
+
+// postgresImage is the image of the test container, the oldest PostgreSQL
+// the migrations support.
+const postgresImage = "postgres:14-alpine"
+
+// The PostgreSQL database of the test binary, started and migrated by the
+// first test to need it.
+var (
+	postgresOnce      sync.Once
+	postgresDB        *gorm.DB
+	postgresErr       error
+	postgresContainer *postgres.PostgresContainer
+)
+
+func sharedPostgres(gormConfig *gorm.Config) (*gorm.DB, error) {
+	postgresOnce.Do(func() {
+		ctx := context.Background()
+		var cfg config.DB
+		if cfg, postgresErr = postgresConfig(ctx); postgresErr != nil {
+			return
+		}
+		if postgresDB, postgresErr = database.Connect(ctx, cfg, gormConfig); postgresErr != nil {
+			return
+		}
+		postgresErr = migrate(postgresDB)
+	})
+	return postgresDB, postgresErr
+}
+
+// postgresConfig returns the settings of the server at TEST_POSTGRES_URL,
+// or else of a container it starts.
+func postgresConfig(ctx context.Context) (config.DB, error) {
+	cfg := config.Default().DB
+	cfg.Driver, cfg.SSLMode = config.DriverPostgres, "disable"
+	if raw := os.Getenv(PostgresURLEnv); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", PostgresURLEnv, err)
+		}
+		cfg.Host = u.Hostname()
+		if port := u.Port(); port != "" {
+			if cfg.Port, err = strconv.Atoi(port); err != nil {
+				return cfg, fmt.Errorf("%s: invalid port %q", PostgresURLEnv, port)
+			}
+		}
+		cfg.User = u.User.Username()
+		password, _ := u.User.Password()
+		cfg.Password = config.Secret(password)
+		cfg.Name = u.Path[min(1, len(u.Path)):]
+		if mode := u.Query().Get("sslmode"); mode != "" {
+			cfg.SSLMode = mode
+		}
+		return cfg, nil
+	}
+
+	cfg.User, cfg.Password, cfg.Name = "golangdb", "golangdb", "golangdb_test"
+	container, err := postgres.Run(ctx, postgresImage,
+		postgres.WithUsername(cfg.User),
+		postgres.WithPassword(string(cfg.Password)),
+		postgres.WithDatabase(cfg.Name),
+		// Once after initdb, then for real
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).WithStartupTimeout(time.Minute)),
+	)
+	if err != nil {
+		return cfg, fmt.Errorf("start PostgreSQL container: %w", err)
+	}
+	postgresContainer = container
+	if cfg.Host, err = container.Host(ctx); err != nil {
+		return cfg, err
+	}
+	port, err := container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Port = port.Int()
+	return cfg, nil
+}
+
+// stopPostgres closes the shared database and removes its container, if
+// there are any.
+func stopPostgres() error {
+	if postgresDB != nil {
+		if sqlDB, err := postgresDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	if postgresContainer == nil {
+		return nil
+	}
+	return postgresContainer.Terminate(context.Background())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// longRoutes are the routes whose requests may run for
+// http.long_request_timeout rather than http.request_timeout, keyed by
+// *mux.Route.
+var longRoutes sync.Map
+
+// allowLongRequests declares that route takes long by design, such as an
+// import or a backup, and returns it.
+func allowLongRequests(route *mux.Route) *mux.Route {
+	longRoutes.Store(route, true)
+	return route
+}
+
+// limitDuration gives each request a deadline http.request_timeout away,
+// or http.long_request_timeout for routes declared with allowLongRequests;
+// 0 sets none. Database statements still running at the deadline are
+// cancelled, and the request gets 504 with code request_timeout. It runs
+// as mux middleware, after routing, so the matched route is known.
+func limitDuration(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := depsFor(r).cfg.HTTP
+		timeout := cfg.RequestTimeout
+		if route := mux.CurrentRoute(r); route != nil {
+			if _, ok := longRoutes.Load(route); ok {
+				timeout = cfg.LongRequestTimeout
+			}
+		}
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout))
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
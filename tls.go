@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/mjpvl-ai/golangdb/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsCipherSuites are the TLS 1.2 cipher suites accepted: forward secret
+// and authenticated only. TLS 1.3's aren't configurable, and are all
+// strong.
+var tlsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+var tlsVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// serverTLS is the TLS setup of the servers.
+type serverTLS struct {
+	config *tls.Config
+	// challenges answers ACME HTTP challenges, and passes other requests
+	// on; nil without autocert.
+	challenges func(fallback http.Handler) http.Handler
+}
+
+// newServerTLS returns the TLS setup cfg asks for, or nil if TLS is off.
+// A certificate from files is loaded now, so that a bad one stops startup.
+func newServerTLS(cfg config.TLS) (*serverTLS, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	s := &serverTLS{config: &tls.Config{}}
+	if len(cfg.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+			Email:      cfg.AutocertEmail,
+		}
+		if cfg.AutocertDirectoryURL != "" {
+			m.Client = &acme.Client{DirectoryURL: cfg.AutocertDirectoryURL}
+		}
+		// Includes the protocol for TLS-ALPN challenges
+		s.config = m.TLSConfig()
+		s.challenges = m.HTTPHandler
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		s.config.Certificates = []tls.Certificate{cert}
+	}
+	s.config.MinVersion = tlsVersions[cfg.MinVersion]
+	s.config.CipherSuites = tlsCipherSuites
+	s.config.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+	return s, nil
+}
+
+// redirectHandler answers plain HTTP requests with a redirect to the same
+// URL over HTTPS on the port of httpsAddr, and ACME HTTP challenges with
+// their answer.
+func (s *serverTLS) redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	var redirect http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		u := *r.URL
+		u.Scheme, u.Host = "https", host
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+	})
+	if s.challenges != nil {
+		redirect = s.challenges(redirect)
+	}
+	return redirect
+}
+
+// strictTransportSecurity is middleware that tells browsers to use only
+// HTTPS for the host for a year.
+func strictTransportSecurity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		next.ServeHTTP(w, r)
+	})
+}
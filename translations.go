@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/service"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bounds of a translation, as its columns allow.
+const (
+	maxTranslationName        = 255
+	maxTranslationDescription = 10000
+)
+
+// translationRequest is the body of PUT /products/{id}/translations/{locale}.
+type translationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// routeLocale returns the locale of a translations route in canonical
+// form, answering 400 if it isn't a language tag.
+func routeLocale(w http.ResponseWriter, r *http.Request) (string, bool) {
+	raw := mux.Vars(r)["locale"]
+	tag, err := language.Parse(raw)
+	if err != nil || tag == language.Und {
+		writeError(w, r, http.StatusBadRequest, "invalid_locale", raw)
+		return "", false
+	}
+	return tag.String(), true
+}
+
+// Get the translations of a product
+func getProductTranslations(w http.ResponseWriter, r *http.Request) {
+	product, ok := routeProduct(w, r)
+	if !ok {
+		return
+	}
+	translations := []model.ProductTranslation{}
+	if err := readDBFor(r).Where("product_id = ?", product.ID).Order("locale").Find(&translations).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string][]model.ProductTranslation{"data": translations})
+}
+
+// Create or replace the translation of a product into a locale
+func putProductTranslation(w http.ResponseWriter, r *http.Request) {
+	product, ok := routeProduct(w, r)
+	if !ok {
+		return
+	}
+	locale, ok := routeLocale(w, r)
+	if !ok {
+		return
+	}
+	var req translationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	var fields []service.FieldError
+	switch {
+	case req.Name == "":
+		fields = append(fields, service.FieldError{Field: "name", Code: "required_field"})
+	case !utf8.ValidString(req.Name) || utf8.RuneCountInString(req.Name) > maxTranslationName:
+		fields = append(fields, service.FieldError{Field: "name", Code: "too_long", Args: []any{maxTranslationName}})
+	}
+	if !utf8.ValidString(req.Description) || utf8.RuneCountInString(req.Description) > maxTranslationDescription {
+		fields = append(fields, service.FieldError{Field: "description", Code: "too_long", Args: []any{maxTranslationDescription}})
+	}
+	if len(fields) > 0 {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, &service.ValidationError{Fields: fields})
+		return
+	}
+
+	translation := model.ProductTranslation{ProductID: product.ID, Locale: locale}
+	created := false
+	err := dbFor(r).Transaction(func(tx *gorm.DB) error {
+		// The product is locked, so that two first translations into
+		// the same locale can't both be created
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&Product{}, product.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("product_id = ? AND locale = ?", product.ID, locale).First(&translation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created = true
+			translation.Name, translation.Description = req.Name, req.Description
+			return tx.Create(&translation).Error
+		}
+		if err != nil {
+			return err
+		}
+		translation.Name, translation.Description = req.Name, req.Description
+		return tx.Save(&translation).Error
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, r, status, translation)
+}
+
+// Delete the translation of a product into a locale
+func deleteProductTranslation(w http.ResponseWriter, r *http.Request) {
+	product, ok := routeProduct(w, r)
+	if !ok {
+		return
+	}
+	locale, ok := routeLocale(w, r)
+	if !ok {
+		return
+	}
+	result := dbFor(r).Where("product_id = ? AND locale = ?", product.ID, locale).Delete(&model.ProductTranslation{})
+	if result.Error != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeError(w, r, http.StatusNotFound, "translation_not_found", locale)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// localize replaces the names of products with their translations best
+// suited to r's Accept-Language, filling in Locale and Description. The
+// client's languages are tried in its order of preference, each falling
+// back from a regional variant to its language, as fr-CA does to fr. A
+// product keeps its own name if that is in a language the client prefers,
+// CATALOG_LOCALE, or if none of its translations is in one it accepts.
+// Without an Accept-Language header products are left as they are.
+func localize(r *http.Request, products []Product) error {
+	accepted, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(accepted) == 0 || len(products) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	var translations []model.ProductTranslation
+	if err := readDBFor(r).Where("product_id IN ?", ids).Order("id").Find(&translations).Error; err != nil {
+		return err
+	}
+	byProduct := map[uint][]model.ProductTranslation{}
+	for _, t := range translations {
+		byProduct[t.ProductID] = append(byProduct[t.ProductID], t)
+	}
+	own := language.Make(depsFor(r).cfg.Catalog.Locale)
+	for i := range products {
+		translations := byProduct[products[i].ID]
+		if len(translations) == 0 {
+			continue
+		}
+		// Index 0, the product's own name, is also the default
+		supported := []language.Tag{own}
+		for _, t := range translations {
+			supported = append(supported, language.Make(t.Locale))
+		}
+		_, index, confidence := language.NewMatcher(supported).Match(accepted...)
+		if index == 0 || confidence == language.No {
+			continue
+		}
+		t := translations[index-1]
+		products[i].Name, products[i].Description, products[i].Locale = t.Name, t.Description, t.Locale
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/mjpvl-ai/golangdb/query"
+)
+
+// maxListBytes caps the encoded size of the rows in a list response. Set
+// with -max-response-bytes; 0 disables the cap.
+var maxListBytes = 4 << 20
+
+// fitPayload drops trailing products until their encoded size, trimmed to
+// fields as they are sent, is within maxListBytes, always keeping at least
+// one so the client can make progress. It reports whether anything was
+// dropped.
+func fitPayload(products *[]Product, fields query.Fields) (bool, error) {
+	if maxListBytes <= 0 {
+		return false, nil
+	}
+	size := 0
+	for i := range *products {
+		b, err := json.Marshal(fields.Select(&(*products)[i]))
+		if err != nil {
+			return false, err
+		}
+		size += len(b) + 1 // separating comma
+		if size > maxListBytes && i > 0 {
+			*products = (*products)[:i]
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestListTruncatedToCap(t *testing.T) {
+	c, _ := newTestAPI(t)
+	for i := range 10 {
+		c.Post("/api/v1/products", map[string]any{"name": fmt.Sprintf("Product %d", i), "price": "1"}).Expect(201)
+	}
+	type list struct {
+		Data       []Product `json:"data"`
+		NextCursor string    `json:"next_cursor"`
+		Truncated  bool      `json:"truncated"`
+	}
+	var all list
+	r := c.Get("/api/v1/products?sort=id&limit=10")
+	r.Expect(200).Decode(&all)
+	if all.Truncated || r.Header().Get("X-Truncated") != "" {
+		t.Fatal("list under the cap flagged as truncated")
+	}
+
+	// Leave room for three and a half products
+	size := 0
+	for i, p := range all.Data {
+		b, err := json.Marshal(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 3 {
+			size += len(b) / 2
+			break
+		}
+		size += len(b) + 1
+	}
+	defer func(old int) { maxListBytes = old }(maxListBytes)
+	maxListBytes = size
+
+	var page list
+	r = c.Get("/api/v1/products?sort=id&limit=10")
+	r.Expect(200).Decode(&page)
+	if len(page.Data) != 3 || !page.Truncated || r.Header().Get("X-Truncated") != "true" {
+		t.Fatalf("got %d products, truncated %v, X-Truncated %q; want 3, flagged", len(page.Data), page.Truncated, r.Header().Get("X-Truncated"))
+	}
+	// The cursor continues after the last product sent
+	var next list
+	c.Get("/api/v1/products?sort=id&limit=10&cursor=" + url.QueryEscape(page.NextCursor)).Expect(200).Decode(&next)
+	if len(next.Data) == 0 || next.Data[0].ID != all.Data[3].ID {
+		t.Errorf("next page starts at %+v, want product %d", next.Data, all.Data[3].ID)
+	}
+
+	// The cap is on what is sent, so sparse rows fit more to a page
+	var sparse list
+	r = c.Get("/api/v1/products?sort=id&limit=10&fields=id")
+	r.Expect(200).Decode(&sparse)
+	if len(sparse.Data) != 10 || sparse.Truncated || r.Header().Get("X-Truncated") != "" {
+		t.Errorf("with fields=id got %d products, truncated %v; want all 10", len(sparse.Data), sparse.Truncated)
+	}
+
+	// A single product over the cap is still sent
+	maxListBytes = 1
+	c.Get("/api/v1/products?sort=id&limit=10").Expect(200).Decode(&page)
+	if len(page.Data) != 1 || !page.Truncated {
+		t.Errorf("got %d products, truncated %v; want 1, flagged", len(page.Data), page.Truncated)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// productETag is the entity tag of a product: its version, quoted.
+func productETag(p *Product) string {
+	return `"` + strconv.FormatUint(uint64(p.Version), 10) + `"`
+}
+
+// ifNoneMatch reports whether r's If-None-Match names etag or is *. Tags
+// are compared weakly, ignoring W/, as RFC 9110 says for GET.
+func ifNoneMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion returns the product version a write is based on: the
+// one in If-Match if the header is set, otherwise bodyVersion. 0 means the
+// client sent none. ok is false if If-Match is not a single product ETag.
+func expectedVersion(r *http.Request, bodyVersion uint) (version uint, ok bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		return bodyVersion, true
+	}
+	tag, found := strings.CutPrefix(ifMatch, `"`)
+	tag, closed := strings.CutSuffix(tag, `"`)
+	if !found || !closed {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(tag, 10, 0)
+	if err != nil || n == 0 {
+		return 0, false
+	}
+	return uint(n), true
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"github.com/mjpvl-ai/golangdb/query"
+	"gorm.io/gorm"
+)
+
+// Webhook is the webhook model. Handlers use it unqualified.
+type Webhook = model.Webhook
+
+// webhookRequest is the body of POST /webhooks. Secret is generated if
+// empty.
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// validate returns the problem with req, if any. The URL's host must
+// resolve to public addresses only.
+func (req *webhookRequest) validate(ctx context.Context) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return newAPIError("invalid_webhook_url")
+	}
+	if err := checkWebhookHost(ctx, u.Hostname()); err != nil {
+		return newAPIError("webhook_url_not_public", u.Hostname())
+	}
+	types := webhookEventTypes()
+	if len(req.Events) == 0 {
+		return newAPIError("invalid_webhook_events", strings.Join(types, ", "))
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(types, event) {
+			return newAPIError("invalid_webhook_events", strings.Join(types, ", "))
+		}
+	}
+	if len(req.Secret) > maxWebhookSecretLength {
+		return newAPIError("invalid_webhook_secret", maxWebhookSecretLength)
+	}
+	return nil
+}
+
+const maxWebhookSecretLength = 64
+
+// webhookEventTypes returns the event types a webhook can subscribe to.
+func webhookEventTypes() []string {
+	var types []string
+	for _, event := range model.WebhookEvents {
+		types = append(types, event)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Register a webhook
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.validate(r.Context()); err != nil {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if req.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "internal_error")
+			return
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+	slices.Sort(req.Events)
+	webhook := Webhook{URL: req.URL, Events: slices.Compact(req.Events), Secret: req.Secret}
+	if err := dbFor(r).Create(&webhook).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, webhook)
+}
+
+// Get all webhooks
+func getWebhooks(w http.ResponseWriter, r *http.Request) {
+	var webhooks []Webhook
+	if err := readDBFor(r).Omit("secret").Order("id").Find(&webhooks).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if webhooks == nil {
+		webhooks = []Webhook{}
+	}
+	writeJSON(w, r, http.StatusOK, webhooks)
+}
+
+// Get a single webhook by ID
+func getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := findWebhook(w, r)
+	if ok {
+		writeJSON(w, r, http.StatusOK, webhook)
+	}
+}
+
+// findWebhook loads the webhook of r's route, less its secret, or answers
+// 404 or 500.
+func findWebhook(w http.ResponseWriter, r *http.Request) (*Webhook, bool) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "webhook_not_found")
+		return nil, false
+	}
+	var webhook Webhook
+	if err := readDBFor(r).Omit("secret").First(&webhook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeError(w, r, http.StatusNotFound, "webhook_not_found")
+			return nil, false
+		}
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return nil, false
+	}
+	return &webhook, true
+}
+
+// Delete a webhook along with its delivery log. Deliveries in flight may
+// still be sent.
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := routeID(r)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "webhook_not_found")
+		return
+	}
+	res := dbFor(r).Delete(&Webhook{}, id)
+	if res.Error != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if res.RowsAffected == 0 {
+		writeError(w, r, http.StatusNotFound, "webhook_not_found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deliverySchema describes how a webhook's delivery log can be listed.
+var deliverySchema = query.Schema{
+	Fields: map[string]query.Field{
+		"id":     {Column: "id", Kind: query.Uint, Sortable: true},
+		"event":  {Column: "event", Kind: query.String, Ops: []query.Op{query.Eq}},
+		"status": {Column: "status", Kind: query.String, Ops: []query.Op{query.Eq}},
+	},
+	Key:          "id",
+	DefaultLimit: defaultPageLimit,
+	MaxLimit:     maxPageLimit,
+}
+
+// deliveryList is the envelope of a webhook's delivery log, paged like
+// productList.
+type deliveryList struct {
+	Data       []model.WebhookDelivery `json:"data"`
+	Meta       query.Meta              `json:"meta"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// Get the delivery log of a webhook
+func getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	params, err := deliverySchema.Parse(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	webhook, ok := findWebhook(w, r)
+	if !ok {
+		return
+	}
+	conn := readDBFor(r).Session(&gorm.Session{})
+	var total int64
+	err = params.Filters.Apply(conn.Model(&model.WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)).Count(&total).Error
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	var deliveries []model.WebhookDelivery
+	if err := params.Apply(conn.Where("webhook_id = ?", webhook.ID)).Find(&deliveries).Error; err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	deliveries, next, err := query.Next(params, deliveries)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "internal_error")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	writeJSON(w, r, http.StatusOK, deliveryList{Data: deliveries, Meta: params.Meta(total), NextCursor: next})
+}
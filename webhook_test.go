@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookURLMustBePublic(t *testing.T) {
+	c, _ := newTestAPI(t)
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://0.0.0.0/hook",
+		"http://100.64.0.1/hook",
+	} {
+		r := c.Post("/api/v1/webhooks", map[string]any{"url": url, "events": []string{"product.created"}})
+		expectProblem(t, r, 422, "webhook_url_not_public")
+	}
+	c.Post("/api/v1/webhooks", map[string]any{"url": "https://203.0.113.10/hook", "events": []string{"product.created"}}).Expect(201)
+}
+
+// The client refuses to connect to a private address, whatever the URL
+// says, and doesn't follow redirects.
+func TestWebhookClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("webhook client reached a loopback server")
+	}))
+	defer server.Close()
+
+	client := newWebhookClient()
+	_, err := client.Post(server.URL, "application/json", nil)
+	if !errors.Is(err, errWebhookAddress) {
+		t.Errorf("posting to %s: %v, want %v", server.URL, err, errWebhookAddress)
+	}
+	if err := client.CheckRedirect(nil, nil); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("redirects are followed: %v", err)
+	}
+}
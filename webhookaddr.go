@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+)
+
+// errWebhookAddress is the dial error for a webhook host that resolves to
+// an address webhooks may not reach.
+var errWebhookAddress = errors.New("webhook address is not public")
+
+// nonPublicPrefixes are the ranges outside netip's own checks that still
+// aren't the public internet: "this network", carrier-grade NAT, and the
+// IPv4 broadcast address.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("255.255.255.255/32"),
+}
+
+// publicAddr reports whether webhooks may be sent to ip. Loopback,
+// private, link-local (such as the 169.254.169.254 metadata service) and
+// multicast addresses are refused, so a tenant can't point a webhook at
+// the server's own network and read the answer in its delivery log.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookHost resolves host and fails unless every address it has is
+// public.
+func checkWebhookHost(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(ip) {
+			return errWebhookAddress
+		}
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !publicAddr(ip) {
+			return errWebhookAddress
+		}
+	}
+	return nil
+}
+
+// webhookDialControl refuses connections to non-public addresses. It runs
+// after DNS resolution, on every connection, so a host that is re-pointed
+// after it is registered is still caught.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook address %q: %w", address, err)
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errWebhookAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newWebhookClient returns the client webhooks are sent with. It only
+// connects to public addresses, ignores proxy settings, which would
+// connect for it, and doesn't follow redirects, which could lead anywhere.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mjpvl-ai/golangdb/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	webhookPollInterval = time.Second
+	webhookBatchSize    = 20
+	// webhookEnqueueBatch bounds the deliveries queued per statement.
+	webhookEnqueueBatch = 500
+	webhookTimeout      = 10 * time.Second
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 10 * time.Second
+	webhookMaxBackoff   = time.Hour
+	// webhookMaxResponse is how much of a response body the delivery log
+	// keeps.
+	webhookMaxResponse = 1024
+)
+
+// webhookOutbox is a GORM plugin that queues a delivery to every webhook
+// subscribed to each audit event as it is recorded. It runs in the
+// recording statement's transaction, so an event is delivered exactly when
+// its change commits.
+type webhookOutbox struct{}
+
+func (webhookOutbox) Name() string { return "webhooks" }
+
+func (webhookOutbox) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:create").Register("webhooks:enqueue", enqueueWebhookDeliveries)
+}
+
+// webhookPayload is the body of a delivery.
+type webhookPayload struct {
+	Event string            `json:"event"`
+	Data  *model.AuditEvent `json:"data"`
+}
+
+func enqueueWebhookDeliveries(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Table != "audit_events" {
+		return
+	}
+	var events []*model.AuditEvent
+	switch v := db.Statement.ReflectValue; v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if event, ok := reflect.Indirect(v.Index(i)).Addr().Interface().(*model.AuditEvent); ok {
+				events = append(events, event)
+			}
+		}
+	case reflect.Struct:
+		if event, ok := v.Addr().Interface().(*model.AuditEvent); ok {
+			events = append(events, event)
+		}
+	}
+
+	tx := db.Session(&gorm.Session{NewDB: true})
+	var webhooks []model.Webhook
+	if err := tx.Omit("secret").Find(&webhooks).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	now := time.Now()
+	var deliveries []model.WebhookDelivery
+	for _, event := range events {
+		name := model.WebhookEvents[event.Action]
+		var payload json.RawMessage
+		for _, webhook := range webhooks {
+			if !slices.Contains(webhook.Events, name) {
+				continue
+			}
+			if payload == nil {
+				var err error
+				if payload, err = json.Marshal(webhookPayload{Event: name, Data: event}); err != nil {
+					db.AddError(err)
+					return
+				}
+			}
+			deliveries = append(deliveries, model.WebhookDelivery{
+				WebhookID:     webhook.ID,
+				Event:         name,
+				Payload:       payload,
+				Status:        model.DeliveryPending,
+				NextAttemptAt: &now,
+			})
+		}
+	}
+	if len(deliveries) > 0 {
+		db.AddError(tx.CreateInBatches(deliveries, webhookEnqueueBatch).Error)
+	}
+}
+
+// webhookDispatcher sends queued webhook deliveries in the background,
+// retrying failures with exponential backoff. Several instances can share
+// the queue: each claims the deliveries it sends.
+type webhookDispatcher struct {
+	db     *gorm.DB
+	client *http.Client
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWebhookDispatcher(db *gorm.DB) *webhookDispatcher {
+	return &webhookDispatcher{db: db, client: newWebhookClient()}
+}
+
+// start starts polling for due deliveries.
+func (d *webhookDispatcher) start(ctx context.Context) error {
+	workerCtx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-workerCtx.Done():
+				return
+			case <-ticker.C:
+				if err := d.deliverDue(workerCtx); err != nil && workerCtx.Err() == nil {
+					slog.Error("failed to deliver webhooks", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stop cancels the deliveries in flight, which are retried later, and
+// waits for the worker to exit.
+func (d *webhookDispatcher) stop(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+	d.cancel()
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliverDue sends the deliveries that are due, until none are left.
+func (d *webhookDispatcher) deliverDue(ctx context.Context) error {
+	for {
+		deliveries, err := d.claim(ctx)
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, &deliveries[i])
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// claim picks the next due deliveries and pushes their next attempt past
+// the time sending them can take, so no other instance picks them too. If
+// this one dies meanwhile they are retried then.
+func (d *webhookDispatcher) claim(ctx context.Context) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_attempt_at <= ?", now).Order("next_attempt_at").Limit(webhookBatchSize).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(2*webhookTimeout)).Error
+	})
+	return deliveries, err
+}
+
+// deliver makes one attempt at sending delivery and records how it went.
+func (d *webhookDispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	var webhook model.Webhook
+	if err := d.db.WithContext(ctx).First(&webhook, delivery.WebhookID).Error; err != nil {
+		// Deleted since, which deletes the delivery too
+		return
+	}
+	status, body, err := d.send(ctx, &webhook, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the claim runs out and it is retried
+		return
+	}
+	update := map[string]any{
+		"attempts":        delivery.Attempts + 1,
+		"response_status": status,
+		"response_body":   body,
+		"error":           "",
+		"next_attempt_at": nil,
+	}
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		update["status"] = model.DeliverySucceeded
+	case delivery.Attempts+1 >= webhookMaxAttempts:
+		update["status"] = model.DeliveryFailed
+	default:
+		update["next_attempt_at"] = time.Now().Add(webhookBackoff(delivery.Attempts + 1))
+	}
+	if err != nil {
+		update["error"] = err.Error()
+	} else if update["status"] != model.DeliverySucceeded {
+		update["error"] = fmt.Sprintf("unexpected status %d", status)
+	}
+	if err := d.db.Model(delivery).Updates(update).Error; err != nil {
+		slog.Error("failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts delivery to webhook and returns the response status and the
+// start of its body.
+func (d *webhookDispatcher) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "golangdb-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Signature", webhookSignature(webhook.Secret, time.Now(), delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponse))
+	return resp.StatusCode, string(bytes.ToValidUTF8(body, nil)), nil
+}
+
+// webhookSignature returns the X-Webhook-Signature of payload sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<payload>">".
+// Receivers recompute it with the webhook's secret, and can reject old
+// timestamps to stop replays.
+func webhookSignature(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, ts+".")
+	mac.Write(payload)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns how long to wait after the attempts'th failed
+// attempt: webhookBaseBackoff, doubling each time, up to webhookMaxBackoff.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}